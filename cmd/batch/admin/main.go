@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/horsewin/echo-playground-batch-task/internal/admin"
+	"github.com/horsewin/echo-playground-batch-task/internal/common/config"
+	"github.com/horsewin/echo-playground-batch-task/internal/common/database"
+	"github.com/horsewin/echo-playground-batch-task/internal/common/job"
+	"github.com/horsewin/echo-playground-batch-task/internal/common/redact"
+	"github.com/horsewin/echo-playground-batch-task/internal/migration"
+	"github.com/horsewin/echo-playground-batch-task/internal/repository"
+)
+
+// exitUsage はコマンドの指定が誤っている場合の終了コードです
+const exitUsage = 2
+
+func main() {
+	os.Exit(run())
+}
+
+// run は管理コマンドを実行し、終了コードを返します
+// deferによる終了処理を確実に実行するため、os.Exitはmainでのみ呼び出します
+func run() int {
+	args := os.Args[1:]
+	if len(args) < 2 {
+		admin.Usage(os.Stderr)
+		return exitUsage
+	}
+
+	// 管理コマンドはStep Functionsから起動されないため、タスクトークンを受け取らない
+	cfg, err := config.LoadConfig("")
+	if err != nil {
+		log.Printf("Failed to load config: %v", err)
+		return job.ExitFailure
+	}
+	redact.Configure(cfg.Redact.AllowFields)
+
+	db, err := database.NewDB(cfg.DB)
+	if err != nil {
+		log.Printf("Failed to create database connection: %v", err)
+		return job.ExitFailure
+	}
+	defer db.Close()
+
+	migrator, err := migration.New(db.DB)
+	if err != nil {
+		log.Printf("Failed to load migrations: %v", err)
+		return job.ExitFailure
+	}
+
+	// database.DBをrepository.DBに変換
+	repoDb := &repository.DB{DB: db.DB}
+	env := &admin.Env{
+		Out:                os.Stdout,
+		Notifications:      repository.NewNotificationRepository(repoDb),
+		Reservations:       repository.NewReservationRepository(repoDb),
+		Pets:               repository.NewPetRepository(repoDb),
+		ReservationReports: repository.NewReservationReportRepository(repoDb),
+		Migrator:           migrator,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if err := admin.Run(ctx, env, args); err != nil {
+		log.Printf("Admin command failed: %s", redact.String(err.Error()))
+		if errors.Is(err, admin.ErrUsage) {
+			admin.Usage(os.Stderr)
+			return exitUsage
+		}
+		return job.ExitFailure
+	}
+	return job.ExitOK
+}
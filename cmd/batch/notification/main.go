@@ -7,14 +7,13 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"os/signal"
-	"runtime/debug"
-	"syscall"
 	"time"
 
-	"github.com/aws/aws-xray-sdk-go/xray"
+	"github.com/horsewin/echo-playground-batch-task/internal/common/apperrors"
 	"github.com/horsewin/echo-playground-batch-task/internal/common/config"
-	"github.com/horsewin/echo-playground-batch-task/internal/common/utils"
+	"github.com/horsewin/echo-playground-batch-task/internal/common/job"
+	"github.com/horsewin/echo-playground-batch-task/internal/common/queue"
+	"github.com/horsewin/echo-playground-batch-task/internal/common/redact"
 	"github.com/horsewin/echo-playground-batch-task/internal/model"
 	"github.com/horsewin/echo-playground-batch-task/internal/service/batch"
 )
@@ -24,129 +23,98 @@ const (
 )
 
 func main() {
-	// コマンドライン引数のパース
-	flag.Parse()
-	timeout := flag.Duration("timeout", 5*time.Minute, "バッチ処理のタイムアウト時間")
-
-	// 最後の引数として渡されたタスクトークンを取得
-	// ENV=LOCALの場合はタスクトークンを取得しない
-	taskToken := "DUMMY_TASK_TOKEN"
-	if os.Getenv("ENV") != "LOCAL" {
-		taskToken = flag.Arg(len(flag.Args()) - 1)
-		if taskToken == "" {
-			log.Fatalf("Task token is required")
-		}
-	}
-
-	// 設定の読み込み
-	cfg, err := config.LoadConfig(taskToken)
-	if err != nil {
-		log.Fatalf("Failed to load config: %v\nStack trace:\n%s", err, debug.Stack())
-	}
+	os.Exit(run())
+}
 
-	// X-Ray設定
-	if cfg.EnableTracing {
-		if err := xray.Configure(xray.Config{
-			DaemonAddr:     "127.0.0.1:2000", // X-Rayデーモンのアドレス
-			ServiceVersion: "1.0.0",
-		}); err != nil {
-			log.Printf("Failed to configure X-Ray: %v", err)
-			// X-Ray設定失敗時はデフォルトの設定を使用
-			if configErr := xray.Configure(xray.Config{}); configErr != nil {
-				log.Fatalf("Failed to configure default X-Ray settings: %v", configErr)
-			}
-		}
-		os.Setenv("AWS_XRAY_CONTEXT_MISSING", "LOG_ERROR")
-	}
+// run はバッチ処理を実行し、終了コードを返します
+// deferによる終了処理を確実に実行するため、os.Exitはmainでのみ呼び出します
+func run() int {
+	// コマンドライン引数・設定・X-Rayの初期化
+	// コンシューマーとして実行する場合はタスクトークンを受け取らない
+	consume := flag.Bool("consume", false, "キューから通知を受信し続けるコンシューマーとして実行する")
+	boot := job.Init(projectName, job.WithOptionalTaskToken(func() bool { return *consume }))
 
 	// 通知バッチサービスを作成
-	service, err := batch.NewNotificationBatchService(cfg)
+	service, err := batch.NewNotificationBatchService(boot.Config)
 	if err != nil {
-		log.Fatalf("Failed to create notification batch service: %v", err)
+		log.Printf("Failed to create notification batch service: %v", err)
+		return job.ExitFailure
 	}
 	defer service.Close()
 
-	// コンテキストを作成
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
 	// X-Rayセグメントの作成
-	if cfg.EnableTracing {
-		var seg *xray.Segment
-		ctx, seg = xray.BeginSegment(ctx, projectName)
-		defer seg.Close(nil)
-
-		// セグメントにメタデータを追加
-		if err := seg.AddMetadata("task_token", taskToken); err != nil {
-			log.Printf("Failed to add task_token metadata: %v", err)
-		}
-		if err := seg.AddMetadata("timeout", timeout.String()); err != nil {
-			log.Printf("Failed to add timeout metadata: %v", err)
+	ctx, end := boot.Start(context.Background())
+	defer end()
+
+	// コンシューマーとして実行
+	// --timeoutを指定しない場合は、停止要求を受けるまで受信を続ける
+	if *consume {
+		if !job.FlagPassed("timeout") {
+			boot.Timeout = 0
 		}
+		result := boot.Execute(ctx, func(ctx context.Context) error {
+			return consumeNotifications(ctx, boot.Config, service)
+		})
+		return boot.Finish(result)
 	}
 
-	// シグナルハンドリング
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-
 	// バッチ処理の実行
-	errChan := make(chan error, 1)
-	go func() {
+	result := boot.Execute(ctx, func(ctx context.Context) error {
 		// タスクトークンから通知データを生成
-		notifications, err := generateNotificationsFromTaskToken(taskToken)
+		notifications, err := generateNotificationsFromTaskToken(boot.TaskToken)
 		if err != nil {
-			log.Printf("Failed to generate notifications: %v", err)
-			cancel()
-			return
+			log.Printf("Failed to generate notifications: %s", redact.String(err.Error()))
+			return err
 		}
 
 		service.SetArgs(notifications)
 
-		errChan <- utils.RunWithTimeout(ctx, *timeout, service.Run)
-	}()
+		return service.Run(ctx)
+	})
+	return boot.Finish(result)
+}
 
-	// シグナルを待機
-	select {
-	case sig := <-sigChan:
-		log.Printf("Received signal: %v", sig)
-		cancel()
-	case err := <-errChan:
-		if err != nil {
-			log.Printf("Batch process failed: %v\nStack trace:\n%s", err, debug.Stack())
-			os.Exit(1)
-		}
-		log.Println("Batch process completed successfully")
+// consumeNotifications はキューから通知を受信し、通知レコードを作成します
+func consumeNotifications(ctx context.Context, cfg *config.Config, service *batch.NotificationBatchService) error {
+	if cfg.Notification.QueueURL == "" {
+		return apperrors.InvalidInput("consumeNotifications", fmt.Errorf("NOTIFICATION_QUEUE_URL is required in consumer mode"))
 	}
+
+	q, err := queue.NewSQSQueue(ctx, cfg.Notification.QueueURL)
+	if err != nil {
+		return apperrors.ExternalService("consumeNotifications", err)
+	}
+
+	_, err = service.Consume(ctx, q, batch.ConsumeOptions{
+		BatchSize: cfg.Notification.QueueBatchSize,
+		WaitTime:  cfg.Notification.QueueWaitTime,
+	})
+	return err
 }
 
 // generateNotificationsFromTaskToken はタスクトークンから通知データを生成します
+// dataは予約イベント (ステータス・キャンセルの理由・繰り上げを含む) としてそのまま通知に引き継ぎます
 func generateNotificationsFromTaskToken(taskToken string) ([]model.Notification, error) {
 	// タスクトークンから通知データを取得する処理を実装
 	// この例では、タスクトークンをJSONとして解析し、通知データを生成します
 	var input struct {
 		Notifications []struct {
-			Type      string    `json:"type"`
-			CreatedAt time.Time `json:"created_at"`
-			Data      struct {
-				UserID   string    `json:"user_id"`
-				DateTime time.Time `json:"date_time"`
-				PetID    string    `json:"pet_id"`
-			} `json:"data"`
+			Type      string                 `json:"type"`
+			CreatedAt time.Time              `json:"created_at"`
+			Data      model.ReservationEvent `json:"data"`
 		} `json:"notifications"`
 	}
 
 	if err := json.Unmarshal([]byte(taskToken), &input); err != nil {
-		return nil, fmt.Errorf("failed to parse task token: %w", err)
+		return nil, apperrors.InvalidInput("generateNotificationsFromTaskToken", fmt.Errorf("failed to parse task token: %w", err))
 	}
 
 	notifications := make([]model.Notification, len(input.Notifications))
 	for i, notification := range input.Notifications {
-		notifications[i] = model.NewReservationNotification(model.ReservationEvent{
-			UserID:    notification.Data.UserID,
-			DateTime:  notification.Data.DateTime,
-			PetID:     notification.Data.PetID,
-			CreatedAt: notification.CreatedAt,
-		})
+		// 作成日時はdataではなく通知に含まれる
+		event := notification.Data
+		event.CreatedAt = notification.CreatedAt
+		notifications[i] = model.NewReservationNotification(event)
 	}
 
 	return notifications, nil
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/horsewin/echo-playground-batch-task/internal/model"
+)
+
+// newTaskToken は予約バッチのタスク出力と同じ形式のタスクトークンを作成します
+func newTaskToken(t *testing.T, events ...model.ReservationEvent) string {
+	t.Helper()
+	notifications := make([]model.Notification, len(events))
+	for i, event := range events {
+		notifications[i] = model.NewReservationNotification(event)
+	}
+	token, err := json.Marshal(map[string]any{"run_id": "run-1", "notifications": notifications})
+	if err != nil {
+		t.Fatalf("failed to marshal task token: %v", err)
+	}
+	return string(token)
+}
+
+func TestGenerateNotificationsFromTaskToken(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	tests := []struct {
+		name      string
+		event     model.ReservationEvent
+		wantTitle string
+		// wantMessage は通知の本文に含まれる文字列です
+		wantMessage string
+	}{
+		{
+			name:      "確定した予約",
+			event:     model.ReservationEvent{UserID: "user1", PetID: "pet1", DateTime: now, CreatedAt: now},
+			wantTitle: "予約が完了しました",
+		},
+		{
+			name:      "キャンセル待ちから繰り上げて確定した予約",
+			event:     model.ReservationEvent{UserID: "user1", PetID: "pet1", DateTime: now, CreatedAt: now, Promoted: true},
+			wantTitle: "キャンセル待ちの予約が確定しました",
+		},
+		{
+			name: "理由を指定してキャンセルした予約",
+			event: model.ReservationEvent{UserID: "user1", PetID: "pet1", DateTime: now, CreatedAt: now,
+				Status: model.ReservationStatusCancelled, Reason: model.StatusReasonPetAdopted},
+			wantTitle:   "予約がキャンセルされました",
+			wantMessage: "譲渡先が決まったため",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			notifications, err := generateNotificationsFromTaskToken(newTaskToken(t, tt.event))
+			if err != nil {
+				t.Fatalf("generateNotificationsFromTaskToken() error = %v", err)
+			}
+			if len(notifications) != 1 {
+				t.Fatalf("notifications = %d, want 1", len(notifications))
+			}
+			if !notifications[0].CreatedAt.Equal(now) {
+				t.Errorf("created_at = %v, want %v", notifications[0].CreatedAt, now)
+			}
+
+			record, err := notifications[0].ToNotificationRecord(map[string]string{"pet1": "ポチ"}, model.DisplayOptions{})
+			if err != nil {
+				t.Fatalf("ToNotificationRecord() error = %v", err)
+			}
+			if record.Title != tt.wantTitle || !strings.Contains(record.Message, tt.wantMessage) {
+				t.Errorf("record = %q %q, want %q containing %q", record.Title, record.Message, tt.wantTitle, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestGenerateNotificationsFromTaskToken_InvalidInput(t *testing.T) {
+	if _, err := generateNotificationsFromTaskToken("not json"); err == nil {
+		t.Error("generateNotificationsFromTaskToken() error = nil, want error")
+	}
+}
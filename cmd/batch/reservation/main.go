@@ -5,18 +5,9 @@ import (
 	"flag"
 	"log"
 	"os"
-	"os/signal"
-	"syscall"
-	"time"
-
 	"runtime/debug"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
-	awsconfig "github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/service/sfn"
-	"github.com/aws/aws-xray-sdk-go/xray"
-	"github.com/horsewin/echo-playground-batch-task/internal/common/config"
-	"github.com/horsewin/echo-playground-batch-task/internal/common/utils"
+	"github.com/horsewin/echo-playground-batch-task/internal/common/job"
 	"github.com/horsewin/echo-playground-batch-task/internal/service/batch"
 )
 
@@ -26,111 +17,47 @@ const (
 )
 
 func main() {
-	// コマンドライン引数のパース
-	flag.Parse()
-	timeout := flag.Duration("timeout", 5*time.Minute, "バッチ処理のタイムアウト時間")
+	os.Exit(run())
+}
 
-	// 最後の引数として渡されたタスクトークンを取得
-	// ENV=LOCALの場合はタスクトークンを取得しない
-	taskToken := "DUMMY_TASK_TOKEN"
-	if os.Getenv("ENV") != "LOCAL" {
-		taskToken = flag.Arg(len(flag.Args()) - 1)
-		if taskToken == "" {
-			log.Fatalf("Task token is required")
-		}
-	}
+// run はバッチ処理を実行し、終了コードを返します
+// deferによる終了処理を確実に実行するため、os.Exitはmainでのみ呼び出します
+func run() int {
+	// 処理対象の予約を絞り込む引数はInitでパースするため、先に定義する
+	parseFilter := batch.ReservationFilterFlags(flag.CommandLine)
 
-	// 設定の読み込み
-	cfg, err := config.LoadConfig(taskToken)
-	if err != nil {
-		log.Fatalf("Failed to load config: %v\nStack trace:\n%s", err, debug.Stack())
-	}
-
-	// X-Ray設定
-	if cfg.EnableTracing {
-		if err := xray.Configure(xray.Config{
-			DaemonAddr:     "127.0.0.1:2000", // X-Rayデーモンのアドレス
-			ServiceVersion: "1.0.0",
-		}); err != nil {
-			log.Printf("Failed to configure X-Ray: %v", err)
-			// X-Ray設定失敗時はデフォルトの設定を使用
-			if configErr := xray.Configure(xray.Config{}); configErr != nil {
-				log.Fatalf("Failed to configure default X-Ray settings: %v", configErr)
-			}
-		}
-		os.Setenv("AWS_XRAY_CONTEXT_MISSING", "LOG_ERROR")
-	}
+	// コマンドライン引数・設定・X-Rayの初期化
+	boot := job.Init(projectName)
 
 	// Step Functionsクライアントの初期化
-	var sfnClient *sfn.Client
-	if os.Getenv("ENV") != "LOCAL" {
-		awsCfg, err := awsconfig.LoadDefaultConfig(context.Background())
-		if err != nil {
-			log.Fatalf("Failed to load AWS config: %v\nStack trace:\n%s", err, debug.Stack())
-		}
-		sfnClient = sfn.NewFromConfig(awsCfg)
+	sfnClient, err := job.NewSFNClient(context.Background())
+	if err != nil {
+		log.Printf("Failed to load AWS config: %v\nStack trace:\n%s", err, debug.Stack())
+		return job.ExitFailure
 	}
+	boot.SFN = sfnClient
 
 	// サービスの初期化
-	service, err := batch.NewReservationBatchService(cfg, sfnClient)
+	service, err := batch.NewReservationBatchService(boot.Config, sfnClient)
 	if err != nil {
-		log.Fatalf("Failed to create service: %v\nStack trace:\n%s", err, debug.Stack())
+		log.Printf("Failed to create service: %v\nStack trace:\n%s", err, debug.Stack())
+		return job.ExitFailure
 	}
 	defer service.Close()
 
-	// コンテキストの作成
-	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
-	defer cancel()
-
-	// X-Rayセグメントの作成
-	if cfg.EnableTracing {
-		var seg *xray.Segment
-		ctx, seg = xray.BeginSegment(ctx, projectName)
-		defer seg.Close(nil)
-
-		// セグメントにメタデータを追加
-		if err := seg.AddMetadata("task_token", taskToken); err != nil {
-			log.Printf("Failed to add task_token metadata: %v", err)
-		}
-		if err := seg.AddMetadata("timeout", timeout.String()); err != nil {
-			log.Printf("Failed to add timeout metadata: %v", err)
-		}
+	// 処理対象の予約の条件が不正な場合は、Step Functionsに入力の誤りとして失敗を通知する
+	filter, err := parseFilter()
+	if err != nil {
+		return boot.Finish(job.Result{Err: err})
 	}
+	service.SetFilter(filter)
 
-	// シグナルハンドリングの設定
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	// X-Rayセグメントの作成
+	ctx, end := boot.Start(context.Background())
+	defer end()
 
 	// バッチ処理の実行
-	errChan := make(chan error, 1)
-	go func() {
-		errChan <- utils.RunWithTimeout(ctx, *timeout, service.Run)
-	}()
-
-	// シグナルまたはエラーの待機
-	select {
-	case sig := <-sigChan:
-		log.Printf("Received signal: %v", sig)
-		cancel()
-	case err := <-errChan:
-		if err != nil {
-			log.Printf("Batch process failed: %v\nStack trace:\n%s", err, debug.Stack())
-
-			// ローカル環境以外の場合のみStep Functionsのエラー通知を行う
-			if os.Getenv("ENV") != "LOCAL" && sfnClient != nil {
-				input := &sfn.SendTaskFailureInput{
-					TaskToken: aws.String(taskToken),
-					Error:     aws.String("Batch process failed"),
-				}
-
-				_, err := sfnClient.SendTaskFailure(ctx, input)
-				if err != nil {
-					log.Printf("Failed to send task failure: %v\nStack trace:\n%s", err, debug.Stack())
-				}
-			}
-
-			os.Exit(1)
-		}
-		log.Println("Batch process completed successfully")
-	}
+	// SIGTERMやタイムアウト時は新しい予約の処理を止め、実行中のトランザクションの完了を待ってから終了する
+	result := boot.Execute(ctx, service.Run)
+	return boot.Finish(result)
 }
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+
+	"github.com/horsewin/echo-playground-batch-task/internal/common/job"
+	"github.com/horsewin/echo-playground-batch-task/internal/service/batch"
+)
+
+const (
+	projectName = "echo-playground-batch-task"
+)
+
+func main() {
+	os.Exit(run())
+}
+
+// run はバッチ処理を実行し、終了コードを返します
+// deferによる終了処理を確実に実行するため、os.Exitはmainでのみ呼び出します
+func run() int {
+	// コマンドライン引数・設定・X-Rayの初期化
+	// 保持期間バッチはスケジュール実行されるため、タスクトークンを受け取らない
+	boot := job.Init(projectName, job.WithOptionalTaskToken(func() bool { return true }))
+
+	// サービスの初期化
+	service, err := batch.NewRetentionBatchService(boot.Config)
+	if err != nil {
+		log.Printf("Failed to create retention batch service: %v", err)
+		return job.ExitFailure
+	}
+	defer service.Close()
+
+	// X-Rayセグメントの作成
+	ctx, end := boot.Start(context.Background())
+	defer end()
+
+	// バッチ処理の実行
+	result := boot.Execute(ctx, service.Run)
+	return boot.Finish(result)
+}
@@ -0,0 +1,147 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/horsewin/echo-playground-batch-task/internal/repository"
+)
+
+// ErrUsage はコマンドの指定が誤っていることを表します
+var ErrUsage = errors.New("invalid usage")
+
+// Env は管理コマンドの実行環境です
+type Env struct {
+	// Out はコマンドの結果の出力先です
+	Out           io.Writer
+	Notifications repository.NotificationRepository
+	Reservations  ReservationCreator
+	Pets          repository.PetRepository
+	// ReservationReports は予約のエクスポートと集計に利用します
+	ReservationReports repository.ReservationReportRepository
+	Migrator           Migrator
+	// Now は現在時刻を返します。nilの場合はtime.Nowを使います
+	Now func() time.Time
+}
+
+// now は現在時刻を返します
+func (e *Env) now() time.Time {
+	if e.Now != nil {
+		return e.Now()
+	}
+	return time.Now()
+}
+
+// command はサブコマンドを表します
+type command struct {
+	name  string
+	usage string
+	run   func(ctx context.Context, env *Env, args []string) error
+}
+
+// groups はコマンドのグループごとのサブコマンドです
+var groups = map[string][]command{
+	"notifications": notificationCommands,
+	"migrate":       migrateCommands,
+	"import":        importCommands,
+	"export":        exportCommands,
+	"report":        reportCommands,
+}
+
+// Run は引数で指定された管理コマンドを実行します
+// 引数は「グループ サブコマンド フラグ...」の形式です (例: notifications list -user user1)
+func Run(ctx context.Context, env *Env, args []string) error {
+	if len(args) < 2 {
+		return usageError("command is required")
+	}
+	commands, ok := groups[args[0]]
+	if !ok {
+		return usageError("unknown command: %s", args[0])
+	}
+	i := slices.IndexFunc(commands, func(c command) bool { return c.name == args[1] })
+	if i < 0 {
+		return usageError("unknown %s command: %s", args[0], args[1])
+	}
+	return commands[i].run(ctx, env, args[2:])
+}
+
+// Usage はコマンドの使い方を出力します
+func Usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: admin-batch <command> <subcommand> [flags]")
+	fmt.Fprintln(w)
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		for _, c := range groups[name] {
+			fmt.Fprintf(w, "  %s %s %s\n", name, c.name, c.usage)
+		}
+	}
+}
+
+func usageError(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", ErrUsage, fmt.Sprintf(format, args...))
+}
+
+// newFlagSet はエラー時に終了しないフラグセットを作成します
+func newFlagSet(name string, out io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(out)
+	return fs
+}
+
+// parseFlags はフラグをパースし、パースできない場合はErrUsageを返します
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("%w: %v", ErrUsage, err)
+	}
+	if fs.NArg() > 0 {
+		return usageError("unexpected arguments: %v", fs.Args())
+	}
+	return nil
+}
+
+// parseIDs はカンマ区切りのIDをパースします
+func parseIDs(s string) ([]int, error) {
+	var ids []int
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v == "" {
+			continue
+		}
+		id, err := strconv.Atoi(v)
+		if err != nil || id <= 0 {
+			return nil, usageError("invalid id: %q", v)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// parseTime は日付 (2006-01-02) またはRFC3339形式の日時をパースします
+// 日付の場合はUTCの0時として扱います。空の場合はnilを返します
+func parseTime(s string) (*time.Time, error) {
+	if s == "" {
+		return nil, nil
+	}
+	for _, layout := range []string{time.DateOnly, time.RFC3339} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return &t, nil
+		}
+	}
+	return nil, usageError("invalid date: %q (expected 2006-01-02 or RFC3339)", s)
+}
+
+// newTableWriter は表形式で出力するためのwriterを作成します
+func newTableWriter(w io.Writer) *tabwriter.Writer {
+	return tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+}
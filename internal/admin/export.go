@@ -0,0 +1,293 @@
+package admin
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/horsewin/echo-playground-batch-task/internal/model"
+)
+
+// exportCommands は予約を出力するサブコマンドです
+var exportCommands = []command{
+	{name: "reservations", usage: "[-status S1,S2] [-pet ID1,ID2] [-from DATE] [-to DATE] [-format csv|jsonl|columnar] [-row-group-size N] [-out PATH]", run: exportReservations},
+}
+
+// reportCommands は集計結果を出力するサブコマンドです
+var reportCommands = []command{
+	{name: "reservations", usage: "-from DATE -to DATE [-tz ZONE] [-format table|json]", run: reportReservations},
+}
+
+// exportColumns はエクスポートする予約の列です
+// 先頭の列はimport reservationsの列を含むため、エクスポートしたCSVをそのまま取り込めます
+var exportColumns = []string{"id", "user_id", "user_name", "email", "reservation_date_time", "pet_id", "status", "created_at", "updated_at"}
+
+// reservationWriter は予約を1件ずつ書き込みます
+type reservationWriter interface {
+	write(r model.Reservation) error
+	// flush は書き込んでいない予約を書き込みます
+	flush() error
+}
+
+// exportReservations は条件に一致する予約を予約日時の順に出力します
+// 予約はデータベースから読みながら書き込むため、件数が多くてもメモリに読み込みません
+func exportReservations(ctx context.Context, env *Env, args []string) (err error) {
+	fs := newFlagSet("export reservations", env.Out)
+	statuses := fs.String("status", "", "出力する予約のステータス (カンマ区切り。省略した場合はすべて)")
+	pets := fs.String("pet", "", "出力する予約のペットID (カンマ区切り)")
+	from := fs.String("from", "", "予約日時の下限 (この日時を含む。2006-01-02 またはRFC3339)")
+	to := fs.String("to", "", "予約日時の上限 (この日時を含まない。2006-01-02 またはRFC3339)")
+	format := fs.String("format", "csv", "出力形式 (csv、jsonl または columnar)")
+	rowGroupSize := fs.Int("row-group-size", 1000, "columnar形式で1行にまとめる予約の件数")
+	out := fs.String("out", "", "出力先のファイル (省略した場合は標準出力)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *format != "csv" && *format != "jsonl" && *format != "columnar" {
+		return usageError("unknown format: %s", *format)
+	}
+	if *rowGroupSize <= 0 {
+		return usageError("-row-group-size must be positive: %d", *rowGroupSize)
+	}
+
+	var filter model.ReservationFilter
+	for _, s := range splitList(*statuses) {
+		filter.Statuses = append(filter.Statuses, model.ReservationStatus(s))
+	}
+	filter.PetIDs = splitList(*pets)
+	if filter.From, err = parseTime(*from); err != nil {
+		return err
+	}
+	if filter.To, err = parseTime(*to); err != nil {
+		return err
+	}
+	if err := filter.Validate(); err != nil {
+		return usageError("%v", err)
+	}
+
+	w := env.Out
+	if *out != "" {
+		var f *os.File
+		if f, err = os.Create(*out); err != nil {
+			return fmt.Errorf("failed to create %s: %w", *out, err)
+		}
+		defer func() {
+			f.Close()
+			// 途中で失敗したファイルを完全なエクスポートと誤認しないよう削除する
+			if err != nil {
+				os.Remove(*out)
+			}
+		}()
+		w = f
+	}
+
+	bw := bufio.NewWriter(w)
+	var rw reservationWriter
+	switch *format {
+	case "csv":
+		rw, err = newCSVReservationWriter(bw)
+	case "jsonl":
+		rw = &jsonlReservationWriter{enc: json.NewEncoder(bw)}
+	case "columnar":
+		rw = &columnarReservationWriter{enc: json.NewEncoder(bw), size: *rowGroupSize}
+	}
+	if err != nil {
+		return err
+	}
+
+	count := 0
+	err = env.ReservationReports.EachReservation(ctx, filter, func(r model.Reservation) error {
+		count++
+		return rw.write(r)
+	})
+	if err == nil {
+		err = rw.flush()
+	}
+	if err == nil {
+		err = bw.Flush()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to export reservations: %w", err)
+	}
+
+	// 標準出力の場合は出力したデータに件数が混ざらないようにする
+	if *out != "" {
+		fmt.Fprintf(env.Out, "Exported %d reservations to %s\n", count, *out)
+	}
+	return nil
+}
+
+// splitList はカンマ区切りの値を分割します。空の値は無視します
+func splitList(s string) []string {
+	var values []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// csvReservationWriter はヘッダー行付きのCSVで予約を書き込みます
+type csvReservationWriter struct {
+	w *csv.Writer
+}
+
+func newCSVReservationWriter(w io.Writer) (*csvReservationWriter, error) {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(exportColumns); err != nil {
+		return nil, err
+	}
+	return &csvReservationWriter{w: cw}, nil
+}
+
+func (c *csvReservationWriter) write(r model.Reservation) error {
+	return c.w.Write([]string{
+		strconv.FormatInt(r.ID, 10),
+		r.UserID,
+		r.UserName,
+		r.Email,
+		r.ReservationDateTime.Format(time.RFC3339),
+		r.PetID,
+		string(r.Status),
+		r.CreatedAt.Format(time.RFC3339),
+		r.UpdatedAt.Format(time.RFC3339),
+	})
+}
+
+func (c *csvReservationWriter) flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// jsonlReservationWriter は1行に1件のJSONで予約を書き込みます
+type jsonlReservationWriter struct {
+	enc *json.Encoder
+}
+
+func (j *jsonlReservationWriter) write(r model.Reservation) error {
+	return j.enc.Encode(r)
+}
+
+func (j *jsonlReservationWriter) flush() error {
+	return nil
+}
+
+// columnarRowGroup は列ごとにまとめたsize件の予約です
+// 分析用のツールで列単位に読み込めるよう、1行に1つのrow groupをJSONで書き込みます
+type columnarRowGroup struct {
+	Rows    int `json:"rows"`
+	Columns struct {
+		ID                  []int64                   `json:"id"`
+		UserID              []string                  `json:"user_id"`
+		UserName            []string                  `json:"user_name"`
+		Email               []string                  `json:"email"`
+		ReservationDateTime []time.Time               `json:"reservation_date_time"`
+		PetID               []string                  `json:"pet_id"`
+		Status              []model.ReservationStatus `json:"status"`
+		CreatedAt           []time.Time               `json:"created_at"`
+		UpdatedAt           []time.Time               `json:"updated_at"`
+	} `json:"columns"`
+}
+
+// columnarReservationWriter は予約をsize件ごとのrow groupにまとめて書き込みます
+type columnarReservationWriter struct {
+	enc   *json.Encoder
+	size  int
+	group columnarRowGroup
+}
+
+func (c *columnarReservationWriter) write(r model.Reservation) error {
+	g := &c.group
+	g.Rows++
+	g.Columns.ID = append(g.Columns.ID, r.ID)
+	g.Columns.UserID = append(g.Columns.UserID, r.UserID)
+	g.Columns.UserName = append(g.Columns.UserName, r.UserName)
+	g.Columns.Email = append(g.Columns.Email, r.Email)
+	g.Columns.ReservationDateTime = append(g.Columns.ReservationDateTime, r.ReservationDateTime)
+	g.Columns.PetID = append(g.Columns.PetID, r.PetID)
+	g.Columns.Status = append(g.Columns.Status, r.Status)
+	g.Columns.CreatedAt = append(g.Columns.CreatedAt, r.CreatedAt)
+	g.Columns.UpdatedAt = append(g.Columns.UpdatedAt, r.UpdatedAt)
+	if g.Rows >= c.size {
+		return c.flush()
+	}
+	return nil
+}
+
+func (c *columnarReservationWriter) flush() error {
+	if c.group.Rows == 0 {
+		return nil
+	}
+	if err := c.enc.Encode(c.group); err != nil {
+		return err
+	}
+	c.group = columnarRowGroup{}
+	return nil
+}
+
+// reportReservations は予約の受付日ごとに、ステータスごとの件数、重複の割合、保留中の平均時間を出力します
+func reportReservations(ctx context.Context, env *Env, args []string) error {
+	fs := newFlagSet("report reservations", env.Out)
+	from := fs.String("from", "", "集計する受付日の初日 (2006-01-02)")
+	to := fs.String("to", "", "集計する受付日の最終日の翌日 (2006-01-02。この日を含まない)")
+	tz := fs.String("tz", "UTC", "受付日を判定するタイムゾーン (例: Asia/Tokyo)")
+	format := fs.String("format", "table", "出力形式 (table または json)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *from == "" || *to == "" {
+		return usageError("-from and -to are required")
+	}
+	if *format != "table" && *format != "json" {
+		return usageError("unknown format: %s", *format)
+	}
+	loc, err := time.LoadLocation(*tz)
+	if err != nil {
+		return usageError("unknown time zone: %s", *tz)
+	}
+	fromDate, errFrom := time.ParseInLocation(time.DateOnly, *from, loc)
+	toDate, errTo := time.ParseInLocation(time.DateOnly, *to, loc)
+	if err := errors.Join(errFrom, errTo); err != nil {
+		return usageError("invalid date (expected 2006-01-02): %v", err)
+	}
+	if !fromDate.Before(toDate) {
+		return usageError("-from must be before -to")
+	}
+
+	stats, err := env.ReservationReports.GetDailyStats(ctx, fromDate, toDate, loc)
+	if err != nil {
+		return fmt.Errorf("failed to get reservation stats: %w", err)
+	}
+	summaries := model.SummarizeDaily(stats)
+
+	if *format == "json" {
+		enc := json.NewEncoder(env.Out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(summaries)
+	}
+
+	tw := newTableWriter(env.Out)
+	fmt.Fprintln(tw, "DATE\tTOTAL\tPENDING\tCONFIRMED\tCANCELLED\tEXPIRED\tCONFLICT_RATE\tAVG_PENDING")
+	for _, s := range summaries {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%d\t%.1f%%\t%s\n",
+			s.Date,
+			s.Total,
+			s.Counts[model.ReservationStatusPending],
+			s.Counts[model.ReservationStatusConfirmed],
+			s.Counts[model.ReservationStatusCancelled],
+			s.Counts[model.ReservationStatusExpired],
+			s.ConflictRate*100,
+			s.AvgPending(),
+		)
+	}
+	return tw.Flush()
+}
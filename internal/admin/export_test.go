@@ -0,0 +1,202 @@
+package admin
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/horsewin/echo-playground-batch-task/internal/model"
+)
+
+// mockReservationReportRepository はテスト用のモックリポジトリです
+type mockReservationReportRepository struct {
+	reservations []model.Reservation
+	stats        []model.ReservationDailyStat
+	// filters はEachReservationに渡された条件です
+	filters []model.ReservationFilter
+	// failAfter は指定された件数を読み込んだ後にエラーを返します。0の場合は失敗しません
+	failAfter int
+	// statsArgs はGetDailyStatsに渡された期間とタイムゾーンです
+	statsFrom, statsTo time.Time
+	statsLoc           *time.Location
+}
+
+func (m *mockReservationReportRepository) EachReservation(ctx context.Context, filter model.ReservationFilter, fn func(model.Reservation) error) error {
+	m.filters = append(m.filters, filter)
+	for i, r := range m.reservations {
+		if m.failAfter > 0 && i == m.failAfter {
+			return errors.New("connection reset")
+		}
+		if len(filter.Statuses) > 0 && !slices.Contains(filter.Statuses, r.Status) {
+			continue
+		}
+		if err := fn(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *mockReservationReportRepository) GetDailyStats(ctx context.Context, from, to time.Time, loc *time.Location) ([]model.ReservationDailyStat, error) {
+	m.statsFrom, m.statsTo, m.statsLoc = from, to, loc
+	return m.stats, nil
+}
+
+func testExportReservations() []model.Reservation {
+	at := time.Date(2024, 6, 10, 1, 0, 0, 0, time.UTC)
+	return []model.Reservation{
+		{ID: 1, UserID: "user1", UserName: "テスト太郎", Email: "taro@example.com", ReservationDateTime: at, PetID: "pet1", Status: model.ReservationStatusConfirmed, CreatedAt: at, UpdatedAt: at},
+		{ID: 2, UserID: "user2", UserName: "テスト花子", Email: "hanako@example.com", ReservationDateTime: at, PetID: "pet2", Status: model.ReservationStatusPending, CreatedAt: at, UpdatedAt: at},
+		{ID: 3, UserID: "user3", UserName: "テスト次郎", Email: "jiro@example.com", ReservationDateTime: at, PetID: "pet1", Status: model.ReservationStatusCancelled, CreatedAt: at, UpdatedAt: at},
+	}
+}
+
+func TestRun_ExportReservations(t *testing.T) {
+	run := func(repo *mockReservationReportRepository, args ...string) (string, error) {
+		var out bytes.Buffer
+		err := Run(context.Background(), &Env{Out: &out, ReservationReports: repo}, append([]string{"export", "reservations"}, args...))
+		return out.String(), err
+	}
+
+	t.Run("CSVで出力し、条件をリポジトリに渡す", func(t *testing.T) {
+		repo := &mockReservationReportRepository{reservations: testExportReservations()}
+		out, err := run(repo, "-status", "confirmed,cancelled", "-pet", "pet1", "-from", "2024-06-01", "-to", "2024-07-01")
+		if err != nil {
+			t.Fatalf("export reservations error = %v", err)
+		}
+		records, err := csv.NewReader(strings.NewReader(out)).ReadAll()
+		if err != nil {
+			t.Fatalf("failed to parse csv: %v", err)
+		}
+		if len(records) != 3 || !slices.Equal(records[0], exportColumns) || records[1][0] != "1" || records[2][6] != "cancelled" {
+			t.Errorf("csv = %v", records)
+		}
+		filter := repo.filters[0]
+		if len(filter.Statuses) != 2 || !slices.Equal(filter.PetIDs, []string{"pet1"}) || filter.From == nil || filter.To == nil {
+			t.Errorf("filter = %+v", filter)
+		}
+	})
+
+	t.Run("JSON Linesで出力する", func(t *testing.T) {
+		repo := &mockReservationReportRepository{reservations: testExportReservations()}
+		out, err := run(repo, "-format", "jsonl")
+		if err != nil {
+			t.Fatalf("export reservations error = %v", err)
+		}
+		var ids []int64
+		scanner := bufio.NewScanner(strings.NewReader(out))
+		for scanner.Scan() {
+			var r model.Reservation
+			if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+				t.Fatalf("failed to parse line: %v", err)
+			}
+			ids = append(ids, r.ID)
+		}
+		if !slices.Equal(ids, []int64{1, 2, 3}) {
+			t.Errorf("ids = %v, want [1 2 3]", ids)
+		}
+	})
+
+	t.Run("columnar形式でrow groupごとに出力する", func(t *testing.T) {
+		repo := &mockReservationReportRepository{reservations: testExportReservations()}
+		out, err := run(repo, "-format", "columnar", "-row-group-size", "2")
+		if err != nil {
+			t.Fatalf("export reservations error = %v", err)
+		}
+		lines := strings.Split(strings.TrimSpace(out), "\n")
+		if len(lines) != 2 {
+			t.Fatalf("row groups = %d, want 2\n%s", len(lines), out)
+		}
+		var group columnarRowGroup
+		if err := json.Unmarshal([]byte(lines[1]), &group); err != nil {
+			t.Fatalf("failed to parse row group: %v", err)
+		}
+		if group.Rows != 1 || !slices.Equal(group.Columns.ID, []int64{3}) || group.Columns.PetID[0] != "pet1" {
+			t.Errorf("row group = %+v", group)
+		}
+	})
+
+	t.Run("ファイルに出力し、件数を出力する", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "reservations.csv")
+		repo := &mockReservationReportRepository{reservations: testExportReservations()}
+		out, err := run(repo, "-out", path)
+		if err != nil || !strings.Contains(out, "Exported 3 reservations") {
+			t.Fatalf("export reservations = %q, %v", out, err)
+		}
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("export file: %v", err)
+		}
+	})
+
+	t.Run("途中で失敗した場合はファイルを削除する", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "reservations.csv")
+		repo := &mockReservationReportRepository{reservations: testExportReservations(), failAfter: 2}
+		if _, err := run(repo, "-out", path); err == nil {
+			t.Fatal("export reservations error = nil, want error")
+		}
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("partial export file remains: %v", err)
+		}
+	})
+
+	t.Run("不明なステータス", func(t *testing.T) {
+		_, err := run(&mockReservationReportRepository{}, "-status", "done")
+		if !errors.Is(err, ErrUsage) {
+			t.Errorf("export reservations error = %v, want ErrUsage", err)
+		}
+	})
+}
+
+func TestRun_ReportReservations(t *testing.T) {
+	day := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	repo := &mockReservationReportRepository{stats: []model.ReservationDailyStat{
+		{Day: day, Status: model.ReservationStatusConfirmed, Count: 3, Decided: 3, PendingSeconds: 540},
+		{Day: day, Status: model.ReservationStatusCancelled, Count: 1, Conflicts: 1, Decided: 1, PendingSeconds: 60},
+	}}
+	run := func(args ...string) (string, error) {
+		var out bytes.Buffer
+		err := Run(context.Background(), &Env{Out: &out, ReservationReports: repo}, append([]string{"report", "reservations"}, args...))
+		return out.String(), err
+	}
+
+	out, err := run("-from", "2024-06-01", "-to", "2024-06-08", "-tz", "Asia/Tokyo")
+	if err != nil {
+		t.Fatalf("report reservations error = %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[1], "2024-06-01") || !strings.Contains(lines[1], "25.0%") || !strings.Contains(lines[1], "2m30s") {
+		t.Errorf("report = %q", out)
+	}
+	// 受付日はタイムゾーンの0時から集計する
+	if repo.statsLoc.String() != "Asia/Tokyo" || !repo.statsFrom.Equal(time.Date(2024, 5, 31, 15, 0, 0, 0, time.UTC)) {
+		t.Errorf("stats range = %s - %s (%s)", repo.statsFrom, repo.statsTo, repo.statsLoc)
+	}
+
+	out, err = run("-from", "2024-06-01", "-to", "2024-06-08", "-format", "json")
+	if err != nil {
+		t.Fatalf("report reservations error = %v", err)
+	}
+	var summaries []model.ReservationDailySummary
+	if err := json.Unmarshal([]byte(out), &summaries); err != nil || len(summaries) != 1 || summaries[0].Total != 4 {
+		t.Errorf("report json = %q, %v", out, err)
+	}
+
+	for _, args := range [][]string{
+		{"-from", "2024-06-01"},
+		{"-from", "2024-06-08", "-to", "2024-06-01"},
+		{"-from", "2024-06-01", "-to", "2024-06-08", "-tz", "Mars/Base"},
+	} {
+		if _, err := run(args...); !errors.Is(err, ErrUsage) {
+			t.Errorf("report reservations %v error = %v, want ErrUsage", args, err)
+		}
+	}
+}
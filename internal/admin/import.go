@@ -0,0 +1,295 @@
+package admin
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/horsewin/echo-playground-batch-task/internal/model"
+)
+
+// ReservationCreator は予約を作成するリポジトリです
+// 渡された予約を1トランザクションで作成します
+type ReservationCreator interface {
+	CreateReservations(ctx context.Context, reservations []model.Reservation) error
+}
+
+// importCommands は外部のデータを取り込むサブコマンドです
+var importCommands = []command{
+	{name: "reservations", usage: "-file PATH [-format csv|jsonl] [-chunk-size N] [-skip-invalid] [-dry-run]", run: importReservations},
+}
+
+// importColumns は取り込むファイルの列 (JSON Linesの場合はキー) です
+var importColumns = []string{"user_id", "user_name", "email", "reservation_date_time", "pet_id"}
+
+// importRow は取り込むファイルの1行です
+type importRow struct {
+	// line はファイル内の行番号 (1始まり) です
+	line                int
+	UserID              string `json:"user_id"`
+	UserName            string `json:"user_name"`
+	Email               string `json:"email"`
+	ReservationDateTime string `json:"reservation_date_time"`
+	PetID               string `json:"pet_id"`
+}
+
+// rowError は取り込めない行とその理由です
+type rowError struct {
+	line int
+	err  error
+}
+
+// importReservations はCSVまたはJSON Linesの予約を検証し、チャンクごとに1トランザクションで作成します
+// 不正な行が1件でもある場合は、-skip-invalidを指定しない限り何も作成しません
+func importReservations(ctx context.Context, env *Env, args []string) error {
+	fs := newFlagSet("import reservations", env.Out)
+	path := fs.String("file", "", "取り込むファイルのパス")
+	format := fs.String("format", "", "ファイルの形式 (csv または jsonl。省略した場合は拡張子から判定)")
+	chunkSize := fs.Int("chunk-size", 100, "1トランザクションで作成する予約の件数")
+	skipInvalid := fs.Bool("skip-invalid", false, "不正な行を除いて取り込む")
+	dryRun := fs.Bool("dry-run", false, "検証のみ行い、予約を作成しない")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *path == "" {
+		return usageError("-file is required")
+	}
+	if *chunkSize <= 0 {
+		return usageError("-chunk-size must be positive: %d", *chunkSize)
+	}
+	if *format == "" {
+		*format = importFormat(*path)
+	}
+	if *format != "csv" && *format != "jsonl" {
+		return usageError("unknown format: %q (use -format csv or jsonl)", *format)
+	}
+
+	f, err := os.Open(*path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", *path, err)
+	}
+	defer f.Close()
+
+	var rows []importRow
+	var rowErrs []rowError
+	if *format == "csv" {
+		rows, rowErrs, err = readCSVRows(f)
+	} else {
+		rows, rowErrs, err = readJSONLRows(f)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", *path, err)
+	}
+
+	reservations, validateErrs, err := validateRows(ctx, env, rows)
+	if err != nil {
+		return err
+	}
+	rowErrs = append(rowErrs, validateErrs...)
+
+	slices.SortStableFunc(rowErrs, func(a, b rowError) int { return cmp.Compare(a.line, b.line) })
+	for _, e := range rowErrs {
+		fmt.Fprintf(env.Out, "line %d: %v\n", e.line, strings.ReplaceAll(e.err.Error(), "\n", "; "))
+	}
+	total := len(reservations) + len(rowErrs)
+	summary := func(imported int) {
+		fmt.Fprintf(env.Out, "Rows: %d, Valid: %d, Invalid: %d, Imported: %d\n", total, len(reservations), len(rowErrs), imported)
+	}
+
+	if len(rowErrs) > 0 && !*skipInvalid {
+		summary(0)
+		return fmt.Errorf("%d invalid rows, nothing was imported (use -skip-invalid to import valid rows)", len(rowErrs))
+	}
+	if *dryRun {
+		summary(0)
+		fmt.Fprintln(env.Out, "Dry run: no reservations were created")
+		return nil
+	}
+
+	imported := 0
+	for start := 0; start < len(reservations); start += *chunkSize {
+		chunk := reservations[start:min(start+*chunkSize, len(reservations))]
+		if err := env.Reservations.CreateReservations(ctx, chunk); err != nil {
+			// 作成済みのチャンクはコミットされているため、件数を出力してから中断する
+			summary(imported)
+			return fmt.Errorf("failed to import valid rows %d-%d: %w", start+1, start+len(chunk), err)
+		}
+		imported += len(chunk)
+	}
+	summary(imported)
+	return nil
+}
+
+// importFormat はファイルの拡張子から形式を判定します
+func importFormat(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return "csv"
+	case ".jsonl", ".ndjson":
+		return "jsonl"
+	}
+	return ""
+}
+
+// readCSVRows はヘッダー行付きのCSVを読み込みます
+// 列はヘッダーの名前で判定するため、順序は問いません
+func readCSVRows(r io.Reader) ([]importRow, []rowError, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil, errors.New("header row is required")
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		// 表計算ソフトで保存したCSVの先頭に付くBOMを取り除く
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		index[name] = i
+	}
+	for _, name := range importColumns {
+		if _, ok := index[name]; !ok {
+			return nil, nil, fmt.Errorf("column %s is required", name)
+		}
+	}
+
+	var rows []importRow
+	var rowErrs []rowError
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		line, _ := cr.FieldPos(0)
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, nil, err
+			}
+			rowErrs = append(rowErrs, rowError{line: parseErr.StartLine, err: parseErr.Err})
+			continue
+		}
+		field := func(name string) string {
+			if i := index[name]; i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		rows = append(rows, importRow{
+			line:                line,
+			UserID:              field("user_id"),
+			UserName:            field("user_name"),
+			Email:               field("email"),
+			ReservationDateTime: field("reservation_date_time"),
+			PetID:               field("pet_id"),
+		})
+	}
+	return rows, rowErrs, nil
+}
+
+// readJSONLRows は1行に1件のJSONオブジェクトを読み込みます。空行は無視します
+func readJSONLRows(r io.Reader) ([]importRow, []rowError, error) {
+	var rows []importRow
+	var rowErrs []rowError
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		row := importRow{line: line}
+		dec := json.NewDecoder(strings.NewReader(text))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&row); err != nil {
+			rowErrs = append(rowErrs, rowError{line: line, err: fmt.Errorf("invalid json: %w", err)})
+			continue
+		}
+		rows = append(rows, row)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+	return rows, rowErrs, nil
+}
+
+// validateRows は各行を予約に変換して検証し、取り込める予約と不正な行を返します
+// ペットの存在確認はペットごとに1回だけ行います。データベースのエラーの場合は検証を中断します
+func validateRows(ctx context.Context, env *Env, rows []importRow) ([]model.Reservation, []rowError, error) {
+	now := env.now()
+	petExists := make(map[string]bool)
+
+	var reservations []model.Reservation
+	var rowErrs []rowError
+	for _, row := range rows {
+		reservation := model.Reservation{
+			UserID:    row.UserID,
+			UserName:  row.UserName,
+			Email:     row.Email,
+			PetID:     row.PetID,
+			Status:    model.ReservationStatusPending,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		var errs []error
+		dateTimeInvalid := false
+		if row.ReservationDateTime != "" {
+			t, err := time.Parse(time.RFC3339, row.ReservationDateTime)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("reservation_date_time must be RFC3339: %q", row.ReservationDateTime))
+				dateTimeInvalid = true
+			}
+			reservation.ReservationDateTime = t
+		}
+		if err := reservation.Validate(now); err != nil {
+			verrs := []error{err}
+			if joined, ok := err.(interface{ Unwrap() []error }); ok {
+				verrs = joined.Unwrap()
+			}
+			for _, e := range verrs {
+				// 日時をパースできなかった行には、未指定のエラーを重ねて出さない
+				if dateTimeInvalid && errors.Is(e, model.ErrReservationDateTimeRequired) {
+					continue
+				}
+				errs = append(errs, e)
+			}
+		}
+
+		if reservation.PetID != "" {
+			exists, ok := petExists[reservation.PetID]
+			if !ok {
+				_, err := env.Pets.GetNameByID(ctx, reservation.PetID)
+				switch {
+				case err == nil:
+					exists = true
+				case !errors.Is(err, sql.ErrNoRows):
+					return nil, nil, fmt.Errorf("failed to check pet %s: %w", reservation.PetID, err)
+				}
+				petExists[reservation.PetID] = exists
+			}
+			if !exists {
+				errs = append(errs, fmt.Errorf("pet not found: %s", reservation.PetID))
+			}
+		}
+
+		if len(errs) > 0 {
+			rowErrs = append(rowErrs, rowError{line: row.line, err: errors.Join(errs...)})
+			continue
+		}
+		reservations = append(reservations, reservation)
+	}
+	return reservations, rowErrs, nil
+}
@@ -0,0 +1,214 @@
+package admin
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/horsewin/echo-playground-batch-task/internal/model"
+)
+
+// mockReservationCreator はテスト用のReservationCreatorです
+type mockReservationCreator struct {
+	// chunks はCreateReservationsに渡された予約です
+	chunks [][]model.Reservation
+	// failAt はエラーを返す呼び出しの回数 (1始まり) です。0の場合は失敗しません
+	failAt int
+}
+
+func (m *mockReservationCreator) CreateReservations(ctx context.Context, reservations []model.Reservation) error {
+	if len(m.chunks)+1 == m.failAt {
+		return errors.New("connection reset")
+	}
+	m.chunks = append(m.chunks, reservations)
+	return nil
+}
+
+// mockPetRepository はテスト用のPetRepositoryです
+type mockPetRepository struct {
+	names map[string]string
+	calls int
+}
+
+func (m *mockPetRepository) GetNameByID(ctx context.Context, petID string) (string, error) {
+	m.calls++
+	name, ok := m.names[petID]
+	if !ok {
+		return "", fmt.Errorf("failed to get pet name: %w", sql.ErrNoRows)
+	}
+	return name, nil
+}
+
+func TestRun_ImportReservations(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	validCSV := "user_id,user_name,email,reservation_date_time,pet_id\n" +
+		"user1,テスト太郎,taro@example.com,2024-06-10T10:00:00+09:00,pet1\n" +
+		"user2,テスト花子,hanako@example.com,2024-06-11T10:00:00+09:00,pet1\n" +
+		"user3,テスト次郎,jiro@example.com,2024-06-12T10:00:00+09:00,pet2\n"
+	invalidCSV := "pet_id,user_id,user_name,email,reservation_date_time\n" +
+		"pet1,user1,テスト太郎,taro@example.com,2024-06-10T10:00:00+09:00\n" +
+		"pet1,user2,テスト花子,hanako@,2024-05-01T10:00:00+09:00\n" +
+		"pet9,user3,テスト次郎,jiro@example.com,2024/06/12 10:00\n"
+
+	tests := []struct {
+		name       string
+		file       string
+		content    string
+		args       []string
+		wantErr    bool
+		wantChunks []int
+		wantOut    []string
+	}{
+		{
+			name:       "CSVをチャンクごとに取り込む",
+			file:       "reservations.csv",
+			content:    validCSV,
+			args:       []string{"-chunk-size", "2"},
+			wantChunks: []int{2, 1},
+			wantOut:    []string{"Rows: 3, Valid: 3, Invalid: 0, Imported: 3"},
+		},
+		{
+			name: "JSON Linesを取り込む",
+			file: "reservations.jsonl",
+			content: `{"user_id":"user1","user_name":"テスト太郎","email":"taro@example.com","reservation_date_time":"2024-06-10T10:00:00Z","pet_id":"pet1"}` + "\n\n" +
+				`{"user_id":"user2","user_name":"テスト花子","email":"hanako@example.com","reservation_date_time":"2024-06-11T10:00:00Z","pet_id":"pet2"}` + "\n",
+			wantChunks: []int{2},
+			wantOut:    []string{"Imported: 2"},
+		},
+		{
+			name:    "dry-runの場合は作成しない",
+			file:    "reservations.csv",
+			content: validCSV,
+			args:    []string{"-dry-run"},
+			wantOut: []string{"Valid: 3", "Imported: 0", "Dry run"},
+		},
+		{
+			name:    "不正な行がある場合は何も取り込まない",
+			file:    "reservations.csv",
+			content: invalidCSV,
+			wantErr: true,
+			wantOut: []string{
+				"line 3: email is invalid; reservation_date_time must be in the future",
+				"line 4: reservation_date_time must be RFC3339",
+				"pet not found: pet9",
+				"Rows: 3, Valid: 1, Invalid: 2, Imported: 0",
+			},
+		},
+		{
+			name:       "skip-invalidの場合は正しい行のみ取り込む",
+			file:       "reservations.csv",
+			content:    invalidCSV,
+			args:       []string{"-skip-invalid"},
+			wantChunks: []int{1},
+			wantOut:    []string{"Imported: 1"},
+		},
+		{
+			name:    "JSONとして不正な行を報告する",
+			file:    "reservations.jsonl",
+			content: `{"user_id":"user1","unknown":1}` + "\n",
+			wantErr: true,
+			wantOut: []string{"line 1: invalid json"},
+		},
+		{
+			name:    "必須の列がない",
+			file:    "reservations.csv",
+			content: "user_id,email\nuser1,taro@example.com\n",
+			wantErr: true,
+		},
+		{
+			name:    "形式を判定できない",
+			file:    "reservations.txt",
+			content: validCSV,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			reservations := &mockReservationCreator{}
+			var out bytes.Buffer
+			env := &Env{
+				Out:          &out,
+				Reservations: reservations,
+				Pets:         &mockPetRepository{names: map[string]string{"pet1": "ポチ", "pet2": "タマ"}},
+				Now:          func() time.Time { return now },
+			}
+
+			err := Run(context.Background(), env, append([]string{"import", "reservations", "-file", path}, tt.args...))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("import reservations error = %v, wantErr %v\n%s", err, tt.wantErr, out.String())
+			}
+			var chunks []int
+			for _, c := range reservations.chunks {
+				chunks = append(chunks, len(c))
+			}
+			if fmt.Sprint(chunks) != fmt.Sprint(tt.wantChunks) {
+				t.Errorf("chunks = %v, want %v", chunks, tt.wantChunks)
+			}
+			for _, want := range tt.wantOut {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("output = %q, want to contain %q", out.String(), want)
+				}
+			}
+		})
+	}
+}
+
+func TestRun_ImportReservations_Details(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	content := "user_id,user_name,email,reservation_date_time,pet_id\n" +
+		"user1,テスト太郎,taro@example.com,2024-06-10T10:00:00+09:00,pet1\n" +
+		"user2,テスト花子,hanako@example.com,2024-06-11T10:00:00+09:00,pet1\n" +
+		"user3,テスト次郎,jiro@example.com,2024-06-12T10:00:00+09:00,pet1\n"
+	path := filepath.Join(t.TempDir(), "reservations.csv")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("保留中の予約として作成し、ペットの確認は1回だけ行う", func(t *testing.T) {
+		reservations := &mockReservationCreator{}
+		pets := &mockPetRepository{names: map[string]string{"pet1": "ポチ"}}
+		env := &Env{Out: &bytes.Buffer{}, Reservations: reservations, Pets: pets, Now: func() time.Time { return now }}
+
+		if err := Run(context.Background(), env, []string{"import", "reservations", "-file", path}); err != nil {
+			t.Fatalf("import reservations error = %v", err)
+		}
+		if pets.calls != 1 {
+			t.Errorf("GetNameByID calls = %d, want 1", pets.calls)
+		}
+		got := reservations.chunks[0][0]
+		if got.Status != model.ReservationStatusPending || !got.CreatedAt.Equal(now) || got.ReservationDateTime.UTC().Hour() != 1 {
+			t.Errorf("reservation = %+v", got)
+		}
+	})
+
+	t.Run("チャンクの作成に失敗した場合は作成済みの件数を出力して中断する", func(t *testing.T) {
+		reservations := &mockReservationCreator{failAt: 2}
+		var out bytes.Buffer
+		env := &Env{
+			Out:          &out,
+			Reservations: reservations,
+			Pets:         &mockPetRepository{names: map[string]string{"pet1": "ポチ"}},
+			Now:          func() time.Time { return now },
+		}
+
+		err := Run(context.Background(), env, []string{"import", "reservations", "-file", path, "-chunk-size", "1"})
+		if err == nil || !strings.Contains(err.Error(), "valid rows 2-2") {
+			t.Fatalf("import reservations error = %v, want failure of rows 2-2", err)
+		}
+		if !strings.Contains(out.String(), "Imported: 1") {
+			t.Errorf("output = %q, want to contain imported count", out.String())
+		}
+	})
+}
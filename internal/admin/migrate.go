@@ -0,0 +1,96 @@
+package admin
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/horsewin/echo-playground-batch-task/internal/migration"
+)
+
+// Migrator はスキーマのマイグレーションの適用を担当するインターフェースです
+type Migrator interface {
+	Up(ctx context.Context, steps int) ([]migration.Migration, error)
+	Down(ctx context.Context, steps int) ([]migration.Migration, error)
+	Status(ctx context.Context) ([]migration.Status, error)
+}
+
+// migrateCommands はバイナリに埋め込んだマイグレーションを適用・取り消すサブコマンドです
+var migrateCommands = []command{
+	{name: "up", usage: "[-steps N]", run: migrateUp},
+	{name: "down", usage: "[-steps N]", run: migrateDown},
+	{name: "status", usage: "", run: migrateStatus},
+}
+
+// migrateUp は未適用のマイグレーションを適用します
+func migrateUp(ctx context.Context, env *Env, args []string) error {
+	fs := newFlagSet("migrate up", env.Out)
+	steps := fs.Int("steps", 0, "適用するマイグレーションの件数 (0の場合はすべて)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *steps < 0 {
+		return usageError("-steps must not be negative")
+	}
+
+	applied, err := env.Migrator.Up(ctx, *steps)
+	for _, m := range applied {
+		fmt.Fprintf(env.Out, "Applied %s\n", m)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to apply migrations: %w", err)
+	}
+	if len(applied) == 0 {
+		fmt.Fprintln(env.Out, "No migrations to apply")
+	}
+	return nil
+}
+
+// migrateDown は適用済みのマイグレーションを新しい順に取り消します
+// テーブルを削除するため、ローカル環境やテスト用のデータベースでの利用を想定しています
+func migrateDown(ctx context.Context, env *Env, args []string) error {
+	fs := newFlagSet("migrate down", env.Out)
+	steps := fs.Int("steps", 1, "取り消すマイグレーションの件数")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *steps <= 0 {
+		return usageError("-steps must be positive")
+	}
+
+	reverted, err := env.Migrator.Down(ctx, *steps)
+	for _, m := range reverted {
+		fmt.Fprintf(env.Out, "Reverted %s\n", m)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to revert migrations: %w", err)
+	}
+	if len(reverted) == 0 {
+		fmt.Fprintln(env.Out, "No migrations to revert")
+	}
+	return nil
+}
+
+// migrateStatus はマイグレーションの適用状況を出力します
+func migrateStatus(ctx context.Context, env *Env, args []string) error {
+	fs := newFlagSet("migrate status", env.Out)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	statuses, err := env.Migrator.Status(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get migration status: %w", err)
+	}
+
+	tw := newTableWriter(env.Out)
+	fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED_AT")
+	for _, s := range statuses {
+		appliedAt := "pending"
+		if s.AppliedAt != nil {
+			appliedAt = s.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(tw, "%04d\t%s\t%s\n", s.Version, s.Name, appliedAt)
+	}
+	return tw.Flush()
+}
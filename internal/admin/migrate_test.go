@@ -0,0 +1,99 @@
+package admin
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/horsewin/echo-playground-batch-task/internal/migration"
+)
+
+// mockMigrator はテスト用のMigratorです
+type mockMigrator struct {
+	migrations []migration.Migration
+	// applied は適用済みのマイグレーションの件数です
+	applied int
+	steps   []int
+}
+
+func (m *mockMigrator) Up(ctx context.Context, steps int) ([]migration.Migration, error) {
+	m.steps = append(m.steps, steps)
+	end := len(m.migrations)
+	if steps > 0 {
+		end = min(m.applied+steps, end)
+	}
+	done := m.migrations[m.applied:end]
+	m.applied = end
+	return done, nil
+}
+
+func (m *mockMigrator) Down(ctx context.Context, steps int) ([]migration.Migration, error) {
+	m.steps = append(m.steps, steps)
+	var done []migration.Migration
+	for ; steps > 0 && m.applied > 0; steps-- {
+		m.applied--
+		done = append(done, m.migrations[m.applied])
+	}
+	return done, nil
+}
+
+func (m *mockMigrator) Status(ctx context.Context) ([]migration.Status, error) {
+	appliedAt := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	statuses := make([]migration.Status, len(m.migrations))
+	for i, mig := range m.migrations {
+		statuses[i] = migration.Status{Migration: mig}
+		if i < m.applied {
+			statuses[i].AppliedAt = &appliedAt
+		}
+	}
+	return statuses, nil
+}
+
+func TestRun_Migrate(t *testing.T) {
+	migrator := &mockMigrator{migrations: []migration.Migration{
+		{Version: 1, Name: "create_core_tables"},
+		{Version: 2, Name: "create_batch_checkpoints"},
+		{Version: 3, Name: "create_reservation_events"},
+	}}
+	run := func(args ...string) (string, error) {
+		var out bytes.Buffer
+		err := Run(context.Background(), &Env{Out: &out, Migrator: migrator}, append([]string{"migrate"}, args...))
+		return out.String(), err
+	}
+
+	out, err := run("up", "-steps", "2")
+	if err != nil || !strings.Contains(out, "Applied 0001_create_core_tables") || !strings.Contains(out, "Applied 0002_create_batch_checkpoints") {
+		t.Fatalf("migrate up = %q, %v", out, err)
+	}
+
+	out, err = run("status")
+	if err != nil {
+		t.Fatalf("migrate status error = %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 4 || !strings.Contains(lines[2], "2024-03-01T00:00:00Z") || !strings.Contains(lines[3], "pending") {
+		t.Errorf("migrate status = %q", out)
+	}
+
+	// downは指定がない場合に1件だけ取り消す
+	out, err = run("down")
+	if err != nil || strings.TrimSpace(out) != "Reverted 0002_create_batch_checkpoints" {
+		t.Errorf("migrate down = %q, %v", out, err)
+	}
+
+	out, err = run("up")
+	if err != nil || strings.Count(out, "Applied") != 2 {
+		t.Errorf("migrate up = %q, %v", out, err)
+	}
+	out, err = run("up")
+	if err != nil || !strings.Contains(out, "No migrations to apply") {
+		t.Errorf("migrate up = %q, %v", out, err)
+	}
+
+	if _, err := run("down", "-steps", "0"); !errors.Is(err, ErrUsage) {
+		t.Errorf("migrate down -steps 0 error = %v, want ErrUsage", err)
+	}
+}
@@ -0,0 +1,137 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/horsewin/echo-playground-batch-task/internal/model"
+)
+
+// notificationCommands は通知の既読状態を確認・変更するサブコマンドです
+// サポートがユーザーの受信箱をSQLを使わずに修正できるようにするためのものです
+var notificationCommands = []command{
+	{name: "list", usage: "-user ID [-format table|json]", run: listNotifications},
+	{name: "mark-read", usage: "-user ID (-id 1,2,... | -all)", run: markNotifications(true)},
+	{name: "mark-unread", usage: "-user ID (-id 1,2,... | -all)", run: markNotifications(false)},
+	{name: "bulk-mark-read", usage: "[-type TYPE] [-from DATE] [-to DATE] [-user ID]", run: bulkMarkRead},
+}
+
+// listNotifications はユーザーの通知を新しい順に出力します
+func listNotifications(ctx context.Context, env *Env, args []string) error {
+	fs := newFlagSet("notifications list", env.Out)
+	userID := fs.String("user", "", "通知を出力するユーザーID")
+	format := fs.String("format", "table", "出力形式 (table または json)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *userID == "" {
+		return usageError("-user is required")
+	}
+	if *format != "table" && *format != "json" {
+		return usageError("unknown format: %s", *format)
+	}
+
+	records, err := env.Notifications.GetByUserID(ctx, *userID)
+	if err != nil {
+		return fmt.Errorf("failed to get notifications of user %s: %w", *userID, err)
+	}
+
+	if *format == "json" {
+		enc := json.NewEncoder(env.Out)
+		enc.SetIndent("", "  ")
+		if records == nil {
+			records = []model.NotificationRecord{}
+		}
+		return enc.Encode(records)
+	}
+
+	tw := newTableWriter(env.Out)
+	fmt.Fprintln(tw, "ID\tTYPE\tREAD\tCREATED_AT\tTITLE")
+	for _, r := range records {
+		fmt.Fprintf(tw, "%d\t%s\t%t\t%s\t%s\n", r.ID, r.Type, r.IsRead, r.CreatedAt.Format(time.RFC3339), r.Title)
+	}
+	return tw.Flush()
+}
+
+// markNotifications はユーザーの指定された通知、またはすべての通知の既読状態を変更するコマンドを返します
+func markNotifications(isRead bool) func(ctx context.Context, env *Env, args []string) error {
+	return func(ctx context.Context, env *Env, args []string) error {
+		fs := newFlagSet("notifications mark", env.Out)
+		userID := fs.String("user", "", "通知を変更するユーザーID")
+		ids := fs.String("id", "", "変更する通知のID (カンマ区切り)")
+		all := fs.Bool("all", false, "ユーザーのすべての通知を変更する")
+		if err := parseFlags(fs, args); err != nil {
+			return err
+		}
+		if *userID == "" {
+			return usageError("-user is required")
+		}
+
+		filter := model.NotificationFilter{UserID: *userID}
+		switch {
+		case *ids != "" && *all:
+			return usageError("-id and -all cannot be used together")
+		case *ids != "":
+			parsed, err := parseIDs(*ids)
+			if err != nil {
+				return err
+			}
+			filter.IDs = parsed
+		case !*all:
+			// 誤ってユーザーのすべての通知を変更しないよう、-allの指定を必須とする
+			return usageError("-id or -all is required")
+		}
+
+		return updateIsRead(ctx, env, filter, isRead)
+	}
+}
+
+// bulkMarkRead は種類と作成日時で絞り込んだ通知を、ユーザーをまたいで既読にします
+func bulkMarkRead(ctx context.Context, env *Env, args []string) error {
+	fs := newFlagSet("notifications bulk-mark-read", env.Out)
+	userID := fs.String("user", "", "通知を変更するユーザーID (省略した場合は全ユーザー)")
+	notificationType := fs.String("type", "", "変更する通知の種類 (reservation または common)")
+	from := fs.String("from", "", "作成日時の下限 (この日時を含む。2006-01-02 またはRFC3339)")
+	to := fs.String("to", "", "作成日時の上限 (この日時を含まない。2006-01-02 またはRFC3339)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	filter := model.NotificationFilter{UserID: *userID, Type: model.NotificationType(*notificationType)}
+	switch filter.Type {
+	case "", model.NotificationTypeReservation, model.NotificationTypeCommon:
+	default:
+		return usageError("unknown notification type: %s", *notificationType)
+	}
+	var err error
+	if filter.CreatedFrom, err = parseTime(*from); err != nil {
+		return err
+	}
+	if filter.CreatedTo, err = parseTime(*to); err != nil {
+		return err
+	}
+	if filter.Type == "" && filter.CreatedFrom == nil && filter.CreatedTo == nil {
+		return usageError("at least one of -type, -from or -to is required")
+	}
+	if err := filter.Validate(); err != nil {
+		return usageError("%v", err)
+	}
+
+	return updateIsRead(ctx, env, filter, true)
+}
+
+// updateIsRead は条件に一致する通知の既読状態を変更し、変更した件数を出力します
+func updateIsRead(ctx context.Context, env *Env, filter model.NotificationFilter, isRead bool) error {
+	updated, err := env.Notifications.UpdateIsReadByFilter(ctx, filter, isRead)
+	if err != nil {
+		return fmt.Errorf("failed to update notifications: %w", err)
+	}
+	state := "read"
+	if !isRead {
+		state = "unread"
+	}
+	fmt.Fprintf(env.Out, "Marked %d notifications as %s\n", updated, state)
+	return nil
+}
@@ -0,0 +1,182 @@
+package admin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/horsewin/echo-playground-batch-task/internal/model"
+	"github.com/jmoiron/sqlx"
+)
+
+// mockNotificationRepository はテスト用のモックリポジトリです
+type mockNotificationRepository struct {
+	records []model.NotificationRecord
+	// filters はUpdateIsReadByFilterに渡された条件です
+	filters []model.NotificationFilter
+	isRead  []bool
+	updated int64
+}
+
+func (m *mockNotificationRepository) CreateNotifications(ctx context.Context, records []model.NotificationRecord) error {
+	return nil
+}
+
+func (m *mockNotificationRepository) Create(ctx context.Context, tx *sqlx.Tx, record *model.NotificationRecord) error {
+	return nil
+}
+
+func (m *mockNotificationRepository) GetByUserID(ctx context.Context, userID string) ([]model.NotificationRecord, error) {
+	var records []model.NotificationRecord
+	for _, r := range m.records {
+		if r.UserID == userID {
+			records = append(records, r)
+		}
+	}
+	return records, nil
+}
+
+func (m *mockNotificationRepository) UpdateIsRead(ctx context.Context, tx *sqlx.Tx, id int, isRead bool) error {
+	return nil
+}
+
+func (m *mockNotificationRepository) UpdateIsReadByFilter(ctx context.Context, filter model.NotificationFilter, isRead bool) (int64, error) {
+	m.filters = append(m.filters, filter)
+	m.isRead = append(m.isRead, isRead)
+	return m.updated, nil
+}
+
+func TestRun_NotificationsList(t *testing.T) {
+	created := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	repo := &mockNotificationRepository{records: []model.NotificationRecord{
+		{ID: 2, UserID: "user1", Title: "予約が完了しました", Type: model.NotificationTypeReservation, CreatedAt: created},
+		{ID: 1, UserID: "user1", Title: "新しい通知", IsRead: true, Type: model.NotificationTypeCommon, CreatedAt: created},
+		{ID: 3, UserID: "user2", Title: "他のユーザーの通知", CreatedAt: created},
+	}}
+
+	t.Run("表形式", func(t *testing.T) {
+		var out bytes.Buffer
+		if err := Run(context.Background(), &Env{Out: &out, Notifications: repo},
+			[]string{"notifications", "list", "-user", "user1"}); err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		if len(lines) != 3 || !strings.HasPrefix(lines[0], "ID") {
+			t.Fatalf("output = %q, want header and 2 rows", out.String())
+		}
+		if !strings.Contains(lines[1], "reservation") || !strings.Contains(lines[1], "false") ||
+			!strings.Contains(lines[1], "2024-03-01T09:00:00Z") {
+			t.Errorf("row = %q", lines[1])
+		}
+	})
+
+	t.Run("JSON形式", func(t *testing.T) {
+		var out bytes.Buffer
+		if err := Run(context.Background(), &Env{Out: &out, Notifications: repo},
+			[]string{"notifications", "list", "-user", "user1", "-format", "json"}); err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+		var records []model.NotificationRecord
+		if err := json.Unmarshal(out.Bytes(), &records); err != nil {
+			t.Fatalf("output is not JSON: %v", err)
+		}
+		if len(records) != 2 || records[1].ID != 1 || !records[1].IsRead {
+			t.Errorf("records = %+v", records)
+		}
+	})
+
+	t.Run("通知がない場合は空の配列", func(t *testing.T) {
+		var out bytes.Buffer
+		if err := Run(context.Background(), &Env{Out: &out, Notifications: repo},
+			[]string{"notifications", "list", "-user", "nobody", "-format", "json"}); err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+		if got := strings.TrimSpace(out.String()); got != "[]" {
+			t.Errorf("output = %q, want []", got)
+		}
+	})
+}
+
+func TestRun_NotificationsMark(t *testing.T) {
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		args       []string
+		wantErr    bool
+		wantFilter model.NotificationFilter
+		wantIsRead bool
+	}{
+		{
+			name:       "指定した通知を既読にする",
+			args:       []string{"notifications", "mark-read", "-user", "user1", "-id", "1, 2"},
+			wantFilter: model.NotificationFilter{UserID: "user1", IDs: []int{1, 2}},
+			wantIsRead: true,
+		},
+		{
+			name:       "すべての通知を未読にする",
+			args:       []string{"notifications", "mark-unread", "-user", "user1", "-all"},
+			wantFilter: model.NotificationFilter{UserID: "user1"},
+			wantIsRead: false,
+		},
+		{
+			name:       "種類と期間で一括で既読にする",
+			args:       []string{"notifications", "bulk-mark-read", "-type", "reservation", "-from", "2024-03-01", "-to", "2024-04-01T00:00:00Z"},
+			wantFilter: model.NotificationFilter{Type: model.NotificationTypeReservation, CreatedFrom: &from, CreatedTo: &to},
+			wantIsRead: true,
+		},
+		{name: "ユーザーの指定がない", args: []string{"notifications", "mark-read", "-all"}, wantErr: true},
+		{name: "通知IDと-allの指定がない", args: []string{"notifications", "mark-read", "-user", "user1"}, wantErr: true},
+		{name: "不正な通知ID", args: []string{"notifications", "mark-read", "-user", "user1", "-id", "a"}, wantErr: true},
+		{name: "一括変更の条件がない", args: []string{"notifications", "bulk-mark-read", "-user", "user1"}, wantErr: true},
+		{name: "不正な期間", args: []string{"notifications", "bulk-mark-read", "-from", "2024-04-01", "-to", "2024-03-01"}, wantErr: true},
+		{name: "未定義の種類", args: []string{"notifications", "bulk-mark-read", "-type", "unknown"}, wantErr: true},
+		{name: "未定義のコマンド", args: []string{"notifications", "delete"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &mockNotificationRepository{updated: 2}
+			var out bytes.Buffer
+			err := Run(context.Background(), &Env{Out: &out, Notifications: repo}, tt.args)
+			if tt.wantErr {
+				if !errors.Is(err, ErrUsage) {
+					t.Errorf("Run() error = %v, want ErrUsage", err)
+				}
+				if len(repo.filters) != 0 {
+					t.Errorf("notifications were updated: %+v", repo.filters)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			if len(repo.filters) != 1 {
+				t.Fatalf("UpdateIsReadByFilter calls = %d, want 1", len(repo.filters))
+			}
+			got := repo.filters[0]
+			if got.UserID != tt.wantFilter.UserID || got.Type != tt.wantFilter.Type ||
+				!slices.Equal(got.IDs, tt.wantFilter.IDs) ||
+				!equalTime(got.CreatedFrom, tt.wantFilter.CreatedFrom) || !equalTime(got.CreatedTo, tt.wantFilter.CreatedTo) {
+				t.Errorf("filter = %+v, want %+v", got, tt.wantFilter)
+			}
+			if repo.isRead[0] != tt.wantIsRead {
+				t.Errorf("isRead = %t, want %t", repo.isRead[0], tt.wantIsRead)
+			}
+			if !strings.Contains(out.String(), "Marked 2 notifications") {
+				t.Errorf("output = %q", out.String())
+			}
+		})
+	}
+}
+
+func equalTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
@@ -0,0 +1,163 @@
+package apperrors
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// Code はバッチ処理のエラー種別を表します
+// Step FunctionsのError名としてそのまま利用されます
+type Code string
+
+const (
+	// CodeDBUnavailable はデータベースに接続できない・一時的に利用できないことを表します
+	CodeDBUnavailable Code = "DBUnavailable"
+	// CodeInvalidInput は入力データが不正であることを表します
+	CodeInvalidInput Code = "InvalidInput"
+	// CodeTimeout は処理がタイムアウトしたことを表します
+	CodeTimeout Code = "Timeout"
+	// CodePartialFailure は一部のデータの処理に失敗したことを表します
+	CodePartialFailure Code = "PartialFailure"
+	// CodeExternalServiceError は外部サービスの呼び出しに失敗したことを表します
+	CodeExternalServiceError Code = "ExternalServiceError"
+	// CodeInterrupted はシグナルにより処理が中断されたことを表します
+	CodeInterrupted Code = "Interrupted"
+	// CodeInternal は上記に分類できないエラーを表します
+	CodeInternal Code = "Internal"
+)
+
+// Error はエラー種別と発生箇所を持つエラーです
+type Error struct {
+	Code Code
+	// Op はエラーが発生した処理の名前です
+	Op  string
+	Err error
+	// Details はStep FunctionsのCauseに含める付加情報です
+	Details map[string]any
+}
+
+// Error はエラーメッセージを返します
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("%s: %s", e.Op, e.Code)
+	}
+	return fmt.Sprintf("%s: %v", e.Op, e.Err)
+}
+
+// Unwrap は元のエラーを返します
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// New は指定されたエラー種別でエラーをラップします
+func New(code Code, op string, err error) *Error {
+	return &Error{Code: code, Op: op, Err: err}
+}
+
+// WithDetails はStep FunctionsのCauseに含める付加情報を設定します
+func (e *Error) WithDetails(details map[string]any) *Error {
+	e.Details = details
+	return e
+}
+
+// DBUnavailable はデータベースが利用できないことを表すエラーを作成します
+func DBUnavailable(op string, err error) *Error {
+	return New(CodeDBUnavailable, op, err)
+}
+
+// InvalidInput は入力データが不正であることを表すエラーを作成します
+func InvalidInput(op string, err error) *Error {
+	return New(CodeInvalidInput, op, err)
+}
+
+// Timeout はタイムアウトを表すエラーを作成します
+func Timeout(op string, err error) *Error {
+	return New(CodeTimeout, op, err)
+}
+
+// PartialFailure は一部のデータの処理に失敗したことを表すエラーを作成します
+func PartialFailure(op string, err error) *Error {
+	return New(CodePartialFailure, op, err)
+}
+
+// Interrupted は処理が中断されたことを表すエラーを作成します
+func Interrupted(op string, err error) *Error {
+	return New(CodeInterrupted, op, err)
+}
+
+// ExternalService は外部サービスの呼び出しに失敗したことを表すエラーを作成します
+func ExternalService(op string, err error) *Error {
+	return New(CodeExternalServiceError, op, err)
+}
+
+// Internal は分類できないエラーを作成します
+func Internal(op string, err error) *Error {
+	return New(CodeInternal, op, err)
+}
+
+// FromDB はデータベース操作のエラーを種別に分類してラップします
+// 既に分類済みのエラーはそのまま返します
+func FromDB(op string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return err
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return Timeout(op, err)
+	}
+	if errors.Is(err, driver.ErrBadConn) {
+		return DBUnavailable(op, err)
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code.Class() {
+		// 08: 接続エラー, 53: リソース不足, 57: オペレータ介入(シャットダウンなど)
+		case "08", "53", "57":
+			return DBUnavailable(op, err)
+		// 22: データ例外, 23: 整合性制約違反
+		case "22", "23":
+			return InvalidInput(op, err)
+		}
+		return Internal(op, err)
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return DBUnavailable(op, err)
+	}
+	if strings.Contains(err.Error(), "connection refused") || strings.Contains(err.Error(), "bad connection") {
+		return DBUnavailable(op, err)
+	}
+	return Internal(op, err)
+}
+
+// CodeOf はエラーの種別を返します
+// 分類されていないエラーはCodeInternalとして扱います
+func CodeOf(err error) Code {
+	if err == nil {
+		return ""
+	}
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr.Code
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return CodeTimeout
+	}
+	return CodeInternal
+}
+
+// Is はエラーが指定された種別かどうかを返します
+func Is(err error, code Code) bool {
+	return err != nil && CodeOf(err) == code
+}
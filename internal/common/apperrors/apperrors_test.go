@@ -0,0 +1,109 @@
+package apperrors
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+func TestCodeOf(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want Code
+	}{
+		{name: "nil", err: nil, want: ""},
+		{name: "分類済みのエラー", err: InvalidInput("parse", errors.New("bad json")), want: CodeInvalidInput},
+		{name: "ラップされた分類済みのエラー", err: fmt.Errorf("outer: %w", DBUnavailable("query", errors.New("down"))), want: CodeDBUnavailable},
+		{name: "外側の分類が優先される", err: PartialFailure("run", DBUnavailable("query", errors.New("down"))), want: CodePartialFailure},
+		{name: "コンテキストのタイムアウト", err: fmt.Errorf("query: %w", context.DeadlineExceeded), want: CodeTimeout},
+		{name: "未分類のエラー", err: errors.New("unknown"), want: CodeInternal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CodeOf(tt.err); got != tt.want {
+				t.Errorf("CodeOf() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFromDB(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want Code
+	}{
+		{name: "コネクション切断", err: driver.ErrBadConn, want: CodeDBUnavailable},
+		{name: "管理者によるシャットダウン", err: &pq.Error{Code: "57P01"}, want: CodeDBUnavailable},
+		{name: "接続失敗", err: &pq.Error{Code: "08006"}, want: CodeDBUnavailable},
+		{name: "一意制約違反", err: &pq.Error{Code: "23505"}, want: CodeInvalidInput},
+		{name: "構文エラー", err: &pq.Error{Code: "42601"}, want: CodeInternal},
+		{name: "タイムアウト", err: context.DeadlineExceeded, want: CodeTimeout},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CodeOf(FromDB("op", tt.err)); got != tt.want {
+				t.Errorf("CodeOf(FromDB()) = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if FromDB("op", nil) != nil {
+		t.Error("FromDB(nil) should return nil")
+	}
+}
+
+func TestStepFunctionsError(t *testing.T) {
+	err := PartialFailure("ReservationBatchService.Run", errors.New("1 of 2 reservations failed for yamada@example.com")).
+		WithDetails(map[string]any{"failed": 1})
+	err.Err = fmt.Errorf("%w\nStack trace:\ngoroutine 1 [running]", err.Err)
+
+	name, cause := StepFunctionsError(err)
+	if name != "PartialFailure" {
+		t.Errorf("StepFunctionsError() error = %q, want %q", name, "PartialFailure")
+	}
+
+	var got sfnCause
+	if err := json.Unmarshal([]byte(cause), &got); err != nil {
+		t.Fatalf("cause is not valid json: %v", err)
+	}
+	if got.Operation != "ReservationBatchService.Run" {
+		t.Errorf("operation = %q, want %q", got.Operation, "ReservationBatchService.Run")
+	}
+	if strings.Contains(got.Message, "Stack trace") {
+		t.Errorf("message should not contain stack trace: %q", got.Message)
+	}
+	if strings.Contains(got.Message, "yamada@example.com") {
+		t.Errorf("message should be redacted: %q", got.Message)
+	}
+	if got.Details["failed"] != float64(1) {
+		t.Errorf("details.failed = %v, want 1", got.Details["failed"])
+	}
+}
+
+func TestStepFunctionsError_TruncatesLargeDetails(t *testing.T) {
+	err := PartialFailure("op", errors.New("failed")).
+		WithDetails(map[string]any{"payload": strings.Repeat("x ", maxSFNCauseLength)})
+
+	_, cause := StepFunctionsError(err)
+	if len(cause) > maxSFNCauseLength {
+		t.Fatalf("cause length = %d, want <= %d", len(cause), maxSFNCauseLength)
+	}
+
+	var got sfnCause
+	if err := json.Unmarshal([]byte(cause), &got); err != nil {
+		t.Fatalf("cause is not valid json: %v", err)
+	}
+	if !got.DetailsTruncated {
+		t.Error("details_truncated should be true")
+	}
+}
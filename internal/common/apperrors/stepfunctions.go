@@ -0,0 +1,91 @@
+package apperrors
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/horsewin/echo-playground-batch-task/internal/common/redact"
+)
+
+const (
+	// Step FunctionsのSendTaskFailureで指定できるError・Causeの最大長
+	maxSFNErrorLength = 256
+	maxSFNCauseLength = 32768
+
+	// utils.GetStackWithErrorが付与するスタックトレースの区切り
+	stackTraceMarker = "\nStack trace:"
+)
+
+// sfnCause はStep FunctionsのCauseに設定するJSONの構造体です
+type sfnCause struct {
+	Error            Code           `json:"error"`
+	Operation        string         `json:"operation,omitempty"`
+	Message          string         `json:"message"`
+	Details          map[string]any `json:"details,omitempty"`
+	DetailsTruncated bool           `json:"details_truncated,omitempty"`
+}
+
+// StepFunctionsError はエラーをStep FunctionsのErrorとCauseに変換します
+// ErrorにはCodeをそのまま設定するため、ステートマシン側でエラー種別ごとにRetry/Catchを定義できます
+// Causeはマスク済みのJSON文字列です
+func StepFunctionsError(err error) (string, string) {
+	code := CodeOf(err)
+	cause := sfnCause{
+		Error:   code,
+		Message: redact.String(message(err)),
+	}
+
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		cause.Operation = appErr.Op
+		if appErr.Details != nil {
+			if masked, ok := redact.Value("", appErr.Details).(map[string]any); ok {
+				cause.Details = masked
+			}
+		}
+	}
+
+	return truncate(string(code), maxSFNErrorLength), marshalCause(cause)
+}
+
+// marshalCause はCauseをJSONに変換します
+// 最大長を超える場合は付加情報を落とし、それでも超える場合はメッセージを切り詰めます
+func marshalCause(cause sfnCause) string {
+	data, err := json.Marshal(cause)
+	if err == nil && len(data) <= maxSFNCauseLength {
+		return string(data)
+	}
+
+	if cause.Details != nil {
+		cause.Details = nil
+		cause.DetailsTruncated = true
+	}
+	cause.Message = truncate(cause.Message, maxSFNCauseLength/2)
+	data, err = json.Marshal(cause)
+	if err != nil {
+		return truncate(cause.Message, maxSFNCauseLength)
+	}
+	return string(data)
+}
+
+// message はスタックトレースを除いたエラーメッセージを返します
+func message(err error) string {
+	msg, _, _ := strings.Cut(err.Error(), stackTraceMarker)
+	return msg
+}
+
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	// マルチバイト文字の途中で切らないように調整
+	for max > 0 && !isRuneStart(s[max]) {
+		max--
+	}
+	return s[:max]
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
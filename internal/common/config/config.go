@@ -5,8 +5,10 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/horsewin/echo-playground-batch-task/internal/common/database"
+	"github.com/horsewin/echo-playground-batch-task/internal/common/retry"
 )
 
 type Config struct {
@@ -14,6 +16,97 @@ type Config struct {
 	SFN struct {
 		TaskToken string
 	}
+	Redact struct {
+		// AllowFields はマスクせずに出力するフィールドの許可リストです
+		AllowFields []string
+	}
+	Run struct {
+		// ID はバッチ処理の実行IDです。再開する場合は再開元の実行IDです
+		ID string
+		// Resume は既存のチェックポイントから処理を再開するかを表します
+		Resume bool
+	}
+	Checkpoint struct {
+		// Store はチェックポイントの保存先です (db または file)
+		Store string
+		// Dir はStoreがfileの場合の保存先ディレクトリです
+		Dir string
+	}
+	Notification struct {
+		// ChunkSize は1トランザクションで作成する通知レコードの件数です
+		ChunkSize int
+		// QueueURL はキューから通知を受信する場合の受信元のキューのURLです
+		QueueURL string
+		// QueueBatchSize は1回に受信する通知の件数です (10件まで)
+		QueueBatchSize int
+		// QueueWaitTime はキューが空の場合に受信を待機する時間です (20秒まで)
+		QueueWaitTime time.Duration
+		// TimeZone は通知に表示する日時の既定のタイムゾーンです。ユーザーが設定している場合はユーザーの設定を使います
+		TimeZone string
+		// Locale は通知に表示する日時の既定の書式の言語です (ja または en)
+		Locale string
+	}
+	Expiry struct {
+		// PendingMaxAge は保留中の予約を期限切れにするまでの作成からの経過時間です。0以下の場合は予約日時だけで判定します
+		PendingMaxAge time.Duration
+	}
+	Rules struct {
+		// Order は予約の確定前に評価するルールの名前です。指定された順に評価します
+		Order []string
+	}
+	Limits struct {
+		// MaxActivePerUser はユーザーごとの確定済みで予約日時を過ぎていない予約の上限です。0以下の場合は制限しません
+		MaxActivePerUser int
+	}
+	Reminder struct {
+		// Windows は予約日時の何時間前にリマインドするかを表します (例: 24h, 1h)
+		Windows []time.Duration
+	}
+	Retention struct {
+		// ReadMaxAge は既読の通知を保持する期間です。0以下の場合は既読の通知を削除しません
+		ReadMaxAge time.Duration
+		// UnreadMaxAge は未読の通知を保持する期間です。0以下の場合は未読の通知を削除しません
+		UnreadMaxAge time.Duration
+		// BatchSize は1トランザクションで削除する通知の件数です
+		BatchSize int
+		// ArchiveDir は削除する通知をアーカイブするディレクトリです。空の場合はアーカイブせずに削除します
+		ArchiveDir string
+	}
+	Delivery struct {
+		// MaxAttempts は外部への配信の最大試行回数です。失敗した配信は次回以降の実行で再度配信されます
+		MaxAttempts int
+		// SMTP はメール配信の設定です。Hostが空の場合はメールで配信しません
+		SMTP struct {
+			Host     string
+			Port     int
+			UserName string
+			Password string
+			From     string
+		}
+		// Webhook はWebhook配信の設定です。Secretが空の場合はWebhookで配信しません
+		Webhook struct {
+			Secret  string
+			Timeout time.Duration
+		}
+		// Push はプッシュ通知の設定です。Endpointが空の場合はプッシュ通知で配信しません
+		Push struct {
+			Endpoint string
+			APIKey   string
+			Timeout  time.Duration
+		}
+	}
+	Outbox struct {
+		// Publisher は予約イベントの配信先です (sfn, queue または file)
+		Publisher string
+		// QueueURL はPublisherがqueueの場合の配信先のキューのURLです
+		QueueURL string
+		// FilePath はPublisherがfileの場合の配信先のJSONLファイルです
+		FilePath string
+		// BatchSize は1回に配信するイベントの件数です。Publisherがsfnの場合は全件を1回で配信します
+		BatchSize int
+	}
+	// Retry はDBトランザクションやStep Functionsへのコールバックのリトライ方針です
+	Retry         retry.Policy
 	EnableTracing bool
 }
 
@@ -34,6 +127,49 @@ func LoadConfig(taskToken string) (*Config, error) {
 		},
 		EnableTracing: false,
 	}
+	cfg.Redact.AllowFields = getEnvAsSliceOrDefault("REDACT_ALLOW_FIELDS", nil)
+
+	cfg.Checkpoint.Store = getEnvOrDefault("CHECKPOINT_STORE", "db")
+	cfg.Checkpoint.Dir = getEnvOrDefault("CHECKPOINT_DIR", ".checkpoints")
+	cfg.Notification.ChunkSize = getEnvAsIntOrDefault("NOTIFICATION_CHUNK_SIZE", 100)
+	cfg.Notification.QueueURL = getEnvOrDefault("NOTIFICATION_QUEUE_URL", "")
+	cfg.Notification.QueueBatchSize = getEnvAsIntOrDefault("NOTIFICATION_QUEUE_BATCH_SIZE", 10)
+	cfg.Notification.QueueWaitTime = getEnvAsDurationOrDefault("NOTIFICATION_QUEUE_WAIT_TIME", 20*time.Second)
+	cfg.Notification.TimeZone = getEnvOrDefault("NOTIFICATION_TIMEZONE", "Asia/Tokyo")
+	cfg.Notification.Locale = getEnvOrDefault("NOTIFICATION_LOCALE", "ja")
+
+	cfg.Expiry.PendingMaxAge = getEnvAsDurationOrDefault("RESERVATION_PENDING_MAX_AGE", 72*time.Hour)
+	cfg.Rules.Order = getEnvAsSliceOrDefault("RESERVATION_RULES", []string{"pet_availability", "user_pet", "user_limit", "pet_conflict"})
+	cfg.Limits.MaxActivePerUser = getEnvAsIntOrDefault("RESERVATION_MAX_ACTIVE_PER_USER", 3)
+	cfg.Reminder.Windows = getEnvAsDurationSliceOrDefault("REMINDER_WINDOWS", []time.Duration{24 * time.Hour, time.Hour})
+
+	cfg.Retention.ReadMaxAge = time.Duration(getEnvAsIntOrDefault("NOTIFICATION_READ_RETENTION_DAYS", 30)) * 24 * time.Hour
+	cfg.Retention.UnreadMaxAge = time.Duration(getEnvAsIntOrDefault("NOTIFICATION_UNREAD_RETENTION_DAYS", 90)) * 24 * time.Hour
+	cfg.Retention.BatchSize = getEnvAsIntOrDefault("NOTIFICATION_RETENTION_BATCH_SIZE", 500)
+	cfg.Retention.ArchiveDir = getEnvOrDefault("NOTIFICATION_ARCHIVE_DIR", "")
+
+	cfg.Delivery.MaxAttempts = getEnvAsIntOrDefault("DELIVERY_MAX_ATTEMPTS", 5)
+	cfg.Delivery.SMTP.Host = getEnvOrDefault("SMTP_HOST", "")
+	cfg.Delivery.SMTP.Port = getEnvAsIntOrDefault("SMTP_PORT", 25)
+	cfg.Delivery.SMTP.UserName = getEnvOrDefault("SMTP_USERNAME", "")
+	cfg.Delivery.SMTP.Password = getEnvOrDefault("SMTP_PASSWORD", "")
+	cfg.Delivery.SMTP.From = getEnvOrDefault("SMTP_FROM", "noreply@example.com")
+	cfg.Delivery.Webhook.Secret = getEnvOrDefault("WEBHOOK_SECRET", "")
+	cfg.Delivery.Webhook.Timeout = getEnvAsDurationOrDefault("WEBHOOK_TIMEOUT", 10*time.Second)
+	cfg.Delivery.Push.Endpoint = getEnvOrDefault("PUSH_ENDPOINT", "")
+	cfg.Delivery.Push.APIKey = getEnvOrDefault("PUSH_API_KEY", "")
+	cfg.Delivery.Push.Timeout = getEnvAsDurationOrDefault("PUSH_TIMEOUT", 10*time.Second)
+
+	cfg.Outbox.Publisher = getEnvOrDefault("OUTBOX_PUBLISHER", "sfn")
+	cfg.Outbox.QueueURL = getEnvOrDefault("OUTBOX_QUEUE_URL", "")
+	cfg.Outbox.FilePath = getEnvOrDefault("OUTBOX_FILE", "reservation_events.jsonl")
+	cfg.Outbox.BatchSize = getEnvAsIntOrDefault("OUTBOX_BATCH_SIZE", 100)
+
+	defaultRetry := retry.DefaultPolicy()
+	cfg.Retry = defaultRetry
+	cfg.Retry.MaxAttempts = getEnvAsIntOrDefault("RETRY_MAX_ATTEMPTS", defaultRetry.MaxAttempts)
+	cfg.Retry.BaseDelay = getEnvAsDurationOrDefault("RETRY_BASE_DELAY", defaultRetry.BaseDelay)
+	cfg.Retry.MaxDelay = getEnvAsDurationOrDefault("RETRY_MAX_DELAY", defaultRetry.MaxDelay)
 
 	// 環境変数[SBCNTR_ENABLE_TRACING]を見てトレースを有効にする。対応しているTracingはAWS_XRAYのみ。
 	// 環境変数[AWS_XRAY_SDK_DISABLED]がtrueの場合は必ずトレースを無効にする。
@@ -66,6 +202,47 @@ func getEnvAsIntOrDefault(key string, defaultValue int) int {
 	return defaultValue
 }
 
+func getEnvAsDurationOrDefault(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+		log.Printf("Environment variable %s has invalid duration %q, using default value", key, value)
+	}
+	return defaultValue
+}
+
+func getEnvAsSliceOrDefault(key string, defaultValue []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	var values []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+func getEnvAsDurationSliceOrDefault(key string, defaultValue []time.Duration) []time.Duration {
+	values := getEnvAsSliceOrDefault(key, nil)
+	if len(values) == 0 {
+		return defaultValue
+	}
+	durations := make([]time.Duration, 0, len(values))
+	for _, v := range values {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			log.Printf("Environment variable %s has invalid duration %q, using default value", key, v)
+			return defaultValue
+		}
+		durations = append(durations, d)
+	}
+	return durations
+}
+
 // Check if SDK is disabled
 func sdkDisabled() bool {
 	disableKey := os.Getenv("AWS_XRAY_SDK_DISABLED")
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/aws/aws-xray-sdk-go/xray"
+	"github.com/horsewin/echo-playground-batch-task/internal/common/redact"
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
 )
@@ -22,6 +23,12 @@ type Config struct {
 	DBName   string
 }
 
+// String はパスワードをマスクした接続設定を返します
+func (c Config) String() string {
+	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s",
+		c.Host, c.Port, c.UserName, redact.Secret(c.Password), c.DBName)
+}
+
 type SQLHandler struct {
 	Conn *sqlx.DB
 }
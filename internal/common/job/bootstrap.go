@@ -0,0 +1,185 @@
+package job
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"os"
+	"runtime/debug"
+	"time"
+
+	"github.com/aws/aws-xray-sdk-go/xray"
+	"github.com/horsewin/echo-playground-batch-task/internal/common/apperrors"
+	"github.com/horsewin/echo-playground-batch-task/internal/common/config"
+	"github.com/horsewin/echo-playground-batch-task/internal/common/redact"
+)
+
+// Bootstrap は各バッチ処理のエントリポイントで共通の初期化・実行・終了処理を担当します
+type Bootstrap struct {
+	Name        string
+	Config      *config.Config
+	TaskToken   string
+	Timeout     time.Duration
+	GracePeriod time.Duration
+	// SFN はStep Functionsへの進捗・失敗の通知に利用します。nilの場合は通知しません
+	SFN SFNClient
+}
+
+// InitOption はInitの動作を変更するオプションです
+type InitOption func(*initOptions)
+
+type initOptions struct {
+	taskTokenOptional func() bool
+}
+
+// WithOptionalTaskToken はcondがtrueを返す場合にタスクトークンを必須としないオプションです
+// condはコマンドライン引数のパース後に評価されます
+// キューから処理対象を受信する場合など、Step Functionsから起動されない実行モードで利用します
+func WithOptionalTaskToken(cond func() bool) InitOption {
+	return func(o *initOptions) {
+		o.taskTokenOptional = cond
+	}
+}
+
+// Init はコマンドライン引数・設定・ログのマスク・X-Rayを初期化します
+// 各バッチ処理で独自のフラグを定義する場合は、Initを呼び出す前に定義してください
+func Init(name string, opts ...InitOption) *Bootstrap {
+	var o initOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	// コマンドライン引数のパース
+	timeout := flag.Duration("timeout", 5*time.Minute, "バッチ処理のタイムアウト時間")
+	gracePeriod := flag.Duration("grace-period", 30*time.Second, "停止要求後に実行中の処理の完了を待つ時間")
+	resume := flag.String("resume", "", "中断したバッチ処理を再開する場合の実行ID")
+	flag.Parse()
+
+	// 最後の引数として渡されたタスクトークンを取得
+	// ENV=LOCALの場合はタスクトークンを取得しない
+	taskToken := "DUMMY_TASK_TOKEN"
+	if !IsLocal() {
+		taskToken = flag.Arg(len(flag.Args()) - 1)
+		if taskToken == "" && (o.taskTokenOptional == nil || !o.taskTokenOptional()) {
+			log.Fatalf("Task token is required")
+		}
+	}
+
+	// 設定の読み込み
+	cfg, err := config.LoadConfig(taskToken)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v\nStack trace:\n%s", err, debug.Stack())
+	}
+
+	// 実行IDの設定
+	// 再開する場合は再開元の実行IDを引き継ぎ、チェックポイントから処理を続ける
+	if *resume != "" {
+		cfg.Run.ID = *resume
+		cfg.Run.Resume = true
+		log.Printf("Resuming batch process. Run ID: %s", cfg.Run.ID)
+	} else {
+		cfg.Run.ID = NewRunID()
+		log.Printf("Starting batch process. Run ID: %s", cfg.Run.ID)
+	}
+
+	// ログ・トレース・タスク出力のマスク設定
+	redact.Configure(cfg.Redact.AllowFields)
+
+	// X-Ray設定
+	if cfg.EnableTracing {
+		if err := xray.Configure(xray.Config{
+			DaemonAddr:     "127.0.0.1:2000", // X-Rayデーモンのアドレス
+			ServiceVersion: "1.0.0",
+		}); err != nil {
+			log.Printf("Failed to configure X-Ray: %v", err)
+			// X-Ray設定失敗時はデフォルトの設定を使用
+			if configErr := xray.Configure(xray.Config{}); configErr != nil {
+				log.Fatalf("Failed to configure default X-Ray settings: %v", configErr)
+			}
+		}
+		os.Setenv("AWS_XRAY_CONTEXT_MISSING", "LOG_ERROR")
+	}
+
+	return &Bootstrap{
+		Name:        name,
+		Config:      cfg,
+		TaskToken:   taskToken,
+		Timeout:     *timeout,
+		GracePeriod: *gracePeriod,
+	}
+}
+
+// NewRunID は新しい実行IDを作成します
+// 実行IDは開始時刻とランダムな値から構成され、ファイル名としても利用できます
+func NewRunID() string {
+	return fmt.Sprintf("%s-%08x", time.Now().UTC().Format("20060102T150405Z"), rand.Uint32())
+}
+
+// FlagPassed は指定したフラグがコマンドライン引数で指定されたかを返します
+func FlagPassed(name string) bool {
+	passed := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			passed = true
+		}
+	})
+	return passed
+}
+
+// IsLocal はローカル環境(ENV=LOCAL)で実行されているかを返します
+func IsLocal() bool {
+	return os.Getenv("ENV") == "LOCAL"
+}
+
+// Start はX-Rayセグメントを開始したコンテキストを返します
+// 返却された関数でセグメントを終了してください
+func (b *Bootstrap) Start(ctx context.Context) (context.Context, func()) {
+	if !b.Config.EnableTracing {
+		return ctx, func() {}
+	}
+
+	ctx, seg := xray.BeginSegment(ctx, b.Name)
+
+	// セグメントにメタデータを追加
+	if err := seg.AddMetadata("task_token", redact.Field("task_token", b.TaskToken)); err != nil {
+		log.Printf("Failed to add task_token metadata: %v", err)
+	}
+	if err := seg.AddMetadata("timeout", b.Timeout.String()); err != nil {
+		log.Printf("Failed to add timeout metadata: %v", err)
+	}
+	if err := seg.AddMetadata("run_id", b.Config.Run.ID); err != nil {
+		log.Printf("Failed to add run_id metadata: %v", err)
+	}
+
+	return ctx, func() { seg.Close(nil) }
+}
+
+// Execute はタイムアウトとシグナルを監視しながらバッチ処理を実行します
+// 停止が要求された場合は、Step Functionsにハートビートを送信してグレース期間の間タスクを延命します
+func (b *Bootstrap) Execute(ctx context.Context, fn func(context.Context) error) Result {
+	return Run(ctx, Options{
+		Timeout:     b.Timeout,
+		GracePeriod: b.GracePeriod,
+		OnStop: func(reason StopReason) {
+			b.sendTaskHeartbeat(reason)
+		},
+	}, fn)
+}
+
+// Finish は実行結果をログに出力し、失敗した場合はStep Functionsに通知して終了コードを返します
+func (b *Bootstrap) Finish(result Result) int {
+	if result.Err == nil {
+		log.Println("Batch process completed successfully")
+		return ExitOK
+	}
+
+	log.Printf("Batch process failed [%s]: %s\nStack trace:\n%s",
+		apperrors.CodeOf(result.Err), redact.String(result.Err.Error()), debug.Stack())
+
+	// ローカル環境以外の場合のみStep Functionsのエラー通知を行う
+	b.sendTaskFailure(result.Err)
+
+	return result.ExitCode()
+}
@@ -0,0 +1,217 @@
+package job
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/horsewin/echo-playground-batch-task/internal/common/apperrors"
+)
+
+// 終了コード
+const (
+	// ExitOK は正常終了を表します
+	ExitOK = 0
+	// ExitFailure はバッチ処理の失敗を表します
+	ExitFailure = 1
+	// ExitTimeout はタイムアウトによる中断を表します (coreutilsのtimeoutコマンドに合わせています)
+	ExitTimeout = 124
+	// exitSignalBase はシグナルによる中断の終了コードの基準値です (128 + シグナル番号)
+	exitSignalBase = 128
+)
+
+// forceStopWait はグレース期間の経過後、処理の終了を待つ最大時間です
+const forceStopWait = 5 * time.Second
+
+// StopReason はバッチ処理が停止を要求された理由です
+type StopReason int
+
+const (
+	// StopNone は停止が要求されずに処理が終了したことを表します
+	StopNone StopReason = iota
+	// StopSignal はシグナルを受信したことを表します
+	StopSignal
+	// StopTimeout はタイムアウトしたことを表します
+	StopTimeout
+)
+
+// String は停止理由の名前を返します
+func (r StopReason) String() string {
+	switch r {
+	case StopSignal:
+		return "signal"
+	case StopTimeout:
+		return "timeout"
+	}
+	return "none"
+}
+
+// Options はバッチ処理の実行方法を表します
+type Options struct {
+	// Timeout はバッチ処理のタイムアウト時間です。0以下の場合はタイムアウトしません
+	Timeout time.Duration
+	// GracePeriod は停止要求後に実行中の処理の完了を待つ時間です
+	GracePeriod time.Duration
+	// Signals は停止要求として扱うシグナルです。nilの場合はSIGINTとSIGTERMを扱います
+	Signals []os.Signal
+	// OnStop は停止が要求されたときに呼び出されます
+	OnStop func(reason StopReason)
+}
+
+// Result はバッチ処理の実行結果です
+type Result struct {
+	Err    error
+	Reason StopReason
+	Signal os.Signal
+}
+
+// ExitCode は実行結果に対応するプロセスの終了コードを返します
+// 処理が停止要求の前に正常に完了した場合は、停止理由に関わらず正常終了とします
+func (r Result) ExitCode() int {
+	if r.Err == nil {
+		return ExitOK
+	}
+	switch r.Reason {
+	case StopSignal:
+		if sig, ok := r.Signal.(syscall.Signal); ok {
+			return exitSignalBase + int(sig)
+		}
+		return exitSignalBase + int(syscall.SIGTERM)
+	case StopTimeout:
+		return ExitTimeout
+	}
+	return ExitFailure
+}
+
+type stopKey struct{}
+
+// stopper は新しい処理の開始を止めるための通知です
+type stopper struct {
+	ch   chan struct{}
+	once sync.Once
+}
+
+func (s *stopper) stop() {
+	s.once.Do(func() { close(s.ch) })
+}
+
+// Stopping は停止が要求されているかを返します
+// バッチ処理は新しいデータの処理を始める前にこれを確認し、trueの場合は処理済みの進捗を返して終了してください
+// 実行中のトランザクションはグレース期間が終わるまでctxがキャンセルされないため、そのまま完了できます
+func Stopping(ctx context.Context) bool {
+	s, ok := ctx.Value(stopKey{}).(*stopper)
+	if !ok {
+		return false
+	}
+	select {
+	case <-s.ch:
+		return true
+	default:
+		return false
+	}
+}
+
+// WithStop は停止要求を通知できるコンテキストを返します
+// 返却された関数を呼び出すと、以降Stoppingはtrueを返します
+func WithStop(ctx context.Context) (context.Context, func()) {
+	st := &stopper{ch: make(chan struct{})}
+	return context.WithValue(ctx, stopKey{}, st), st.stop
+}
+
+// Run はシグナルとタイムアウトを監視しながらバッチ処理を実行します
+// 停止が要求された場合は、まずStoppingで新しい処理の開始を止め、グレース期間内に処理が終わるのを待ちます
+// グレース期間を過ぎた場合はctxをキャンセルし、処理が戻るのを待ってから終了します
+func Run(ctx context.Context, opts Options, fn func(context.Context) error) Result {
+	stopCtx, stop := WithStop(ctx)
+	workCtx, cancel := context.WithCancel(stopCtx)
+	defer cancel()
+
+	signals := opts.Signals
+	if signals == nil {
+		signals = []os.Signal{syscall.SIGINT, syscall.SIGTERM}
+	}
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, signals...)
+	defer signal.Stop(sigChan)
+
+	var timeoutChan <-chan time.Time
+	if opts.Timeout > 0 {
+		timer := time.NewTimer(opts.Timeout)
+		defer timer.Stop()
+		timeoutChan = timer.C
+	}
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- fn(workCtx)
+	}()
+
+	result := Result{}
+	select {
+	case err := <-errChan:
+		result.Err = err
+		return result
+	case sig := <-sigChan:
+		log.Printf("Received signal: %v. Waiting up to %v for in-flight work to finish", sig, opts.GracePeriod)
+		result.Reason = StopSignal
+		result.Signal = sig
+	case <-timeoutChan:
+		log.Printf("Batch process timed out after %v. Waiting up to %v for in-flight work to finish", opts.Timeout, opts.GracePeriod)
+		result.Reason = StopTimeout
+	}
+
+	stop()
+	if opts.OnStop != nil {
+		opts.OnStop(result.Reason)
+	}
+
+	grace := time.NewTimer(opts.GracePeriod)
+	defer grace.Stop()
+
+	var err error
+	select {
+	case err = <-errChan:
+	case <-grace.C:
+		log.Printf("Grace period of %v elapsed. Cancelling in-flight work", opts.GracePeriod)
+		cancel()
+		select {
+		case err = <-errChan:
+		case <-time.After(forceStopWait):
+			err = fmt.Errorf("batch process did not stop within %v after cancellation", forceStopWait)
+		}
+	}
+
+	result.Err = stopError(result, opts, err)
+	return result
+}
+
+// stopError は停止理由に応じたエラーを作成します
+// 処理が停止要求の前に完了していた場合はnilを返します
+// 処理が返したエラーに付加情報(進捗など)があれば引き継ぎます
+func stopError(result Result, opts Options, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var wrapped *apperrors.Error
+	switch result.Reason {
+	case StopSignal:
+		wrapped = apperrors.Interrupted("job.Run", fmt.Errorf("stopped by signal %v: %w", result.Signal, err))
+	case StopTimeout:
+		wrapped = apperrors.Timeout("job.Run", fmt.Errorf("batch process timed out after %v: %w", opts.Timeout, err))
+	default:
+		return err
+	}
+
+	var appErr *apperrors.Error
+	if errors.As(err, &appErr) {
+		wrapped.Details = appErr.Details
+	}
+	return wrapped
+}
@@ -0,0 +1,135 @@
+package job
+
+import (
+	"context"
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/horsewin/echo-playground-batch-task/internal/common/apperrors"
+)
+
+// drainingJob は停止要求を受けるまで処理を続け、停止後に進捗を返すテスト用のバッチ処理です
+func drainingJob(ctx context.Context) error {
+	for !Stopping(ctx) {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Millisecond):
+		}
+	}
+	return apperrors.Interrupted("drainingJob", errors.New("stopped")).
+		WithDetails(map[string]any{"processed": 3})
+}
+
+func TestRun_Completed(t *testing.T) {
+	result := Run(context.Background(), Options{Timeout: time.Second}, func(ctx context.Context) error {
+		return nil
+	})
+	if result.Err != nil || result.Reason != StopNone {
+		t.Fatalf("Run() = %+v, want success", result)
+	}
+	if got := result.ExitCode(); got != ExitOK {
+		t.Errorf("ExitCode() = %d, want %d", got, ExitOK)
+	}
+}
+
+func TestRun_Failure(t *testing.T) {
+	result := Run(context.Background(), Options{Timeout: time.Second}, func(ctx context.Context) error {
+		return apperrors.InvalidInput("test", errors.New("bad input"))
+	})
+	if !apperrors.Is(result.Err, apperrors.CodeInvalidInput) {
+		t.Fatalf("Run() error = %v, want %s", result.Err, apperrors.CodeInvalidInput)
+	}
+	if got := result.ExitCode(); got != ExitFailure {
+		t.Errorf("ExitCode() = %d, want %d", got, ExitFailure)
+	}
+}
+
+func TestRun_TimeoutDrainsInFlightWork(t *testing.T) {
+	result := Run(context.Background(), Options{
+		Timeout:     10 * time.Millisecond,
+		GracePeriod: time.Second,
+	}, drainingJob)
+
+	if result.Reason != StopTimeout {
+		t.Fatalf("Reason = %v, want %v", result.Reason, StopTimeout)
+	}
+	if !apperrors.Is(result.Err, apperrors.CodeTimeout) {
+		t.Fatalf("Run() error = %v, want %s", result.Err, apperrors.CodeTimeout)
+	}
+	if got := result.ExitCode(); got != ExitTimeout {
+		t.Errorf("ExitCode() = %d, want %d", got, ExitTimeout)
+	}
+
+	// 処理が返した進捗が引き継がれていること
+	var appErr *apperrors.Error
+	if !errors.As(result.Err, &appErr) || appErr.Details["processed"] != 3 {
+		t.Errorf("Run() error details = %v, want processed=3", appErr)
+	}
+}
+
+func TestRun_SignalDrainsInFlightWork(t *testing.T) {
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		if err := syscall.Kill(syscall.Getpid(), syscall.SIGUSR1); err != nil {
+			t.Errorf("failed to send signal: %v", err)
+		}
+	}()
+
+	var onStop StopReason
+	result := Run(context.Background(), Options{
+		Timeout:     time.Minute,
+		GracePeriod: time.Second,
+		Signals:     []os.Signal{syscall.SIGUSR1},
+		OnStop:      func(reason StopReason) { onStop = reason },
+	}, drainingJob)
+
+	if result.Reason != StopSignal || onStop != StopSignal {
+		t.Fatalf("Reason = %v, OnStop = %v, want %v", result.Reason, onStop, StopSignal)
+	}
+	if !apperrors.Is(result.Err, apperrors.CodeInterrupted) {
+		t.Fatalf("Run() error = %v, want %s", result.Err, apperrors.CodeInterrupted)
+	}
+	if got, want := result.ExitCode(), 128+int(syscall.SIGUSR1); got != want {
+		t.Errorf("ExitCode() = %d, want %d", got, want)
+	}
+}
+
+func TestRun_GracePeriodElapsedCancelsContext(t *testing.T) {
+	result := Run(context.Background(), Options{
+		Timeout:     10 * time.Millisecond,
+		GracePeriod: 10 * time.Millisecond,
+	}, func(ctx context.Context) error {
+		// 停止要求を無視して実行を続ける処理
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	if !apperrors.Is(result.Err, apperrors.CodeTimeout) {
+		t.Fatalf("Run() error = %v, want %s", result.Err, apperrors.CodeTimeout)
+	}
+	if !errors.Is(result.Err, context.Canceled) {
+		t.Errorf("Run() error = %v, want to wrap context.Canceled", result.Err)
+	}
+}
+
+func TestRun_CompletedDuringGracePeriod(t *testing.T) {
+	result := Run(context.Background(), Options{
+		Timeout:     10 * time.Millisecond,
+		GracePeriod: time.Second,
+	}, func(ctx context.Context) error {
+		// 停止要求を受けても実行中の処理を最後まで完了させる
+		time.Sleep(30 * time.Millisecond)
+		return nil
+	})
+
+	if result.Err != nil {
+		t.Fatalf("Run() error = %v, want nil", result.Err)
+	}
+	if got := result.ExitCode(); got != ExitOK {
+		t.Errorf("ExitCode() = %d, want %d", got, ExitOK)
+	}
+}
@@ -0,0 +1,79 @@
+package job
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sfn"
+	"github.com/horsewin/echo-playground-batch-task/internal/common/apperrors"
+)
+
+// callbackTimeout はStep Functionsへのコールバックのタイムアウト時間です
+// 停止要求やタイムアウトでバッチ処理のコンテキストがキャンセルされていても通知できるよう、独立したコンテキストを利用します
+const callbackTimeout = 30 * time.Second
+
+// SFNClient はタスクトークンを使ったStep Functionsへのコールバックを行うクライアントです
+type SFNClient interface {
+	SendTaskSuccess(ctx context.Context, params *sfn.SendTaskSuccessInput, optFns ...func(*sfn.Options)) (*sfn.SendTaskSuccessOutput, error)
+	SendTaskFailure(ctx context.Context, params *sfn.SendTaskFailureInput, optFns ...func(*sfn.Options)) (*sfn.SendTaskFailureOutput, error)
+	SendTaskHeartbeat(ctx context.Context, params *sfn.SendTaskHeartbeatInput, optFns ...func(*sfn.Options)) (*sfn.SendTaskHeartbeatOutput, error)
+}
+
+// NewSFNClient はStep Functionsのクライアントを作成します
+// ローカル環境の場合はStep Functionsを利用しないためnilを返します
+func NewSFNClient(ctx context.Context) (SFNClient, error) {
+	if IsLocal() {
+		return nil, nil
+	}
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return sfn.NewFromConfig(awsCfg), nil
+}
+
+// sendTaskHeartbeat は停止要求を受けたことをStep Functionsに通知します
+func (b *Bootstrap) sendTaskHeartbeat(reason StopReason) {
+	if IsLocal() || b.SFN == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), callbackTimeout)
+	defer cancel()
+
+	input := &sfn.SendTaskHeartbeatInput{
+		TaskToken: aws.String(b.TaskToken),
+	}
+	if _, err := b.SFN.SendTaskHeartbeat(ctx, input); err != nil {
+		log.Printf("Failed to send task heartbeat on %s: %v", reason, err)
+	}
+}
+
+// sendTaskFailure はエラー種別と進捗を含めてStep Functionsにタスクの失敗を通知します
+// エラー種別をErrorに設定し、ステートマシン側でRetry/Catchを判断できるようにする
+func (b *Bootstrap) sendTaskFailure(err error) {
+	if IsLocal() || b.SFN == nil {
+		return
+	}
+
+	sfnError, sfnCause := apperrors.StepFunctionsError(err)
+	input := &sfn.SendTaskFailureInput{
+		TaskToken: aws.String(b.TaskToken),
+		Error:     aws.String(sfnError),
+		Cause:     aws.String(sfnCause),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), callbackTimeout)
+	defer cancel()
+
+	err = b.Config.Retry.Do(ctx, "SendTaskFailure", func(ctx context.Context) error {
+		_, err := b.SFN.SendTaskFailure(ctx, input)
+		return err
+	})
+	if err != nil {
+		log.Printf("Failed to send task failure: %v", err)
+	}
+}
@@ -0,0 +1,90 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/horsewin/echo-playground-batch-task/internal/model"
+)
+
+// SMTPConfig はメール配信に利用するSMTPサーバーの設定です
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	UserName string
+	Password string
+	From     string
+}
+
+// EmailNotifier はSMTPで通知をメール配信します
+type EmailNotifier struct {
+	cfg SMTPConfig
+	// sendMail はテストで差し替えられるようにしています
+	sendMail func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+// NewEmailNotifier は新しいEmailNotifierを作成します
+func NewEmailNotifier(cfg SMTPConfig) *EmailNotifier {
+	return &EmailNotifier{cfg: cfg, sendMail: smtp.SendMail}
+}
+
+// Channel は配信チャネルを返します
+func (n *EmailNotifier) Channel() model.NotificationChannel {
+	return model.NotificationChannelEmail
+}
+
+// Notify は通知をメールで送信します
+func (n *EmailNotifier) Notify(ctx context.Context, msg Message) error {
+	to, err := mail.ParseAddress(msg.Address)
+	if err != nil {
+		return permanent("invalid email address: %v", err)
+	}
+
+	// SMTPの認証はユーザー名が設定されている場合のみ行う
+	var auth smtp.Auth
+	if n.cfg.UserName != "" {
+		auth = smtp.PlainAuth("", n.cfg.UserName, n.cfg.Password, n.cfg.Host)
+	}
+
+	addr := net.JoinHostPort(n.cfg.Host, strconv.Itoa(n.cfg.Port))
+	body := n.buildMessage(to.Address, msg)
+
+	// net/smtpはコンテキストに対応していないため、キャンセルされた場合は結果を待たずに返す
+	done := make(chan error, 1)
+	go func() {
+		done <- n.sendMail(addr, auth, n.cfg.From, []string{to.Address}, body)
+	}()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("failed to send email: %w", err)
+		}
+		return nil
+	}
+}
+
+// buildMessage はメールのヘッダーと本文を作成します
+// 件名と本文は日本語を含むためUTF-8でエンコードします
+func (n *EmailNotifier) buildMessage(to string, msg Message) []byte {
+	var b strings.Builder
+	b.WriteString("From: " + n.cfg.From + "\r\n")
+	b.WriteString("To: " + to + "\r\n")
+	b.WriteString("Subject: " + mime.BEncoding.Encode("UTF-8", msg.Title) + "\r\n")
+	b.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	b.WriteString("\r\n")
+	return []byte(b.String())
+}
@@ -0,0 +1,49 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/horsewin/echo-playground-batch-task/internal/model"
+)
+
+// ErrPermanent は再試行しても成功しない配信の失敗を表します (宛先が不正など)
+var ErrPermanent = errors.New("permanent delivery failure")
+
+// Message は外部に配信する通知の内容です
+type Message struct {
+	NotificationID int
+	UserID         string
+	Type           model.NotificationType
+	Title          string
+	Body           string
+	CreatedAt      time.Time
+	// Address は配信先です (メールアドレス、WebhookのURL、プッシュ通知のデバイストークン)
+	Address string
+}
+
+// NewMessage は配信状況から配信する通知の内容を作成します
+func NewMessage(d model.NotificationDelivery) Message {
+	return Message{
+		NotificationID: d.NotificationID,
+		UserID:         d.UserID,
+		Type:           d.Type,
+		Title:          d.Title,
+		Body:           d.Message,
+		CreatedAt:      d.CreatedAt,
+		Address:        d.Address,
+	}
+}
+
+// Notifier は通知を外部のチャネルに配信するインターフェースです
+type Notifier interface {
+	Channel() model.NotificationChannel
+	Notify(ctx context.Context, msg Message) error
+}
+
+// permanent は再試行しても成功しないエラーとしてラップします
+func permanent(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", ErrPermanent, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,287 @@
+package notifier
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/horsewin/echo-playground-batch-task/internal/model"
+)
+
+// smtpSink はテスト用のSMTPサーバーで、受信したメールを保持します
+type smtpSink struct {
+	listener net.Listener
+	mu       sync.Mutex
+	mails    []sinkMail
+}
+
+type sinkMail struct {
+	from string
+	to   []string
+	data string
+}
+
+func newSMTPSink(t *testing.T) *smtpSink {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	s := &smtpSink{listener: l}
+	t.Cleanup(func() { l.Close() })
+	go s.serve()
+	return s
+}
+
+func (s *smtpSink) config() SMTPConfig {
+	host, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	p, _ := strconv.Atoi(port)
+	return SMTPConfig{Host: host, Port: p, From: "noreply@example.com"}
+}
+
+func (s *smtpSink) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *smtpSink) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+
+	reply("220 sink ESMTP")
+	var mail sinkMail
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.TrimRight(line, "\r\n")
+		switch upper := strings.ToUpper(cmd); {
+		case strings.HasPrefix(upper, "EHLO"), strings.HasPrefix(upper, "HELO"):
+			reply("250 sink")
+		case strings.HasPrefix(upper, "MAIL FROM:"):
+			mail = sinkMail{from: strings.Trim(cmd[len("MAIL FROM:"):], "<>")}
+			reply("250 OK")
+		case strings.HasPrefix(upper, "RCPT TO:"):
+			to := strings.Trim(cmd[len("RCPT TO:"):], "<>")
+			if strings.HasSuffix(to, "@rejected.example.com") {
+				reply("550 no such user")
+				continue
+			}
+			mail.to = append(mail.to, to)
+			reply("250 OK")
+		case upper == "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(l)
+			}
+			mail.data = data.String()
+			s.mu.Lock()
+			s.mails = append(s.mails, mail)
+			s.mu.Unlock()
+			reply("250 OK")
+		case upper == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func (s *smtpSink) received() []sinkMail {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]sinkMail(nil), s.mails...)
+}
+
+func testMessage(address string) Message {
+	return Message{
+		NotificationID: 1,
+		UserID:         "user-1",
+		Type:           model.NotificationTypeReservation,
+		Title:          "予約が確定しました",
+		Body:           "ポチの予約が確定しました",
+		CreatedAt:      time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		Address:        address,
+	}
+}
+
+func TestEmailNotifier(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("SMTPサーバーにメールを送信する", func(t *testing.T) {
+		sink := newSMTPSink(t)
+		n := NewEmailNotifier(sink.config())
+
+		if err := n.Notify(ctx, testMessage("taro@example.com")); err != nil {
+			t.Fatalf("Notify() error = %v", err)
+		}
+
+		mails := sink.received()
+		if len(mails) != 1 {
+			t.Fatalf("received = %d, want 1", len(mails))
+		}
+		if mails[0].from != "noreply@example.com" || len(mails[0].to) != 1 || mails[0].to[0] != "taro@example.com" {
+			t.Errorf("envelope = %+v", mails[0])
+		}
+		// 件名はUTF-8でBエンコードされる
+		if !strings.Contains(mails[0].data, "Subject: =?UTF-8?b?") {
+			t.Errorf("subject is not encoded: %q", mails[0].data)
+		}
+		if !strings.Contains(mails[0].data, "ポチの予約が確定しました") {
+			t.Errorf("body not found: %q", mails[0].data)
+		}
+	})
+
+	t.Run("不正なメールアドレスは再試行しないエラーになる", func(t *testing.T) {
+		sink := newSMTPSink(t)
+		n := NewEmailNotifier(sink.config())
+
+		err := n.Notify(ctx, testMessage("not-an-address"))
+		if !errors.Is(err, ErrPermanent) {
+			t.Errorf("Notify() error = %v, want ErrPermanent", err)
+		}
+		if len(sink.received()) != 0 {
+			t.Errorf("mail should not be sent")
+		}
+	})
+
+	t.Run("SMTPサーバーが受け付けない場合はエラーになる", func(t *testing.T) {
+		sink := newSMTPSink(t)
+		n := NewEmailNotifier(sink.config())
+
+		if err := n.Notify(ctx, testMessage("taro@rejected.example.com")); err == nil {
+			t.Errorf("Notify() error = nil, want error")
+		}
+	})
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	ctx := context.Background()
+	secret := "webhook-secret"
+
+	t.Run("HMACで署名した通知を送信する", func(t *testing.T) {
+		var payload WebhookPayload
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			want := "sha256=" + Sign([]byte(secret), r.Header.Get(TimestampHeader), body)
+			if r.Header.Get(SignatureHeader) != want {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			json.Unmarshal(body, &payload)
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer server.Close()
+
+		n := NewWebhookNotifier(secret, time.Second)
+		if err := n.Notify(ctx, testMessage(server.URL)); err != nil {
+			t.Fatalf("Notify() error = %v", err)
+		}
+		if payload.NotificationID != 1 || payload.Title != "予約が確定しました" {
+			t.Errorf("payload = %+v", payload)
+		}
+	})
+
+	t.Run("異なるシークレットの署名は検証に失敗する", func(t *testing.T) {
+		body := []byte(`{"notification_id":1}`)
+		if Sign([]byte(secret), "1700000000", body) == Sign([]byte("other"), "1700000000", body) {
+			t.Errorf("signatures with different secrets must differ")
+		}
+		if Sign([]byte(secret), "1700000000", body) == Sign([]byte(secret), "1700000001", body) {
+			t.Errorf("signatures with different timestamps must differ")
+		}
+	})
+
+	tests := []struct {
+		name          string
+		status        int
+		wantErr       bool
+		wantPermanent bool
+	}{
+		{name: "2xxは成功", status: http.StatusOK},
+		{name: "4xxは再試行しないエラー", status: http.StatusNotFound, wantErr: true, wantPermanent: true},
+		{name: "429は再試行するエラー", status: http.StatusTooManyRequests, wantErr: true},
+		{name: "5xxは再試行するエラー", status: http.StatusBadGateway, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			err := NewWebhookNotifier(secret, time.Second).Notify(ctx, testMessage(server.URL))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Notify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if errors.Is(err, ErrPermanent) != tt.wantPermanent {
+				t.Errorf("Notify() permanent = %v, want %v", errors.Is(err, ErrPermanent), tt.wantPermanent)
+			}
+		})
+	}
+
+	t.Run("不正なURLは再試行しないエラーになる", func(t *testing.T) {
+		err := NewWebhookNotifier(secret, time.Second).Notify(ctx, testMessage("ftp://example.com"))
+		if !errors.Is(err, ErrPermanent) {
+			t.Errorf("Notify() error = %v, want ErrPermanent", err)
+		}
+	})
+}
+
+func TestPushNotifier(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("デバイストークン宛てにゲートウェイへ送信する", func(t *testing.T) {
+		var got PushRequest
+		var auth string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			auth = r.Header.Get("Authorization")
+			json.NewDecoder(r.Body).Decode(&got)
+		}))
+		defer server.Close()
+
+		n := NewPushNotifier(server.URL, "api-key", time.Second)
+		if err := n.Notify(ctx, testMessage("device-token")); err != nil {
+			t.Fatalf("Notify() error = %v", err)
+		}
+		if auth != "Bearer api-key" {
+			t.Errorf("Authorization = %q", auth)
+		}
+		if got.To != "device-token" || got.Notification.Title != "予約が確定しました" || got.Data["notification_id"] != "1" {
+			t.Errorf("request = %+v", got)
+		}
+	})
+
+	t.Run("デバイストークンが空の場合は再試行しないエラーになる", func(t *testing.T) {
+		err := NewPushNotifier("http://127.0.0.1:0", "", time.Second).Notify(ctx, testMessage(""))
+		if !errors.Is(err, ErrPermanent) {
+			t.Errorf("Notify() error = %v, want ErrPermanent", err)
+		}
+	})
+}
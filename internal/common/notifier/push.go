@@ -0,0 +1,88 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/horsewin/echo-playground-batch-task/internal/model"
+)
+
+// PushRequest はプッシュ通知のゲートウェイに送信する本文です
+type PushRequest struct {
+	// To は配信先のデバイストークンです
+	To           string            `json:"to"`
+	Notification PushNotification  `json:"notification"`
+	Data         map[string]string `json:"data,omitempty"`
+}
+
+// PushNotification は端末に表示する内容です
+type PushNotification struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+}
+
+// PushNotifier はプッシュ通知のゲートウェイ(HTTP API)を経由して通知を配信します
+type PushNotifier struct {
+	client   *http.Client
+	endpoint string
+	apiKey   string
+}
+
+// NewPushNotifier は新しいPushNotifierを作成します
+func NewPushNotifier(endpoint, apiKey string, timeout time.Duration) *PushNotifier {
+	return &PushNotifier{
+		client:   &http.Client{Timeout: timeout},
+		endpoint: endpoint,
+		apiKey:   apiKey,
+	}
+}
+
+// Channel は配信チャネルを返します
+func (n *PushNotifier) Channel() model.NotificationChannel {
+	return model.NotificationChannelPush
+}
+
+// Notify は通知をデバイストークン宛てにプッシュ通知します
+func (n *PushNotifier) Notify(ctx context.Context, msg Message) error {
+	if msg.Address == "" {
+		return permanent("device token is empty")
+	}
+
+	body, err := json.Marshal(PushRequest{
+		To: msg.Address,
+		Notification: PushNotification{
+			Title: msg.Title,
+			Body:  msg.Body,
+		},
+		Data: map[string]string{
+			"notification_id": fmt.Sprint(msg.NotificationID),
+			"type":            string(msg.Type),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal push request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create push request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if n.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+n.apiKey)
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send push notification: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+
+	return checkStatus("push gateway", resp.StatusCode)
+}
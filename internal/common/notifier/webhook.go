@@ -0,0 +1,117 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/horsewin/echo-playground-batch-task/internal/model"
+)
+
+// Webhookの署名に利用するヘッダー
+const (
+	// SignatureHeader は "sha256=<HMAC-SHA256の16進数>" 形式の署名を設定するヘッダーです
+	SignatureHeader = "X-Signature"
+	// TimestampHeader は署名に含めた送信時刻(UNIX秒)を設定するヘッダーです
+	TimestampHeader = "X-Timestamp"
+)
+
+// WebhookPayload はWebhookで送信する本文です
+type WebhookPayload struct {
+	NotificationID int                    `json:"notification_id"`
+	UserID         string                 `json:"user_id"`
+	Type           model.NotificationType `json:"type"`
+	Title          string                 `json:"title"`
+	Message        string                 `json:"message"`
+	CreatedAt      time.Time              `json:"created_at"`
+}
+
+// WebhookNotifier はHMACで署名した通知をHTTP POSTで配信します
+type WebhookNotifier struct {
+	client *http.Client
+	secret []byte
+	now    func() time.Time
+}
+
+// NewWebhookNotifier は新しいWebhookNotifierを作成します
+func NewWebhookNotifier(secret string, timeout time.Duration) *WebhookNotifier {
+	return &WebhookNotifier{
+		client: &http.Client{Timeout: timeout},
+		secret: []byte(secret),
+		now:    time.Now,
+	}
+}
+
+// Channel は配信チャネルを返します
+func (n *WebhookNotifier) Channel() model.NotificationChannel {
+	return model.NotificationChannelWebhook
+}
+
+// Notify は通知を配信先のURLにPOSTします
+// 2xx以外のレスポンスはエラーとし、4xx(429を除く)は再試行しても成功しないエラーとして扱います
+func (n *WebhookNotifier) Notify(ctx context.Context, msg Message) error {
+	target, err := url.Parse(msg.Address)
+	if err != nil || (target.Scheme != "https" && target.Scheme != "http") || target.Host == "" {
+		return permanent("invalid webhook url")
+	}
+
+	body, err := json.Marshal(WebhookPayload{
+		NotificationID: msg.NotificationID,
+		UserID:         msg.UserID,
+		Type:           msg.Type,
+		Title:          msg.Title,
+		Message:        msg.Body,
+		CreatedAt:      msg.CreatedAt,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook payload: %w", err)
+	}
+
+	timestamp := strconv.FormatInt(n.now().Unix(), 10)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target.String(), bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, "sha256="+Sign(n.secret, timestamp, body))
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+
+	return checkStatus("webhook", resp.StatusCode)
+}
+
+// Sign はタイムスタンプと本文からWebhookの署名を作成します
+// 受信側は "<timestamp>.<body>" のHMAC-SHA256を計算して署名を検証してください
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// checkStatus はHTTPのステータスコードを配信結果に変換します
+func checkStatus(name string, status int) error {
+	switch {
+	case status >= 200 && status < 300:
+		return nil
+	case status >= 400 && status < 500 && status != http.StatusTooManyRequests:
+		return permanent("%s responded with status %d", name, status)
+	}
+	return fmt.Errorf("%s responded with status %d", name, status)
+}
@@ -0,0 +1,281 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// MaxBatchSize はSQSのバッチAPIで1回に扱えるメッセージの最大件数です
+const MaxBatchSize = 10
+
+// maxWaitTime はSQSのロングポーリングの最大待機時間です
+const maxWaitTime = 20 * time.Second
+
+// Message はキューから受信したメッセージです
+type Message struct {
+	ID   string
+	Body string
+	// ReceiptHandle は削除に利用する受信ごとのハンドルです
+	ReceiptHandle string
+	// ReceiveCount はこのメッセージを受信した回数です
+	// 上限を超えるとデッドレターキューに移動されます
+	ReceiveCount int
+}
+
+// Sender はキューへのメッセージの送信を担当するインターフェースです
+type Sender interface {
+	Send(ctx context.Context, bodies []string) error
+}
+
+// Receiver はキューからのメッセージの受信と削除を担当するインターフェースです
+// 受信したメッセージは削除されるまで可視性タイムアウトの間他の受信者から見えなくなり、その後再度受信されます
+type Receiver interface {
+	Receive(ctx context.Context, limit int, wait time.Duration) ([]Message, error)
+	Delete(ctx context.Context, receiptHandles []string) error
+}
+
+// SQSClient はSQSQueueが利用するSQSのAPIです
+type SQSClient interface {
+	SendMessageBatch(ctx context.Context, params *sqs.SendMessageBatchInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageBatchOutput, error)
+	ReceiveMessage(ctx context.Context, params *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error)
+	DeleteMessageBatch(ctx context.Context, params *sqs.DeleteMessageBatchInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageBatchOutput, error)
+}
+
+// SQSQueue はSQS(またはElasticMQなどのSQS互換のキュー)を利用したキューです
+// 接続先はAWS_ENDPOINT_URL_SQSで変更できます
+type SQSQueue struct {
+	client SQSClient
+	url    string
+}
+
+// NewSQSQueue はデフォルトのAWS設定でSQSQueueを作成します
+func NewSQSQueue(ctx context.Context, url string) (*SQSQueue, error) {
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	return NewSQSQueueWithClient(sqs.NewFromConfig(awsCfg), url), nil
+}
+
+// NewSQSQueueWithClient は指定したクライアントでSQSQueueを作成します
+func NewSQSQueueWithClient(client SQSClient, url string) *SQSQueue {
+	return &SQSQueue{client: client, url: url}
+}
+
+// Send はメッセージを10件ずつまとめて送信します
+// 一部のメッセージの送信に失敗した場合はエラーを返します。呼び出し側で全件を再送してください
+func (q *SQSQueue) Send(ctx context.Context, bodies []string) error {
+	for start := 0; start < len(bodies); start += MaxBatchSize {
+		batch := bodies[start:min(start+MaxBatchSize, len(bodies))]
+
+		entries := make([]types.SendMessageBatchRequestEntry, len(batch))
+		for i, body := range batch {
+			entries[i] = types.SendMessageBatchRequestEntry{
+				Id:          aws.String(strconv.Itoa(start + i)),
+				MessageBody: aws.String(body),
+			}
+		}
+
+		output, err := q.client.SendMessageBatch(ctx, &sqs.SendMessageBatchInput{
+			QueueUrl: aws.String(q.url),
+			Entries:  entries,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to send messages: %w", err)
+		}
+		if len(output.Failed) > 0 {
+			failed := output.Failed[0]
+			return fmt.Errorf("failed to send %d of %d messages: %s: %s",
+				len(output.Failed), len(batch), aws.ToString(failed.Code), aws.ToString(failed.Message))
+		}
+	}
+	return nil
+}
+
+// Receive は最大limit件(10件まで)のメッセージを受信します
+// メッセージがない場合はwaitの間(20秒まで)待機します
+func (q *SQSQueue) Receive(ctx context.Context, limit int, wait time.Duration) ([]Message, error) {
+	output, err := q.client.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+		QueueUrl:            aws.String(q.url),
+		MaxNumberOfMessages: int32(min(max(limit, 1), MaxBatchSize)),
+		WaitTimeSeconds:     int32(min(wait, maxWaitTime) / time.Second),
+		MessageSystemAttributeNames: []types.MessageSystemAttributeName{
+			types.MessageSystemAttributeNameApproximateReceiveCount,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to receive messages: %w", err)
+	}
+
+	messages := make([]Message, len(output.Messages))
+	for i, m := range output.Messages {
+		count, _ := strconv.Atoi(m.Attributes[string(types.MessageSystemAttributeNameApproximateReceiveCount)])
+		messages[i] = Message{
+			ID:            aws.ToString(m.MessageId),
+			Body:          aws.ToString(m.Body),
+			ReceiptHandle: aws.ToString(m.ReceiptHandle),
+			ReceiveCount:  count,
+		}
+	}
+	return messages, nil
+}
+
+// Delete は処理が完了したメッセージを10件ずつまとめて削除します
+func (q *SQSQueue) Delete(ctx context.Context, receiptHandles []string) error {
+	for start := 0; start < len(receiptHandles); start += MaxBatchSize {
+		batch := receiptHandles[start:min(start+MaxBatchSize, len(receiptHandles))]
+
+		entries := make([]types.DeleteMessageBatchRequestEntry, len(batch))
+		for i, handle := range batch {
+			entries[i] = types.DeleteMessageBatchRequestEntry{
+				Id:            aws.String(strconv.Itoa(start + i)),
+				ReceiptHandle: aws.String(handle),
+			}
+		}
+
+		output, err := q.client.DeleteMessageBatch(ctx, &sqs.DeleteMessageBatchInput{
+			QueueUrl: aws.String(q.url),
+			Entries:  entries,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to delete messages: %w", err)
+		}
+		if len(output.Failed) > 0 {
+			failed := output.Failed[0]
+			return fmt.Errorf("failed to delete %d of %d messages: %s: %s",
+				len(output.Failed), len(batch), aws.ToString(failed.Code), aws.ToString(failed.Message))
+		}
+	}
+	return nil
+}
+
+// MemoryQueueOptions はMemoryQueueの動作を表します
+type MemoryQueueOptions struct {
+	// VisibilityTimeout は受信したメッセージが再度受信できるようになるまでの時間です
+	VisibilityTimeout time.Duration
+	// MaxReceiveCount はデッドレターキューに移動するまでの最大受信回数です。0以下の場合は移動しません
+	MaxReceiveCount int
+	// DeadLetterQueue は最大受信回数を超えたメッセージの移動先です
+	DeadLetterQueue *MemoryQueue
+}
+
+type memoryMessage struct {
+	Message
+	visibleAt time.Time
+}
+
+// MemoryQueue はメモリ上のSQS互換のキューです
+// ローカル環境での実行やテストでSQSの代わりに利用します
+type MemoryQueue struct {
+	mu       sync.Mutex
+	opts     MemoryQueueOptions
+	messages []*memoryMessage
+	nextID   int
+}
+
+// NewMemoryQueue は新しいMemoryQueueを作成します
+func NewMemoryQueue() *MemoryQueue {
+	return NewMemoryQueueWithOptions(MemoryQueueOptions{})
+}
+
+// NewMemoryQueueWithOptions は指定した動作のMemoryQueueを作成します
+func NewMemoryQueueWithOptions(opts MemoryQueueOptions) *MemoryQueue {
+	return &MemoryQueue{opts: opts}
+}
+
+// Send はメッセージをキューに追加します
+func (q *MemoryQueue) Send(ctx context.Context, bodies []string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, body := range bodies {
+		q.nextID++
+		q.messages = append(q.messages, &memoryMessage{
+			Message: Message{ID: strconv.Itoa(q.nextID), Body: body},
+		})
+	}
+	return nil
+}
+
+// Receive は受信可能なメッセージを最大limit件受信します
+// 受信可能なメッセージがない場合は、waitの間待ってから再度受信します
+func (q *MemoryQueue) Receive(ctx context.Context, limit int, wait time.Duration) ([]Message, error) {
+	if messages := q.receive(limit); len(messages) > 0 || wait <= 0 {
+		return messages, nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-timer.C:
+	}
+	return q.receive(limit), nil
+}
+
+func (q *MemoryQueue) receive(limit int) []Message {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := time.Now()
+	var received []Message
+	remaining := q.messages[:0]
+	for _, m := range q.messages {
+		if len(received) >= limit || m.visibleAt.After(now) {
+			remaining = append(remaining, m)
+			continue
+		}
+
+		// 最大受信回数を超えたメッセージはデッドレターキューに移動する
+		if q.opts.MaxReceiveCount > 0 && m.ReceiveCount >= q.opts.MaxReceiveCount {
+			if q.opts.DeadLetterQueue != nil {
+				q.opts.DeadLetterQueue.Send(context.Background(), []string{m.Body})
+			}
+			continue
+		}
+
+		m.ReceiveCount++
+		m.ReceiptHandle = fmt.Sprintf("%s-%d", m.ID, m.ReceiveCount)
+		m.visibleAt = now.Add(q.opts.VisibilityTimeout)
+		received = append(received, m.Message)
+		remaining = append(remaining, m)
+	}
+	q.messages = remaining
+	return received
+}
+
+// Delete はメッセージを削除します
+// 再度受信された後の古いハンドルは無視されます
+func (q *MemoryQueue) Delete(ctx context.Context, receiptHandles []string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	remaining := q.messages[:0]
+	for _, m := range q.messages {
+		if m.ReceiptHandle == "" || !slices.Contains(receiptHandles, m.ReceiptHandle) {
+			remaining = append(remaining, m)
+		}
+	}
+	q.messages = remaining
+	return nil
+}
+
+// Messages はキューに残っているメッセージの本文を返します
+func (q *MemoryQueue) Messages() []string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	bodies := make([]string, len(q.messages))
+	for i, m := range q.messages {
+		bodies[i] = m.Body
+	}
+	return bodies
+}
@@ -0,0 +1,140 @@
+package queue
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+func TestMemoryQueue(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("削除しないメッセージは可視性タイムアウト後に再度受信される", func(t *testing.T) {
+		q := NewMemoryQueueWithOptions(MemoryQueueOptions{VisibilityTimeout: time.Hour})
+		q.Send(ctx, []string{"a", "b"})
+
+		first, _ := q.Receive(ctx, 10, 0)
+		if len(first) != 2 {
+			t.Fatalf("received = %d, want 2", len(first))
+		}
+		// 可視性タイムアウト中は受信されない
+		if again, _ := q.Receive(ctx, 10, 0); len(again) != 0 {
+			t.Errorf("received during visibility timeout = %d, want 0", len(again))
+		}
+
+		q.Delete(ctx, []string{first[0].ReceiptHandle})
+		if got := q.Messages(); !slices.Equal(got, []string{"b"}) {
+			t.Errorf("messages = %v, want [b]", got)
+		}
+	})
+
+	t.Run("最大受信回数を超えたメッセージはデッドレターキューに移動する", func(t *testing.T) {
+		dlq := NewMemoryQueue()
+		q := NewMemoryQueueWithOptions(MemoryQueueOptions{MaxReceiveCount: 2, DeadLetterQueue: dlq})
+		q.Send(ctx, []string{"poison"})
+
+		for i := 1; i <= 2; i++ {
+			messages, _ := q.Receive(ctx, 10, 0)
+			if len(messages) != 1 || messages[0].ReceiveCount != i {
+				t.Fatalf("receive %d = %+v, want receive count %d", i, messages, i)
+			}
+		}
+		if messages, _ := q.Receive(ctx, 10, 0); len(messages) != 0 {
+			t.Errorf("received after max receive count = %d, want 0", len(messages))
+		}
+		if got := dlq.Messages(); !slices.Equal(got, []string{"poison"}) {
+			t.Errorf("dead letter messages = %v, want [poison]", got)
+		}
+	})
+
+	t.Run("再度受信された後の古いハンドルでは削除されない", func(t *testing.T) {
+		q := NewMemoryQueue()
+		q.Send(ctx, []string{"a"})
+		first, _ := q.Receive(ctx, 10, 0)
+		q.Receive(ctx, 10, 0)
+
+		q.Delete(ctx, []string{first[0].ReceiptHandle})
+		if len(q.Messages()) != 1 {
+			t.Errorf("messages = %v, want 1 message", q.Messages())
+		}
+	})
+
+	t.Run("キューが空の場合は待機時間だけ待つ", func(t *testing.T) {
+		q := NewMemoryQueue()
+		start := time.Now()
+		messages, err := q.Receive(ctx, 10, 20*time.Millisecond)
+		if err != nil || len(messages) != 0 {
+			t.Fatalf("Receive() = %v, %v, want no messages", messages, err)
+		}
+		if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+			t.Errorf("elapsed = %v, want >= 20ms", elapsed)
+		}
+	})
+}
+
+// fakeSQSClient はSQSのAPIの呼び出しを記録するテスト用のクライアントです
+type fakeSQSClient struct {
+	sendBatches   [][]types.SendMessageBatchRequestEntry
+	deleteBatches [][]types.DeleteMessageBatchRequestEntry
+	received      *sqs.ReceiveMessageInput
+}
+
+func (f *fakeSQSClient) SendMessageBatch(ctx context.Context, params *sqs.SendMessageBatchInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageBatchOutput, error) {
+	f.sendBatches = append(f.sendBatches, params.Entries)
+	return &sqs.SendMessageBatchOutput{}, nil
+}
+
+func (f *fakeSQSClient) ReceiveMessage(ctx context.Context, params *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error) {
+	f.received = params
+	return &sqs.ReceiveMessageOutput{
+		Messages: []types.Message{{
+			MessageId:     aws.String("m1"),
+			Body:          aws.String("body"),
+			ReceiptHandle: aws.String("handle"),
+			Attributes:    map[string]string{"ApproximateReceiveCount": "3"},
+		}},
+	}, nil
+}
+
+func (f *fakeSQSClient) DeleteMessageBatch(ctx context.Context, params *sqs.DeleteMessageBatchInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageBatchOutput, error) {
+	f.deleteBatches = append(f.deleteBatches, params.Entries)
+	return &sqs.DeleteMessageBatchOutput{}, nil
+}
+
+func TestSQSQueue(t *testing.T) {
+	ctx := context.Background()
+	client := &fakeSQSClient{}
+	q := NewSQSQueueWithClient(client, "http://localhost:9324/queue/notifications")
+
+	bodies := make([]string, 23)
+	if err := q.Send(ctx, bodies); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if len(client.sendBatches) != 3 || len(client.sendBatches[2]) != 3 {
+		t.Errorf("send batches = %d, want 3 batches (10, 10, 3)", len(client.sendBatches))
+	}
+
+	messages, err := q.Receive(ctx, 50, time.Minute)
+	if err != nil {
+		t.Fatalf("Receive() error = %v", err)
+	}
+	if client.received.MaxNumberOfMessages != 10 || client.received.WaitTimeSeconds != 20 {
+		t.Errorf("receive input = %d messages, %d seconds, want 10 messages, 20 seconds",
+			client.received.MaxNumberOfMessages, client.received.WaitTimeSeconds)
+	}
+	if len(messages) != 1 || messages[0].ReceiveCount != 3 || messages[0].ReceiptHandle != "handle" {
+		t.Errorf("messages = %+v", messages)
+	}
+
+	if err := q.Delete(ctx, make([]string, 11)); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if len(client.deleteBatches) != 2 {
+		t.Errorf("delete batches = %d, want 2", len(client.deleteBatches))
+	}
+}
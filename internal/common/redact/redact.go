@@ -0,0 +1,205 @@
+package redact
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"unicode/utf8"
+)
+
+// Mask はマスク済みの値を表す文字列です
+const Mask = "***"
+
+// sensitiveFields はデフォルトでマスク対象となるフィールド名です
+// 許可リストに含まれるフィールドはマスクされません
+var sensitiveFields = map[string]fieldKind{
+	"email":       kindEmail,
+	"user_name":   kindName,
+	"username":    kindName,
+	"name":        kindName,
+	"task_token":  kindSecret,
+	"token":       kindSecret,
+	"password":    kindSecret,
+	"db_password": kindSecret,
+}
+
+type fieldKind int
+
+const (
+	kindEmail fieldKind = iota + 1
+	kindName
+	kindSecret
+)
+
+var (
+	emailPattern    = regexp.MustCompile(`([A-Za-z0-9._%+\-])[A-Za-z0-9._%+\-]*@([A-Za-z0-9.\-]+\.[A-Za-z]{2,})`)
+	passwordPattern = regexp.MustCompile(`(?i)(password\s*[=:]\s*)("[^"]*"|'[^']*'|\S+)`)
+	// Step Functionsのタスクトークンのような長い不透明な文字列
+	tokenPattern      = regexp.MustCompile(`[A-Za-z0-9+/=_\-]{64,}`)
+	sqlLiteralPattern = regexp.MustCompile(`'(?:[^']|'')*'`)
+)
+
+// Redactor はログ・トレース・タスク出力に含まれる個人情報や秘匿情報をマスクします
+type Redactor struct {
+	allow map[string]struct{}
+}
+
+// New は許可リストを指定してRedactorを作成します
+// 許可リストに含まれるフィールドはマスクせずにそのまま出力します
+func New(allowFields []string) *Redactor {
+	allow := make(map[string]struct{}, len(allowFields))
+	for _, field := range allowFields {
+		field = strings.ToLower(strings.TrimSpace(field))
+		if field != "" {
+			allow[field] = struct{}{}
+		}
+	}
+	return &Redactor{allow: allow}
+}
+
+var defaultRedactor atomic.Pointer[Redactor]
+
+func init() {
+	defaultRedactor.Store(New(nil))
+}
+
+// Configure はパッケージ全体で利用するRedactorの許可リストを設定します
+func Configure(allowFields []string) {
+	defaultRedactor.Store(New(allowFields))
+}
+
+// Default は現在設定されているRedactorを返します
+func Default() *Redactor {
+	return defaultRedactor.Load()
+}
+
+// Allowed は指定されたフィールドが許可リストに含まれているかを返します
+func (r *Redactor) Allowed(field string) bool {
+	_, ok := r.allow[strings.ToLower(field)]
+	return ok
+}
+
+// Field はフィールド名に応じて値をマスクします
+// マスク対象外のフィールドでも、値に含まれるメールアドレスなどはマスクされます
+func (r *Redactor) Field(field, value string) string {
+	if r.Allowed(field) {
+		return value
+	}
+	switch sensitiveFields[strings.ToLower(field)] {
+	case kindEmail:
+		return Email(value)
+	case kindName:
+		return Name(value)
+	case kindSecret:
+		return Secret(value)
+	}
+	return r.String(value)
+}
+
+// String は自由形式の文字列に含まれるメールアドレス・パスワード・トークンをマスクします
+func (r *Redactor) String(s string) string {
+	if !r.Allowed("email") {
+		s = emailPattern.ReplaceAllString(s, "$1"+Mask+"@$2")
+	}
+	if !r.Allowed("password") {
+		s = passwordPattern.ReplaceAllString(s, "${1}"+Mask)
+	}
+	if !r.Allowed("task_token") {
+		s = tokenPattern.ReplaceAllString(s, Mask)
+	}
+	return s
+}
+
+// Value は任意の値をフィールド名に応じてマスクします
+// マップやスライスは再帰的に処理されます
+func (r *Redactor) Value(field string, v any) any {
+	switch val := v.(type) {
+	case string:
+		return r.Field(field, val)
+	case map[string]any:
+		masked := make(map[string]any, len(val))
+		for k, item := range val {
+			masked[k] = r.Value(k, item)
+		}
+		return masked
+	case []any:
+		masked := make([]any, len(val))
+		for i, item := range val {
+			masked[i] = r.Value(field, item)
+		}
+		return masked
+	}
+	return v
+}
+
+// JSON はJSONドキュメントに含まれるマスク対象フィールドをマスクします
+// JSONとして解釈できない場合は文字列としてマスクします
+func (r *Redactor) JSON(data []byte) []byte {
+	var doc any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return []byte(r.String(string(data)))
+	}
+	masked, err := json.Marshal(r.Value("", doc))
+	if err != nil {
+		return []byte(r.String(string(data)))
+	}
+	return masked
+}
+
+// SQL はクエリ中の文字列リテラルをマスクします
+func (r *Redactor) SQL(query string) string {
+	return sqlLiteralPattern.ReplaceAllString(query, "'?'")
+}
+
+// Email はメールアドレスのローカル部をマスクします
+func Email(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at <= 0 {
+		return Secret(email)
+	}
+	first, _ := utf8.DecodeRuneInString(email)
+	return string(first) + Mask + email[at:]
+}
+
+// Name は氏名の先頭1文字以外をマスクします
+func Name(name string) string {
+	if name == "" {
+		return ""
+	}
+	first, _ := utf8.DecodeRuneInString(name)
+	return string(first) + Mask
+}
+
+// Secret は値全体をマスクします
+func Secret(value string) string {
+	if value == "" {
+		return ""
+	}
+	return Mask
+}
+
+// Field はデフォルトのRedactorでフィールドの値をマスクします
+func Field(field, value string) string {
+	return Default().Field(field, value)
+}
+
+// String はデフォルトのRedactorで文字列をマスクします
+func String(s string) string {
+	return Default().String(s)
+}
+
+// Value はデフォルトのRedactorで任意の値をマスクします
+func Value(field string, v any) any {
+	return Default().Value(field, v)
+}
+
+// JSON はデフォルトのRedactorでJSONドキュメントをマスクします
+func JSON(data []byte) []byte {
+	return Default().JSON(data)
+}
+
+// SQL はデフォルトのRedactorでクエリをマスクします
+func SQL(query string) string {
+	return Default().SQL(query)
+}
@@ -0,0 +1,81 @@
+package redact
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestRedactor_Field(t *testing.T) {
+	tests := []struct {
+		name  string
+		allow []string
+		field string
+		value string
+		want  string
+	}{
+		{name: "メールアドレスをマスク", field: "email", value: "yamada@example.com", want: "y***@example.com"},
+		{name: "氏名をマスク", field: "user_name", value: "山田太郎", want: "山***"},
+		{name: "タスクトークンをマスク", field: "task_token", value: "AQB4AAAA", want: "***"},
+		{name: "パスワードをマスク", field: "password", value: "secret", want: "***"},
+		{name: "対象外のフィールドはそのまま", field: "pet_id", value: "pet001", want: "pet001"},
+		{name: "許可リストのフィールドはそのまま", allow: []string{"email"}, field: "email", value: "yamada@example.com", want: "yamada@example.com"},
+		{name: "対象外のフィールドでもメールアドレスはマスク", field: "message", value: "contact: sato@example.com", want: "contact: s***@example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := New(tt.allow).Field(tt.field, tt.value)
+			if got != tt.want {
+				t.Errorf("Field() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactor_String(t *testing.T) {
+	r := New(nil)
+
+	dsn := "host=db port=5432 user=app password=p@ss dbname=app"
+	if got := r.String(dsn); strings.Contains(got, "p@ss") {
+		t.Errorf("String() did not mask password: %q", got)
+	}
+
+	token := strings.Repeat("AQB4", 40)
+	if got := r.String("token " + token); strings.Contains(got, token) {
+		t.Errorf("String() did not mask task token: %q", got)
+	}
+}
+
+func TestRedactor_JSON(t *testing.T) {
+	r := New([]string{"user_name"})
+	input := `{"notifications":[{"data":{"user_id":"user1","email":"yamada@example.com","user_name":"山田太郎"}}]}`
+
+	var got struct {
+		Notifications []struct {
+			Data map[string]string `json:"data"`
+		} `json:"notifications"`
+	}
+	if err := json.Unmarshal(r.JSON([]byte(input)), &got); err != nil {
+		t.Fatalf("JSON() returned invalid json: %v", err)
+	}
+
+	data := got.Notifications[0].Data
+	if data["user_id"] != "user1" {
+		t.Errorf("user_id = %q, want %q", data["user_id"], "user1")
+	}
+	if data["email"] != "y***@example.com" {
+		t.Errorf("email = %q, want %q", data["email"], "y***@example.com")
+	}
+	if data["user_name"] != "山田太郎" {
+		t.Errorf("user_name = %q, want %q", data["user_name"], "山田太郎")
+	}
+}
+
+func TestRedactor_SQL(t *testing.T) {
+	got := New(nil).SQL(`SELECT 1 FROM reservations WHERE email = 'yamada@example.com' AND status = $1`)
+	want := `SELECT 1 FROM reservations WHERE email = '?' AND status = $1`
+	if got != want {
+		t.Errorf("SQL() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,68 @@
+package retry
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"syscall"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsretry "github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/lib/pq"
+)
+
+// retryableSQLStates はリトライ可能なPostgreSQLのSQLSTATEです
+var retryableSQLStates = map[pq.ErrorCode]struct{}{
+	"40001": {}, // serialization_failure
+	"40P01": {}, // deadlock_detected
+	"55P03": {}, // lock_not_available
+	"57P01": {}, // admin_shutdown
+	"57P02": {}, // crash_shutdown
+	"57P03": {}, // cannot_connect_now
+	"53300": {}, // too_many_connections
+}
+
+// IsRetryable はエラーが一時的なものでリトライ可能かを判定します
+// PostgreSQLのシリアライズ失敗・デッドロック・接続断と、AWSのスロットリング・一時的なエラーを対象とします
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	// 呼び出し元によるキャンセルやタイムアウトはリトライしない
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	return isRetryableDBError(err) || isRetryableAWSError(err)
+}
+
+func isRetryableDBError(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		if _, ok := retryableSQLStates[pqErr.Code]; ok {
+			return true
+		}
+		// 08: connection_exception
+		return pqErr.Code.Class() == "08"
+	}
+
+	if errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+
+	var opErr *net.OpError
+	return errors.As(err, &opErr)
+}
+
+func isRetryableAWSError(err error) bool {
+	if awsretry.IsErrorThrottles(awsretry.DefaultThrottles).IsErrorThrottle(err) == aws.TrueTernary {
+		return true
+	}
+	return awsretry.IsErrorRetryables(awsretry.DefaultRetryables).IsErrorRetryable(err) == aws.TrueTernary
+}
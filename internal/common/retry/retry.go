@@ -0,0 +1,97 @@
+package retry
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// Policy はリトライの方針を表します
+type Policy struct {
+	// MaxAttempts は最初の試行を含む最大試行回数です。1以下の場合はリトライしません
+	MaxAttempts int
+	// BaseDelay は1回目のリトライまでの待機時間です。以降は指数的に増加します
+	BaseDelay time.Duration
+	// MaxDelay は待機時間の上限です
+	MaxDelay time.Duration
+	// Jitter は待機時間に加えるゆらぎの割合(0〜1)です
+	Jitter float64
+	// Retryable はエラーがリトライ可能かを判定します。nilの場合はIsRetryableを利用します
+	Retryable func(error) bool
+
+	// sleep はテストで待機を差し替えるための関数です
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// DefaultPolicy はデフォルトのリトライ方針を返します
+func DefaultPolicy() Policy {
+	return Policy{
+		MaxAttempts: 3,
+		BaseDelay:   200 * time.Millisecond,
+		MaxDelay:    5 * time.Second,
+		Jitter:      0.5,
+	}
+}
+
+// NoRetry はリトライしない方針を返します
+func NoRetry() Policy {
+	return Policy{MaxAttempts: 1}
+}
+
+// Do はリトライ可能なエラーが発生した場合に、バックオフしながらfnを再実行します
+// リトライ回数を使い切った場合やリトライできないエラーの場合は、最後のエラーをそのまま返します
+func (p Policy) Do(ctx context.Context, op string, fn func(ctx context.Context) error) error {
+	retryable := p.Retryable
+	if retryable == nil {
+		retryable = IsRetryable
+	}
+	maxAttempts := max(p.MaxAttempts, 1)
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = fn(ctx); err == nil {
+			return nil
+		}
+		if attempt >= maxAttempts || !retryable(err) {
+			return err
+		}
+
+		delay := p.Backoff(attempt)
+		log.Printf("Retrying %s after %v (attempt %d/%d): %v", op, delay, attempt+1, maxAttempts, err)
+		if sleepErr := p.wait(ctx, delay); sleepErr != nil {
+			return fmt.Errorf("%s: retry aborted: %w (last error: %v)", op, sleepErr, err)
+		}
+	}
+}
+
+// Backoff は指定された試行回数の後に待機する時間を返します
+func (p Policy) Backoff(attempt int) time.Duration {
+	if p.BaseDelay <= 0 {
+		return 0
+	}
+	delay := float64(p.BaseDelay) * math.Pow(2, float64(attempt-1))
+	if p.MaxDelay > 0 && delay > float64(p.MaxDelay) {
+		delay = float64(p.MaxDelay)
+	}
+	if jitter := min(max(p.Jitter, 0), 1); jitter > 0 {
+		delay = delay*(1-jitter) + rand.Float64()*delay*jitter
+	}
+	return time.Duration(delay)
+}
+
+func (p Policy) wait(ctx context.Context, d time.Duration) error {
+	if p.sleep != nil {
+		return p.sleep(ctx, d)
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,117 @@
+package retry
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/smithy-go"
+	"github.com/lib/pq"
+)
+
+// noSleep は待機せずにリトライするためのテスト用関数です
+func noSleep(ctx context.Context, d time.Duration) error {
+	return ctx.Err()
+}
+
+func TestPolicy_Do(t *testing.T) {
+	retryableErr := &pq.Error{Code: "40001"}
+	fatalErr := errors.New("syntax error")
+
+	tests := []struct {
+		name         string
+		maxAttempts  int
+		errs         []error
+		wantErr      error
+		wantAttempts int
+	}{
+		{name: "初回で成功", maxAttempts: 3, errs: []error{nil}, wantAttempts: 1},
+		{name: "リトライ後に成功", maxAttempts: 3, errs: []error{retryableErr, retryableErr, nil}, wantAttempts: 3},
+		{name: "リトライ回数を使い切る", maxAttempts: 3, errs: []error{retryableErr, retryableErr, retryableErr}, wantErr: retryableErr, wantAttempts: 3},
+		{name: "リトライできないエラー", maxAttempts: 3, errs: []error{fatalErr}, wantErr: fatalErr, wantAttempts: 1},
+		{name: "リトライ無効", maxAttempts: 1, errs: []error{retryableErr}, wantErr: retryableErr, wantAttempts: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := Policy{MaxAttempts: tt.maxAttempts, BaseDelay: time.Millisecond, sleep: noSleep}
+
+			attempts := 0
+			err := policy.Do(context.Background(), "test", func(ctx context.Context) error {
+				err := tt.errs[attempts]
+				attempts++
+				return err
+			})
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Do() error = %v, want %v", err, tt.wantErr)
+			}
+			if attempts != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", attempts, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestPolicy_Do_ContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	policy := Policy{MaxAttempts: 3, BaseDelay: time.Hour}
+	err := policy.Do(ctx, "test", func(ctx context.Context) error {
+		return driver.ErrBadConn
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Do() error = %v, want context.Canceled", err)
+	}
+}
+
+func TestPolicy_Backoff(t *testing.T) {
+	policy := Policy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+
+	want := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second}
+	for i, w := range want {
+		if got := policy.Backoff(i + 1); got != w {
+			t.Errorf("Backoff(%d) = %v, want %v", i+1, got, w)
+		}
+	}
+
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		got := policy.Backoff(1)
+		if got < 50*time.Millisecond || got > 100*time.Millisecond {
+			t.Fatalf("Backoff(1) with jitter = %v, want between 50ms and 100ms", got)
+		}
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "nil", err: nil, want: false},
+		{name: "シリアライズ失敗", err: &pq.Error{Code: "40001"}, want: true},
+		{name: "デッドロック", err: &pq.Error{Code: "40P01"}, want: true},
+		{name: "管理者によるシャットダウン", err: &pq.Error{Code: "57P01"}, want: true},
+		{name: "接続エラー", err: &pq.Error{Code: "08006"}, want: true},
+		{name: "一意制約違反", err: &pq.Error{Code: "23505"}, want: false},
+		{name: "コネクション切断", err: fmt.Errorf("commit: %w", driver.ErrBadConn), want: true},
+		{name: "AWSのスロットリング", err: &smithy.GenericAPIError{Code: "ThrottlingException"}, want: true},
+		{name: "AWSの入力エラー", err: &smithy.GenericAPIError{Code: "InvalidToken"}, want: false},
+		{name: "キャンセル", err: context.Canceled, want: false},
+		{name: "その他のエラー", err: errors.New("unknown"), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsRetryable(tt.err); got != tt.want {
+				t.Errorf("IsRetryable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
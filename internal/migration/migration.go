@@ -0,0 +1,275 @@
+package migration
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"regexp"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// files はバイナリに埋め込むマイグレーションのSQLファイルです
+//
+//go:embed migrations/*.sql
+var files embed.FS
+
+// fileNamePattern はマイグレーションのファイル名 (<バージョン>_<名前>.<up|down>.sql) です
+var fileNamePattern = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// lockKey はマイグレーションを同時に適用しないためのアドバイザリーロックのキーです
+const lockKey = 7_203_117_040
+
+// Migration はバージョン付きのスキーマの変更です
+type Migration struct {
+	Version int64  `json:"version"`
+	Name    string `json:"name"`
+	up      string
+	down    string
+}
+
+// String はマイグレーションをファイル名の形式で返します
+func (m Migration) String() string {
+	return fmt.Sprintf("%04d_%s", m.Version, m.Name)
+}
+
+// Status はマイグレーションの適用状況です
+type Status struct {
+	Migration
+	// AppliedAt は適用した日時です。未適用の場合はnilです
+	AppliedAt *time.Time `json:"applied_at"`
+}
+
+// Load はマイグレーションのファイルを読み込み、バージョン順に返します
+// 各バージョンにはupとdownの両方のファイルが必要です
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		match := fileNamePattern.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			return nil, fmt.Errorf("invalid migration file name: %s", entry.Name())
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("invalid migration version: %s", entry.Name())
+		}
+		body, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("duplicate migration version %d: %s and %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.up = string(body)
+		} else {
+			m.down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.up == "" || m.down == "" {
+			return nil, fmt.Errorf("migration %s requires both up and down files", m)
+		}
+		migrations = append(migrations, *m)
+	}
+	slices.SortFunc(migrations, func(a, b Migration) int { return cmp.Compare(a.Version, b.Version) })
+	return migrations, nil
+}
+
+// Migrator はバイナリに埋め込んだマイグレーションをデータベースに適用します
+// 適用済みのバージョンはschema_migrationsに記録します
+type Migrator struct {
+	db         *sqlx.DB
+	migrations []Migration
+}
+
+// New は埋め込んだマイグレーションを適用するMigratorを作成します
+func New(db *sqlx.DB) (*Migrator, error) {
+	fsys, err := fs.Sub(files, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// Up は未適用のマイグレーションをバージョン順に最大steps件適用し、適用したマイグレーションを返します
+// stepsが0以下の場合はすべて適用します
+func (m *Migrator) Up(ctx context.Context, steps int) ([]Migration, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+		if steps > 0 && len(done) >= steps {
+			break
+		}
+		changed, err := m.apply(ctx, migration, true)
+		if err != nil {
+			return done, err
+		}
+		if changed {
+			log.Printf("Applied migration %s", migration)
+			done = append(done, migration)
+		}
+	}
+	return done, nil
+}
+
+// Down は適用済みのマイグレーションを新しい順にsteps件取り消し、取り消したマイグレーションを返します
+// 誤ってすべてを取り消さないよう、stepsは1以上を指定する必要があります
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	if steps <= 0 {
+		return nil, fmt.Errorf("steps must be positive: %d", steps)
+	}
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, migration := range slices.Backward(m.migrations) {
+		if len(done) >= steps {
+			break
+		}
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+		changed, err := m.apply(ctx, migration, false)
+		if err != nil {
+			return done, err
+		}
+		if changed {
+			log.Printf("Reverted migration %s", migration)
+			done = append(done, migration)
+		}
+	}
+	return done, nil
+}
+
+// Status はすべてのマイグレーションの適用状況をバージョン順に返します
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, len(m.migrations))
+	for i, migration := range m.migrations {
+		statuses[i] = Status{Migration: migration}
+		if at, ok := applied[migration.Version]; ok {
+			statuses[i].AppliedAt = &at
+		}
+	}
+	return statuses, nil
+}
+
+// applied はschema_migrationsを作成し、適用済みのバージョンと適用日時を返します
+func (m *Migrator) applied(ctx context.Context) (map[int64]time.Time, error) {
+	query := `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    BIGINT PRIMARY KEY,
+			name       VARCHAR(255) NOT NULL,
+			applied_at TIMESTAMP WITH TIME ZONE NOT NULL
+		)`
+	if _, err := m.db.ExecContext(ctx, query); err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	var rows []struct {
+		Version   int64     `db:"version"`
+		AppliedAt time.Time `db:"applied_at"`
+	}
+	if err := m.db.SelectContext(ctx, &rows, `SELECT version, applied_at FROM schema_migrations`); err != nil {
+		return nil, fmt.Errorf("failed to get applied migrations: %w", err)
+	}
+
+	applied := make(map[int64]time.Time, len(rows))
+	for _, row := range rows {
+		applied[row.Version] = row.AppliedAt
+	}
+	return applied, nil
+}
+
+// apply は1件のマイグレーションを適用 (upがtrue) または取り消し、schema_migrationsとともに1トランザクションでコミットします
+// 他のプロセスが同時に実行した場合に備え、ロックを取得してから適用状況を再確認します
+// 他のプロセスが先に適用・取り消していた場合は何もせずにfalseを返します
+func (m *Migrator) apply(ctx context.Context, migration Migration, up bool) (changed bool, err error) {
+	tx, err := m.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil && !errors.Is(rbErr, sql.ErrTxDone) {
+				log.Printf("rollback failed: %v, original error: %v", rbErr, err)
+			}
+		}
+	}()
+
+	if _, err = tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, lockKey); err != nil {
+		return false, fmt.Errorf("failed to lock schema_migrations: %w", err)
+	}
+
+	var applied bool
+	err = tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)`,
+		migration.Version).Scan(&applied)
+	if err != nil {
+		return false, fmt.Errorf("failed to check migration %s: %w", migration, err)
+	}
+	if applied == up {
+		return false, tx.Commit()
+	}
+
+	body := migration.down
+	if up {
+		body = migration.up
+	}
+	if _, err = tx.ExecContext(ctx, body); err != nil {
+		return false, fmt.Errorf("failed to run migration %s: %w", migration, err)
+	}
+
+	if up {
+		_, err = tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, $2, $3)`,
+			migration.Version, migration.Name, time.Now())
+	} else {
+		_, err = tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = $1`, migration.Version)
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to record migration %s: %w", migration, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit migration %s: %w", migration, err)
+	}
+	return true, nil
+}
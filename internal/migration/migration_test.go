@@ -0,0 +1,119 @@
+package migration
+
+import (
+	"io/fs"
+	"regexp"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoad(t *testing.T) {
+	file := func(body string) *fstest.MapFile { return &fstest.MapFile{Data: []byte(body)} }
+	tests := []struct {
+		name    string
+		fsys    fstest.MapFS
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "バージョン順に読み込む",
+			fsys: fstest.MapFS{
+				"0002_add_index.up.sql":      file("CREATE INDEX"),
+				"0002_add_index.down.sql":    file("DROP INDEX"),
+				"0001_create_table.up.sql":   file("CREATE TABLE"),
+				"0001_create_table.down.sql": file("DROP TABLE"),
+				"0010_add_column.up.sql":     file("ALTER TABLE"),
+				"0010_add_column.down.sql":   file("ALTER TABLE"),
+			},
+			want: []string{"0001_create_table", "0002_add_index", "0010_add_column"},
+		},
+		{
+			name:    "downがない",
+			fsys:    fstest.MapFS{"0001_create_table.up.sql": file("CREATE TABLE")},
+			wantErr: true,
+		},
+		{
+			name: "バージョンが重複している",
+			fsys: fstest.MapFS{
+				"0001_create_table.up.sql":   file("CREATE TABLE"),
+				"0001_create_table.down.sql": file("DROP TABLE"),
+				"0001_other.up.sql":          file("CREATE TABLE"),
+				"0001_other.down.sql":        file("DROP TABLE"),
+			},
+			wantErr: true,
+		},
+		{
+			name:    "不正なファイル名",
+			fsys:    fstest.MapFS{"create_table.sql": file("CREATE TABLE")},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migrations, err := Load(tt.fsys)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var got []string
+			for _, m := range migrations {
+				got = append(got, m.String())
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Load() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestEmbeddedMigrations はバッチのクエリが利用するテーブルがマイグレーションで作成されることを確認します
+func TestEmbeddedMigrations(t *testing.T) {
+	fsys, err := fs.Sub(files, "migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrations, err := Load(fsys)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	created := make(map[string]bool)
+	dropped := make(map[string]bool)
+	createTable := regexp.MustCompile(`CREATE TABLE IF NOT EXISTS (\w+)`)
+	dropTable := regexp.MustCompile(`DROP TABLE IF EXISTS (\w+)`)
+	for i, m := range migrations {
+		if m.Version != int64(i+1) {
+			t.Errorf("migration %s: version is not sequential", m)
+		}
+		for _, match := range createTable.FindAllStringSubmatch(m.up, -1) {
+			created[match[1]] = true
+		}
+		for _, match := range dropTable.FindAllStringSubmatch(m.down, -1) {
+			dropped[match[1]] = true
+		}
+	}
+
+	for _, table := range []string{
+		"pets",
+		"reservations",
+		"notifications",
+		"batch_checkpoints",
+		"reservation_events",
+		"notification_channels",
+		"notification_deliveries",
+		"notification_preferences",
+		"notification_quiet_hours",
+		"reservation_reminders",
+		"reservation_status_history",
+		"shops",
+		"shop_opening_hours",
+		"users",
+	} {
+		if !created[table] {
+			t.Errorf("table %s is not created by migrations", table)
+		}
+		if !dropped[table] {
+			t.Errorf("table %s is not dropped by down migrations", table)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+-- 予約・通知・ペットのテーブル
+-- 既存の環境にも適用できるよう、作成済みのテーブル・インデックスは作成しない
+CREATE TABLE IF NOT EXISTS pets (
+    id         VARCHAR(255) PRIMARY KEY,
+    name       VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS reservations (
+    id                    BIGSERIAL PRIMARY KEY,
+    user_id               VARCHAR(255) NOT NULL,
+    user_name             VARCHAR(255) NOT NULL,
+    email                 VARCHAR(255) NOT NULL,
+    reservation_date_time TIMESTAMP WITH TIME ZONE NOT NULL,
+    pet_id                VARCHAR(255) NOT NULL,
+    status                VARCHAR(32) NOT NULL DEFAULT 'pending',
+    created_at            TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at            TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- ステータスごとに予約日時順で取得するため (予約バッチ・リマインドバッチ)
+CREATE INDEX IF NOT EXISTS idx_reservations_status_date_time ON reservations (status, reservation_date_time, id);
+-- ペットの確定済みの予約の有無を確認するため
+CREATE INDEX IF NOT EXISTS idx_reservations_pet_id_status ON reservations (pet_id, status);
+
+CREATE TABLE IF NOT EXISTS notifications (
+    id         SERIAL PRIMARY KEY,
+    user_id    VARCHAR(255) NOT NULL,
+    title      VARCHAR(255) NOT NULL,
+    message    TEXT NOT NULL,
+    is_read    BOOLEAN NOT NULL DEFAULT FALSE,
+    type       VARCHAR(32) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- ユーザーの通知を新しい順に取得するため
+CREATE INDEX IF NOT EXISTS idx_notifications_user_id_created_at ON notifications (user_id, created_at);
+-- 保持期間を過ぎた通知を取得するため
+CREATE INDEX IF NOT EXISTS idx_notifications_is_read_created_at ON notifications (is_read, created_at);
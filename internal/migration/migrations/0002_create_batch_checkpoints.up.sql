@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS batch_checkpoints (
+    run_id     VARCHAR(64) PRIMARY KEY,
+    job_name   VARCHAR(64) NOT NULL,
+    last_key   TEXT,
+    outcome    VARCHAR(16) NOT NULL,
+    events     JSONB,
+    error      TEXT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
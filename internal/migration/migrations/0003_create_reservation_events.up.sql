@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS reservation_events (
+    id             BIGSERIAL PRIMARY KEY,
+    reservation_id BIGINT NOT NULL REFERENCES reservations (id),
+    event_type     VARCHAR(64) NOT NULL,
+    payload        JSONB NOT NULL,
+    created_at     TIMESTAMP WITH TIME ZONE NOT NULL,
+    delivered_at   TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_reservation_events_undelivered ON reservation_events (id) WHERE delivered_at IS NULL;
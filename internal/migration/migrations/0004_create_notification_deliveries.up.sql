@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS notification_channels (
+    user_id    VARCHAR(255) NOT NULL,
+    channel    VARCHAR(16) NOT NULL,
+    address    TEXT NOT NULL,
+    enabled    BOOLEAN NOT NULL DEFAULT TRUE,
+    PRIMARY KEY (user_id, channel)
+);
+
+CREATE TABLE IF NOT EXISTS notification_deliveries (
+    id              BIGSERIAL PRIMARY KEY,
+    notification_id INTEGER NOT NULL REFERENCES notifications (id),
+    user_id         VARCHAR(255) NOT NULL,
+    channel         VARCHAR(16) NOT NULL,
+    address         TEXT NOT NULL,
+    status          VARCHAR(16) NOT NULL,
+    attempts        INTEGER NOT NULL DEFAULT 0,
+    last_error      TEXT,
+    created_at      TIMESTAMP WITH TIME ZONE NOT NULL,
+    updated_at      TIMESTAMP WITH TIME ZONE NOT NULL,
+    delivered_at    TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_notification_deliveries_undelivered ON notification_deliveries (id) WHERE status IN ('pending', 'failed');
+-- 保持期間を過ぎた通知とともに削除するため
+CREATE INDEX IF NOT EXISTS idx_notification_deliveries_notification_id ON notification_deliveries (notification_id);
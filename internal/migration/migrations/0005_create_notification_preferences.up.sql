@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    user_id VARCHAR(255) NOT NULL,
+    type    VARCHAR(32) NOT NULL,
+    channel VARCHAR(16) NOT NULL,
+    enabled BOOLEAN NOT NULL,
+    PRIMARY KEY (user_id, type, channel)
+);
+
+CREATE TABLE IF NOT EXISTS notification_quiet_hours (
+    user_id    VARCHAR(255) PRIMARY KEY,
+    start_time TIME NOT NULL,
+    end_time   TIME NOT NULL,
+    timezone   VARCHAR(64) NOT NULL DEFAULT 'Asia/Tokyo'
+);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS reservation_reminders (
+    reservation_id  BIGINT NOT NULL REFERENCES reservations (id),
+    window_minutes  INTEGER NOT NULL,
+    notification_id INTEGER NOT NULL REFERENCES notifications (id),
+    created_at      TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (reservation_id, window_minutes)
+);
+
+-- 保持期間を過ぎた通知とともに削除するため
+CREATE INDEX IF NOT EXISTS idx_reservation_reminders_notification_id ON reservation_reminders (notification_id);
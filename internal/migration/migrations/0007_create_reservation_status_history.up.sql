@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS reservation_status_history (
+    id             BIGSERIAL PRIMARY KEY,
+    reservation_id BIGINT NOT NULL REFERENCES reservations (id),
+    from_status    VARCHAR(32) NOT NULL,
+    to_status      VARCHAR(32) NOT NULL,
+    reason         VARCHAR(64) NOT NULL,
+    run_id         VARCHAR(64) NOT NULL,
+    changed_at     TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_reservation_status_history_reservation_id ON reservation_status_history (reservation_id, changed_at);
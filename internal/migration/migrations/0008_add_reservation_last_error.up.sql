@@ -0,0 +1,2 @@
+-- 予約バッチが処理に失敗した原因を記録する
+ALTER TABLE reservations ADD COLUMN IF NOT EXISTS last_error TEXT;
@@ -0,0 +1,5 @@
+ALTER TABLE pets DROP COLUMN IF EXISTS shop_id;
+ALTER TABLE pets DROP COLUMN IF EXISTS adopted;
+ALTER TABLE pets DROP COLUMN IF EXISTS status;
+DROP TABLE IF EXISTS shop_opening_hours;
+DROP TABLE IF EXISTS shops;
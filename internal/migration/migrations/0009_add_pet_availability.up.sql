@@ -0,0 +1,19 @@
+-- 予約を確定する前にペットの掲載状態とショップの営業時間を確認するため
+CREATE TABLE IF NOT EXISTS shops (
+    id       VARCHAR(255) PRIMARY KEY,
+    name     VARCHAR(255) NOT NULL,
+    timezone VARCHAR(64) NOT NULL DEFAULT 'Asia/Tokyo'
+);
+
+-- weekdayは0 (日曜日) から6 (土曜日)。営業時間が登録されていないショップは営業時間を確認しない
+CREATE TABLE IF NOT EXISTS shop_opening_hours (
+    shop_id   VARCHAR(255) NOT NULL REFERENCES shops (id),
+    weekday   SMALLINT NOT NULL CHECK (weekday BETWEEN 0 AND 6),
+    opens_at  TIME NOT NULL,
+    closes_at TIME NOT NULL,
+    PRIMARY KEY (shop_id, weekday, opens_at)
+);
+
+ALTER TABLE pets ADD COLUMN IF NOT EXISTS status VARCHAR(32) NOT NULL DEFAULT 'available';
+ALTER TABLE pets ADD COLUMN IF NOT EXISTS adopted BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE pets ADD COLUMN IF NOT EXISTS shop_id VARCHAR(255) REFERENCES shops (id);
@@ -0,0 +1,2 @@
+-- ユーザーごとの予約の上限・重複を確認するため
+CREATE INDEX IF NOT EXISTS idx_reservations_user_id_status ON reservations (user_id, status);
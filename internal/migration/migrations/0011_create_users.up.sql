@@ -0,0 +1,8 @@
+-- 通知に表示する日時のタイムゾーンと言語をユーザーごとに設定するため
+-- 設定されていない場合は NOTIFICATION_TIMEZONE・NOTIFICATION_LOCALE を使う
+CREATE TABLE IF NOT EXISTS users (
+    id VARCHAR(255) PRIMARY KEY
+);
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS timezone VARCHAR(64);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS locale VARCHAR(16);
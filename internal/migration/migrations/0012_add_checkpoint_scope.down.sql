@@ -0,0 +1 @@
+ALTER TABLE batch_checkpoints DROP COLUMN IF EXISTS scope;
@@ -0,0 +1,2 @@
+-- 再開時に照合するため、処理位置を記録した処理対象の条件を記録する
+ALTER TABLE batch_checkpoints ADD COLUMN IF NOT EXISTS scope TEXT;
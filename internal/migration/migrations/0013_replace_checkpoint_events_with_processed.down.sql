@@ -0,0 +1,2 @@
+ALTER TABLE batch_checkpoints ADD COLUMN IF NOT EXISTS events JSONB;
+ALTER TABLE batch_checkpoints DROP COLUMN IF EXISTS processed;
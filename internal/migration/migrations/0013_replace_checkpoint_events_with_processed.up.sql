@@ -0,0 +1,3 @@
+-- 発行したイベントはアウトボックス (reservation_events) に記録するため、チェックポイントには処理件数のみを記録する
+ALTER TABLE batch_checkpoints ADD COLUMN IF NOT EXISTS processed INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE batch_checkpoints DROP COLUMN IF EXISTS events;
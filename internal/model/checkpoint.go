@@ -0,0 +1,73 @@
+package model
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CheckpointOutcome はバッチ処理の実行結果を表します
+type CheckpointOutcome string
+
+const (
+	// CheckpointOutcomeRunning は実行中(または異常終了して結果を記録できなかった)ことを表します
+	CheckpointOutcomeRunning CheckpointOutcome = "running"
+	// CheckpointOutcomeCompleted は正常に完了したことを表します
+	CheckpointOutcomeCompleted CheckpointOutcome = "completed"
+	// CheckpointOutcomeFailed は失敗したことを表します
+	CheckpointOutcomeFailed CheckpointOutcome = "failed"
+	// CheckpointOutcomeInterrupted はシグナルやタイムアウトで中断されたことを表します
+	CheckpointOutcomeInterrupted CheckpointOutcome = "interrupted"
+)
+
+// Checkpoint はバッチ処理の進捗を表します
+// 中断したバッチ処理を同じRunIDで再開する際に、処理済みのデータを飛ばすために利用します
+type Checkpoint struct {
+	RunID   string `db:"run_id" json:"run_id"`
+	JobName string `db:"job_name" json:"job_name"`
+	// LastKey は最後に処理したデータのキーです。形式はバッチ処理ごとに異なります
+	LastKey string `db:"last_key" json:"last_key"`
+	// Scope は処理位置を記録した処理対象の条件です。形式はバッチ処理ごとに異なります
+	// 異なる条件で再開すると処理位置が別の結果に適用されて対象を飛ばしてしまうため、再開時に照合します
+	Scope   string            `db:"scope" json:"scope,omitempty"`
+	Outcome CheckpointOutcome `db:"outcome" json:"outcome"`
+	// Processed はこれまでに処理したデータの件数です。発行したイベントはアウトボックスに記録するため保持しません
+	Processed int       `db:"processed" json:"processed"`
+	Error     string    `db:"error" json:"error,omitempty"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// ReservationCursor は予約バッチの処理位置を表します
+// 予約は予約日時とIDの昇順で処理されるため、この2つで位置を一意に決められます
+type ReservationCursor struct {
+	DateTime time.Time
+	ID       int64
+}
+
+// String はチェックポイントに保存する形式に変換します
+func (c ReservationCursor) String() string {
+	return fmt.Sprintf("%s/%d", c.DateTime.UTC().Format(time.RFC3339Nano), c.ID)
+}
+
+// ParseReservationCursor はチェックポイントに保存された処理位置を解析します
+// 空文字の場合はnilを返します
+func ParseReservationCursor(key string) (*ReservationCursor, error) {
+	if key == "" {
+		return nil, nil
+	}
+	dateTime, id, ok := strings.Cut(key, "/")
+	if !ok {
+		return nil, fmt.Errorf("invalid reservation cursor: %q", key)
+	}
+	t, err := time.Parse(time.RFC3339Nano, dateTime)
+	if err != nil {
+		return nil, fmt.Errorf("invalid reservation cursor date_time: %w", err)
+	}
+	n, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid reservation cursor id: %w", err)
+	}
+	return &ReservationCursor{DateTime: t, ID: n}, nil
+}
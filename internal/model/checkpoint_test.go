@@ -0,0 +1,61 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseReservationCursor(t *testing.T) {
+	dateTime := time.Date(2024, 3, 1, 9, 30, 0, 500, time.UTC)
+
+	tests := []struct {
+		name    string
+		key     string
+		want    *ReservationCursor
+		wantErr bool
+	}{
+		{
+			name: "空文字の場合はnil",
+			key:  "",
+			want: nil,
+		},
+		{
+			name: "保存した形式を復元できる",
+			key:  ReservationCursor{DateTime: dateTime, ID: 42}.String(),
+			want: &ReservationCursor{DateTime: dateTime, ID: 42},
+		},
+		{
+			name:    "区切り文字がない",
+			key:     "2024-03-01T09:30:00Z",
+			wantErr: true,
+		},
+		{
+			name:    "日時の形式が不正",
+			key:     "2024-03-01/42",
+			wantErr: true,
+		},
+		{
+			name:    "IDが数値でない",
+			key:     "2024-03-01T09:30:00Z/abc",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseReservationCursor(tt.key)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseReservationCursor() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.want == nil {
+				if got != nil {
+					t.Errorf("ParseReservationCursor() = %v, want nil", got)
+				}
+				return
+			}
+			if got == nil || !got.DateTime.Equal(tt.want.DateTime) || got.ID != tt.want.ID {
+				t.Errorf("ParseReservationCursor() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package model
+
+import "time"
+
+// NotificationChannel は通知の配信チャネルを表します
+type NotificationChannel string
+
+const (
+	// NotificationChannelEmail はメールでの配信を表します
+	NotificationChannelEmail NotificationChannel = "email"
+	// NotificationChannelWebhook はWebhookでの配信を表します
+	NotificationChannelWebhook NotificationChannel = "webhook"
+	// NotificationChannelPush はプッシュ通知での配信を表します
+	NotificationChannelPush NotificationChannel = "push"
+)
+
+// DeliveryStatus は通知の配信状況を表します
+type DeliveryStatus string
+
+const (
+	// DeliveryStatusPending は配信待ちを表します
+	DeliveryStatusPending DeliveryStatus = "pending"
+	// DeliveryStatusSent は配信済みを表します
+	DeliveryStatusSent DeliveryStatus = "sent"
+	// DeliveryStatusFailed は配信に失敗したことを表します。最大試行回数に達するまで再度配信されます
+	DeliveryStatusFailed DeliveryStatus = "failed"
+	// DeliveryStatusRejected は宛先が不正などで再試行しても成功しないため、配信を諦めたことを表します
+	DeliveryStatusRejected DeliveryStatus = "rejected"
+	// DeliveryStatusSuppressed は配信前にユーザーがチャネルの受け取りを停止したため、配信しなかったことを表します
+	DeliveryStatusSuppressed DeliveryStatus = "suppressed"
+)
+
+// NotificationChannelSetting はユーザーごとの配信チャネルの設定です
+type NotificationChannelSetting struct {
+	UserID  string              `db:"user_id"`
+	Channel NotificationChannel `db:"channel"`
+	// Address は配信先です (メールアドレス、WebhookのURL、プッシュ通知のデバイストークン)
+	Address string `db:"address"`
+	Enabled bool   `db:"enabled"`
+}
+
+// NotificationDelivery は通知レコードごと・チャネルごとの外部への配信状況です
+// 通知レコードと同じトランザクションで作成し、アプリ内の通知と外部への配信の対応を保ちます
+type NotificationDelivery struct {
+	ID             int64               `db:"id"`
+	NotificationID int                 `db:"notification_id"`
+	UserID         string              `db:"user_id"`
+	Channel        NotificationChannel `db:"channel"`
+	Address        string              `db:"address"`
+	Status         DeliveryStatus      `db:"status"`
+	Attempts       int                 `db:"attempts"`
+	LastError      string              `db:"last_error"`
+	CreatedAt      time.Time           `db:"created_at"`
+	UpdatedAt      time.Time           `db:"updated_at"`
+	DeliveredAt    *time.Time          `db:"delivered_at"`
+
+	// 以下は配信時に通知レコードから読み込む内容です
+	Type    NotificationType `db:"type"`
+	Title   string           `db:"title"`
+	Message string           `db:"message"`
+}
@@ -0,0 +1,98 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Locale は通知に表示する日時の書式の言語です
+type Locale string
+
+const (
+	// LocaleJa は日本語の書式 (2024年6月1日(土) 10:00 JST) を表します
+	LocaleJa Locale = "ja"
+	// LocaleEn は英語の書式 (Sat, Jun 1, 2024 10:00 AM JST) を表します
+	LocaleEn Locale = "en"
+)
+
+// ParseLocale は "ja" や "en-US" のような言語タグから書式の言語を返します
+// 地域 ("-JP" など) は書式に影響しないため無視します
+func ParseLocale(s string) (Locale, error) {
+	lang, _, _ := strings.Cut(strings.ReplaceAll(strings.TrimSpace(s), "_", "-"), "-")
+	switch locale := Locale(strings.ToLower(lang)); locale {
+	case LocaleJa, LocaleEn:
+		return locale, nil
+	default:
+		return "", fmt.Errorf("unsupported locale %q", s)
+	}
+}
+
+// jaWeekdays は日本語の曜日の表記です
+var jaWeekdays = [...]string{"日", "月", "火", "水", "木", "金", "土"}
+
+// DisplayOptions は通知に表示する日時のタイムゾーンと書式です
+// ゼロ値はUTCの日本語の書式で表示します
+type DisplayOptions struct {
+	Location *time.Location
+	Locale   Locale
+}
+
+// NewDisplayOptions はタイムゾーン名 (例: Asia/Tokyo) と言語タグから表示設定を作成します
+func NewDisplayOptions(timeZone, locale string) (DisplayOptions, error) {
+	loc, err := time.LoadLocation(timeZone)
+	if err != nil {
+		return DisplayOptions{}, fmt.Errorf("invalid display timezone %q: %w", timeZone, err)
+	}
+	l, err := ParseLocale(locale)
+	if err != nil {
+		return DisplayOptions{}, err
+	}
+	return DisplayOptions{Location: loc, Locale: l}, nil
+}
+
+// ForUser はユーザーが設定したタイムゾーンと言語で上書きした表示設定を返します
+// ユーザーが設定していない項目はoの設定を使います
+func (o DisplayOptions) ForUser(user *User) (DisplayOptions, error) {
+	if user == nil {
+		return o, nil
+	}
+	if user.TimeZone != "" {
+		loc, err := time.LoadLocation(user.TimeZone)
+		if err != nil {
+			return o, fmt.Errorf("invalid timezone %q of user %s: %w", user.TimeZone, user.ID, err)
+		}
+		o.Location = loc
+	}
+	if user.Locale != "" {
+		locale, err := ParseLocale(user.Locale)
+		if err != nil {
+			return o, fmt.Errorf("invalid locale of user %s: %w", user.ID, err)
+		}
+		o.Locale = locale
+	}
+	return o, nil
+}
+
+// FormatDateTime は日時を表示設定のタイムゾーンに変換し、言語に応じた書式で返します
+// 夏時間のあるタイムゾーンでも日時ごとの略称 (EST/EDT など) を付けるため、利用者が時差を確認できます
+func (o DisplayOptions) FormatDateTime(t time.Time) string {
+	loc := o.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	local := t.In(loc)
+	if o.Locale == LocaleEn {
+		return local.Format("Mon, Jan 2, 2006 3:04 PM MST")
+	}
+	return fmt.Sprintf("%d年%d月%d日(%s) %s", local.Year(), local.Month(), local.Day(),
+		jaWeekdays[local.Weekday()], local.Format("15:04 MST"))
+}
+
+// User は通知の表示に利用するユーザーの設定です
+// TimeZoneとLocaleが空の場合は既定の表示設定 (NOTIFICATION_TIMEZONE・NOTIFICATION_LOCALE) を使います
+type User struct {
+	ID       string `db:"id"`
+	TimeZone string `db:"timezone"`
+	Locale   string `db:"locale"`
+}
@@ -0,0 +1,108 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseLocale(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Locale
+		wantErr bool
+	}{
+		{input: "ja", want: LocaleJa},
+		{input: "ja-JP", want: LocaleJa},
+		{input: "en_US", want: LocaleEn},
+		{input: "EN", want: LocaleEn},
+		{input: "fr", wantErr: true},
+		{input: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseLocale(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseLocale(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseLocale(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDisplayOptions_FormatDateTime(t *testing.T) {
+	mustOptions := func(timeZone, locale string) DisplayOptions {
+		opts, err := NewDisplayOptions(timeZone, locale)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return opts
+	}
+
+	// 2024年のニューヨークの夏時間は3月10日2時 (EST) から11月3日2時 (EDT) まで
+	winter := time.Date(2024, 1, 15, 15, 0, 0, 0, time.UTC)
+	summer := time.Date(2024, 7, 15, 15, 0, 0, 0, time.UTC)
+	// 夏時間の開始直前と直後 (現地時刻は1:59 ESTから3:00 EDTに進む)
+	beforeDST := time.Date(2024, 3, 10, 6, 59, 0, 0, time.UTC)
+	afterDST := time.Date(2024, 3, 10, 7, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		opts DisplayOptions
+		at   time.Time
+		want string
+	}{
+		{name: "UTC", opts: mustOptions("UTC", "ja"), at: winter, want: "2024年1月15日(月) 15:00 UTC"},
+		{name: "JSTで日付が変わる", opts: mustOptions("Asia/Tokyo", "ja"), at: winter, want: "2024年1月16日(火) 00:00 JST"},
+		{name: "JSTの英語の書式", opts: mustOptions("Asia/Tokyo", "en"), at: winter, want: "Tue, Jan 16, 2024 12:00 AM JST"},
+		{name: "ニューヨークの標準時", opts: mustOptions("America/New_York", "en"), at: winter, want: "Mon, Jan 15, 2024 10:00 AM EST"},
+		{name: "ニューヨークの夏時間", opts: mustOptions("America/New_York", "en"), at: summer, want: "Mon, Jul 15, 2024 11:00 AM EDT"},
+		{name: "夏時間の開始直前", opts: mustOptions("America/New_York", "ja"), at: beforeDST, want: "2024年3月10日(日) 01:59 EST"},
+		{name: "夏時間の開始直後", opts: mustOptions("America/New_York", "ja"), at: afterDST, want: "2024年3月10日(日) 03:00 EDT"},
+		{name: "ゼロ値はUTCの日本語の書式", opts: DisplayOptions{}, at: summer, want: "2024年7月15日(月) 15:00 UTC"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.opts.FormatDateTime(tt.at); got != tt.want {
+				t.Errorf("FormatDateTime() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDisplayOptions_ForUser(t *testing.T) {
+	defaults, err := NewDisplayOptions("Asia/Tokyo", "ja")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		user         *User
+		wantLocation string
+		wantLocale   Locale
+		wantErr      bool
+	}{
+		{name: "ユーザーが登録されていない", user: nil, wantLocation: "Asia/Tokyo", wantLocale: LocaleJa},
+		{name: "設定がない", user: &User{ID: "user1"}, wantLocation: "Asia/Tokyo", wantLocale: LocaleJa},
+		{name: "タイムゾーンと言語を設定", user: &User{ID: "user1", TimeZone: "America/New_York", Locale: "en-US"}, wantLocation: "America/New_York", wantLocale: LocaleEn},
+		{name: "タイムゾーンだけ設定", user: &User{ID: "user1", TimeZone: "UTC"}, wantLocation: "UTC", wantLocale: LocaleJa},
+		{name: "不正なタイムゾーン", user: &User{ID: "user1", TimeZone: "Mars/Olympus"}, wantErr: true},
+		{name: "未対応の言語", user: &User{ID: "user1", Locale: "fr"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := defaults.ForUser(tt.user)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ForUser() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got.Location.String() != tt.wantLocation || got.Locale != tt.wantLocale {
+				t.Errorf("ForUser() = %s, %s, want %s, %s", got.Location, got.Locale, tt.wantLocation, tt.wantLocale)
+			}
+		})
+	}
+}
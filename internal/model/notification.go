@@ -26,18 +26,29 @@ type Notification struct {
 // NotificationRecord は通知のドメインモデルです
 // データベースに永続化される通知レコードと今回は一致しています
 type NotificationRecord struct {
-	ID        int              `db:"id"`
-	UserID    string           `db:"user_id"`
-	Title     string           `db:"title"`
-	Message   string           `db:"message"`
-	IsRead    bool             `db:"is_read"`
-	Type      NotificationType `db:"type"`
-	CreatedAt time.Time        `db:"created_at"`
-	UpdatedAt time.Time        `db:"updated_at"`
+	ID        int              `db:"id" json:"id"`
+	UserID    string           `db:"user_id" json:"user_id"`
+	Title     string           `db:"title" json:"title"`
+	Message   string           `db:"message" json:"message"`
+	IsRead    bool             `db:"is_read" json:"is_read"`
+	Type      NotificationType `db:"type" json:"type"`
+	CreatedAt time.Time        `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time        `db:"updated_at" json:"updated_at"`
+
+	// Deliveries は通知レコードと同じトランザクションで作成する外部への配信です
+	Deliveries []NotificationDelivery `db:"-" json:"-"`
+}
+
+// UserID は通知の宛先のユーザーIDを返します。Dataにuser_idがない場合は空を返します
+func (n Notification) UserID() string {
+	data, _ := n.Data.(map[string]interface{})
+	userID, _ := data["user_id"].(string)
+	return userID
 }
 
 // ToNotificationRecord は通知を通知レコードに変換します
-func (n Notification) ToNotificationRecord(petNameMap map[string]string) (*NotificationRecord, error) {
+// 予約日時はoptsのタイムゾーンと言語の書式で表示します
+func (n Notification) ToNotificationRecord(petNameMap map[string]string, opts DisplayOptions) (*NotificationRecord, error) {
 	// Dataフィールドの型をチェック
 	if _, ok := n.Data.(map[string]interface{}); !ok {
 		return nil, fmt.Errorf("invalid notification data format")
@@ -67,13 +78,45 @@ func (n Notification) ToNotificationRecord(petNameMap map[string]string) (*Notif
 			return nil, fmt.Errorf("unexpected type for date_time: %T", v)
 		}
 
+		reservedAt := opts.FormatDateTime(dateTime)
+		title := "予約が完了しました"
 		message := fmt.Sprintf(`予約が完了しました。見学をお楽しみください。
 予約日時: %s
-ペット名: %s`, dateTime.Format("2006-01-02 15:04"), petName)
+ペット名: %s`, reservedAt, petName)
+
+		status, _ := data["status"].(string)
+		promoted, _ := data["promoted"].(bool)
+		switch {
+		// 有効期限が切れた予約の通知
+		case ReservationStatus(status) == ReservationStatusExpired:
+			title = "予約の有効期限が切れました"
+			message = fmt.Sprintf(`予約が確定されないまま有効期限が切れました。お手数ですが再度ご予約ください。
+予約日時: %s
+ペット名: %s`, reservedAt, petName)
+		// キャンセルした予約の通知
+		case ReservationStatus(status) == ReservationStatusCancelled:
+			reason, _ := data["reason"].(string)
+			title = "予約がキャンセルされました"
+			message = fmt.Sprintf(`%s
+予約日時: %s
+ペット名: %s`, cancellationMessage(reason), reservedAt, petName)
+		// キャンセル待ちになった予約の通知
+		case ReservationStatus(status) == ReservationStatusWaitlisted:
+			title = "キャンセル待ちに登録しました"
+			message = fmt.Sprintf(`ご希望のペットには先に確定した予約があるため、キャンセル待ちに登録しました。予約が空き次第、順番にご案内します。
+予約日時: %s
+ペット名: %s`, reservedAt, petName)
+		// キャンセル待ちから繰り上げて確定した予約の通知
+		case promoted:
+			title = "キャンセル待ちの予約が確定しました"
+			message = fmt.Sprintf(`キャンセル待ちの予約が繰り上がり、予約が確定しました。見学をお楽しみください。
+予約日時: %s
+ペット名: %s`, reservedAt, petName)
+		}
 
 		return &NotificationRecord{
 			UserID:    data["user_id"].(string),
-			Title:     "予約が完了しました",
+			Title:     title,
 			Message:   message,
 			IsRead:    false,
 			Type:      NotificationTypeReservation,
@@ -93,16 +136,47 @@ func (n Notification) ToNotificationRecord(petNameMap map[string]string) (*Notif
 	}, nil
 }
 
+// cancellationMessages はキャンセルの理由ごとの、利用者に通知する説明です
+var cancellationMessages = map[string]string{
+	StatusReasonPetNotFound:            "ご希望のペットの情報が見つからないため、予約をキャンセルしました。",
+	StatusReasonPetAdopted:             "ご希望のペットの譲渡先が決まったため、予約をキャンセルしました。",
+	StatusReasonPetWithdrawn:           "ご希望のペットの掲載が終了したため、予約をキャンセルしました。",
+	StatusReasonPetUnavailable:         "ご希望のペットが現在見学を受け付けていないため、予約をキャンセルしました。",
+	StatusReasonOutsideOpeningHours:    "予約日時がショップの営業時間外のため、予約をキャンセルしました。",
+	StatusReasonUserAlreadyReservedPet: "同じペットの確定済みの予約があるため、予約をキャンセルしました。",
+	StatusReasonUserLimitExceeded:      "確定済みの予約が上限に達しているため、予約をキャンセルしました。",
+	StatusReasonPetAlreadyReserved:     "ご希望のペットに他の確定済みの予約があるため、予約をキャンセルしました。",
+}
+
+// cancellationMessage はキャンセルの理由の説明を返します
+// 追加したルールの理由など説明のない理由の場合は、理由を含めない説明を返します
+func cancellationMessage(reason string) string {
+	if message, ok := cancellationMessages[reason]; ok {
+		return message
+	}
+	return "予約をキャンセルしました。"
+}
+
 // NewReservationNotification は予約イベントから通知を作成します
 func NewReservationNotification(event ReservationEvent) Notification {
+	data := map[string]interface{}{
+		"user_id":   event.UserID,
+		"pet_id":    event.PetID,
+		"date_time": event.DateTime,
+	}
+	if event.Status != "" {
+		data["status"] = string(event.Status)
+	}
+	if event.Reason != "" {
+		data["reason"] = event.Reason
+	}
+	if event.Promoted {
+		data["promoted"] = true
+	}
 	return Notification{
 		Type:      NotificationTypeReservation,
 		CreatedAt: event.CreatedAt,
-		Data: map[string]interface{}{
-			"user_id":   event.UserID,
-			"pet_id":    event.PetID,
-			"date_time": event.DateTime,
-		},
+		Data:      data,
 	}
 }
 
@@ -119,3 +193,28 @@ func NewReservationNotificationRecord(event ReservationEvent) NotificationRecord
 		UpdatedAt: now,
 	}
 }
+
+// NotificationFilter は既読状態を一括で変更する通知の条件です
+// 指定されていない条件は絞り込みに利用しません
+type NotificationFilter struct {
+	UserID string
+	IDs    []int
+	Type   NotificationType
+	// CreatedFrom は作成日時の下限です (この日時を含む)
+	CreatedFrom *time.Time
+	// CreatedTo は作成日時の上限です (この日時を含まない)
+	CreatedTo *time.Time
+}
+
+// Validate は条件が指定されていることを検証します
+// 誤って全ユーザーの通知を変更しないよう、ユーザーID・通知ID・種類・作成日時のいずれかの指定を必須とします
+func (f NotificationFilter) Validate() error {
+	if f.UserID == "" && len(f.IDs) == 0 && f.Type == "" && f.CreatedFrom == nil && f.CreatedTo == nil {
+		return fmt.Errorf("at least one of user, ids, type or date range is required")
+	}
+	if f.CreatedFrom != nil && f.CreatedTo != nil && !f.CreatedFrom.Before(*f.CreatedTo) {
+		return fmt.Errorf("invalid date range: %s - %s",
+			f.CreatedFrom.Format(time.RFC3339), f.CreatedTo.Format(time.RFC3339))
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package model
 
 import (
+	"strings"
 	"testing"
 	"time"
 )
@@ -19,6 +20,8 @@ func TestToNotificationRecord(t *testing.T) {
 		wantErr       bool
 		expectedTitle string
 		expectedType  NotificationType
+		// wantMessage は通知の本文に含まれる文字列です
+		wantMessage string
 	}{
 		{
 			name: "予約通知の正常系",
@@ -36,6 +39,80 @@ func TestToNotificationRecord(t *testing.T) {
 			expectedTitle: "予約が完了しました",
 			expectedType:  NotificationTypeReservation,
 		},
+		{
+			name: "有効期限が切れた予約の通知",
+			notification: NewReservationNotification(ReservationEvent{
+				UserID:    "user1",
+				PetID:     "pet1",
+				DateTime:  now,
+				CreatedAt: now,
+				Status:    "expired",
+			}),
+			petNameMap:    petNameMap,
+			wantErr:       false,
+			expectedTitle: "予約の有効期限が切れました",
+			expectedType:  NotificationTypeReservation,
+		},
+		{
+			name: "キャンセルした予約の通知に理由を含める",
+			notification: NewReservationNotification(ReservationEvent{
+				UserID:    "user1",
+				PetID:     "pet1",
+				DateTime:  now,
+				CreatedAt: now,
+				Status:    ReservationStatusCancelled,
+				Reason:    StatusReasonPetAdopted,
+			}),
+			petNameMap:    petNameMap,
+			wantErr:       false,
+			expectedTitle: "予約がキャンセルされました",
+			expectedType:  NotificationTypeReservation,
+			wantMessage:   "譲渡先が決まったため",
+		},
+		{
+			name: "説明のない理由でキャンセルした予約の通知",
+			notification: NewReservationNotification(ReservationEvent{
+				UserID:    "user1",
+				PetID:     "pet1",
+				DateTime:  now,
+				CreatedAt: now,
+				Status:    ReservationStatusCancelled,
+				Reason:    "custom_rule",
+			}),
+			petNameMap:    petNameMap,
+			wantErr:       false,
+			expectedTitle: "予約がキャンセルされました",
+			expectedType:  NotificationTypeReservation,
+			wantMessage:   "予約をキャンセルしました。",
+		},
+		{
+			name: "キャンセル待ちになった予約の通知",
+			notification: NewReservationNotification(ReservationEvent{
+				UserID:    "user1",
+				PetID:     "pet1",
+				DateTime:  now,
+				CreatedAt: now,
+				Status:    ReservationStatusWaitlisted,
+			}),
+			petNameMap:    petNameMap,
+			wantErr:       false,
+			expectedTitle: "キャンセル待ちに登録しました",
+			expectedType:  NotificationTypeReservation,
+		},
+		{
+			name: "キャンセル待ちから繰り上げて確定した予約の通知",
+			notification: NewReservationNotification(ReservationEvent{
+				UserID:    "user1",
+				PetID:     "pet1",
+				DateTime:  now,
+				CreatedAt: now,
+				Promoted:  true,
+			}),
+			petNameMap:    petNameMap,
+			wantErr:       false,
+			expectedTitle: "キャンセル待ちの予約が確定しました",
+			expectedType:  NotificationTypeReservation,
+		},
 		{
 			name: "共通通知の正常系",
 			notification: Notification{
@@ -78,7 +155,7 @@ func TestToNotificationRecord(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := tt.notification.ToNotificationRecord(tt.petNameMap)
+			got, err := tt.notification.ToNotificationRecord(tt.petNameMap, DisplayOptions{})
 			if (err != nil) != tt.wantErr {
 				t.Errorf("ToNotificationRecord() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -92,6 +169,9 @@ func TestToNotificationRecord(t *testing.T) {
 			if got.Type != tt.expectedType {
 				t.Errorf("ToNotificationRecord() type = %v, want %v", got.Type, tt.expectedType)
 			}
+			if !strings.Contains(got.Message, tt.wantMessage) {
+				t.Errorf("ToNotificationRecord() message = %q, want containing %q", got.Message, tt.wantMessage)
+			}
 		})
 	}
 }
@@ -148,3 +228,58 @@ func TestNewReservationNotificationRecord(t *testing.T) {
 		t.Error("NewReservationNotificationRecord() created_at should be after now")
 	}
 }
+
+func TestNotificationFilter_Validate(t *testing.T) {
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+	tests := []struct {
+		name    string
+		filter  NotificationFilter
+		wantErr bool
+	}{
+		{name: "ユーザーの指定", filter: NotificationFilter{UserID: "user1"}},
+		{name: "種類と期間の指定", filter: NotificationFilter{Type: NotificationTypeCommon, CreatedFrom: &from, CreatedTo: &to}},
+		{name: "条件の指定がない", filter: NotificationFilter{}, wantErr: true},
+		{name: "期間の開始が終了以降", filter: NotificationFilter{CreatedFrom: &to, CreatedTo: &from}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.filter.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestToNotificationRecord_DisplayOptions(t *testing.T) {
+	notification := NewReservationNotification(ReservationEvent{
+		UserID:   "user1",
+		PetID:    "pet1",
+		DateTime: time.Date(2024, 6, 1, 1, 30, 0, 0, time.UTC),
+	})
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		opts DisplayOptions
+		want string
+	}{
+		{name: "UTC", opts: DisplayOptions{Location: time.UTC, Locale: LocaleJa}, want: "予約日時: 2024年6月1日(土) 01:30 UTC"},
+		{name: "JST", opts: DisplayOptions{Location: tokyo, Locale: LocaleJa}, want: "予約日時: 2024年6月1日(土) 10:30 JST"},
+		{name: "英語の書式", opts: DisplayOptions{Location: tokyo, Locale: LocaleEn}, want: "予約日時: Sat, Jun 1, 2024 10:30 AM JST"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record, err := notification.ToNotificationRecord(map[string]string{"pet1": "ポチ"}, tt.opts)
+			if err != nil {
+				t.Fatalf("ToNotificationRecord() error = %v", err)
+			}
+			if !strings.Contains(record.Message, tt.want) {
+				t.Errorf("message = %q, want to contain %q", record.Message, tt.want)
+			}
+		})
+	}
+}
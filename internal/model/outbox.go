@@ -0,0 +1,111 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// 予約イベントの種類
+const (
+	// ReservationEventConfirmed は予約が確定したことを表します
+	ReservationEventConfirmed = "reservation.confirmed"
+	// ReservationEventCancelled は予約がキャンセルされたことを表します
+	ReservationEventCancelled = "reservation.cancelled"
+	// ReservationEventExpired は確定されないまま予約の有効期限が切れたことを表します
+	ReservationEventExpired = "reservation.expired"
+	// ReservationEventWaitlisted は予約がキャンセル待ちになったことを表します
+	ReservationEventWaitlisted = "reservation.waitlisted"
+	// ReservationEventPromoted はキャンセル待ちの予約が繰り上げて確定したことを表します
+	ReservationEventPromoted = "reservation.promoted"
+)
+
+// OutboxEvent は予約のステータス変更と同じトランザクションで記録される、未配信のイベントです
+// 配信済みになるまで繰り返し配信されるため、受信側は同じEventIDのイベントを重複して受け取る可能性があります
+type OutboxEvent struct {
+	ID            int64           `db:"id" json:"id"`
+	ReservationID int64           `db:"reservation_id" json:"reservation_id"`
+	EventType     string          `db:"event_type" json:"event_type"`
+	Payload       json.RawMessage `db:"payload" json:"payload"`
+	CreatedAt     time.Time       `db:"created_at" json:"created_at"`
+	DeliveredAt   *time.Time      `db:"delivered_at" json:"delivered_at,omitempty"`
+}
+
+// NewReservationOutboxEvent は予約イベントから配信待ちのイベントを作成します
+func NewReservationOutboxEvent(reservationID int64, eventType string, event ReservationEvent) (*OutboxEvent, error) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal reservation event: %w", err)
+	}
+	return &OutboxEvent{
+		ReservationID: reservationID,
+		EventType:     eventType,
+		Payload:       payload,
+		CreatedAt:     time.Now(),
+	}, nil
+}
+
+// ReservationEvent はペイロードを予約イベントとして解析します
+func (e OutboxEvent) ReservationEvent() (ReservationEvent, error) {
+	var event ReservationEvent
+	if err := json.Unmarshal(e.Payload, &event); err != nil {
+		return event, fmt.Errorf("failed to parse payload of outbox event %d: %w", e.ID, err)
+	}
+	return event, nil
+}
+
+// OutboxMessage はキューやファイルに配信するメッセージです
+type OutboxMessage struct {
+	EventID       int64        `json:"event_id"`
+	EventType     string       `json:"event_type"`
+	ReservationID int64        `json:"reservation_id"`
+	Notification  Notification `json:"notification"`
+}
+
+// NewOutboxMessage は配信待ちのイベントから配信するメッセージを作成します
+func NewOutboxMessage(e OutboxEvent) (*OutboxMessage, error) {
+	event, err := e.ReservationEvent()
+	if err != nil {
+		return nil, err
+	}
+	return &OutboxMessage{
+		EventID:       e.ID,
+		EventType:     e.EventType,
+		ReservationID: e.ReservationID,
+		Notification:  NewReservationNotification(event),
+	}, nil
+}
+
+// ParseNotificationMessage はキューから受信したメッセージの本文を通知として解析します
+// アウトボックスから配信されたメッセージ(OutboxMessage)と、通知のみのメッセージの両方を受け付けます
+func ParseNotificationMessage(body []byte) (*Notification, error) {
+	var envelope struct {
+		Notification *Notification `json:"notification"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return nil, fmt.Errorf("failed to parse notification message: %w", err)
+	}
+
+	notification := envelope.Notification
+	if notification == nil {
+		notification = &Notification{}
+		if err := json.Unmarshal(body, notification); err != nil {
+			return nil, fmt.Errorf("failed to parse notification message: %w", err)
+		}
+	}
+
+	// ToNotificationRecordで必要となるフィールドを検証する
+	data, ok := notification.Data.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid notification data format")
+	}
+	if _, ok := data["user_id"].(string); !ok {
+		return nil, fmt.Errorf("user_id is not a string")
+	}
+	if notification.Type == NotificationTypeReservation {
+		if _, ok := data["pet_id"].(string); !ok {
+			return nil, fmt.Errorf("pet_id is not a string")
+		}
+	}
+	return notification, nil
+}
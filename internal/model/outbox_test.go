@@ -0,0 +1,47 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestParseNotificationMessage(t *testing.T) {
+	notification := NewReservationNotification(ReservationEvent{
+		UserID:    "user1",
+		PetID:     "pet1",
+		DateTime:  time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC),
+		CreatedAt: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+	})
+	plain, _ := json.Marshal(notification)
+	envelope, _ := json.Marshal(OutboxMessage{EventID: 1, EventType: ReservationEventConfirmed, Notification: notification})
+
+	tests := []struct {
+		name    string
+		body    string
+		wantErr bool
+	}{
+		{name: "通知のみのメッセージ", body: string(plain)},
+		{name: "アウトボックスから配信されたメッセージ", body: string(envelope)},
+		{name: "共通の通知はpet_idがなくてもよい", body: `{"type":"common","data":{"user_id":"user1"}}`},
+		{name: "JSONでない", body: `not json`, wantErr: true},
+		{name: "dataがオブジェクトでない", body: `{"type":"reservation","data":"broken"}`, wantErr: true},
+		{name: "user_idがない", body: `{"type":"common","data":{}}`, wantErr: true},
+		{name: "予約の通知にpet_idがない", body: `{"type":"reservation","data":{"user_id":"user1"}}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseNotificationMessage([]byte(tt.body))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseNotificationMessage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if _, err := got.ToNotificationRecord(map[string]string{"pet1": "TestPet"}, DisplayOptions{}); err != nil {
+				t.Errorf("ToNotificationRecord() error = %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+// PetStatus はペットの掲載状態を表します
+type PetStatus string
+
+const (
+	// PetStatusAvailable は見学の予約を受け付けていることを表します
+	PetStatusAvailable PetStatus = "available"
+	// PetStatusUnavailable は体調不良などで一時的に予約を受け付けていないことを表します
+	PetStatusUnavailable PetStatus = "unavailable"
+	// PetStatusWithdrawn は掲載を取り下げたことを表します
+	PetStatusWithdrawn PetStatus = "withdrawn"
+)
+
+// OpeningHours はショップの曜日ごとの営業時間です
+// OpensとClosesはショップのタイムゾーンでの時刻 ("10:00" または "10:00:00") で、Closesが "00:00" の場合は24時までを表します
+type OpeningHours struct {
+	Weekday time.Weekday `db:"weekday"`
+	Opens   string       `db:"opens_at"`
+	Closes  string       `db:"closes_at"`
+}
+
+// PetAvailability はペットの見学を予約できるかを判断するための情報です
+type PetAvailability struct {
+	PetID   string    `db:"id"`
+	Status  PetStatus `db:"status"`
+	Adopted bool      `db:"adopted"`
+	// ShopID はペットがいるショップのIDです。空の場合は営業時間を確認しません
+	ShopID   string `db:"shop_id"`
+	TimeZone string `db:"timezone"`
+	// OpeningHours はショップの営業時間です。登録されていない場合は営業時間を確認しません
+	OpeningHours []OpeningHours `db:"-"`
+}
+
+// UnavailableReason は予約日時atにペットを見学できない理由を返します。見学できる場合は空を返します
+// 理由は予約のステータスを変更した理由として記録します
+func (a PetAvailability) UnavailableReason(at time.Time) (string, error) {
+	switch {
+	case a.Adopted:
+		return StatusReasonPetAdopted, nil
+	case a.Status == PetStatusWithdrawn:
+		return StatusReasonPetWithdrawn, nil
+	case a.Status != PetStatusAvailable:
+		return StatusReasonPetUnavailable, nil
+	}
+
+	open, err := a.IsOpen(at)
+	if err != nil {
+		return "", err
+	}
+	if !open {
+		return StatusReasonOutsideOpeningHours, nil
+	}
+	return "", nil
+}
+
+// IsOpen は指定された時刻がショップの営業時間内かを返します
+func (a PetAvailability) IsOpen(at time.Time) (bool, error) {
+	if len(a.OpeningHours) == 0 {
+		return true, nil
+	}
+	loc, err := time.LoadLocation(a.TimeZone)
+	if err != nil {
+		return false, fmt.Errorf("invalid timezone %q of shop %s: %w", a.TimeZone, a.ShopID, err)
+	}
+
+	local := at.In(loc)
+	now := local.Hour()*60 + local.Minute()
+	for _, hours := range a.OpeningHours {
+		if hours.Weekday != local.Weekday() {
+			continue
+		}
+		opens, err := parseClock(hours.Opens)
+		if err != nil {
+			return false, err
+		}
+		closes, err := parseClock(hours.Closes)
+		if err != nil {
+			return false, err
+		}
+		if closes == 0 {
+			closes = 24 * 60
+		}
+		if opens <= now && now < closes {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,56 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPetAvailability_UnavailableReason(t *testing.T) {
+	jst := time.FixedZone("JST", 9*60*60)
+	// 2024-06-03は月曜日
+	monday := func(hour, minute int) time.Time {
+		return time.Date(2024, 6, 3, hour, minute, 0, 0, jst)
+	}
+	hours := []OpeningHours{
+		{Weekday: time.Monday, Opens: "10:00:00", Closes: "12:00:00"},
+		{Weekday: time.Monday, Opens: "13:00:00", Closes: "19:00:00"},
+		{Weekday: time.Saturday, Opens: "18:00", Closes: "00:00"},
+	}
+	open := PetAvailability{Status: PetStatusAvailable, ShopID: "shop1", TimeZone: "Asia/Tokyo", OpeningHours: hours}
+
+	tests := []struct {
+		name         string
+		availability PetAvailability
+		at           time.Time
+		want         string
+		wantErr      bool
+	}{
+		{name: "営業時間内", availability: open, at: monday(10, 0)},
+		{name: "昼休み", availability: open, at: monday(12, 0), want: StatusReasonOutsideOpeningHours},
+		{name: "閉店時刻", availability: open, at: monday(19, 0), want: StatusReasonOutsideOpeningHours},
+		{name: "UTCの予約日時をショップのタイムゾーンで判定", availability: open, at: monday(14, 30).UTC()},
+		{name: "営業時間のない曜日", availability: open, at: monday(14, 0).AddDate(0, 0, 1), want: StatusReasonOutsideOpeningHours},
+		{name: "24時までの営業", availability: open, at: time.Date(2024, 6, 8, 23, 30, 0, 0, jst)},
+		{name: "営業時間が登録されていない", availability: PetAvailability{Status: PetStatusAvailable}, at: monday(3, 0)},
+		{name: "譲渡済み", availability: PetAvailability{Status: PetStatusAvailable, Adopted: true}, at: monday(10, 0), want: StatusReasonPetAdopted},
+		{name: "掲載の取り下げ", availability: PetAvailability{Status: PetStatusWithdrawn}, at: monday(10, 0), want: StatusReasonPetWithdrawn},
+		{name: "一時的に受付停止", availability: PetAvailability{Status: PetStatusUnavailable}, at: monday(10, 0), want: StatusReasonPetUnavailable},
+		{
+			name:         "不正なタイムゾーン",
+			availability: PetAvailability{Status: PetStatusAvailable, TimeZone: "Invalid/Zone", OpeningHours: hours},
+			at:           monday(10, 0),
+			wantErr:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.availability.UnavailableReason(tt.at)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("UnavailableReason() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("UnavailableReason() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
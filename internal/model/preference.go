@@ -0,0 +1,108 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// NotificationChannelInApp はアプリ内の通知(notificationsテーブルの通知レコード)を表します
+// 受け取りを停止すると通知レコードも外部への配信も作成しません
+const NotificationChannelInApp NotificationChannel = "in_app"
+
+// NotificationPreference は通知の種類・チャネルごとの受け取り設定です
+// 設定がない組み合わせは受け取るものとして扱います
+type NotificationPreference struct {
+	UserID  string              `db:"user_id"`
+	Type    NotificationType    `db:"type"`
+	Channel NotificationChannel `db:"channel"`
+	Enabled bool                `db:"enabled"`
+}
+
+// QuietHours は外部への配信を控える時間帯です
+// StartとEndはTimeZoneでの時刻 ("22:00" または "22:00:00") で、Endが Start より前の場合は日をまたぐ時間帯を表します
+type QuietHours struct {
+	UserID   string `db:"user_id"`
+	Start    string `db:"start_time"`
+	End      string `db:"end_time"`
+	TimeZone string `db:"timezone"`
+}
+
+// Contains は指定された時刻がおやすみ時間帯に含まれるかを返します
+// StartとEndが同じ場合はおやすみ時間帯なしとして扱います
+func (q QuietHours) Contains(t time.Time) (bool, error) {
+	loc, err := time.LoadLocation(q.TimeZone)
+	if err != nil {
+		return false, fmt.Errorf("invalid quiet hours timezone %q: %w", q.TimeZone, err)
+	}
+	start, err := parseClock(q.Start)
+	if err != nil {
+		return false, err
+	}
+	end, err := parseClock(q.End)
+	if err != nil {
+		return false, err
+	}
+
+	local := t.In(loc)
+	now := local.Hour()*60 + local.Minute()
+	switch {
+	case start == end:
+		return false, nil
+	case start < end:
+		return start <= now && now < end, nil
+	default:
+		// 22:00-07:00のように日をまたぐ場合
+		return now >= start || now < end, nil
+	}
+}
+
+// parseClock は "15:04" または "15:04:05" 形式の時刻を0時からの分に変換します
+func parseClock(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range []string{"15:04", "15:04:05"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.Hour()*60 + t.Minute(), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid time of day %q", s)
+}
+
+// NotificationPreferences はユーザーの通知の受け取り設定です
+// nilの場合はすべての通知を受け取り、おやすみ時間帯もないものとして扱います
+type NotificationPreferences struct {
+	UserID      string
+	Preferences []NotificationPreference
+	QuietHours  *QuietHours
+}
+
+// AllowsNotification は指定された種類の通知レコードを作成するかを返します
+func (p *NotificationPreferences) AllowsNotification(notificationType NotificationType) bool {
+	return p.enabled(notificationType, NotificationChannelInApp)
+}
+
+// AllowsChannel は指定された種類の通知を外部のチャネルに配信するかを返します
+// アプリ内の通知の受け取りを停止している場合は、外部にも配信しません
+func (p *NotificationPreferences) AllowsChannel(notificationType NotificationType, channel NotificationChannel) bool {
+	return p.AllowsNotification(notificationType) && p.enabled(notificationType, channel)
+}
+
+// InQuietHours は指定された時刻がおやすみ時間帯に含まれるかを返します
+func (p *NotificationPreferences) InQuietHours(t time.Time) (bool, error) {
+	if p == nil || p.QuietHours == nil {
+		return false, nil
+	}
+	return p.QuietHours.Contains(t)
+}
+
+func (p *NotificationPreferences) enabled(notificationType NotificationType, channel NotificationChannel) bool {
+	if p == nil {
+		return true
+	}
+	for _, pref := range p.Preferences {
+		if pref.Type == notificationType && pref.Channel == channel {
+			return pref.Enabled
+		}
+	}
+	return true
+}
@@ -0,0 +1,111 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQuietHours_Contains(t *testing.T) {
+	tests := []struct {
+		name    string
+		quiet   QuietHours
+		at      time.Time
+		want    bool
+		wantErr bool
+	}{
+		{
+			name:  "日をまたぐ時間帯の夜",
+			quiet: QuietHours{Start: "22:00", End: "07:00", TimeZone: "Asia/Tokyo"},
+			at:    time.Date(2024, 1, 1, 14, 0, 0, 0, time.UTC), // 23:00 JST
+			want:  true,
+		},
+		{
+			name:  "日をまたぐ時間帯の朝",
+			quiet: QuietHours{Start: "22:00:00", End: "07:00:00", TimeZone: "Asia/Tokyo"},
+			at:    time.Date(2024, 1, 1, 21, 59, 0, 0, time.UTC), // 06:59 JST
+			want:  true,
+		},
+		{
+			name:  "終了時刻ちょうどは含まない",
+			quiet: QuietHours{Start: "22:00", End: "07:00", TimeZone: "Asia/Tokyo"},
+			at:    time.Date(2024, 1, 1, 22, 0, 0, 0, time.UTC), // 07:00 JST
+			want:  false,
+		},
+		{
+			name:  "タイムゾーンで判定する",
+			quiet: QuietHours{Start: "22:00", End: "07:00", TimeZone: "UTC"},
+			at:    time.Date(2024, 1, 1, 14, 0, 0, 0, time.UTC),
+			want:  false,
+		},
+		{
+			name:  "日をまたがない時間帯",
+			quiet: QuietHours{Start: "12:00", End: "13:00", TimeZone: "America/New_York"},
+			at:    time.Date(2024, 7, 1, 16, 30, 0, 0, time.UTC), // 12:30 EDT
+			want:  true,
+		},
+		{
+			name:  "開始と終了が同じ場合はおやすみ時間帯なし",
+			quiet: QuietHours{Start: "00:00", End: "00:00", TimeZone: "UTC"},
+			at:    time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			want:  false,
+		},
+		{
+			name:    "不正なタイムゾーン",
+			quiet:   QuietHours{Start: "22:00", End: "07:00", TimeZone: "Invalid/Zone"},
+			at:      time.Now(),
+			wantErr: true,
+		},
+		{
+			name:    "不正な時刻",
+			quiet:   QuietHours{Start: "25:00", End: "07:00", TimeZone: "UTC"},
+			at:      time.Now(),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.quiet.Contains(tt.at)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Contains() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Contains() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNotificationPreferences_Allows(t *testing.T) {
+	prefs := &NotificationPreferences{
+		Preferences: []NotificationPreference{
+			{Type: NotificationTypeReservation, Channel: NotificationChannelEmail, Enabled: false},
+			{Type: NotificationTypeCommon, Channel: NotificationChannelInApp, Enabled: false},
+		},
+	}
+
+	tests := []struct {
+		name             string
+		prefs            *NotificationPreferences
+		notificationType NotificationType
+		channel          NotificationChannel
+		wantNotification bool
+		wantChannel      bool
+	}{
+		{name: "設定がない場合は受け取る", prefs: nil, notificationType: NotificationTypeReservation, channel: NotificationChannelEmail, wantNotification: true, wantChannel: true},
+		{name: "チャネルだけ停止", prefs: prefs, notificationType: NotificationTypeReservation, channel: NotificationChannelEmail, wantNotification: true, wantChannel: false},
+		{name: "他のチャネルは受け取る", prefs: prefs, notificationType: NotificationTypeReservation, channel: NotificationChannelPush, wantNotification: true, wantChannel: true},
+		{name: "アプリ内の通知を停止すると外部にも配信しない", prefs: prefs, notificationType: NotificationTypeCommon, channel: NotificationChannelEmail, wantNotification: false, wantChannel: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.prefs.AllowsNotification(tt.notificationType); got != tt.wantNotification {
+				t.Errorf("AllowsNotification() = %v, want %v", got, tt.wantNotification)
+			}
+			if got := tt.prefs.AllowsChannel(tt.notificationType, tt.channel); got != tt.wantChannel {
+				t.Errorf("AllowsChannel() = %v, want %v", got, tt.wantChannel)
+			}
+		})
+	}
+}
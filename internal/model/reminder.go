@@ -0,0 +1,52 @@
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+// ReservationReminder は予約ごと・リマインドのタイミングごとに作成したリマインド通知の記録です
+// 同じ予約・タイミングのリマインドを重複して作成しないために利用します
+type ReservationReminder struct {
+	ReservationID int64 `db:"reservation_id"`
+	// WindowMinutes は予約日時の何分前のリマインドかを表します
+	WindowMinutes  int       `db:"window_minutes"`
+	NotificationID int       `db:"notification_id"`
+	CreatedAt      time.Time `db:"created_at"`
+}
+
+// NewReservationReminder は予約日時のwindow前のリマインドの記録を作成します
+func NewReservationReminder(reservationID int64, window time.Duration, notificationID int, now time.Time) ReservationReminder {
+	return ReservationReminder{
+		ReservationID:  reservationID,
+		WindowMinutes:  int(window / time.Minute),
+		NotificationID: notificationID,
+		CreatedAt:      now,
+	}
+}
+
+// NewReminderNotificationRecord は予約のリマインド通知の通知レコードを作成します
+// 予約日時はoptsのタイムゾーンと言語の書式で表示します
+func NewReminderNotificationRecord(event ReservationEvent, petName string, window time.Duration, now time.Time, opts DisplayOptions) NotificationRecord {
+	message := fmt.Sprintf(`見学の予約まであと%sです。
+予約日時: %s
+ペット名: %s`, formatWindow(window), opts.FormatDateTime(event.DateTime), petName)
+
+	return NotificationRecord{
+		UserID:    event.UserID,
+		Title:     "予約のリマインド",
+		Message:   message,
+		IsRead:    false,
+		Type:      NotificationTypeReservation,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// formatWindow はリマインドのタイミングを表示用の文字列に変換します
+func formatWindow(window time.Duration) string {
+	if window%time.Hour == 0 {
+		return fmt.Sprintf("%d時間", int(window/time.Hour))
+	}
+	return fmt.Sprintf("%d分", int(window/time.Minute))
+}
@@ -0,0 +1,33 @@
+package model
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewReminderNotificationRecord(t *testing.T) {
+	now := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	event := ReservationEvent{UserID: "user1", PetID: "pet1", DateTime: time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)}
+
+	tests := []struct {
+		name   string
+		window time.Duration
+		want   string
+	}{
+		{name: "時間単位", window: 24 * time.Hour, want: "あと24時間です"},
+		{name: "分単位", window: 30 * time.Minute, want: "あと30分です"},
+		{name: "予約日時", window: time.Hour, want: "予約日時: 2024年3月1日(金) 10:00 UTC"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := NewReminderNotificationRecord(event, "ポチ", tt.window, now, DisplayOptions{})
+			if record.UserID != "user1" || record.Type != NotificationTypeReservation || !record.CreatedAt.Equal(now) {
+				t.Errorf("record = %+v", record)
+			}
+			if !strings.Contains(record.Message, tt.want) || !strings.Contains(record.Message, "ペット名: ポチ") {
+				t.Errorf("message = %q, want to contain %q", record.Message, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package model
+
+import (
+	"cmp"
+	"slices"
+	"time"
+)
+
+// ReservationDailyStat は予約の受付日ごと・ステータスごとの集計です
+type ReservationDailyStat struct {
+	// Day は予約を受け付けた (作成した) 日です
+	Day    time.Time         `db:"day"`
+	Status ReservationStatus `db:"status"`
+	Count  int64             `db:"count"`
+	// Conflicts は他の予約と重なったためにキャンセルした件数です
+	Conflicts int64 `db:"conflicts"`
+	// Decided はバッチが確定またはキャンセルした件数です
+	Decided int64 `db:"decided"`
+	// PendingSeconds は確定またはキャンセルした予約が保留中だった秒数の合計です
+	PendingSeconds float64 `db:"pending_seconds"`
+}
+
+// ReservationDailySummary は予約の受付日ごとの集計です
+type ReservationDailySummary struct {
+	Date      string                      `json:"date"`
+	Total     int64                       `json:"total"`
+	Counts    map[ReservationStatus]int64 `json:"counts"`
+	Conflicts int64                       `json:"conflicts"`
+	Decided   int64                       `json:"decided"`
+	// ConflictRate は確定またはキャンセルした予約のうち、他の予約と重なった割合です
+	ConflictRate float64 `json:"conflict_rate"`
+	// AvgPendingSeconds は保留中から確定またはキャンセルまでの平均の秒数です
+	AvgPendingSeconds float64 `json:"avg_pending_seconds"`
+}
+
+// AvgPending は保留中から確定またはキャンセルまでの平均の時間を返します
+func (s ReservationDailySummary) AvgPending() time.Duration {
+	return time.Duration(s.AvgPendingSeconds * float64(time.Second)).Round(time.Second)
+}
+
+// SummarizeDaily はステータスごとの集計を受付日ごとにまとめ、日付の順に返します
+func SummarizeDaily(stats []ReservationDailyStat) []ReservationDailySummary {
+	byDate := make(map[string]*ReservationDailySummary)
+	pendingSeconds := make(map[string]float64)
+	for _, stat := range stats {
+		date := stat.Day.Format(time.DateOnly)
+		summary, ok := byDate[date]
+		if !ok {
+			summary = &ReservationDailySummary{Date: date, Counts: make(map[ReservationStatus]int64)}
+			byDate[date] = summary
+		}
+		summary.Total += stat.Count
+		summary.Counts[stat.Status] += stat.Count
+		summary.Conflicts += stat.Conflicts
+		summary.Decided += stat.Decided
+		pendingSeconds[date] += stat.PendingSeconds
+	}
+
+	summaries := make([]ReservationDailySummary, 0, len(byDate))
+	for date, summary := range byDate {
+		if summary.Decided > 0 {
+			summary.ConflictRate = float64(summary.Conflicts) / float64(summary.Decided)
+			summary.AvgPendingSeconds = pendingSeconds[date] / float64(summary.Decided)
+		}
+		summaries = append(summaries, *summary)
+	}
+	slices.SortFunc(summaries, func(a, b ReservationDailySummary) int { return cmp.Compare(a.Date, b.Date) })
+	return summaries
+}
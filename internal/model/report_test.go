@@ -0,0 +1,36 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSummarizeDaily(t *testing.T) {
+	day1 := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	day2 := day1.Add(24 * time.Hour)
+	stats := []ReservationDailyStat{
+		{Day: day2, Status: ReservationStatusPending, Count: 2},
+		{Day: day1, Status: ReservationStatusConfirmed, Count: 3, Decided: 3, PendingSeconds: 300},
+		{Day: day1, Status: ReservationStatusCancelled, Count: 1, Conflicts: 1, Decided: 1, PendingSeconds: 100},
+		{Day: day1, Status: ReservationStatusExpired, Count: 1},
+	}
+
+	got := SummarizeDaily(stats)
+	if len(got) != 2 || got[0].Date != "2024-06-01" || got[1].Date != "2024-06-02" {
+		t.Fatalf("SummarizeDaily() = %+v, want 2 days in order", got)
+	}
+	first := got[0]
+	if first.Total != 5 || first.Counts[ReservationStatusConfirmed] != 3 || first.Decided != 4 {
+		t.Errorf("summary = %+v", first)
+	}
+	if first.ConflictRate != 0.25 {
+		t.Errorf("ConflictRate = %v, want 0.25", first.ConflictRate)
+	}
+	if first.AvgPending() != 100*time.Second {
+		t.Errorf("AvgPending() = %v, want 100s", first.AvgPending())
+	}
+	// 確定・キャンセルした予約がない日は割合を0とする
+	if got[1].ConflictRate != 0 || got[1].AvgPendingSeconds != 0 {
+		t.Errorf("summary = %+v, want zero rates", got[1])
+	}
+}
@@ -1,23 +1,160 @@
 package model
 
-import "time"
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
 
+	"github.com/horsewin/echo-playground-batch-task/internal/common/redact"
+)
+
+// ErrReservationDateTimeRequired は予約日時が指定されていないことを表します
+var ErrReservationDateTimeRequired = errors.New("reservation_date_time is required")
+
+// Reservation は予約情報を表す構造体です
+// リポジトリはdbタグの列名で予約を読み込み・作成します
 type Reservation struct {
-	ID                  int64     `json:"id"`
-	UserID              string    `json:"user_id"`
-	UserName            string    `json:"user_name"`
-	Email               string    `json:"email"`
-	ReservationDateTime time.Time `json:"reservation_date_time"`
-	PetID               string    `json:"pet_id"`
-	CreatedAt           time.Time `json:"created_at"`
-	UpdatedAt           time.Time `json:"updated_at"`
-	Status              string    `json:"status"` // pending, confirmed, cancelled
+	ID                  int64             `db:"id" json:"id"`
+	UserID              string            `db:"user_id" json:"user_id"`
+	UserName            string            `db:"user_name" json:"user_name"`
+	Email               string            `db:"email" json:"email"`
+	ReservationDateTime time.Time         `db:"reservation_date_time" json:"reservation_date_time"`
+	PetID               string            `db:"pet_id" json:"pet_id"`
+	CreatedAt           time.Time         `db:"created_at" json:"created_at"`
+	UpdatedAt           time.Time         `db:"updated_at" json:"updated_at"`
+	Status              ReservationStatus `db:"status" json:"status"`
+	// LastError は予約バッチが最後に処理に失敗した原因です。処理に成功すると空になります
+	LastError string `db:"last_error" json:"last_error,omitempty"`
+}
+
+// Validate は新しく作成する予約の内容を検証します
+// 必須項目、メールアドレスの形式、予約日時がnowより後であることを確認し、すべての問題をまとめて返します
+func (r Reservation) Validate(now time.Time) error {
+	var errs []error
+	for _, f := range []struct{ name, value string }{
+		{"user_id", r.UserID},
+		{"user_name", r.UserName},
+		{"email", r.Email},
+		{"pet_id", r.PetID},
+	} {
+		if strings.TrimSpace(f.value) == "" {
+			errs = append(errs, fmt.Errorf("%s is required", f.name))
+		}
+	}
+	if r.Email != "" {
+		// 表示名付きのアドレス ("Name <a@example.com>") は受け付けない
+		if addr, err := mail.ParseAddress(r.Email); err != nil || addr.Address != r.Email {
+			errs = append(errs, errors.New("email is invalid"))
+		}
+	}
+	switch {
+	case r.ReservationDateTime.IsZero():
+		errs = append(errs, ErrReservationDateTimeRequired)
+	case !r.ReservationDateTime.After(now):
+		errs = append(errs, fmt.Errorf("reservation_date_time must be in the future: %s", r.ReservationDateTime.Format(time.RFC3339)))
+	}
+	return errors.Join(errs...)
+}
+
+// String はメールアドレスと氏名をマスクした予約情報を返します
+// ログに予約情報をそのまま出力しても個人情報が漏れないようにしています
+func (r Reservation) String() string {
+	return fmt.Sprintf("{ID:%d UserID:%s UserName:%s Email:%s ReservationDateTime:%s PetID:%s Status:%s}",
+		r.ID,
+		r.UserID,
+		redact.Field("user_name", r.UserName),
+		redact.Field("email", r.Email),
+		r.ReservationDateTime.Format(time.RFC3339),
+		r.PetID,
+		r.Status,
+	)
+}
+
+// ReservationFilter は取得する予約の条件です
+// 指定されていない条件は絞り込みに利用しません
+type ReservationFilter struct {
+	IDs      []int64             `json:"ids,omitempty"`
+	Statuses []ReservationStatus `json:"statuses,omitempty"`
+	PetIDs   []string            `json:"pet_ids,omitempty"`
+	UserIDs  []string            `json:"user_ids,omitempty"`
+	// From は予約日時の下限です (この日時を含む)
+	From *time.Time `json:"from,omitempty"`
+	// To は予約日時の上限です (この日時を含まない)
+	To *time.Time `json:"to,omitempty"`
+	// Limit は取得する予約の最大件数です。0の場合は制限しません
+	Limit int `json:"limit,omitempty"`
+	// SlotAfter は予約日時がこの日時より後の予約に限定します
+	// 確定済みの予約の再評価で過去の予約を変更しないためにバッチが設定し、コマンドラインからは指定しません
+	SlotAfter *time.Time `json:"-"`
+}
+
+// Validate は条件の値が正しいことを検証します
+func (f ReservationFilter) Validate() error {
+	for _, status := range f.Statuses {
+		if !status.Valid() {
+			return fmt.Errorf("unknown reservation status: %s", status)
+		}
+	}
+	if f.From != nil && f.To != nil && !f.From.Before(*f.To) {
+		return fmt.Errorf("invalid date range: %s - %s", f.From.Format(time.RFC3339), f.To.Format(time.RFC3339))
+	}
+	if f.Limit < 0 {
+		return fmt.Errorf("limit must not be negative: %d", f.Limit)
+	}
+	return nil
+}
+
+// Targeted はステータス以外の条件で予約を絞り込むかを返します
+func (f ReservationFilter) Targeted() bool {
+	return len(f.IDs) > 0 || len(f.PetIDs) > 0 || len(f.UserIDs) > 0 || f.From != nil || f.To != nil || f.Limit > 0
+}
+
+// String は指定された条件をログに出力する形式で返します
+func (f ReservationFilter) String() string {
+	var parts []string
+	add := func(name string, value any) {
+		parts = append(parts, fmt.Sprintf("%s=%v", name, value))
+	}
+	if len(f.IDs) > 0 {
+		add("ids", f.IDs)
+	}
+	if len(f.Statuses) > 0 {
+		add("statuses", f.Statuses)
+	}
+	if len(f.PetIDs) > 0 {
+		add("pet_ids", f.PetIDs)
+	}
+	if len(f.UserIDs) > 0 {
+		add("user_ids", f.UserIDs)
+	}
+	if f.From != nil {
+		add("from", f.From.Format(time.RFC3339))
+	}
+	if f.To != nil {
+		add("to", f.To.Format(time.RFC3339))
+	}
+	if f.Limit > 0 {
+		add("limit", f.Limit)
+	}
+	if f.SlotAfter != nil {
+		add("slot_after", f.SlotAfter.Format(time.RFC3339))
+	}
+	return "{" + strings.Join(parts, " ") + "}"
 }
 
 // ReservationEvent は予約処理完了時に発行されるイベントの構造体
+// Step Functionsのタスク出力としてマスクせずに送信されるため、氏名やメールアドレスなどの個人情報は含めません
 type ReservationEvent struct {
 	UserID    string    `json:"user_id"`
 	DateTime  time.Time `json:"date_time"`
 	PetID     string    `json:"pet_id"`
 	CreatedAt time.Time `json:"created_at"`
+	// Status は確定以外の予約のステータスです (expired, waitlisted, cancelled)。確定した予約の場合は空です
+	Status ReservationStatus `json:"status,omitempty"`
+	// Reason はキャンセルした理由です (pet_adopted など)。キャンセル以外の場合は空です
+	Reason string `json:"reason,omitempty"`
+	// Promoted はキャンセル待ちから繰り上げて確定したことを表します
+	Promoted bool `json:"promoted,omitempty"`
 }
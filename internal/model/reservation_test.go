@@ -1,6 +1,7 @@
 package model
 
 import (
+	"strings"
 	"testing"
 	"time"
 )
@@ -70,3 +71,103 @@ func TestReservation(t *testing.T) {
 		t.Errorf("Reservation.Status = %v, want %v", reservation.Status, "pending")
 	}
 }
+
+func TestReservation_String(t *testing.T) {
+	reservation := Reservation{
+		ID:       1,
+		UserID:   "user1",
+		UserName: "テスト太郎",
+		Email:    "test@example.com",
+		PetID:    "pet1",
+		Status:   "pending",
+	}
+
+	got := reservation.String()
+	if strings.Contains(got, "test@example.com") {
+		t.Errorf("Reservation.String() contains raw email: %s", got)
+	}
+	if strings.Contains(got, "テスト太郎") {
+		t.Errorf("Reservation.String() contains raw user name: %s", got)
+	}
+	if !strings.Contains(got, "user1") {
+		t.Errorf("Reservation.String() = %s, want to contain user id", got)
+	}
+}
+
+func TestReservation_Validate(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	valid := Reservation{
+		UserID:              "user1",
+		UserName:            "テスト太郎",
+		Email:               "test@example.com",
+		ReservationDateTime: now.Add(24 * time.Hour),
+		PetID:               "pet1",
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(r *Reservation)
+		wantErr string
+	}{
+		{name: "正常な予約", modify: func(r *Reservation) {}},
+		{name: "ユーザーIDが空", modify: func(r *Reservation) { r.UserID = " " }, wantErr: "user_id is required"},
+		{name: "メールアドレスの形式が不正", modify: func(r *Reservation) { r.Email = "test@" }, wantErr: "email is invalid"},
+		{name: "表示名付きのメールアドレス", modify: func(r *Reservation) { r.Email = "Taro <test@example.com>" }, wantErr: "email is invalid"},
+		{name: "予約日時が空", modify: func(r *Reservation) { r.ReservationDateTime = time.Time{} }, wantErr: "reservation_date_time is required"},
+		{name: "予約日時が過去", modify: func(r *Reservation) { r.ReservationDateTime = now }, wantErr: "must be in the future"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := valid
+			tt.modify(&r)
+			err := r.Validate(now)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() error = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReservationFilter_Validate(t *testing.T) {
+	from := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(24 * time.Hour)
+
+	tests := []struct {
+		name    string
+		filter  ReservationFilter
+		wantErr bool
+	}{
+		{name: "条件なし", filter: ReservationFilter{}},
+		{name: "ステータスと期間", filter: ReservationFilter{Statuses: []ReservationStatus{ReservationStatusConfirmed}, From: &from, To: &to}},
+		{name: "不明なステータス", filter: ReservationFilter{Statuses: []ReservationStatus{"done"}}, wantErr: true},
+		{name: "期間の上限が下限以前", filter: ReservationFilter{From: &to, To: &from}, wantErr: true},
+		{name: "最大件数が負", filter: ReservationFilter{Limit: -1}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.filter.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReservationFilter_Targeted(t *testing.T) {
+	from := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	if (ReservationFilter{Statuses: []ReservationStatus{ReservationStatusPending}}).Targeted() {
+		t.Error("Targeted() = true for status only filter")
+	}
+	for _, f := range []ReservationFilter{{IDs: []int64{1}}, {PetIDs: []string{"pet1"}}, {UserIDs: []string{"user1"}}, {From: &from}, {Limit: 10}} {
+		if !f.Targeted() {
+			t.Errorf("Targeted() = false for %s", f)
+		}
+	}
+}
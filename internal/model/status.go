@@ -0,0 +1,122 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// ReservationStatus は予約のステータスを表します
+type ReservationStatus string
+
+const (
+	// ReservationStatusPending は予約バッチによる確定を待っていることを表します
+	ReservationStatusPending ReservationStatus = "pending"
+	// ReservationStatusConfirmed は予約が確定したことを表します
+	ReservationStatusConfirmed ReservationStatus = "confirmed"
+	// ReservationStatusCancelled は予約がキャンセルされたことを表します
+	ReservationStatusCancelled ReservationStatus = "cancelled"
+	// ReservationStatusExpired は確定されないまま予約の有効期限が切れたことを表します
+	ReservationStatusExpired ReservationStatus = "expired"
+	// ReservationStatusFailed は予約バッチがステータスの変更をコミットできなかったことを表します
+	// 原因は予約のlast_errorに記録され、次回以降の実行で再度処理できます
+	ReservationStatusFailed ReservationStatus = "failed"
+	// ReservationStatusWaitlisted はペットに確定済みの予約があるため、キャンセル待ちとしていることを表します
+	// 確定済みの予約がキャンセル・期限切れになると、登録の早い順に繰り上げて確定します
+	ReservationStatusWaitlisted ReservationStatus = "waitlisted"
+)
+
+// reservationStatusTransitions は変更前のステータスごとに、変更できるステータスを表します
+// ここにないステータスの変更はリポジトリで拒否されます
+var reservationStatusTransitions = map[ReservationStatus][]ReservationStatus{
+	ReservationStatusPending:    {ReservationStatusConfirmed, ReservationStatusCancelled, ReservationStatusExpired, ReservationStatusFailed, ReservationStatusWaitlisted},
+	ReservationStatusConfirmed:  {ReservationStatusCancelled},
+	ReservationStatusFailed:     {ReservationStatusConfirmed, ReservationStatusCancelled, ReservationStatusExpired, ReservationStatusWaitlisted},
+	ReservationStatusWaitlisted: {ReservationStatusConfirmed, ReservationStatusCancelled, ReservationStatusExpired},
+}
+
+var (
+	// ErrInvalidStatusTransition は許可されていないステータスの変更であることを表します
+	ErrInvalidStatusTransition = errors.New("invalid reservation status transition")
+	// ErrStatusConflict は変更前のステータスが想定と異なり、他の処理が先にステータスを変更したことを表します
+	ErrStatusConflict = errors.New("reservation status was changed by another process")
+)
+
+// Valid は定義済みのステータスかどうかを返します
+func (s ReservationStatus) Valid() bool {
+	switch s {
+	case ReservationStatusPending, ReservationStatusConfirmed, ReservationStatusCancelled, ReservationStatusExpired, ReservationStatusFailed, ReservationStatusWaitlisted:
+		return true
+	}
+	return false
+}
+
+// CanTransitionTo はステータスをtoに変更できるかどうかを返します
+func (s ReservationStatus) CanTransitionTo(to ReservationStatus) bool {
+	return slices.Contains(reservationStatusTransitions[s], to)
+}
+
+// ValidateStatusTransition はステータスをfromからtoに変更できない場合にErrInvalidStatusTransitionを返します
+func ValidateStatusTransition(from, to ReservationStatus) error {
+	if !from.CanTransitionTo(to) {
+		return fmt.Errorf("%w: %q -> %q", ErrInvalidStatusTransition, from, to)
+	}
+	return nil
+}
+
+// ステータスを変更した理由
+const (
+	// StatusReasonPetAvailable はペットに確定済みの予約がないため確定したことを表します
+	StatusReasonPetAvailable = "pet_available"
+	// StatusReasonPetAlreadyReserved はペットに確定済みの予約があるため、キャンセル待ちまたはキャンセルとしたことを表します
+	StatusReasonPetAlreadyReserved = "pet_already_reserved"
+	// StatusReasonSlotPassed は確定されないまま予約日時を過ぎたことを表します
+	StatusReasonSlotPassed = "slot_passed"
+	// StatusReasonPendingTooLong は作成から一定時間が経過しても確定されなかったことを表します
+	StatusReasonPendingTooLong = "pending_too_long"
+	// StatusReasonProcessingFailed はリトライしてもステータスの変更をコミットできなかったことを表します
+	StatusReasonProcessingFailed = "processing_failed"
+	// StatusReasonPetNotFound はペットが登録されていないためキャンセルしたことを表します
+	StatusReasonPetNotFound = "pet_not_found"
+	// StatusReasonPetAdopted はペットの譲渡が決まったためキャンセルしたことを表します
+	StatusReasonPetAdopted = "pet_adopted"
+	// StatusReasonPetWithdrawn はペットの掲載が取り下げられたためキャンセルしたことを表します
+	StatusReasonPetWithdrawn = "pet_withdrawn"
+	// StatusReasonPetUnavailable はペットが一時的に予約を受け付けていないためキャンセルしたことを表します
+	StatusReasonPetUnavailable = "pet_unavailable"
+	// StatusReasonOutsideOpeningHours は予約日時がショップの営業時間外のためキャンセルしたことを表します
+	StatusReasonOutsideOpeningHours = "outside_opening_hours"
+	// StatusReasonUserAlreadyReservedPet は同じユーザーが同じペットに確定済みの予約を持っているためキャンセルしたことを表します
+	StatusReasonUserAlreadyReservedPet = "user_already_reserved_pet"
+	// StatusReasonUserLimitExceeded はユーザーの確定済みの予約が上限に達しているためキャンセルしたことを表します
+	StatusReasonUserLimitExceeded = "user_limit_exceeded"
+	// StatusReasonPromotedFromWaitlist はペットの確定済みの予約がなくなったため、キャンセル待ちから繰り上げて確定したことを表します
+	StatusReasonPromotedFromWaitlist = "promoted_from_waitlist"
+)
+
+// ReservationStatusChange は予約のステータスの変更と、その履歴(reservation_status_history)を表します
+type ReservationStatusChange struct {
+	ID            int64             `db:"id" json:"id"`
+	ReservationID int64             `db:"reservation_id" json:"reservation_id"`
+	FromStatus    ReservationStatus `db:"from_status" json:"from_status"`
+	ToStatus      ReservationStatus `db:"to_status" json:"to_status"`
+	Reason        string            `db:"reason" json:"reason"`
+	// RunID はステータスを変更したバッチの実行IDです
+	RunID     string    `db:"run_id" json:"run_id"`
+	ChangedAt time.Time `db:"changed_at" json:"changed_at"`
+	// Error は処理に失敗した原因です。変更後の予約のlast_errorに記録し、履歴には記録しません
+	Error string `db:"-" json:"error,omitempty"`
+}
+
+// NewReservationStatusChange は予約のステータスの変更を作成します
+func NewReservationStatusChange(reservationID int64, from, to ReservationStatus, reason, runID string, changedAt time.Time) *ReservationStatusChange {
+	return &ReservationStatusChange{
+		ReservationID: reservationID,
+		FromStatus:    from,
+		ToStatus:      to,
+		Reason:        reason,
+		RunID:         runID,
+		ChangedAt:     changedAt,
+	}
+}
@@ -0,0 +1,57 @@
+package model
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateStatusTransition(t *testing.T) {
+	tests := []struct {
+		name    string
+		from    ReservationStatus
+		to      ReservationStatus
+		wantErr bool
+	}{
+		{name: "保留中から確定", from: ReservationStatusPending, to: ReservationStatusConfirmed},
+		{name: "保留中からキャンセル", from: ReservationStatusPending, to: ReservationStatusCancelled},
+		{name: "保留中から期限切れ", from: ReservationStatusPending, to: ReservationStatusExpired},
+		{name: "保留中から失敗", from: ReservationStatusPending, to: ReservationStatusFailed},
+		{name: "失敗から確定", from: ReservationStatusFailed, to: ReservationStatusConfirmed},
+		{name: "失敗から期限切れ", from: ReservationStatusFailed, to: ReservationStatusExpired},
+		{name: "確定から失敗", from: ReservationStatusConfirmed, to: ReservationStatusFailed, wantErr: true},
+		{name: "保留中からキャンセル待ち", from: ReservationStatusPending, to: ReservationStatusWaitlisted},
+		{name: "キャンセル待ちから確定", from: ReservationStatusWaitlisted, to: ReservationStatusConfirmed},
+		{name: "キャンセル待ちから期限切れ", from: ReservationStatusWaitlisted, to: ReservationStatusExpired},
+		{name: "確定からキャンセル待ち", from: ReservationStatusConfirmed, to: ReservationStatusWaitlisted, wantErr: true},
+		{name: "確定からキャンセル", from: ReservationStatusConfirmed, to: ReservationStatusCancelled},
+		{name: "確定から期限切れ", from: ReservationStatusConfirmed, to: ReservationStatusExpired, wantErr: true},
+		{name: "キャンセルから確定", from: ReservationStatusCancelled, to: ReservationStatusConfirmed, wantErr: true},
+		{name: "期限切れから保留中", from: ReservationStatusExpired, to: ReservationStatusPending, wantErr: true},
+		{name: "同じステータス", from: ReservationStatusPending, to: ReservationStatusPending, wantErr: true},
+		{name: "未定義のステータス", from: "", to: ReservationStatusConfirmed, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateStatusTransition(tt.from, tt.to)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateStatusTransition() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidStatusTransition) {
+				t.Errorf("ValidateStatusTransition() error = %v, want ErrInvalidStatusTransition", err)
+			}
+		})
+	}
+}
+
+func TestReservationStatus_Valid(t *testing.T) {
+	for _, status := range []ReservationStatus{
+		ReservationStatusPending, ReservationStatusConfirmed, ReservationStatusCancelled, ReservationStatusExpired, ReservationStatusFailed, ReservationStatusWaitlisted,
+	} {
+		if !status.Valid() {
+			t.Errorf("%q.Valid() = false, want true", status)
+		}
+	}
+	if ReservationStatus("unknown").Valid() {
+		t.Error(`"unknown".Valid() = true, want false`)
+	}
+}
@@ -0,0 +1,183 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/aws/aws-xray-sdk-go/xray"
+	"github.com/horsewin/echo-playground-batch-task/internal/model"
+)
+
+// ErrCheckpointNotFound は指定されたRunIDのチェックポイントが存在しないことを表します
+var ErrCheckpointNotFound = errors.New("checkpoint not found")
+
+// CheckpointRepository はバッチ処理のチェックポイントの永続化を担当するインターフェースです
+type CheckpointRepository interface {
+	Get(ctx context.Context, runID string) (*model.Checkpoint, error)
+	Save(ctx context.Context, checkpoint *model.Checkpoint) error
+}
+
+// CheckpointRepositoryImpl はチェックポイントをデータベースに保存します
+type CheckpointRepositoryImpl struct {
+	db *DB
+}
+
+// NewCheckpointRepository は新しいCheckpointRepositoryを作成します
+func NewCheckpointRepository(db *DB) *CheckpointRepositoryImpl {
+	return &CheckpointRepositoryImpl{db: db}
+}
+
+// Get は指定されたRunIDのチェックポイントを取得します
+func (r *CheckpointRepositoryImpl) Get(ctx context.Context, runID string) (*model.Checkpoint, error) {
+	ctx, seg := xray.BeginSubsegment(ctx, "CheckpointRepository.Get")
+	defer seg.Close(nil)
+
+	query := `
+		SELECT
+			run_id,
+			job_name,
+			COALESCE(last_key, '') AS last_key,
+			COALESCE(scope, '') AS scope,
+			outcome,
+			processed,
+			COALESCE(error, '') AS error,
+			created_at,
+			updated_at
+		FROM batch_checkpoints
+		WHERE run_id = $1`
+
+	var checkpoint model.Checkpoint
+	err := r.db.QueryRowxContext(ctx, query, runID).StructScan(&checkpoint)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrCheckpointNotFound, runID)
+	}
+	if err != nil {
+		seg.Close(err)
+		return nil, fmt.Errorf("failed to get checkpoint: %w", err)
+	}
+
+	return &checkpoint, nil
+}
+
+// Save はチェックポイントを作成または更新します
+func (r *CheckpointRepositoryImpl) Save(ctx context.Context, checkpoint *model.Checkpoint) error {
+	ctx, seg := xray.BeginSubsegment(ctx, "CheckpointRepository.Save")
+	defer seg.Close(nil)
+
+	query := `
+		INSERT INTO batch_checkpoints (
+			run_id, job_name, last_key, scope, outcome, processed, error, created_at, updated_at
+		) VALUES (
+			$1, $2, $3, $4, $5, $6, $7, $8, $9
+		)
+		ON CONFLICT (run_id) DO UPDATE SET
+			last_key = EXCLUDED.last_key,
+			outcome = EXCLUDED.outcome,
+			processed = EXCLUDED.processed,
+			error = EXCLUDED.error,
+			updated_at = EXCLUDED.updated_at`
+
+	_, err := r.db.ExecContext(ctx, query,
+		checkpoint.RunID,
+		checkpoint.JobName,
+		checkpoint.LastKey,
+		checkpoint.Scope,
+		checkpoint.Outcome,
+		checkpoint.Processed,
+		checkpoint.Error,
+		checkpoint.CreatedAt,
+		checkpoint.UpdatedAt,
+	)
+	if err != nil {
+		seg.Close(err)
+		return fmt.Errorf("failed to save checkpoint: %w", err)
+	}
+
+	return nil
+}
+
+// runIDPattern はファイル名として安全なRunIDの形式です
+var runIDPattern = regexp.MustCompile(`^[A-Za-z0-9._\-]+$`)
+
+// FileCheckpointRepository はチェックポイントをローカルのJSONファイルに保存します
+// ローカル環境での実行やデータベースを利用できない環境向けの実装です
+type FileCheckpointRepository struct {
+	dir string
+}
+
+// NewFileCheckpointRepository は新しいFileCheckpointRepositoryを作成します
+func NewFileCheckpointRepository(dir string) *FileCheckpointRepository {
+	return &FileCheckpointRepository{dir: dir}
+}
+
+// Get は指定されたRunIDのチェックポイントを取得します
+func (r *FileCheckpointRepository) Get(ctx context.Context, runID string) (*model.Checkpoint, error) {
+	path, err := r.path(runID)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrCheckpointNotFound, runID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint file: %w", err)
+	}
+
+	var checkpoint model.Checkpoint
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoint file: %w", err)
+	}
+	return &checkpoint, nil
+}
+
+// Save はチェックポイントをファイルに書き込みます
+// 書き込み途中で中断されても壊れたファイルが残らないよう、一時ファイルに書き込んでからリネームします
+func (r *FileCheckpointRepository) Save(ctx context.Context, checkpoint *model.Checkpoint) error {
+	path, err := r.path(checkpoint.RunID)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(checkpoint, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal checkpoint: %w", err)
+	}
+
+	if err := os.MkdirAll(r.dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create checkpoint directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(r.dir, checkpoint.RunID+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create checkpoint file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write checkpoint file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close checkpoint file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to save checkpoint file: %w", err)
+	}
+
+	return nil
+}
+
+func (r *FileCheckpointRepository) path(runID string) (string, error) {
+	if !runIDPattern.MatchString(runID) {
+		return "", fmt.Errorf("invalid run id: %q", runID)
+	}
+	return filepath.Join(r.dir, runID+".json"), nil
+}
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/aws/aws-xray-sdk-go/xray"
+	"github.com/horsewin/echo-playground-batch-task/internal/common/redact"
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
 )
@@ -96,7 +97,7 @@ func (db *DB) QueryContext(ctx context.Context, query string, args ...interface{
 	defer seg.Close(nil)
 
 	// クエリをメタデータとして追加
-	if err := seg.AddMetadata("query", query); err != nil {
+	if err := seg.AddMetadata("query", redact.SQL(query)); err != nil {
 		log.Printf("Failed to add query metadata: %v", err)
 	}
 
@@ -118,7 +119,7 @@ func (db *DB) QueryxContext(ctx context.Context, query string, args ...interface
 	defer seg.Close(nil)
 
 	// クエリをメタデータとして追加
-	if err := seg.AddMetadata("query", query); err != nil {
+	if err := seg.AddMetadata("query", redact.SQL(query)); err != nil {
 		log.Printf("Failed to add query metadata: %v", err)
 	}
 
@@ -140,7 +141,7 @@ func (db *DB) ExecContext(ctx context.Context, query string, args ...interface{}
 	defer seg.Close(nil)
 
 	// クエリをメタデータとして追加
-	if err := seg.AddMetadata("query", query); err != nil {
+	if err := seg.AddMetadata("query", redact.SQL(query)); err != nil {
 		log.Printf("Failed to add query metadata: %v", err)
 	}
 
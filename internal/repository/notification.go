@@ -3,10 +3,12 @@ package repository
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/aws/aws-xray-sdk-go/xray"
 	"github.com/horsewin/echo-playground-batch-task/internal/model"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
 // NotificationRepository は通知の永続化を担当するインターフェースです
@@ -15,6 +17,7 @@ type NotificationRepository interface {
 	Create(ctx context.Context, tx *sqlx.Tx, record *model.NotificationRecord) error
 	GetByUserID(ctx context.Context, userID string) ([]model.NotificationRecord, error)
 	UpdateIsRead(ctx context.Context, tx *sqlx.Tx, id int, isRead bool) error
+	UpdateIsReadByFilter(ctx context.Context, filter model.NotificationFilter, isRead bool) (int64, error)
 }
 
 // NotificationRepositoryImpl は通知の永続化を担当します
@@ -51,11 +54,22 @@ func (r *NotificationRepositoryImpl) CreateNotifications(ctx context.Context, re
 		}
 	}()
 
-	for _, record := range records {
-		if err := r.Create(ctx, tx, &record); err != nil {
+	// 作成したIDを呼び出し元に返すため、要素のポインタを渡す
+	for i := range records {
+		if err = r.Create(ctx, tx, &records[i]); err != nil {
 			seg.Close(err)
 			return fmt.Errorf("failed to create notification: %w", err)
 		}
+
+		// 外部への配信を通知レコードと同じトランザクションで作成する
+		for j := range records[i].Deliveries {
+			delivery := &records[i].Deliveries[j]
+			delivery.NotificationID = records[i].ID
+			if err = r.createDelivery(ctx, tx, delivery); err != nil {
+				seg.Close(err)
+				return fmt.Errorf("failed to create notification delivery: %w", err)
+			}
+		}
 	}
 
 	if err = tx.Commit(); err != nil {
@@ -103,6 +117,27 @@ func (r *NotificationRepositoryImpl) Create(ctx context.Context, tx *sqlx.Tx, re
 	return nil
 }
 
+// createDelivery は通知レコードの外部への配信を作成します
+func (r *NotificationRepositoryImpl) createDelivery(ctx context.Context, tx *sqlx.Tx, delivery *model.NotificationDelivery) error {
+	query := `
+		INSERT INTO notification_deliveries (
+			notification_id, user_id, channel, address, status, attempts, created_at, updated_at
+		) VALUES (
+			$1, $2, $3, $4, $5, 0, $6, $6
+		)
+		RETURNING id`
+
+	return tx.QueryRowContext(ctx,
+		query,
+		delivery.NotificationID,
+		delivery.UserID,
+		delivery.Channel,
+		delivery.Address,
+		model.DeliveryStatusPending,
+		delivery.CreatedAt,
+	).Scan(&delivery.ID)
+}
+
 // BeginTx は新しいトランザクションを開始します
 func (r *NotificationRepositoryImpl) BeginTx() (*sqlx.Tx, error) {
 	ctx, seg := xray.BeginSegment(context.Background(), "NotificationRepository.BeginTx")
@@ -187,3 +222,56 @@ func (r *NotificationRepositoryImpl) UpdateIsRead(ctx context.Context, tx *sqlx.
 
 	return nil
 }
+
+// UpdateIsReadByFilter は条件に一致する通知の既読状態を1つのUPDATE文で変更し、変更した件数を返します
+// 既に指定された既読状態の通知は変更せず、件数にも含めません
+func (r *NotificationRepositoryImpl) UpdateIsReadByFilter(ctx context.Context, filter model.NotificationFilter, isRead bool) (int64, error) {
+	ctx, seg := xray.BeginSubsegment(ctx, "NotificationRepository.UpdateIsReadByFilter")
+	defer seg.Close(nil)
+
+	if err := filter.Validate(); err != nil {
+		seg.Close(err)
+		return 0, fmt.Errorf("invalid notification filter: %w", err)
+	}
+
+	conditions := []string{"is_read <> $1"}
+	args := []interface{}{isRead}
+	where := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+	if filter.UserID != "" {
+		where("user_id = $%d", filter.UserID)
+	}
+	if len(filter.IDs) > 0 {
+		where("id = ANY($%d)", pq.Array(filter.IDs))
+	}
+	if filter.Type != "" {
+		where("type = $%d", filter.Type)
+	}
+	if filter.CreatedFrom != nil {
+		where("created_at >= $%d", *filter.CreatedFrom)
+	}
+	if filter.CreatedTo != nil {
+		where("created_at < $%d", *filter.CreatedTo)
+	}
+
+	query := `
+		UPDATE notifications
+		SET is_read = $1, updated_at = CURRENT_TIMESTAMP
+		WHERE ` + strings.Join(conditions, "\n\t\tAND ")
+
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		seg.Close(err)
+		return 0, fmt.Errorf("failed to update notification is_read: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		seg.Close(err)
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rowsAffected, nil
+}
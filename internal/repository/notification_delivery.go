@@ -0,0 +1,190 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-xray-sdk-go/xray"
+	"github.com/horsewin/echo-playground-batch-task/internal/model"
+)
+
+// NotificationChannelRepository はユーザーごとの配信チャネルの設定の取得を担当するインターフェースです
+type NotificationChannelRepository interface {
+	GetByUserID(ctx context.Context, userID string) ([]model.NotificationChannelSetting, error)
+}
+
+// NotificationChannelRepositoryImpl はNotificationChannelRepositoryの実装です
+type NotificationChannelRepositoryImpl struct {
+	db *DB
+}
+
+// NewNotificationChannelRepository は新しいNotificationChannelRepositoryを作成します
+func NewNotificationChannelRepository(db *DB) *NotificationChannelRepositoryImpl {
+	return &NotificationChannelRepositoryImpl{db: db}
+}
+
+// GetByUserID は指定されたユーザーの配信チャネルの設定を取得します
+// 設定がないユーザーは、最新の予約のメールアドレスへのメール配信を設定として返します
+func (r *NotificationChannelRepositoryImpl) GetByUserID(ctx context.Context, userID string) ([]model.NotificationChannelSetting, error) {
+	ctx, seg := xray.BeginSubsegment(ctx, "NotificationChannelRepository.GetByUserID")
+	defer seg.Close(nil)
+
+	query := `
+		SELECT user_id, channel, address, enabled
+		FROM notification_channels
+		WHERE user_id = $1
+		ORDER BY channel ASC`
+
+	var settings []model.NotificationChannelSetting
+	if err := r.db.SelectContext(ctx, &settings, query, userID); err != nil {
+		seg.Close(err)
+		return nil, fmt.Errorf("failed to get notification channels: %w", err)
+	}
+	if len(settings) > 0 {
+		return settings, nil
+	}
+
+	query = `
+		SELECT user_id, 'email' AS channel, email AS address, TRUE AS enabled
+		FROM reservations
+		WHERE user_id = $1
+		AND email <> ''
+		ORDER BY created_at DESC
+		LIMIT 1`
+
+	if err := r.db.SelectContext(ctx, &settings, query, userID); err != nil {
+		seg.Close(err)
+		return nil, fmt.Errorf("failed to get reservation email: %w", err)
+	}
+
+	return settings, nil
+}
+
+// NotificationDeliveryRepository は通知の外部への配信状況の永続化を担当するインターフェースです
+// 配信状況は通知レコードと同じトランザクションでNotificationRepository.CreateNotificationsにより作成されます
+type NotificationDeliveryRepository interface {
+	GetUndelivered(ctx context.Context, afterID int64, maxAttempts int, limit int) ([]model.NotificationDelivery, error)
+	MarkSent(ctx context.Context, id int64) error
+	MarkFailed(ctx context.Context, id int64, lastError string, permanent bool) error
+	MarkSuppressed(ctx context.Context, id int64) error
+}
+
+// NotificationDeliveryRepositoryImpl はNotificationDeliveryRepositoryの実装です
+type NotificationDeliveryRepositoryImpl struct {
+	db *DB
+}
+
+// NewNotificationDeliveryRepository は新しいNotificationDeliveryRepositoryを作成します
+func NewNotificationDeliveryRepository(db *DB) *NotificationDeliveryRepositoryImpl {
+	return &NotificationDeliveryRepositoryImpl{db: db}
+}
+
+// GetUndelivered は配信待ちまたは配信に失敗した配信をIDの昇順に取得します
+// 試行回数がmaxAttempts以上の配信は対象外です
+func (r *NotificationDeliveryRepositoryImpl) GetUndelivered(ctx context.Context, afterID int64, maxAttempts int, limit int) ([]model.NotificationDelivery, error) {
+	ctx, seg := xray.BeginSubsegment(ctx, "NotificationDeliveryRepository.GetUndelivered")
+	defer seg.Close(nil)
+
+	query := `
+		SELECT
+			d.id,
+			d.notification_id,
+			d.user_id,
+			d.channel,
+			d.address,
+			d.status,
+			d.attempts,
+			COALESCE(d.last_error, '') AS last_error,
+			d.created_at,
+			d.updated_at,
+			d.delivered_at,
+			n.type,
+			n.title,
+			n.message
+		FROM notification_deliveries d
+		JOIN notifications n ON n.id = d.notification_id
+		WHERE d.status IN ($1, $2)
+		AND d.attempts < $3
+		AND d.id > $4
+		ORDER BY d.id ASC
+		LIMIT $5`
+
+	var deliveries []model.NotificationDelivery
+	err := r.db.SelectContext(ctx, &deliveries, query,
+		model.DeliveryStatusPending, model.DeliveryStatusFailed, maxAttempts, afterID, limit)
+	if err != nil {
+		seg.Close(err)
+		return nil, fmt.Errorf("failed to get undelivered notification deliveries: %w", err)
+	}
+
+	return deliveries, nil
+}
+
+// MarkSent は配信を配信済みにします
+func (r *NotificationDeliveryRepositoryImpl) MarkSent(ctx context.Context, id int64) error {
+	ctx, seg := xray.BeginSubsegment(ctx, "NotificationDeliveryRepository.MarkSent")
+	defer seg.Close(nil)
+
+	query := `
+		UPDATE notification_deliveries
+		SET status = $1,
+			attempts = attempts + 1,
+			last_error = NULL,
+			delivered_at = $2,
+			updated_at = $2
+		WHERE id = $3`
+
+	if _, err := r.db.ExecContext(ctx, query, model.DeliveryStatusSent, time.Now(), id); err != nil {
+		seg.Close(err)
+		return fmt.Errorf("failed to mark notification delivery as sent: %w", err)
+	}
+
+	return nil
+}
+
+// MarkFailed は配信の失敗を記録します
+// permanentがtrueの場合は再試行しても成功しないため、rejectedとして以降は配信しません
+func (r *NotificationDeliveryRepositoryImpl) MarkFailed(ctx context.Context, id int64, lastError string, permanent bool) error {
+	ctx, seg := xray.BeginSubsegment(ctx, "NotificationDeliveryRepository.MarkFailed")
+	defer seg.Close(nil)
+
+	status := model.DeliveryStatusFailed
+	if permanent {
+		status = model.DeliveryStatusRejected
+	}
+
+	query := `
+		UPDATE notification_deliveries
+		SET status = $1,
+			attempts = attempts + 1,
+			last_error = $2,
+			updated_at = $3
+		WHERE id = $4`
+
+	if _, err := r.db.ExecContext(ctx, query, status, lastError, time.Now(), id); err != nil {
+		seg.Close(err)
+		return fmt.Errorf("failed to mark notification delivery as failed: %w", err)
+	}
+
+	return nil
+}
+
+// MarkSuppressed は配信をユーザーの受け取り設定により配信しなかったものとして記録します
+func (r *NotificationDeliveryRepositoryImpl) MarkSuppressed(ctx context.Context, id int64) error {
+	ctx, seg := xray.BeginSubsegment(ctx, "NotificationDeliveryRepository.MarkSuppressed")
+	defer seg.Close(nil)
+
+	query := `
+		UPDATE notification_deliveries
+		SET status = $1,
+			updated_at = $2
+		WHERE id = $3`
+
+	if _, err := r.db.ExecContext(ctx, query, model.DeliveryStatusSuppressed, time.Now(), id); err != nil {
+		seg.Close(err)
+		return fmt.Errorf("failed to mark notification delivery as suppressed: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-xray-sdk-go/xray"
+	"github.com/horsewin/echo-playground-batch-task/internal/model"
+)
+
+// NotificationPreferenceRepository はユーザーの通知の受け取り設定の取得を担当するインターフェースです
+type NotificationPreferenceRepository interface {
+	GetByUserID(ctx context.Context, userID string) (*model.NotificationPreferences, error)
+}
+
+// NotificationPreferenceRepositoryImpl はNotificationPreferenceRepositoryの実装です
+type NotificationPreferenceRepositoryImpl struct {
+	db *DB
+}
+
+// NewNotificationPreferenceRepository は新しいNotificationPreferenceRepositoryを作成します
+func NewNotificationPreferenceRepository(db *DB) *NotificationPreferenceRepositoryImpl {
+	return &NotificationPreferenceRepositoryImpl{db: db}
+}
+
+// GetByUserID は指定されたユーザーの通知の受け取り設定とおやすみ時間帯を取得します
+func (r *NotificationPreferenceRepositoryImpl) GetByUserID(ctx context.Context, userID string) (*model.NotificationPreferences, error) {
+	ctx, seg := xray.BeginSubsegment(ctx, "NotificationPreferenceRepository.GetByUserID")
+	defer seg.Close(nil)
+
+	prefs := &model.NotificationPreferences{UserID: userID}
+
+	query := `
+		SELECT user_id, type, channel, enabled
+		FROM notification_preferences
+		WHERE user_id = $1`
+
+	if err := r.db.SelectContext(ctx, &prefs.Preferences, query, userID); err != nil {
+		seg.Close(err)
+		return nil, fmt.Errorf("failed to get notification preferences: %w", err)
+	}
+
+	query = `
+		SELECT user_id, start_time, end_time, timezone
+		FROM notification_quiet_hours
+		WHERE user_id = $1`
+
+	var quietHours model.QuietHours
+	err := r.db.GetContext(ctx, &quietHours, query, userID)
+	switch {
+	case err == nil:
+		prefs.QuietHours = &quietHours
+	case !errors.Is(err, sql.ErrNoRows):
+		seg.Close(err)
+		return nil, fmt.Errorf("failed to get notification quiet hours: %w", err)
+	}
+
+	return prefs, nil
+}
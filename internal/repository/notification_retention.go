@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aws/aws-xray-sdk-go/xray"
+	"github.com/horsewin/echo-playground-batch-task/internal/model"
+	"github.com/lib/pq"
+)
+
+// NotificationRetentionRepository は保持期間を過ぎた通知の取得と削除を担当するインターフェースです
+type NotificationRetentionRepository interface {
+	GetExpired(ctx context.Context, isRead bool, before time.Time, limit int) ([]model.NotificationRecord, error)
+	Delete(ctx context.Context, ids []int) (int64, error)
+}
+
+// NotificationRetentionRepositoryImpl はNotificationRetentionRepositoryの実装です
+type NotificationRetentionRepositoryImpl struct {
+	db *DB
+}
+
+// NewNotificationRetentionRepository は新しいNotificationRetentionRepositoryを作成します
+func NewNotificationRetentionRepository(db *DB) *NotificationRetentionRepositoryImpl {
+	return &NotificationRetentionRepositoryImpl{db: db}
+}
+
+// GetExpired は既読状態がisReadで、作成日時がbeforeより前の通知を古い順に最大limit件取得します
+func (r *NotificationRetentionRepositoryImpl) GetExpired(ctx context.Context, isRead bool, before time.Time, limit int) ([]model.NotificationRecord, error) {
+	ctx, seg := xray.BeginSubsegment(ctx, "NotificationRetentionRepository.GetExpired")
+	defer seg.Close(nil)
+
+	query := `
+		SELECT id, user_id, title, message, is_read, type, created_at, updated_at
+		FROM notifications
+		WHERE is_read = $1
+		AND created_at < $2
+		ORDER BY created_at ASC, id ASC
+		LIMIT $3`
+
+	var records []model.NotificationRecord
+	if err := r.db.SelectContext(ctx, &records, query, isRead, before, limit); err != nil {
+		seg.Close(err)
+		return nil, fmt.Errorf("failed to get expired notifications: %w", err)
+	}
+
+	return records, nil
+}
+
+// Delete は指定された通知を、通知を参照する配信とリマインドの記録とともに1トランザクションで削除し、削除した通知の件数を返します
+// リマインドの記録は予約日時の直前に作成されるため、保持期間を過ぎた通知のリマインドの対象は既に過去の予約です
+func (r *NotificationRetentionRepositoryImpl) Delete(ctx context.Context, ids []int) (int64, error) {
+	ctx, seg := xray.BeginSubsegment(ctx, "NotificationRetentionRepository.Delete")
+	defer seg.Close(nil)
+
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		seg.Close(err)
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	rollback := func(err error) {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			log.Printf("rollback failed: %v, original error: %v", rbErr, err)
+		}
+		seg.Close(err)
+	}
+
+	for _, query := range []string{
+		`DELETE FROM notification_deliveries WHERE notification_id = ANY($1)`,
+		`DELETE FROM reservation_reminders WHERE notification_id = ANY($1)`,
+	} {
+		if _, err := tx.ExecContext(ctx, query, pq.Array(ids)); err != nil {
+			rollback(err)
+			return 0, fmt.Errorf("failed to delete rows referencing notifications: %w", err)
+		}
+	}
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM notifications WHERE id = ANY($1)`, pq.Array(ids))
+	if err != nil {
+		rollback(err)
+		return 0, fmt.Errorf("failed to delete notifications: %w", err)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		rollback(err)
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		seg.Close(err)
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return deleted, nil
+}
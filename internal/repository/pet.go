@@ -5,6 +5,7 @@ import (
 	"fmt"
 
 	"github.com/aws/aws-xray-sdk-go/xray"
+	"github.com/horsewin/echo-playground-batch-task/internal/model"
 )
 
 // PetRepository はペット情報の永続化を担当するインターフェースです
@@ -43,3 +44,38 @@ func (r *PetRepositoryImpl) GetNameByID(ctx context.Context, petID string) (stri
 
 	return name, nil
 }
+
+// getPetAvailability はペットの掲載状態と、ペットがいるショップの営業時間を取得します
+// ペットが存在しない場合はsql.ErrNoRowsをラップしたエラーを返します
+func getPetAvailability(ctx context.Context, q queryer, petID string) (*model.PetAvailability, error) {
+	query := `
+		SELECT
+			p.id,
+			p.status,
+			p.adopted,
+			COALESCE(p.shop_id, '') AS shop_id,
+			COALESCE(s.timezone, '') AS timezone
+		FROM pets p
+		LEFT JOIN shops s ON s.id = p.shop_id
+		WHERE p.id = $1`
+
+	var availability model.PetAvailability
+	if err := q.GetContext(ctx, &availability, query, petID); err != nil {
+		return nil, fmt.Errorf("failed to get availability of pet %s: %w", petID, err)
+	}
+	if availability.ShopID == "" {
+		return &availability, nil
+	}
+
+	query = `
+		SELECT weekday, opens_at, closes_at
+		FROM shop_opening_hours
+		WHERE shop_id = $1
+		ORDER BY weekday, opens_at`
+
+	if err := q.SelectContext(ctx, &availability.OpeningHours, query, availability.ShopID); err != nil {
+		return nil, fmt.Errorf("failed to get opening hours of shop %s: %w", availability.ShopID, err)
+	}
+
+	return &availability, nil
+}
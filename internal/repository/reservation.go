@@ -4,19 +4,23 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-xray-sdk-go/xray"
-	"github.com/horsewin/echo-playground-batch-task/internal/common/models"
 	"github.com/horsewin/echo-playground-batch-task/internal/model"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
 type ReservationRepository interface {
 	BeginTx() (*sqlx.Tx, error)
-	GetReservationsByStatus(ctx context.Context, status string) ([]models.Reservation, error)
-	UpdateStatus(ctx context.Context, tx *sqlx.Tx, reservationID int64, status string) error
-	CheckExistingReservation(ctx context.Context, petID string) (bool, error)
+	GetReservations(ctx context.Context, filter model.ReservationFilter, after *model.ReservationCursor) ([]model.Reservation, error)
+	GetReservationsByStatusBetween(ctx context.Context, status model.ReservationStatus, from, to time.Time) ([]model.Reservation, error)
+	GetStaleReservations(ctx context.Context, slotBefore time.Time, createdBefore *time.Time) ([]model.Reservation, error)
+	GetPromotableWaitlisted(ctx context.Context, now time.Time) ([]model.Reservation, error)
+	UpdateStatus(ctx context.Context, tx *sqlx.Tx, change *model.ReservationStatusChange) error
+	GetStatusHistory(ctx context.Context, reservationID int64) ([]model.ReservationStatusChange, error)
 	CreateReservations(ctx context.Context, reservations []model.Reservation) error
 }
 
@@ -36,13 +40,8 @@ func (r *ReservationRepositoryImpl) BeginTx() (*sqlx.Tx, error) {
 	return r.db.BeginTxx(ctx, nil)
 }
 
-// GetReservationsByStatus は、指定されたステータスの予約を取得します
-func (r *ReservationRepositoryImpl) GetReservationsByStatus(ctx context.Context, status string) ([]models.Reservation, error) {
-	ctx, seg := xray.BeginSubsegment(ctx, "ReservationRepository.GetReservationsByStatus")
-	defer seg.Close(nil)
-
-	query := `
-		SELECT 
+// reservationColumns は予約の取得時に利用するカラムです
+const reservationColumns = `
 			id,
 			user_id,
 			user_name,
@@ -51,61 +50,187 @@ func (r *ReservationRepositoryImpl) GetReservationsByStatus(ctx context.Context,
 			pet_id,
 			created_at,
 			updated_at,
-			status
+			status,
+			COALESCE(last_error, '') AS last_error`
+
+// GetReservations は、条件に一致する予約のうち、処理位置より後の予約を予約日時の順に取得します
+// 処理位置がnilの場合は先頭から取得します。条件の最大件数は処理位置より後の予約に適用します
+func (r *ReservationRepositoryImpl) GetReservations(ctx context.Context, filter model.ReservationFilter, after *model.ReservationCursor) ([]model.Reservation, error) {
+	ctx, seg := xray.BeginSubsegment(ctx, "ReservationRepository.GetReservations")
+	defer seg.Close(nil)
+
+	if err := filter.Validate(); err != nil {
+		seg.Close(err)
+		return nil, fmt.Errorf("invalid reservation filter: %w", err)
+	}
+
+	conditions, args := reservationFilterConditions(filter)
+	if after != nil {
+		args = append(args, after.DateTime, after.ID)
+		conditions = append(conditions, fmt.Sprintf("(reservation_date_time, id) > ($%d, $%d)", len(args)-1, len(args)))
+	}
+	query := `
+		SELECT ` + reservationColumns + `
+		FROM reservations
+		WHERE ` + strings.Join(conditions, "\n\t\tAND ") + `
+		ORDER BY reservation_date_time ASC, id ASC`
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf("\n\t\tLIMIT $%d", len(args))
+	}
+
+	reservations, err := r.queryReservations(ctx, query, args...)
+	if err != nil {
+		seg.Close(err)
+		return nil, fmt.Errorf("failed to query reservations %s after %s: %w", filter, after, err)
+	}
+
+	return reservations, nil
+}
+
+// reservationFilterConditions は予約の条件をWHERE句の条件とプレースホルダーの値に変換します
+// 条件が指定されていない場合も、WHERE句を組み立てられるよう常に真となる条件を含めます
+func reservationFilterConditions(filter model.ReservationFilter) ([]string, []interface{}) {
+	conditions := []string{"TRUE"}
+	var args []interface{}
+	where := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+	if len(filter.IDs) > 0 {
+		where("id = ANY($%d)", pq.Array(filter.IDs))
+	}
+	if len(filter.Statuses) > 0 {
+		where("status = ANY($%d)", pq.Array(filter.Statuses))
+	}
+	if len(filter.PetIDs) > 0 {
+		where("pet_id = ANY($%d)", pq.Array(filter.PetIDs))
+	}
+	if len(filter.UserIDs) > 0 {
+		where("user_id = ANY($%d)", pq.Array(filter.UserIDs))
+	}
+	if filter.From != nil {
+		where("reservation_date_time >= $%d", *filter.From)
+	}
+	if filter.To != nil {
+		where("reservation_date_time < $%d", *filter.To)
+	}
+	if filter.SlotAfter != nil {
+		where("reservation_date_time > $%d", *filter.SlotAfter)
+	}
+	return conditions, args
+}
+
+// GetReservationsByStatusBetween は、指定されたステータスの予約のうち、予約日時がfromより後かつto以前の予約を取得します
+func (r *ReservationRepositoryImpl) GetReservationsByStatusBetween(ctx context.Context, status model.ReservationStatus, from, to time.Time) ([]model.Reservation, error) {
+	ctx, seg := xray.BeginSubsegment(ctx, "ReservationRepository.GetReservationsByStatusBetween")
+	defer seg.Close(nil)
+
+	query := `
+		SELECT ` + reservationColumns + `
 		FROM reservations
 		WHERE status = $1
-		ORDER BY reservation_date_time ASC
+		AND reservation_date_time > $2
+		AND reservation_date_time <= $3
+		ORDER BY reservation_date_time ASC, id ASC
 	`
 
-	rows, err := r.db.QueryContext(ctx, query, status)
+	reservations, err := r.queryReservations(ctx, query, status, from, to)
 	if err != nil {
 		seg.Close(err)
-		return nil, fmt.Errorf("failed to query reservations with status %s: %w", status, err)
-	}
-	defer rows.Close()
-
-	var reservations []models.Reservation
-	for rows.Next() {
-		var r models.Reservation
-		err := rows.Scan(
-			&r.ReservationID,
-			&r.UserID,
-			&r.UserName,
-			&r.Email,
-			&r.ReservationDateTime,
-			&r.PetID,
-			&r.CreatedAt,
-			&r.UpdatedAt,
-			&r.Status,
-		)
-		if err != nil {
-			seg.Close(err)
-			return nil, fmt.Errorf("failed to scan reservation row: %w", err)
-		}
-		reservations = append(reservations, r)
+		return nil, fmt.Errorf("failed to query reservations with status %s between %s and %s: %w",
+			status, from.Format(time.RFC3339), to.Format(time.RFC3339), err)
+	}
+
+	return reservations, nil
+}
+
+// GetStaleReservations は、予約日時がslotBefore以前、または作成日時がcreatedBefore以前の保留中の予約と、
+// 予約日時がslotBefore以前のキャンセル待ち・失敗の予約を取得します
+// createdBeforeがnilの場合は予約日時だけで判定します
+func (r *ReservationRepositoryImpl) GetStaleReservations(ctx context.Context, slotBefore time.Time, createdBefore *time.Time) ([]model.Reservation, error) {
+	ctx, seg := xray.BeginSubsegment(ctx, "ReservationRepository.GetStaleReservations")
+	defer seg.Close(nil)
+
+	query := `
+		SELECT ` + reservationColumns + `
+		FROM reservations
+		WHERE (status = 'pending' AND (reservation_date_time <= $1 OR created_at <= $2))
+		OR (status IN ('waitlisted', 'failed') AND reservation_date_time <= $1)
+		ORDER BY reservation_date_time ASC, id ASC
+	`
+
+	reservations, err := r.queryReservations(ctx, query, slotBefore, createdBefore)
+	if err != nil {
+		seg.Close(err)
+		return nil, fmt.Errorf("failed to query stale reservations: %w", err)
 	}
 
-	if err = rows.Err(); err != nil {
+	return reservations, nil
+}
+
+// GetPromotableWaitlisted は、確定済みの予約がなくなったペットごとに、最も早く作成されたキャンセル待ちの予約を取得します
+// 予約日時がnow以前のキャンセル待ちの予約は期限切れにするため含みません
+func (r *ReservationRepositoryImpl) GetPromotableWaitlisted(ctx context.Context, now time.Time) ([]model.Reservation, error) {
+	ctx, seg := xray.BeginSubsegment(ctx, "ReservationRepository.GetPromotableWaitlisted")
+	defer seg.Close(nil)
+
+	query := `
+		SELECT DISTINCT ON (w.pet_id) ` + reservationColumns + `
+		FROM reservations w
+		WHERE w.status = 'waitlisted'
+		AND w.reservation_date_time > $1
+		AND NOT EXISTS (
+			SELECT 1 FROM reservations c
+			WHERE c.pet_id = w.pet_id
+			AND c.status = 'confirmed'
+			AND c.reservation_date_time > $1
+		)
+		ORDER BY w.pet_id ASC, w.created_at ASC, w.id ASC
+	`
+
+	reservations, err := r.queryReservations(ctx, query, now)
+	if err != nil {
 		seg.Close(err)
-		return nil, fmt.Errorf("error iterating reservation rows: %w", err)
+		return nil, fmt.Errorf("failed to query promotable waitlisted reservations: %w", err)
 	}
 
 	return reservations, nil
 }
 
-// UpdateStatus は予約のステータスを更新します
-func (r *ReservationRepositoryImpl) UpdateStatus(ctx context.Context, tx *sqlx.Tx, reservationID int64, status string) error {
+// queryReservations は予約を取得するクエリを実行し、結果を読み込みます
+// クエリはreservationColumnsの列を取得する必要があります
+func (r *ReservationRepositoryImpl) queryReservations(ctx context.Context, query string, args ...interface{}) ([]model.Reservation, error) {
+	var reservations []model.Reservation
+	if err := r.db.SelectContext(ctx, &reservations, query, args...); err != nil {
+		return nil, err
+	}
+	return reservations, nil
+}
+
+// UpdateStatus は予約のステータスを変更し、変更の履歴をreservation_status_historyに記録します
+// 許可されていないステータスの変更の場合はErrInvalidStatusTransitionを返します
+// 予約のステータスが変更前のステータスと異なる場合は、他の処理が先に変更したものとしてErrStatusConflictを返します
+// 予約のlast_errorにはchange.Errorを記録します。空の場合は以前の失敗の原因を消去します
+func (r *ReservationRepositoryImpl) UpdateStatus(ctx context.Context, tx *sqlx.Tx, change *model.ReservationStatusChange) error {
 	ctx, seg := xray.BeginSubsegment(ctx, "ReservationRepository.UpdateStatus")
 	defer seg.Close(nil)
 
+	if err := model.ValidateStatusTransition(change.FromStatus, change.ToStatus); err != nil {
+		seg.Close(err)
+		return fmt.Errorf("failed to update status of reservation %d: %w", change.ReservationID, err)
+	}
+
 	query := `
 		UPDATE reservations
 		SET status = $1,
-			updated_at = $2
+			updated_at = $2,
+			last_error = NULLIF($5, '')
 		WHERE id = $3
+		AND status = $4
 	`
 
-	result, err := tx.ExecContext(ctx, query, status, time.Now(), reservationID)
+	result, err := tx.ExecContext(ctx, query, change.ToStatus, change.ChangedAt, change.ReservationID, change.FromStatus, change.Error)
 	if err != nil {
 		seg.Close(err)
 		return fmt.Errorf("failed to update reservation status: %w", err)
@@ -118,37 +243,68 @@ func (r *ReservationRepositoryImpl) UpdateStatus(ctx context.Context, tx *sqlx.T
 	}
 
 	if rowsAffected == 0 {
-		err := fmt.Errorf("no reservation found with ID %d", reservationID)
+		err := fmt.Errorf("no reservation found with ID %d and status %s: %w",
+			change.ReservationID, change.FromStatus, model.ErrStatusConflict)
 		seg.Close(err)
 		return err
 	}
 
+	query = `
+		INSERT INTO reservation_status_history (
+			reservation_id,
+			from_status,
+			to_status,
+			reason,
+			run_id,
+			changed_at
+		) VALUES (
+			$1, $2, $3, $4, $5, $6
+		)
+		RETURNING id
+	`
+
+	err = tx.QueryRowContext(ctx, query,
+		change.ReservationID,
+		change.FromStatus,
+		change.ToStatus,
+		change.Reason,
+		change.RunID,
+		change.ChangedAt,
+	).Scan(&change.ID)
+	if err != nil {
+		seg.Close(err)
+		return fmt.Errorf("failed to record reservation status history: %w", err)
+	}
+
 	return nil
 }
 
-// CheckExistingReservation は、指定されたペットIDに対して予約が存在するかチェックします
-func (r *ReservationRepositoryImpl) CheckExistingReservation(ctx context.Context, petID string) (bool, error) {
-	ctx, seg := xray.BeginSubsegment(ctx, "ReservationRepository.CheckExistingReservation")
+// GetStatusHistory は指定された予約のステータスの変更履歴を古い順に取得します
+func (r *ReservationRepositoryImpl) GetStatusHistory(ctx context.Context, reservationID int64) ([]model.ReservationStatusChange, error) {
+	ctx, seg := xray.BeginSubsegment(ctx, "ReservationRepository.GetStatusHistory")
 	defer seg.Close(nil)
 
 	query := `
-		SELECT EXISTS (
-			SELECT 1
-			FROM reservations
-			WHERE pet_id = $1
-			AND status = 'confirmed'
-			AND reservation_date_time > NOW()
-		)
+		SELECT
+			id,
+			reservation_id,
+			from_status,
+			to_status,
+			reason,
+			run_id,
+			changed_at
+		FROM reservation_status_history
+		WHERE reservation_id = $1
+		ORDER BY changed_at ASC, id ASC
 	`
 
-	var exists bool
-	err := r.db.QueryRowContext(ctx, query, petID).Scan(&exists)
-	if err != nil {
+	var history []model.ReservationStatusChange
+	if err := r.db.SelectContext(ctx, &history, query, reservationID); err != nil {
 		seg.Close(err)
-		return false, fmt.Errorf("failed to check existing reservation: %w", err)
+		return nil, fmt.Errorf("failed to get status history of reservation %d: %w", reservationID, err)
 	}
 
-	return exists, nil
+	return history, nil
 }
 
 // CreateReservations は複数の予約を作成します
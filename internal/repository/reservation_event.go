@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-xray-sdk-go/xray"
+	"github.com/horsewin/echo-playground-batch-task/internal/model"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// ReservationEventRepository は予約イベントのアウトボックス(reservation_events)の永続化を担当するインターフェースです
+type ReservationEventRepository interface {
+	Create(ctx context.Context, tx *sqlx.Tx, event *model.OutboxEvent) error
+	GetUndelivered(ctx context.Context, limit int) ([]model.OutboxEvent, error)
+	MarkDelivered(ctx context.Context, ids []int64) error
+}
+
+// ReservationEventRepositoryImpl は予約イベントをデータベースに保存します
+type ReservationEventRepositoryImpl struct {
+	db *DB
+}
+
+// NewReservationEventRepository は新しいReservationEventRepositoryを作成します
+func NewReservationEventRepository(db *DB) *ReservationEventRepositoryImpl {
+	return &ReservationEventRepositoryImpl{db: db}
+}
+
+// Create は予約イベントを記録します
+// 予約のステータス変更と同じトランザクションで呼び出すことで、ステータス変更とイベントの記録を不可分にします
+func (r *ReservationEventRepositoryImpl) Create(ctx context.Context, tx *sqlx.Tx, event *model.OutboxEvent) error {
+	ctx, seg := xray.BeginSubsegment(ctx, "ReservationEventRepository.Create")
+	defer seg.Close(nil)
+
+	query := `
+		INSERT INTO reservation_events (
+			reservation_id,
+			event_type,
+			payload,
+			created_at
+		) VALUES (
+			$1, $2, $3, $4
+		)
+		RETURNING id
+	`
+
+	// jsonbカラムに[]byteを渡すとbyteaとして送信されるため、文字列として渡す
+	err := tx.QueryRowxContext(ctx, query,
+		event.ReservationID,
+		event.EventType,
+		string(event.Payload),
+		event.CreatedAt,
+	).Scan(&event.ID)
+	if err != nil {
+		seg.Close(err)
+		return fmt.Errorf("failed to create reservation event: %w", err)
+	}
+
+	return nil
+}
+
+// GetUndelivered は未配信の予約イベントを古い順に取得します
+// limitが0以下の場合は全件を取得します
+func (r *ReservationEventRepositoryImpl) GetUndelivered(ctx context.Context, limit int) ([]model.OutboxEvent, error) {
+	ctx, seg := xray.BeginSubsegment(ctx, "ReservationEventRepository.GetUndelivered")
+	defer seg.Close(nil)
+
+	query := `
+		SELECT
+			id,
+			reservation_id,
+			event_type,
+			payload,
+			created_at,
+			delivered_at
+		FROM reservation_events
+		WHERE delivered_at IS NULL
+		ORDER BY id ASC
+	`
+	args := []interface{}{}
+	if limit > 0 {
+		query += ` LIMIT $1`
+		args = append(args, limit)
+	}
+
+	var events []model.OutboxEvent
+	if err := r.db.SelectContext(ctx, &events, query, args...); err != nil {
+		seg.Close(err)
+		return nil, fmt.Errorf("failed to get undelivered reservation events: %w", err)
+	}
+
+	return events, nil
+}
+
+// MarkDelivered は予約イベントを配信済みにします
+func (r *ReservationEventRepositoryImpl) MarkDelivered(ctx context.Context, ids []int64) error {
+	ctx, seg := xray.BeginSubsegment(ctx, "ReservationEventRepository.MarkDelivered")
+	defer seg.Close(nil)
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	query := `
+		UPDATE reservation_events
+		SET delivered_at = $1
+		WHERE id = ANY($2)
+		AND delivered_at IS NULL
+	`
+
+	if _, err := r.db.ExecContext(ctx, query, time.Now(), pq.Array(ids)); err != nil {
+		seg.Close(err)
+		return fmt.Errorf("failed to mark reservation events as delivered: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-xray-sdk-go/xray"
+	"github.com/horsewin/echo-playground-batch-task/internal/model"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// ReservationReminderRepository は作成済みのリマインド通知の記録(reservation_reminders)を担当するインターフェースです
+type ReservationReminderRepository interface {
+	Create(ctx context.Context, tx *sqlx.Tx, reminder *model.ReservationReminder) (bool, error)
+	GetByReservationIDs(ctx context.Context, reservationIDs []int64) ([]model.ReservationReminder, error)
+}
+
+// ReservationReminderRepositoryImpl はReservationReminderRepositoryの実装です
+type ReservationReminderRepositoryImpl struct {
+	db *DB
+}
+
+// NewReservationReminderRepository は新しいReservationReminderRepositoryを作成します
+func NewReservationReminderRepository(db *DB) *ReservationReminderRepositoryImpl {
+	return &ReservationReminderRepositoryImpl{db: db}
+}
+
+// Create はリマインド通知を作成したことを記録します
+// 同じ予約・タイミングの記録が既にある場合は記録せずにfalseを返します
+// 通知レコードと同じトランザクションで呼び出し、falseの場合はロールバックしてください
+func (r *ReservationReminderRepositoryImpl) Create(ctx context.Context, tx *sqlx.Tx, reminder *model.ReservationReminder) (bool, error) {
+	ctx, seg := xray.BeginSubsegment(ctx, "ReservationReminderRepository.Create")
+	defer seg.Close(nil)
+
+	query := `
+		INSERT INTO reservation_reminders (
+			reservation_id,
+			window_minutes,
+			notification_id,
+			created_at
+		) VALUES (
+			$1, $2, $3, $4
+		)
+		ON CONFLICT (reservation_id, window_minutes) DO NOTHING
+	`
+
+	result, err := tx.ExecContext(ctx, query,
+		reminder.ReservationID,
+		reminder.WindowMinutes,
+		reminder.NotificationID,
+		reminder.CreatedAt,
+	)
+	if err != nil {
+		seg.Close(err)
+		return false, fmt.Errorf("failed to create reservation reminder: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		seg.Close(err)
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rowsAffected > 0, nil
+}
+
+// GetByReservationIDs は指定された予約の作成済みのリマインド通知の記録を取得します
+func (r *ReservationReminderRepositoryImpl) GetByReservationIDs(ctx context.Context, reservationIDs []int64) ([]model.ReservationReminder, error) {
+	ctx, seg := xray.BeginSubsegment(ctx, "ReservationReminderRepository.GetByReservationIDs")
+	defer seg.Close(nil)
+
+	if len(reservationIDs) == 0 {
+		return nil, nil
+	}
+
+	query := `
+		SELECT
+			reservation_id,
+			window_minutes,
+			notification_id,
+			created_at
+		FROM reservation_reminders
+		WHERE reservation_id = ANY($1)
+	`
+
+	var reminders []model.ReservationReminder
+	if err := r.db.SelectContext(ctx, &reminders, query, pq.Array(reservationIDs)); err != nil {
+		seg.Close(err)
+		return nil, fmt.Errorf("failed to get reservation reminders: %w", err)
+	}
+
+	return reminders, nil
+}
@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-xray-sdk-go/xray"
+	"github.com/horsewin/echo-playground-batch-task/internal/model"
+)
+
+// ReservationReportRepository は予約のエクスポートと集計を担当するインターフェースです
+type ReservationReportRepository interface {
+	EachReservation(ctx context.Context, filter model.ReservationFilter, fn func(model.Reservation) error) error
+	GetDailyStats(ctx context.Context, from, to time.Time, loc *time.Location) ([]model.ReservationDailyStat, error)
+}
+
+// ReservationReportRepositoryImpl はReservationReportRepositoryの実装です
+type ReservationReportRepositoryImpl struct {
+	db *DB
+}
+
+// NewReservationReportRepository は新しいReservationReportRepositoryを作成します
+func NewReservationReportRepository(db *DB) *ReservationReportRepositoryImpl {
+	return &ReservationReportRepositoryImpl{db: db}
+}
+
+// EachReservation は条件に一致する予約を予約日時の順に1件ずつ読み込み、fnを呼び出します
+// すべての予約をメモリに読み込まないよう、結果を読みながらfnを呼び出します。fnがエラーを返した場合は中断します
+func (r *ReservationReportRepositoryImpl) EachReservation(ctx context.Context, filter model.ReservationFilter, fn func(model.Reservation) error) error {
+	ctx, seg := xray.BeginSubsegment(ctx, "ReservationReportRepository.EachReservation")
+	defer seg.Close(nil)
+
+	if err := filter.Validate(); err != nil {
+		seg.Close(err)
+		return fmt.Errorf("invalid reservation filter: %w", err)
+	}
+
+	conditions, args := reservationFilterConditions(filter)
+	query := `
+		SELECT ` + reservationColumns + `
+		FROM reservations
+		WHERE ` + strings.Join(conditions, "\n\t\tAND ") + `
+		ORDER BY reservation_date_time ASC, id ASC`
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf("\n\t\tLIMIT $%d", len(args))
+	}
+
+	rows, err := r.db.QueryxContext(ctx, query, args...)
+	if err != nil {
+		seg.Close(err)
+		return fmt.Errorf("failed to query reservations: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var reservation model.Reservation
+		if err := rows.StructScan(&reservation); err != nil {
+			seg.Close(err)
+			return fmt.Errorf("failed to scan reservation row: %w", err)
+		}
+		if err := fn(reservation); err != nil {
+			seg.Close(err)
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		seg.Close(err)
+		return fmt.Errorf("error iterating reservation rows: %w", err)
+	}
+
+	return nil
+}
+
+// GetDailyStats は作成日時がfrom以降かつtoより前の予約を、locでの受付日・ステータスごとに集計します
+// 保留中から確定・キャンセル・キャンセル待ちまでの時間は、ステータスの変更履歴から求めます
+func (r *ReservationReportRepositoryImpl) GetDailyStats(ctx context.Context, from, to time.Time, loc *time.Location) ([]model.ReservationDailyStat, error) {
+	ctx, seg := xray.BeginSubsegment(ctx, "ReservationReportRepository.GetDailyStats")
+	defer seg.Close(nil)
+
+	// 保留中からの変更は1回だけのため、予約ごとに履歴は最大1件となる
+	query := `
+		SELECT
+			(r.created_at AT TIME ZONE $3)::date AS day,
+			r.status,
+			COUNT(*) AS count,
+			COUNT(h.id) FILTER (WHERE h.reason = $4) AS conflicts,
+			COUNT(h.id) AS decided,
+			COALESCE(SUM(EXTRACT(EPOCH FROM h.changed_at - r.created_at)), 0) AS pending_seconds
+		FROM reservations r
+		LEFT JOIN reservation_status_history h
+			ON h.reservation_id = r.id
+			AND h.from_status = $5
+			AND h.to_status IN ($6, $7, $8)
+		WHERE r.created_at >= $1
+		AND r.created_at < $2
+		GROUP BY day, r.status
+		ORDER BY day ASC, r.status ASC`
+
+	var stats []model.ReservationDailyStat
+	err := r.db.SelectContext(ctx, &stats, query, from, to, loc.String(),
+		model.StatusReasonPetAlreadyReserved,
+		model.ReservationStatusPending,
+		model.ReservationStatusConfirmed,
+		model.ReservationStatusCancelled,
+		model.ReservationStatusWaitlisted,
+	)
+	if err != nil {
+		seg.Close(err)
+		return nil, fmt.Errorf("failed to get daily reservation stats: %w", err)
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/aws/aws-xray-sdk-go/xray"
+	"github.com/horsewin/echo-playground-batch-task/internal/model"
+	"github.com/jmoiron/sqlx"
+)
+
+// queryer はDBとトランザクションに共通する読み取りの操作です
+type queryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+	SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+}
+
+// ReservationRuleRepository は予約を確定できるかを判定するルールが参照する情報を取得します
+// 予約のステータスを変更するトランザクション内で読み取ります
+type ReservationRuleRepository interface {
+	GetPetAvailability(ctx context.Context, petID string) (*model.PetAvailability, error)
+	CheckExistingReservation(ctx context.Context, petID string, excludeID int64) (bool, error)
+	CheckUserReservationForPet(ctx context.Context, userID, petID string, excludeID int64) (bool, error)
+	CountActiveReservationsByUser(ctx context.Context, userID string, excludeID int64) (int, error)
+}
+
+// ReservationRuleRepositoryImpl はReservationRuleRepositoryの実装です
+type ReservationRuleRepositoryImpl struct {
+	q queryer
+}
+
+// NewReservationRuleRepository はトランザクション内で読み取るReservationRuleRepositoryを作成します
+func NewReservationRuleRepository(tx *sqlx.Tx) *ReservationRuleRepositoryImpl {
+	return &ReservationRuleRepositoryImpl{q: tx}
+}
+
+// GetPetAvailability は指定されたペットの掲載状態と、ペットがいるショップの営業時間を取得します
+// ペットが存在しない場合はsql.ErrNoRowsをラップしたエラーを返します
+func (r *ReservationRuleRepositoryImpl) GetPetAvailability(ctx context.Context, petID string) (*model.PetAvailability, error) {
+	ctx, seg := xray.BeginSubsegment(ctx, "ReservationRuleRepository.GetPetAvailability")
+	defer seg.Close(nil)
+
+	availability, err := getPetAvailability(ctx, r.q, petID)
+	if err != nil {
+		seg.Close(err)
+		return nil, err
+	}
+	return availability, nil
+}
+
+// CheckExistingReservation は、指定されたペットIDに対して予約が存在するかチェックします
+// 確定済みの予約を再評価する場合に自身と重ならないよう、excludeIDの予約は除外します
+func (r *ReservationRuleRepositoryImpl) CheckExistingReservation(ctx context.Context, petID string, excludeID int64) (bool, error) {
+	ctx, seg := xray.BeginSubsegment(ctx, "ReservationRuleRepository.CheckExistingReservation")
+	defer seg.Close(nil)
+
+	query := `
+		SELECT EXISTS (
+			SELECT 1
+			FROM reservations
+			WHERE pet_id = $1
+			AND status = 'confirmed'
+			AND reservation_date_time > NOW()
+			AND id <> $2
+		)
+	`
+
+	var exists bool
+	err := r.q.QueryRowContext(ctx, query, petID, excludeID).Scan(&exists)
+	if err != nil {
+		seg.Close(err)
+		return false, fmt.Errorf("failed to check existing reservation: %w", err)
+	}
+
+	return exists, nil
+}
+
+// CheckUserReservationForPet は、指定されたユーザーが指定されたペットに確定済みの予約を持っているかチェックします
+// excludeIDの予約は除外します
+func (r *ReservationRuleRepositoryImpl) CheckUserReservationForPet(ctx context.Context, userID, petID string, excludeID int64) (bool, error) {
+	ctx, seg := xray.BeginSubsegment(ctx, "ReservationRuleRepository.CheckUserReservationForPet")
+	defer seg.Close(nil)
+
+	query := `
+		SELECT EXISTS (
+			SELECT 1
+			FROM reservations
+			WHERE user_id = $1
+			AND pet_id = $2
+			AND status = 'confirmed'
+			AND reservation_date_time > NOW()
+			AND id <> $3
+		)
+	`
+
+	var exists bool
+	err := r.q.QueryRowContext(ctx, query, userID, petID, excludeID).Scan(&exists)
+	if err != nil {
+		seg.Close(err)
+		return false, fmt.Errorf("failed to check reservation of user for pet: %w", err)
+	}
+
+	return exists, nil
+}
+
+// CountActiveReservationsByUser は、指定されたユーザーの確定済みで予約日時を過ぎていない予約の件数を返します
+// excludeIDの予約は除外します
+func (r *ReservationRuleRepositoryImpl) CountActiveReservationsByUser(ctx context.Context, userID string, excludeID int64) (int, error) {
+	ctx, seg := xray.BeginSubsegment(ctx, "ReservationRuleRepository.CountActiveReservationsByUser")
+	defer seg.Close(nil)
+
+	query := `
+		SELECT COUNT(*)
+		FROM reservations
+		WHERE user_id = $1
+		AND status = 'confirmed'
+		AND reservation_date_time > NOW()
+		AND id <> $2
+	`
+
+	var count int
+	err := r.q.QueryRowContext(ctx, query, userID, excludeID).Scan(&count)
+	if err != nil {
+		seg.Close(err)
+		return 0, fmt.Errorf("failed to count active reservations of user: %w", err)
+	}
+
+	return count, nil
+}
@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-xray-sdk-go/xray"
+	"github.com/horsewin/echo-playground-batch-task/internal/model"
+)
+
+// UserRepository はユーザーの設定の取得を担当するインターフェースです
+type UserRepository interface {
+	GetByID(ctx context.Context, id string) (*model.User, error)
+}
+
+// UserRepositoryImpl はUserRepositoryの実装です
+type UserRepositoryImpl struct {
+	db *DB
+}
+
+// NewUserRepository は新しいUserRepositoryを作成します
+func NewUserRepository(db *DB) *UserRepositoryImpl {
+	return &UserRepositoryImpl{db: db}
+}
+
+// GetByID は指定されたユーザーのタイムゾーンと言語を取得します
+// ユーザーが登録されていない場合はnilを返します
+func (r *UserRepositoryImpl) GetByID(ctx context.Context, id string) (*model.User, error) {
+	ctx, seg := xray.BeginSubsegment(ctx, "UserRepository.GetByID")
+	defer seg.Close(nil)
+
+	query := `
+		SELECT id, COALESCE(timezone, '') AS timezone, COALESCE(locale, '') AS locale
+		FROM users
+		WHERE id = $1`
+
+	var user model.User
+	err := r.db.GetContext(ctx, &user, query, id)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, nil
+	case err != nil:
+		seg.Close(err)
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	return &user, nil
+}
@@ -0,0 +1,136 @@
+package batch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/horsewin/echo-playground-batch-task/internal/common/apperrors"
+	"github.com/horsewin/echo-playground-batch-task/internal/common/config"
+	"github.com/horsewin/echo-playground-batch-task/internal/model"
+	"github.com/horsewin/echo-playground-batch-task/internal/repository"
+)
+
+// newCheckpointRepository は設定に応じたチェックポイントの保存先を作成します
+func newCheckpointRepository(cfg *config.Config, db *repository.DB) repository.CheckpointRepository {
+	if cfg.Checkpoint.Store == "file" {
+		return repository.NewFileCheckpointRepository(cfg.Checkpoint.Dir)
+	}
+	return repository.NewCheckpointRepository(db)
+}
+
+// checkpointer はバッチ処理のチェックポイントの記録を担当します
+// リポジトリまたは実行IDが設定されていない場合は、記録せずに進捗をメモリ上でのみ保持します
+type checkpointer struct {
+	repo       repository.CheckpointRepository
+	checkpoint model.Checkpoint
+}
+
+// startCheckpoint は新しい実行のチェックポイントを作成するか、再開元のチェックポイントを読み込みます
+// scopeは処理対象の条件です。再開元と異なる条件で再開すると処理位置を正しく適用できないため、InvalidInputとして拒否します
+func startCheckpoint(ctx context.Context, repo repository.CheckpointRepository, cfg *config.Config, jobName, scope string) (*checkpointer, error) {
+	now := time.Now()
+	c := &checkpointer{
+		checkpoint: model.Checkpoint{
+			RunID:     cfg.Run.ID,
+			JobName:   jobName,
+			Scope:     scope,
+			Outcome:   model.CheckpointOutcomeRunning,
+			CreatedAt: now,
+			UpdatedAt: now,
+		},
+	}
+	if cfg.Run.ID == "" || repo == nil {
+		return c, nil
+	}
+	c.repo = repo
+
+	if cfg.Run.Resume {
+		checkpoint, err := repo.Get(ctx, cfg.Run.ID)
+		if errors.Is(err, repository.ErrCheckpointNotFound) {
+			return nil, apperrors.InvalidInput("startCheckpoint", err)
+		}
+		if err != nil {
+			return nil, apperrors.FromDB("startCheckpoint", err)
+		}
+		if checkpoint.JobName != jobName {
+			return nil, apperrors.InvalidInput("startCheckpoint",
+				fmt.Errorf("run %s belongs to %s job, not %s", cfg.Run.ID, checkpoint.JobName, jobName))
+		}
+		if checkpoint.Outcome == model.CheckpointOutcomeCompleted {
+			return nil, apperrors.InvalidInput("startCheckpoint",
+				fmt.Errorf("run %s has already completed", cfg.Run.ID))
+		}
+		if checkpoint.Scope != scope {
+			return nil, apperrors.InvalidInput("startCheckpoint",
+				fmt.Errorf("run %s processed %s, not %s. Resume with the same conditions", cfg.Run.ID, checkpoint.Scope, scope))
+		}
+
+		log.Printf("Resuming run %s of %s job from key %q (previous outcome: %s)",
+			checkpoint.RunID, jobName, checkpoint.LastKey, checkpoint.Outcome)
+		c.checkpoint = *checkpoint
+		c.checkpoint.Outcome = model.CheckpointOutcomeRunning
+		c.checkpoint.Error = ""
+	}
+
+	if err := c.save(ctx); err != nil {
+		return nil, apperrors.FromDB("startCheckpoint", err)
+	}
+	return c, nil
+}
+
+// lastKey は最後に処理したデータのキーを返します
+func (c *checkpointer) lastKey() string {
+	return c.checkpoint.LastKey
+}
+
+// processed は再開元までの実行で処理したデータの件数を返します
+func (c *checkpointer) processed() int {
+	return c.checkpoint.Processed
+}
+
+// advance は処理位置とこれまでに処理したデータの件数を記録します
+// チェックポイントの記録に失敗してもバッチ処理は継続します
+// 再開時に処理済みのデータを再度処理する可能性がありますが、処理済みの予約はステータスが変わっているため対象になりません
+func (c *checkpointer) advance(ctx context.Context, key string, processed int) {
+	c.checkpoint.LastKey = key
+	c.checkpoint.Processed = processed
+
+	if err := c.save(ctx); err != nil {
+		log.Printf("Failed to save checkpoint for run %s: %v", c.checkpoint.RunID, err)
+	}
+}
+
+// finish はバッチ処理の実行結果を記録します
+func (c *checkpointer) finish(ctx context.Context, runErr error) {
+	switch {
+	case runErr == nil:
+		c.checkpoint.Outcome = model.CheckpointOutcomeCompleted
+	case apperrors.Is(runErr, apperrors.CodeInterrupted), apperrors.Is(runErr, apperrors.CodeTimeout):
+		c.checkpoint.Outcome = model.CheckpointOutcomeInterrupted
+	default:
+		c.checkpoint.Outcome = model.CheckpointOutcomeFailed
+	}
+	if runErr != nil {
+		c.checkpoint.Error = string(apperrors.CodeOf(runErr))
+	}
+
+	// グレース期間の経過でctxがキャンセルされていても結果を記録できるようにする
+	if err := c.save(context.WithoutCancel(ctx)); err != nil {
+		log.Printf("Failed to save checkpoint outcome for run %s: %v", c.checkpoint.RunID, err)
+		return
+	}
+	if c.repo != nil && c.checkpoint.Outcome != model.CheckpointOutcomeCompleted {
+		log.Printf("Run %s finished as %s. Rerun with --resume %s to continue", c.checkpoint.RunID, c.checkpoint.Outcome, c.checkpoint.RunID)
+	}
+}
+
+func (c *checkpointer) save(ctx context.Context) error {
+	if c.repo == nil {
+		return nil
+	}
+	c.checkpoint.UpdatedAt = time.Now()
+	return c.repo.Save(ctx, &c.checkpoint)
+}
@@ -0,0 +1,212 @@
+package batch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/aws/aws-xray-sdk-go/xray"
+	"github.com/horsewin/echo-playground-batch-task/internal/common/apperrors"
+	"github.com/horsewin/echo-playground-batch-task/internal/common/config"
+	"github.com/horsewin/echo-playground-batch-task/internal/common/job"
+	"github.com/horsewin/echo-playground-batch-task/internal/common/notifier"
+	"github.com/horsewin/echo-playground-batch-task/internal/common/redact"
+	"github.com/horsewin/echo-playground-batch-task/internal/model"
+)
+
+// deliveryBatchSize は1回に読み込む配信待ちの配信の件数です
+const deliveryBatchSize = 100
+
+// newNotifiers は設定されているチャネルの配信を作成します
+func newNotifiers(cfg *config.Config) map[model.NotificationChannel]notifier.Notifier {
+	notifiers := make(map[model.NotificationChannel]notifier.Notifier)
+	if smtp := cfg.Delivery.SMTP; smtp.Host != "" {
+		notifiers[model.NotificationChannelEmail] = notifier.NewEmailNotifier(notifier.SMTPConfig{
+			Host:     smtp.Host,
+			Port:     smtp.Port,
+			UserName: smtp.UserName,
+			Password: smtp.Password,
+			From:     smtp.From,
+		})
+	}
+	if webhook := cfg.Delivery.Webhook; webhook.Secret != "" {
+		notifiers[model.NotificationChannelWebhook] = notifier.NewWebhookNotifier(webhook.Secret, webhook.Timeout)
+	}
+	if push := cfg.Delivery.Push; push.Endpoint != "" {
+		notifiers[model.NotificationChannelPush] = notifier.NewPushNotifier(push.Endpoint, push.APIKey, push.Timeout)
+	}
+	return notifiers
+}
+
+// DeliveryResult は外部への配信の結果です
+type DeliveryResult struct {
+	Sent   int
+	Failed int
+	// Rejected は宛先が不正などで配信を諦めた件数です
+	Rejected int
+	// Suppressed は配信前にユーザーがチャネルの受け取りを停止したため、配信しなかった件数です
+	Suppressed int
+	// Deferred はおやすみ時間帯のため次回以降の実行に見送った件数です
+	Deferred int
+}
+
+// prepareRecords はユーザーの通知の受け取り設定に応じて作成する通知レコードを選び、配信チャネルの設定に応じた外部への配信を設定します
+// 受け取りを停止している通知・チャネルは作成せず、件数をSuppressionResultに数えます
+// 配信は通知レコードと同じトランザクションで作成されます
+func (s *NotificationBatchService) prepareRecords(ctx context.Context, records []model.NotificationRecord, suppressed *SuppressionResult) ([]model.NotificationRecord, error) {
+	prefs := newPreferenceCache(s.preferenceRepo)
+	settingsByUser := make(map[string][]model.NotificationChannelSetting)
+	now := s.now()
+
+	targets := make([]model.NotificationRecord, 0, len(records))
+	for _, record := range records {
+		p, err := prefs.get(ctx, record.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if !p.AllowsNotification(record.Type) {
+			suppressed.Notifications++
+			continue
+		}
+
+		record.Deliveries = nil
+		if len(s.notifiers) > 0 {
+			settings, ok := settingsByUser[record.UserID]
+			if !ok {
+				settings, err = s.channelRepo.GetByUserID(ctx, record.UserID)
+				if err != nil {
+					return nil, apperrors.FromDB("NotificationBatchService.prepareRecords", err)
+				}
+				settingsByUser[record.UserID] = settings
+			}
+
+			for _, setting := range settings {
+				// 無効にしたチャネルや、このバッチで配信できないチャネルには配信しない
+				if !setting.Enabled || setting.Address == "" || s.notifiers[setting.Channel] == nil {
+					continue
+				}
+				if !p.AllowsChannel(record.Type, setting.Channel) {
+					suppressed.Deliveries++
+					continue
+				}
+				record.Deliveries = append(record.Deliveries, model.NotificationDelivery{
+					UserID:    record.UserID,
+					Channel:   setting.Channel,
+					Address:   setting.Address,
+					Status:    model.DeliveryStatusPending,
+					CreatedAt: now,
+				})
+			}
+		}
+		targets = append(targets, record)
+	}
+	return targets, nil
+}
+
+// deliverPending は配信待ち・配信に失敗した通知を外部に配信し、配信状況を記録します
+// 配信に失敗しても処理は継続し、最大試行回数に達するまで次回以降の実行で再度配信します
+// 配信時点のユーザーの受け取り設定とおやすみ時間帯も確認します
+func (s *NotificationBatchService) deliverPending(ctx context.Context) (*DeliveryResult, error) {
+	result := &DeliveryResult{}
+	if len(s.notifiers) == 0 {
+		return result, nil
+	}
+
+	ctx, seg := xray.BeginSubsegment(ctx, "NotificationBatchService.deliverPending")
+	defer seg.Close(nil)
+
+	prefs := newPreferenceCache(s.preferenceRepo)
+	var afterID int64
+	for !job.Stopping(ctx) {
+		deliveries, err := s.deliveryRepo.GetUndelivered(ctx, afterID, s.cfg.Delivery.MaxAttempts, deliveryBatchSize)
+		if err != nil {
+			seg.Close(err)
+			return result, apperrors.FromDB("NotificationBatchService.deliverPending", err)
+		}
+
+		for _, delivery := range deliveries {
+			afterID = delivery.ID
+			n := s.notifiers[delivery.Channel]
+			if n == nil {
+				continue
+			}
+			p, err := prefs.get(ctx, delivery.UserID)
+			if err != nil {
+				seg.Close(err)
+				return result, err
+			}
+			if !p.AllowsChannel(delivery.Type, delivery.Channel) {
+				if err := s.deliveryRepo.MarkSuppressed(ctx, delivery.ID); err != nil {
+					seg.Close(err)
+					return result, apperrors.FromDB("NotificationBatchService.deliverPending", err)
+				}
+				result.Suppressed++
+				continue
+			}
+			// おやすみ時間帯の配信は配信待ちのまま残し、次回以降の実行で配信する
+			quiet, err := p.InQuietHours(s.now())
+			if err != nil {
+				log.Printf("Ignoring invalid quiet hours of user %s: %v", delivery.UserID, err)
+			}
+			if quiet {
+				result.Deferred++
+				continue
+			}
+
+			if err := s.deliver(ctx, n, delivery, result); err != nil {
+				seg.Close(err)
+				return result, err
+			}
+		}
+
+		if len(deliveries) < deliveryBatchSize {
+			break
+		}
+	}
+
+	if err := seg.AddMetadata("delivery_result", result); err != nil {
+		log.Printf("Failed to add delivery_result metadata: %v", err)
+	}
+	return result, nil
+}
+
+// deliver は1件の配信を行い、結果を記録します
+func (s *NotificationBatchService) deliver(ctx context.Context, n notifier.Notifier, delivery model.NotificationDelivery, result *DeliveryResult) error {
+	notifyErr := n.Notify(ctx, notifier.NewMessage(delivery))
+	if notifyErr == nil {
+		if err := s.deliveryRepo.MarkSent(ctx, delivery.ID); err != nil {
+			return apperrors.FromDB("NotificationBatchService.deliver", err)
+		}
+		result.Sent++
+		return nil
+	}
+
+	// 配信先やエラーメッセージに含まれる個人情報はマスクして記録する
+	permanent := errors.Is(notifyErr, notifier.ErrPermanent)
+	message := redact.String(notifyErr.Error())
+	log.Printf("Failed to deliver notification %d via %s to %s (attempt %d, permanent: %t): %s",
+		delivery.NotificationID, delivery.Channel, maskAddress(delivery.Channel, delivery.Address),
+		delivery.Attempts+1, permanent, message)
+
+	if err := s.deliveryRepo.MarkFailed(ctx, delivery.ID, message, permanent); err != nil {
+		return apperrors.FromDB("NotificationBatchService.deliver", fmt.Errorf("failed to record delivery failure: %w", err))
+	}
+	if permanent {
+		result.Rejected++
+	} else {
+		result.Failed++
+	}
+	return nil
+}
+
+// maskAddress はログに出力する配信先をマスクします
+func maskAddress(channel model.NotificationChannel, address string) string {
+	switch channel {
+	case model.NotificationChannelEmail:
+		return redact.Field("email", address)
+	case model.NotificationChannelPush:
+		return redact.Field("token", address)
+	}
+	return address
+}
@@ -0,0 +1,293 @@
+package batch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-xray-sdk-go/xray"
+	"github.com/horsewin/echo-playground-batch-task/internal/common/notifier"
+	"github.com/horsewin/echo-playground-batch-task/internal/model"
+)
+
+// MockNotificationChannelRepository はテスト用のモックリポジトリです
+type MockNotificationChannelRepository struct {
+	settings map[string][]model.NotificationChannelSetting
+	calls    int
+}
+
+func (m *MockNotificationChannelRepository) GetByUserID(ctx context.Context, userID string) ([]model.NotificationChannelSetting, error) {
+	m.calls++
+	return m.settings[userID], nil
+}
+
+// MockNotificationDeliveryRepository はテスト用のモックリポジトリです
+type MockNotificationDeliveryRepository struct {
+	deliveries []model.NotificationDelivery
+}
+
+func (m *MockNotificationDeliveryRepository) GetUndelivered(ctx context.Context, afterID int64, maxAttempts int, limit int) ([]model.NotificationDelivery, error) {
+	var result []model.NotificationDelivery
+	for _, d := range m.deliveries {
+		if d.ID <= afterID || d.Attempts >= maxAttempts {
+			continue
+		}
+		if d.Status != model.DeliveryStatusPending && d.Status != model.DeliveryStatusFailed {
+			continue
+		}
+		result = append(result, d)
+		if len(result) == limit {
+			break
+		}
+	}
+	return result, nil
+}
+
+func (m *MockNotificationDeliveryRepository) MarkSent(ctx context.Context, id int64) error {
+	return m.update(id, model.DeliveryStatusSent, "")
+}
+
+func (m *MockNotificationDeliveryRepository) MarkFailed(ctx context.Context, id int64, lastError string, permanent bool) error {
+	if permanent {
+		return m.update(id, model.DeliveryStatusRejected, lastError)
+	}
+	return m.update(id, model.DeliveryStatusFailed, lastError)
+}
+
+func (m *MockNotificationDeliveryRepository) update(id int64, status model.DeliveryStatus, lastError string) error {
+	for i := range m.deliveries {
+		if m.deliveries[i].ID == id {
+			m.deliveries[i].Status = status
+			m.deliveries[i].Attempts++
+			m.deliveries[i].LastError = lastError
+			return nil
+		}
+	}
+	return fmt.Errorf("delivery %d not found", id)
+}
+
+func (m *MockNotificationDeliveryRepository) MarkSuppressed(ctx context.Context, id int64) error {
+	for i := range m.deliveries {
+		if m.deliveries[i].ID == id {
+			m.deliveries[i].Status = model.DeliveryStatusSuppressed
+			return nil
+		}
+	}
+	return fmt.Errorf("delivery %d not found", id)
+}
+
+// fakeNotifier は配信した通知を記録するテスト用のNotifierです
+type fakeNotifier struct {
+	channel model.NotificationChannel
+	// errs は配信先ごとに返すエラーです
+	errs map[string]error
+	sent []notifier.Message
+}
+
+func (n *fakeNotifier) Channel() model.NotificationChannel {
+	return n.channel
+}
+
+func (n *fakeNotifier) Notify(ctx context.Context, msg notifier.Message) error {
+	if err := n.errs[msg.Address]; err != nil {
+		return err
+	}
+	n.sent = append(n.sent, msg)
+	return nil
+}
+
+func TestNotificationBatchService_prepareRecords(t *testing.T) {
+	ctx := context.Background()
+
+	channelRepo := &MockNotificationChannelRepository{
+		settings: map[string][]model.NotificationChannelSetting{
+			"user1": {
+				{UserID: "user1", Channel: model.NotificationChannelEmail, Address: "user1@example.com", Enabled: true},
+				{UserID: "user1", Channel: model.NotificationChannelWebhook, Address: "https://example.com/hook", Enabled: false},
+				{UserID: "user1", Channel: model.NotificationChannelPush, Address: "device-token", Enabled: true},
+			},
+			"user3": {
+				{UserID: "user3", Channel: model.NotificationChannelEmail, Address: "user3@example.com", Enabled: true},
+			},
+		},
+	}
+	preferenceRepo := &MockNotificationPreferenceRepository{
+		prefs: map[string]*model.NotificationPreferences{
+			// 予約の通知のメールだけ受け取らない
+			"user3": {Preferences: []model.NotificationPreference{
+				{Type: model.NotificationTypeReservation, Channel: model.NotificationChannelEmail, Enabled: false},
+			}},
+			// 予約の通知を受け取らない
+			"user4": {Preferences: []model.NotificationPreference{
+				{Type: model.NotificationTypeReservation, Channel: model.NotificationChannelInApp, Enabled: false},
+			}},
+		},
+	}
+	service := newTestNotificationBatchService(&MockNotificationRepository{}, &MockPetRepository{})
+	service.channelRepo = channelRepo
+	service.preferenceRepo = preferenceRepo
+	// プッシュ通知は設定されていない
+	service.notifiers = map[model.NotificationChannel]notifier.Notifier{
+		model.NotificationChannelEmail:   &fakeNotifier{channel: model.NotificationChannelEmail},
+		model.NotificationChannelWebhook: &fakeNotifier{channel: model.NotificationChannelWebhook},
+	}
+
+	records := []model.NotificationRecord{
+		{UserID: "user1", Type: model.NotificationTypeReservation},
+		{UserID: "user1", Type: model.NotificationTypeReservation},
+		{UserID: "user2", Type: model.NotificationTypeReservation},
+		{UserID: "user3", Type: model.NotificationTypeReservation},
+		{UserID: "user4", Type: model.NotificationTypeReservation},
+		{UserID: "user4", Type: model.NotificationTypeCommon},
+	}
+	var suppressed SuppressionResult
+	targets, err := service.prepareRecords(ctx, records, &suppressed)
+	if err != nil {
+		t.Fatalf("prepareRecords() error = %v", err)
+	}
+
+	if len(targets) != 5 {
+		t.Fatalf("targets = %d, want 5", len(targets))
+	}
+	// 有効で、かつこのバッチで配信できるチャネルだけが配信対象になる
+	for i, record := range targets[:2] {
+		if len(record.Deliveries) != 1 || record.Deliveries[0].Channel != model.NotificationChannelEmail {
+			t.Errorf("targets[%d].Deliveries = %+v, want email only", i, record.Deliveries)
+		}
+	}
+	for i, record := range targets[2:] {
+		if len(record.Deliveries) != 0 {
+			t.Errorf("targets[%d].Deliveries = %+v, want none", i+2, record.Deliveries)
+		}
+	}
+	if targets[4].UserID != "user4" || targets[4].Type != model.NotificationTypeCommon {
+		t.Errorf("targets[4] = %+v, want common notification of user4", targets[4])
+	}
+	if suppressed != (SuppressionResult{Notifications: 1, Deliveries: 1}) {
+		t.Errorf("suppressed = %+v, want {Notifications:1 Deliveries:1}", suppressed)
+	}
+	// 同じユーザーの設定は1回だけ取得する
+	if channelRepo.calls != 4 {
+		t.Errorf("GetByUserID calls = %d, want 4", channelRepo.calls)
+	}
+}
+
+func TestNotificationBatchService_deliverPending(t *testing.T) {
+	ctx, seg := xray.BeginSegment(context.Background(), "TestNotificationBatchService_deliverPending")
+	defer seg.Close(nil)
+
+	email := &fakeNotifier{
+		channel: model.NotificationChannelEmail,
+		errs: map[string]error{
+			"down@example.com":    errors.New("connection refused"),
+			"invalid@example.com": fmt.Errorf("%w: mailbox not found", notifier.ErrPermanent),
+		},
+	}
+	deliveryRepo := &MockNotificationDeliveryRepository{
+		deliveries: []model.NotificationDelivery{
+			{ID: 1, Channel: model.NotificationChannelEmail, Address: "ok@example.com", Status: model.DeliveryStatusPending},
+			{ID: 2, Channel: model.NotificationChannelEmail, Address: "down@example.com", Status: model.DeliveryStatusPending},
+			{ID: 3, Channel: model.NotificationChannelEmail, Address: "invalid@example.com", Status: model.DeliveryStatusPending},
+			{ID: 4, Channel: model.NotificationChannelEmail, Address: "retry@example.com", Status: model.DeliveryStatusFailed, Attempts: 1},
+			// 最大試行回数に達した配信は再試行しない
+			{ID: 5, Channel: model.NotificationChannelEmail, Address: "gaveup@example.com", Status: model.DeliveryStatusFailed, Attempts: 3},
+			// 設定されていないチャネルは配信しない
+			{ID: 6, Channel: model.NotificationChannelPush, Address: "device-token", Status: model.DeliveryStatusPending},
+		},
+	}
+
+	service := newTestNotificationBatchService(&MockNotificationRepository{}, &MockPetRepository{})
+	service.deliveryRepo = deliveryRepo
+	service.notifiers = map[model.NotificationChannel]notifier.Notifier{model.NotificationChannelEmail: email}
+	service.cfg.Delivery.MaxAttempts = 3
+
+	result, err := service.deliverPending(ctx)
+	if err != nil {
+		t.Fatalf("deliverPending() error = %v", err)
+	}
+	if *result != (DeliveryResult{Sent: 2, Failed: 1, Rejected: 1}) {
+		t.Errorf("result = %+v, want {Sent:2 Failed:1 Rejected:1}", *result)
+	}
+
+	wantStatus := map[int64]model.DeliveryStatus{
+		1: model.DeliveryStatusSent,
+		2: model.DeliveryStatusFailed,
+		3: model.DeliveryStatusRejected,
+		4: model.DeliveryStatusSent,
+		5: model.DeliveryStatusFailed,
+		6: model.DeliveryStatusPending,
+	}
+	for _, d := range deliveryRepo.deliveries {
+		if d.Status != wantStatus[d.ID] {
+			t.Errorf("delivery %d status = %s, want %s", d.ID, d.Status, wantStatus[d.ID])
+		}
+	}
+}
+
+func TestNotificationBatchService_deliverPendingPaging(t *testing.T) {
+	ctx, seg := xray.BeginSegment(context.Background(), "TestNotificationBatchService_deliverPendingPaging")
+	defer seg.Close(nil)
+
+	deliveryRepo := &MockNotificationDeliveryRepository{}
+	total := deliveryBatchSize*2 + 1
+	for i := 1; i <= total; i++ {
+		deliveryRepo.deliveries = append(deliveryRepo.deliveries, model.NotificationDelivery{
+			ID:      int64(i),
+			Channel: model.NotificationChannelWebhook,
+			Address: fmt.Sprintf("https://example.com/hook/%d", i),
+			Status:  model.DeliveryStatusPending,
+		})
+	}
+	webhook := &fakeNotifier{channel: model.NotificationChannelWebhook}
+
+	service := newTestNotificationBatchService(&MockNotificationRepository{}, &MockPetRepository{})
+	service.deliveryRepo = deliveryRepo
+	service.notifiers = map[model.NotificationChannel]notifier.Notifier{model.NotificationChannelWebhook: webhook}
+	service.cfg.Delivery.MaxAttempts = 5
+
+	result, err := service.deliverPending(ctx)
+	if err != nil {
+		t.Fatalf("deliverPending() error = %v", err)
+	}
+	if result.Sent != total || len(webhook.sent) != total {
+		t.Errorf("sent = %d (notified %d), want %d", result.Sent, len(webhook.sent), total)
+	}
+}
+
+func TestNotificationBatchService_RunWithDeliveries(t *testing.T) {
+	ctx, seg := xray.BeginSegment(context.Background(), "TestNotificationBatchService_RunWithDeliveries")
+	defer seg.Close(nil)
+
+	notificationRepo := &MockNotificationRepository{}
+	service := newTestNotificationBatchService(notificationRepo, &MockPetRepository{})
+	service.channelRepo = &MockNotificationChannelRepository{
+		settings: map[string][]model.NotificationChannelSetting{
+			"user1": {{UserID: "user1", Channel: model.NotificationChannelEmail, Address: "user1@example.com", Enabled: true}},
+		},
+	}
+	service.deliveryRepo = &MockNotificationDeliveryRepository{}
+	service.notifiers = map[model.NotificationChannel]notifier.Notifier{
+		model.NotificationChannelEmail: &fakeNotifier{channel: model.NotificationChannelEmail},
+	}
+	service.cfg.Delivery.MaxAttempts = 5
+	service.SetArgs([]model.Notification{model.NewReservationNotification(model.ReservationEvent{
+		UserID:   "user1",
+		PetID:    "pet1",
+		DateTime: time.Now(),
+	})})
+
+	if err := service.Run(ctx); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	// 配信は通知レコードと一緒に作成される
+	if len(notificationRepo.notifications) != 1 {
+		t.Fatalf("notifications = %d, want 1", len(notificationRepo.notifications))
+	}
+	deliveries := notificationRepo.notifications[0].Deliveries
+	if len(deliveries) != 1 || deliveries[0].Address != "user1@example.com" || deliveries[0].Status != model.DeliveryStatusPending {
+		t.Errorf("deliveries = %+v", deliveries)
+	}
+}
@@ -0,0 +1,47 @@
+package batch
+
+import (
+	"context"
+	"log"
+
+	"github.com/horsewin/echo-playground-batch-task/internal/common/apperrors"
+	"github.com/horsewin/echo-playground-batch-task/internal/common/config"
+	"github.com/horsewin/echo-playground-batch-task/internal/model"
+	"github.com/horsewin/echo-playground-batch-task/internal/repository"
+)
+
+// newDisplayOptions は設定 (NOTIFICATION_TIMEZONE・NOTIFICATION_LOCALE) から通知の既定の表示設定を作成します
+func newDisplayOptions(cfg *config.Config) (model.DisplayOptions, error) {
+	return model.NewDisplayOptions(cfg.Notification.TimeZone, cfg.Notification.Locale)
+}
+
+// displayCache はユーザーごとの通知の表示設定を1回の処理の間保持します
+type displayCache struct {
+	repo     repository.UserRepository
+	defaults model.DisplayOptions
+	opts     map[string]model.DisplayOptions
+}
+
+func newDisplayCache(repo repository.UserRepository, defaults model.DisplayOptions) *displayCache {
+	return &displayCache{repo: repo, defaults: defaults, opts: make(map[string]model.DisplayOptions)}
+}
+
+// get は指定されたユーザーの表示設定を返します
+// ユーザーが登録されていない場合や、ユーザーの設定が不正な場合は既定の表示設定を返します
+func (c *displayCache) get(ctx context.Context, userID string) (model.DisplayOptions, error) {
+	if o, ok := c.opts[userID]; ok {
+		return o, nil
+	}
+	user, err := c.repo.GetByID(ctx, userID)
+	if err != nil {
+		return model.DisplayOptions{}, apperrors.FromDB("displayCache.get", err)
+	}
+	o, err := c.defaults.ForUser(user)
+	if err != nil {
+		// 不正な設定で通知を作成できなくならないよう、既定の表示設定で表示する
+		log.Printf("Using default display options for user %s: %v", userID, err)
+		o = c.defaults
+	}
+	c.opts[userID] = o
+	return o, nil
+}
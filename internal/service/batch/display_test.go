@@ -0,0 +1,145 @@
+package batch
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-xray-sdk-go/xray"
+	"github.com/horsewin/echo-playground-batch-task/internal/common/config"
+	"github.com/horsewin/echo-playground-batch-task/internal/model"
+)
+
+// MockUserRepository はテスト用のモックリポジトリです
+// 登録されていないユーザーはnilを返します
+type MockUserRepository struct {
+	users map[string]*model.User
+	err   error
+	// transientErrors はerrより先に、呼び出し順に返すエラーです
+	transientErrors []error
+	calls           int
+}
+
+func (m *MockUserRepository) GetByID(ctx context.Context, id string) (*model.User, error) {
+	m.calls++
+	if len(m.transientErrors) > 0 {
+		err := m.transientErrors[0]
+		m.transientErrors = m.transientErrors[1:]
+		return nil, err
+	}
+	if m.err != nil {
+		return nil, m.err
+	}
+	return m.users[id], nil
+}
+
+// testDisplayUsers は表示設定の異なるユーザーです
+var testDisplayUsers = map[string]*model.User{
+	"user-ny":  {ID: "user-ny", TimeZone: "America/New_York", Locale: "en-US"},
+	"user-utc": {ID: "user-utc", TimeZone: "UTC"},
+	// 不正な設定のユーザーは既定の表示設定で表示する
+	"user-invalid": {ID: "user-invalid", TimeZone: "Mars/Olympus"},
+}
+
+func TestNotificationBatchService_Run_DisplayOptions(t *testing.T) {
+	ctx, seg := xray.BeginSegment(context.Background(), "TestNotificationBatchService_Run_DisplayOptions")
+	defer seg.Close(nil)
+	t.Setenv("ENV", "LOCAL")
+
+	cfg := &config.Config{}
+	cfg.Notification.TimeZone = "Asia/Tokyo"
+	cfg.Notification.Locale = "ja"
+	display, err := newDisplayOptions(cfg)
+	if err != nil {
+		t.Fatalf("newDisplayOptions() error = %v", err)
+	}
+
+	// 夏時間の期間中の日時
+	at := time.Date(2024, 7, 1, 1, 30, 0, 0, time.UTC)
+	want := map[string]string{
+		"user-jp":      "予約日時: 2024年7月1日(月) 10:30 JST",
+		"user-ny":      "予約日時: Sun, Jun 30, 2024 9:30 PM EDT",
+		"user-utc":     "予約日時: 2024年7月1日(月) 01:30 UTC",
+		"user-invalid": "予約日時: 2024年7月1日(月) 10:30 JST",
+	}
+
+	var notifications []model.Notification
+	for _, userID := range []string{"user-jp", "user-ny", "user-utc", "user-invalid", "user-ny"} {
+		notifications = append(notifications, model.NewReservationNotification(model.ReservationEvent{
+			UserID: userID, PetID: "pet1", DateTime: at,
+		}))
+	}
+
+	notificationRepo := &MockNotificationRepository{}
+	userRepo := &MockUserRepository{users: testDisplayUsers}
+	service := newTestNotificationBatchService(notificationRepo, &MockPetRepository{})
+	service.userRepo = userRepo
+	service.display = display
+	service.cfg.Notification = cfg.Notification
+	service.SetArgs(notifications)
+
+	if err := service.Run(ctx); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if len(notificationRepo.notifications) != len(notifications) {
+		t.Fatalf("notifications = %d, want %d", len(notificationRepo.notifications), len(notifications))
+	}
+	for _, record := range notificationRepo.notifications {
+		if !strings.Contains(record.Message, want[record.UserID]) {
+			t.Errorf("message for %s = %q, want to contain %q", record.UserID, record.Message, want[record.UserID])
+		}
+	}
+	// 同じユーザーの設定は1回だけ取得する
+	if userRepo.calls != len(want) {
+		t.Errorf("GetByID calls = %d, want %d", userRepo.calls, len(want))
+	}
+
+	t.Run("ユーザーの設定を取得できない場合は失敗", func(t *testing.T) {
+		service := newTestNotificationBatchService(&MockNotificationRepository{}, &MockPetRepository{})
+		service.userRepo = &MockUserRepository{err: errors.New("connection reset")}
+		service.SetArgs(notifications[:1])
+		if err := service.Run(ctx); err == nil {
+			t.Errorf("Run() error = nil, want error")
+		}
+	})
+}
+
+func TestReminderBatchService_Run_DisplayOptions(t *testing.T) {
+	ctx, seg := xray.BeginSegment(context.Background(), "TestReminderBatchService_Run_DisplayOptions")
+	defer seg.Close(nil)
+
+	// ニューヨークの標準時の期間中の日時
+	now := time.Date(2024, 1, 15, 14, 0, 0, 0, time.UTC)
+	reservations := []model.Reservation{
+		{ID: 1, UserID: "user-jp", PetID: "pet1", ReservationDateTime: now.Add(time.Hour), Status: "confirmed"},
+		{ID: 2, UserID: "user-ny", PetID: "pet1", ReservationDateTime: now.Add(time.Hour), Status: "confirmed"},
+	}
+	want := map[string]string{
+		"user-jp": "予約日時: 2024年1月16日(火) 00:00 JST",
+		"user-ny": "予約日時: Mon, Jan 15, 2024 10:00 AM EST",
+	}
+
+	notificationRepo := &MockNotificationRepository{}
+	service := newTestReminderBatchService(&MockReservationRepository{pendingReservations: reservations},
+		&MockPetRepository{}, notificationRepo, &MockReservationReminderRepository{}, now)
+	service.userRepo = &MockUserRepository{users: testDisplayUsers}
+	display, err := model.NewDisplayOptions("Asia/Tokyo", "ja")
+	if err != nil {
+		t.Fatal(err)
+	}
+	service.display = display
+
+	if err := service.Run(ctx); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if len(notificationRepo.created) != len(want) {
+		t.Fatalf("created notifications = %d, want %d", len(notificationRepo.created), len(want))
+	}
+	for _, record := range notificationRepo.created {
+		if !strings.Contains(record.Message, want[record.UserID]) {
+			t.Errorf("message for %s = %q, want to contain %q", record.UserID, record.Message, want[record.UserID])
+		}
+	}
+}
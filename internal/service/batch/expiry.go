@@ -0,0 +1,104 @@
+package batch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aws/aws-xray-sdk-go/xray"
+	"github.com/horsewin/echo-playground-batch-task/internal/common/apperrors"
+	"github.com/horsewin/echo-playground-batch-task/internal/model"
+)
+
+// expireStaleReservations は予約日時を過ぎた、または作成から一定時間が経過した保留中の予約と、予約日時を過ぎたキャンセル待ち・失敗の予約を期限切れにします
+// 保留中の予約を処理する前に実行し、過去の日時の予約が確定されないようにします
+// 期限切れにした予約ごとに、ステータスの変更と同じトランザクションで期限切れのイベントをアウトボックスに記録します
+func (s *ReservationBatchService) expireStaleReservations(ctx context.Context, now time.Time) (int, error) {
+	ctx, seg := xray.BeginSubsegment(ctx, "ReservationBatchService.expireStaleReservations")
+	defer seg.Close(nil)
+
+	var createdBefore *time.Time
+	if maxAge := s.cfg.Expiry.PendingMaxAge; maxAge > 0 {
+		t := now.Add(-maxAge)
+		createdBefore = &t
+	}
+
+	reservations, err := s.reservationRepo.GetStaleReservations(ctx, now, createdBefore)
+	if err != nil {
+		seg.Close(err)
+		return 0, apperrors.FromDB("ReservationBatchService.expireStaleReservations", err)
+	}
+	if len(reservations) > 0 {
+		log.Printf("Found %d stale reservations to expire", len(reservations))
+	}
+
+	expired := 0
+	for _, reservation := range reservations {
+		// 期限切れにできなかった予約が確定されないよう、失敗した場合は保留中の予約の処理に進まない
+		err := s.cfg.Retry.Do(ctx, "ReservationBatchService.expireReservation", func(ctx context.Context) error {
+			return s.expireReservation(ctx, reservation, now)
+		})
+		// 他の処理が先にステータスを変更した予約は保留中ではなくなったため、期限切れにしない
+		if errors.Is(err, model.ErrStatusConflict) {
+			log.Printf("Skipping expiry of reservation %d: status was changed by another process", reservation.ID)
+			continue
+		}
+		if err != nil {
+			seg.Close(err)
+			return expired, apperrors.FromDB("ReservationBatchService.expireStaleReservations",
+				fmt.Errorf("failed to expire reservation %d after expiring %d: %w", reservation.ID, expired, err))
+		}
+		expired++
+	}
+
+	if err := seg.AddMetadata("expired", expired); err != nil {
+		log.Printf("Failed to add expired metadata: %v", err)
+	}
+	return expired, nil
+}
+
+// expireReservation は1件の予約をトランザクション内で期限切れにします
+func (s *ReservationBatchService) expireReservation(ctx context.Context, reservation model.Reservation, now time.Time) error {
+	// トランザクション開始
+	tx, err := s.reservationRepo.BeginTx()
+	if err != nil {
+		return err
+	}
+
+	reason := model.StatusReasonPendingTooLong
+	if !reservation.ReservationDateTime.After(now) {
+		reason = model.StatusReasonSlotPassed
+	}
+	change := model.NewReservationStatusChange(reservation.ID, reservation.Status,
+		model.ReservationStatusExpired, reason, s.cfg.Run.ID, now)
+	if err := s.reservationRepo.UpdateStatus(ctx, tx, change); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("Failed to rollback transaction for reservation %d: %v",
+				reservation.ID, rollbackErr)
+		}
+		return err
+	}
+
+	outboxEvent, err := model.NewReservationOutboxEvent(reservation.ID, model.ReservationEventExpired, model.ReservationEvent{
+		UserID:    reservation.UserID,
+		DateTime:  reservation.ReservationDateTime,
+		PetID:     reservation.PetID,
+		CreatedAt: reservation.CreatedAt,
+		Status:    model.ReservationStatusExpired,
+	})
+	if err == nil {
+		err = s.eventRepo.Create(ctx, tx, outboxEvent)
+	}
+	if err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("Failed to rollback transaction for reservation %d: %v",
+				reservation.ID, rollbackErr)
+		}
+		return err
+	}
+
+	// トランザクションをコミット
+	return tx.Commit()
+}
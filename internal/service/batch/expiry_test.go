@@ -0,0 +1,106 @@
+package batch
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-xray-sdk-go/xray"
+	"github.com/horsewin/echo-playground-batch-task/internal/model"
+)
+
+func TestReservationBatchService_RunExpiresStaleReservations(t *testing.T) {
+	ctx, seg := xray.BeginSegment(context.Background(), "TestReservationBatchService_RunExpiresStaleReservations")
+	defer seg.Close(nil)
+	t.Setenv("ENV", "LOCAL")
+
+	past := time.Now().Add(-time.Hour).UTC()
+	future := time.Now().Add(time.Hour).UTC()
+	stale := []model.Reservation{
+		{ID: 1, UserID: "user1", PetID: "pet1", ReservationDateTime: past, Status: "pending"},
+		{ID: 2, UserID: "user2", PetID: "pet2", ReservationDateTime: future, CreatedAt: past.Add(-72 * time.Hour), Status: "pending"},
+		{ID: 4, UserID: "user4", PetID: "pet4", ReservationDateTime: past, Status: "waitlisted"},
+		{ID: 5, UserID: "user5", PetID: "pet5", ReservationDateTime: past, Status: "failed", LastError: "commit failed"},
+	}
+
+	t.Run("期限切れにした予約のイベントを記録し、保留中の予約の処理を続ける", func(t *testing.T) {
+		mock := &MockReservationRepository{
+			staleReservations: stale,
+			pendingReservations: []model.Reservation{
+				{ID: 3, UserID: "user3", PetID: "pet3", ReservationDateTime: future, Status: "pending"},
+			},
+		}
+		service := newTestReservationBatchService(mock)
+		eventRepo := service.eventRepo.(*MockReservationEventRepository)
+
+		if err := service.Run(ctx); err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+
+		want := map[int64]string{1: "expired", 2: "expired", 3: "confirmed", 4: "expired", 5: "expired"}
+		for id, status := range want {
+			if got := mock.updatedStatuses[id]; got != status {
+				t.Errorf("reservation %d status = %q, want %q", id, got, status)
+			}
+		}
+
+		var expiredEvents int
+		for _, e := range eventRepo.events {
+			if e.EventType != model.ReservationEventExpired {
+				continue
+			}
+			expiredEvents++
+			event, err := e.ReservationEvent()
+			if err != nil || event.Status != "expired" {
+				t.Errorf("expired event payload = %+v, err = %v", event, err)
+			}
+		}
+		if expiredEvents != 4 {
+			t.Errorf("expired events = %d, want 4", expiredEvents)
+		}
+	})
+
+	t.Run("期限切れにできなかった場合は保留中の予約を処理しない", func(t *testing.T) {
+		mock := &MockReservationRepository{
+			staleReservations: stale[:1],
+			pendingReservations: []model.Reservation{
+				{ID: 3, UserID: "user3", PetID: "pet3", ReservationDateTime: future, Status: "pending"},
+			},
+			updateStatusErrors: map[int64][]error{1: {errors.New("connection reset")}},
+		}
+		service := newTestReservationBatchService(mock)
+
+		if err := service.Run(ctx); err == nil {
+			t.Fatalf("Run() error = nil, want error")
+		}
+		if _, ok := mock.updatedStatuses[3]; ok {
+			t.Errorf("pending reservation should not be processed")
+		}
+	})
+
+	t.Run("失敗した予約を処理し直す実行でも予約日時を過ぎた予約は確定しない", func(t *testing.T) {
+		mock := &MockReservationRepository{
+			pendingReservations: []model.Reservation{
+				{ID: 5, UserID: "user5", PetID: "pet5", ReservationDateTime: past, Status: "failed", LastError: "commit failed"},
+				{ID: 6, UserID: "user6", PetID: "pet6", ReservationDateTime: future, Status: "failed", LastError: "commit failed"},
+			},
+		}
+		service := newTestReservationBatchService(mock)
+		service.SetFilter(model.ReservationFilter{Statuses: []model.ReservationStatus{model.ReservationStatusFailed}})
+
+		if err := service.Run(ctx); err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+		want := map[int64]string{5: "expired", 6: "confirmed"}
+		for id, status := range want {
+			if got := mock.updatedStatuses[id]; got != status {
+				t.Errorf("reservation %d status = %q, want %q", id, got, status)
+			}
+		}
+		history, _ := mock.GetStatusHistory(ctx, 5)
+		if len(history) != 1 || history[0].FromStatus != model.ReservationStatusFailed || history[0].Reason != model.StatusReasonSlotPassed {
+			t.Errorf("GetStatusHistory(5) = %+v, want failed to expired by slot_passed", history)
+		}
+	})
+}
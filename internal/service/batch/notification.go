@@ -2,25 +2,45 @@ package batch
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"slices"
+	"strconv"
 	"time"
 
 	"github.com/aws/aws-xray-sdk-go/xray"
+	"github.com/horsewin/echo-playground-batch-task/internal/common/apperrors"
 	"github.com/horsewin/echo-playground-batch-task/internal/common/config"
 	"github.com/horsewin/echo-playground-batch-task/internal/common/database"
+	"github.com/horsewin/echo-playground-batch-task/internal/common/job"
+	"github.com/horsewin/echo-playground-batch-task/internal/common/notifier"
 	"github.com/horsewin/echo-playground-batch-task/internal/model"
 	"github.com/horsewin/echo-playground-batch-task/internal/repository"
 )
 
+// notificationJobName はチェックポイントに記録する通知バッチのジョブ名です
+const notificationJobName = "notification"
+
 // NotificationBatchService は通知バッチ処理を担当します
 type NotificationBatchService struct {
 	args             []model.Notification
 	db               *database.DB
 	notificationRepo repository.NotificationRepository
 	petRepo          repository.PetRepository
-	cfg              *config.Config
+	checkpointRepo   repository.CheckpointRepository
+	channelRepo      repository.NotificationChannelRepository
+	deliveryRepo     repository.NotificationDeliveryRepository
+	preferenceRepo   repository.NotificationPreferenceRepository
+	userRepo         repository.UserRepository
+	// display は通知に表示する日時の既定のタイムゾーンと書式です。ユーザーが設定している場合はユーザーの設定で表示します
+	display model.DisplayOptions
+	// notifiers は設定されているチャネルごとの外部への配信です。空の場合は外部に配信しません
+	notifiers map[model.NotificationChannel]notifier.Notifier
+	// now はおやすみ時間帯の判定に利用する現在時刻です
+	now func() time.Time
+	cfg *config.Config
 }
 
 // NewNotificationBatchService は新しいNotificationBatchServiceを作成します
@@ -33,10 +53,24 @@ func NewNotificationBatchService(cfg *config.Config) (*NotificationBatchService,
 	// database.DBをrepository.DBに変換
 	repoDb := &repository.DB{DB: db.DB}
 
+	display, err := newDisplayOptions(cfg)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create notification display options: %w", err)
+	}
+
 	return &NotificationBatchService{
 		db:               db,
 		notificationRepo: repository.NewNotificationRepository(repoDb),
 		petRepo:          repository.NewPetRepository(repoDb),
+		checkpointRepo:   newCheckpointRepository(cfg, repoDb),
+		channelRepo:      repository.NewNotificationChannelRepository(repoDb),
+		deliveryRepo:     repository.NewNotificationDeliveryRepository(repoDb),
+		preferenceRepo:   repository.NewNotificationPreferenceRepository(repoDb),
+		userRepo:         repository.NewUserRepository(repoDb),
+		display:          display,
+		notifiers:        newNotifiers(cfg),
+		now:              time.Now,
 		cfg:              cfg,
 	}, nil
 }
@@ -55,7 +89,9 @@ func (s *NotificationBatchService) SetArgs(args []model.Notification) {
 }
 
 // Run は通知バッチ処理を実行します
-func (s *NotificationBatchService) Run(ctx context.Context) error {
+// 通知レコードはチャンク単位で作成し、再開する場合は作成済みのチャンクを飛ばします
+// ユーザーが受け取りを停止している通知は作成せず、件数を実行結果に記録します
+func (s *NotificationBatchService) Run(ctx context.Context) (runErr error) {
 	// X-Rayセグメントの作成
 	ctx, seg := xray.BeginSubsegment(ctx, "NotificationBatchService.Run")
 	defer seg.Close(nil)
@@ -79,22 +115,98 @@ func (s *NotificationBatchService) Run(ctx context.Context) error {
 	}
 
 	// 通知をレコードに変換
+	// 予約日時はユーザーごとのタイムゾーンと書式で表示する
+	displays := newDisplayCache(s.userRepo, s.display)
 	records := make([]model.NotificationRecord, len(notifications))
 	for i, notification := range notifications {
-		record, err := notification.ToNotificationRecord(petNameMap)
+		opts, err := displays.get(ctx, notification.UserID())
 		if err != nil {
 			seg.Close(err)
 			return err
 		}
+		record, err := notification.ToNotificationRecord(petNameMap, opts)
+		if err != nil {
+			seg.Close(err)
+			return apperrors.InvalidInput("NotificationBatchService.Run", err)
+		}
 		records[i] = *record
 	}
 
-	// 通知レコードを作成
-	if err := s.notificationRepo.CreateNotifications(ctx, records); err != nil {
+	// チェックポイントの記録を開始
+	cp, err := startCheckpoint(ctx, s.checkpointRepo, s.cfg, notificationJobName, "")
+	if err != nil {
+		seg.Close(err)
+		return err
+	}
+	defer func() {
+		cp.finish(ctx, runErr)
+	}()
+
+	// 再開する場合は作成済みの通知レコードを飛ばす
+	// 処理位置には作成済みの通知レコードの件数を記録している
+	created, err := parseNotificationOffset(cp.lastKey(), len(records))
+	if err != nil {
 		seg.Close(err)
-		return fmt.Errorf("failed to create notifications: %w", err)
+		return err
+	}
+	if created > 0 {
+		log.Printf("Skipping %d notifications already created by run %s", created, s.cfg.Run.ID)
 	}
 
+	// 通知レコードをチャンク単位で作成
+	chunkSize := s.cfg.Notification.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = len(records)
+	}
+	var suppressed SuppressionResult
+	for first := true; first || created < len(records); first = false {
+		// 停止要求を受けている場合は次のチャンクを作成せずに終了する
+		if job.Stopping(ctx) {
+			err := apperrors.Interrupted("NotificationBatchService.Run",
+				fmt.Errorf("stopped after creating %d of %d notifications", created, len(records))).
+				WithDetails(map[string]any{
+					"run_id":    s.cfg.Run.ID,
+					"total":     len(records),
+					"processed": created,
+				})
+			seg.Close(err)
+			return err
+		}
+
+		chunk := records[created:min(created+chunkSize, len(records))]
+
+		// ユーザーの受け取り設定と配信チャネルの設定に応じて、作成する通知レコードと外部への配信を決める
+		// 処理位置は受け取りを停止している通知も含めた件数で記録する
+		targets, err := s.prepareRecords(ctx, chunk, &suppressed)
+		if err != nil {
+			seg.Close(err)
+			return err
+		}
+
+		// 一時的なエラーの場合はトランザクションごとリトライする
+		err = s.cfg.Retry.Do(ctx, "NotificationRepository.CreateNotifications", func(ctx context.Context) error {
+			return s.notificationRepo.CreateNotifications(ctx, targets)
+		})
+		if err != nil {
+			seg.Close(err)
+			return apperrors.FromDB("NotificationBatchService.Run",
+				fmt.Errorf("failed to create notifications after %d of %d: %w", created, len(records), err))
+		}
+
+		created += len(chunk)
+		cp.advance(ctx, strconv.Itoa(created), created)
+	}
+
+	// 作成した通知を外部に配信する (前回までに配信に失敗した通知も含む)
+	// 配信の失敗は配信状況に記録して次回以降に再試行するため、ジョブの失敗にはしない
+	delivery, err := s.deliverPending(ctx)
+	if err != nil {
+		seg.Close(err)
+		return err
+	}
+	log.Printf("Notification delivery completed. Sent: %d, Failed: %d, Rejected: %d, Suppressed: %d, Deferred: %d",
+		delivery.Sent, delivery.Failed, delivery.Rejected, delivery.Suppressed, delivery.Deferred)
+
 	// 処理終了時刻を記録し、実行時間を計算
 	endTime := time.Now()
 	duration := endTime.Sub(startTime)
@@ -106,11 +218,28 @@ func (s *NotificationBatchService) Run(ctx context.Context) error {
 	if err := seg.AddMetadata("pet_count", len(petNameMap)); err != nil {
 		log.Printf("Failed to add pet_count metadata: %v", err)
 	}
+	if err := seg.AddMetadata("suppressed", suppressed); err != nil {
+		log.Printf("Failed to add suppressed metadata: %v", err)
+	}
 
-	log.Printf("Notification batch process completed successfully. Duration: %v", duration)
+	log.Printf("Notification batch process completed successfully. Created: %d, Suppressed: %d (deliveries: %d), Duration: %v",
+		len(records)-suppressed.Notifications, suppressed.Notifications, suppressed.Deliveries, duration)
 	return nil
 }
 
+// parseNotificationOffset はチェックポイントに記録された作成済みの通知レコードの件数を解析します
+func parseNotificationOffset(key string, total int) (int, error) {
+	if key == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(key)
+	if err != nil || n < 0 || n > total {
+		return 0, apperrors.InvalidInput("parseNotificationOffset",
+			fmt.Errorf("invalid notification checkpoint %q for %d notifications", key, total))
+	}
+	return n, nil
+}
+
 // 通知データに含まれる情報からペット名を取得する
 // N+1とならないように先に重複がないペットIDを取得をしておく
 // 1. 重複がないペットIDを取得
@@ -126,14 +255,14 @@ func (s *NotificationBatchService) getPetNameMap(ctx context.Context, notificati
 		// Dataフィールドの型をチェック
 		data, ok := notification.Data.(map[string]interface{})
 		if !ok {
-			err := fmt.Errorf("invalid notification data format")
+			err := apperrors.InvalidInput("NotificationBatchService.getPetNameMap", fmt.Errorf("invalid notification data format"))
 			seg.Close(err)
 			return nil, err
 		}
 
 		petID, ok := data["pet_id"].(string)
 		if !ok {
-			err := fmt.Errorf("pet_id is not a string")
+			err := apperrors.InvalidInput("NotificationBatchService.getPetNameMap", fmt.Errorf("pet_id is not a string"))
 			seg.Close(err)
 			return nil, err
 		}
@@ -156,7 +285,11 @@ func (s *NotificationBatchService) getPetNameMap(ctx context.Context, notificati
 		petName, err := s.petRepo.GetNameByID(ctx, petID)
 		if err != nil {
 			seg.Close(err)
-			return nil, err
+			// 存在しないペットIDは入力データの不備として扱う
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, apperrors.InvalidInput("NotificationBatchService.getPetNameMap", err)
+			}
+			return nil, apperrors.FromDB("NotificationBatchService.getPetNameMap", err)
 		}
 		petNameMap[petID] = petName
 	}
@@ -0,0 +1,204 @@
+package batch
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aws/aws-xray-sdk-go/xray"
+	"github.com/horsewin/echo-playground-batch-task/internal/common/apperrors"
+	"github.com/horsewin/echo-playground-batch-task/internal/common/job"
+	"github.com/horsewin/echo-playground-batch-task/internal/common/queue"
+	"github.com/horsewin/echo-playground-batch-task/internal/model"
+)
+
+// ConsumeOptions はキューからの通知の受信方法を表します
+type ConsumeOptions struct {
+	// BatchSize は1回に受信する通知の件数です (10件まで)
+	BatchSize int
+	// WaitTime はキューが空の場合に受信を待機する時間です
+	WaitTime time.Duration
+	// StopWhenEmpty がtrueの場合、キューが空になった時点で終了します
+	// falseの場合は停止要求を受けるまで受信を続けます
+	StopWhenEmpty bool
+}
+
+// ConsumeResult はキューから受信した通知の処理結果です
+type ConsumeResult struct {
+	Received  int
+	Processed int
+	// Failed は処理に失敗してキューに残した通知の件数です
+	// 同じ通知が再度受信されて失敗した場合は重複して数えます
+	Failed int
+	// Suppressed はユーザーが受け取りを停止しているため作成せずにキューから削除した通知の件数です
+	Suppressed int
+	// Delivered は外部に配信した通知の件数です
+	Delivered int
+}
+
+// Consume はキューから通知を受信し、バッチ単位で通知レコードを作成します
+// 作成に成功した通知はキューから削除し、不正な通知はキューに残します
+// キューに残した通知は可視性タイムアウト後に再度受信され、最大受信回数を超えるとデッドレターキューに移動されます
+// データベースに接続できないなど、通知によらないエラーの場合は受信を止めてエラーを返します
+func (s *NotificationBatchService) Consume(ctx context.Context, receiver queue.Receiver, opts ConsumeOptions) (*ConsumeResult, error) {
+	// X-Rayセグメントの作成
+	ctx, seg := xray.BeginSubsegment(ctx, "NotificationBatchService.Consume")
+	defer seg.Close(nil)
+
+	batchSize := opts.BatchSize
+	if batchSize <= 0 || batchSize > queue.MaxBatchSize {
+		batchSize = queue.MaxBatchSize
+	}
+
+	log.Printf("Starting notification consumer (batch size: %d, wait time: %v)", batchSize, opts.WaitTime)
+
+	result := &ConsumeResult{}
+	for {
+		// 停止要求を受けた場合は新しい通知を受信しない
+		if job.Stopping(ctx) || ctx.Err() != nil {
+			log.Printf("Stop requested. Stopping notification consumer")
+			break
+		}
+
+		var messages []queue.Message
+		err := s.cfg.Retry.Do(ctx, "queue.Receive", func(ctx context.Context) error {
+			var err error
+			messages, err = receiver.Receive(ctx, batchSize, opts.WaitTime)
+			return err
+		})
+		if err != nil {
+			// 待機中にグレース期間が経過した場合は停止要求として扱う
+			if ctx.Err() != nil {
+				break
+			}
+			seg.Close(err)
+			return result, apperrors.ExternalService("NotificationBatchService.Consume", fmt.Errorf("failed to receive notifications: %w", err))
+		}
+
+		if len(messages) == 0 {
+			if opts.StopWhenEmpty {
+				break
+			}
+			continue
+		}
+
+		result.Received += len(messages)
+		if err := s.consumeBatch(ctx, receiver, messages, result); err != nil {
+			seg.Close(err)
+			return result, err
+		}
+	}
+
+	if err := seg.AddMetadata("consume_result", result); err != nil {
+		log.Printf("Failed to add consume_result metadata: %v", err)
+	}
+	log.Printf("Notification consumer stopped. Received: %d, Processed: %d, Failed: %d, Suppressed: %d, Delivered: %d",
+		result.Received, result.Processed, result.Failed, result.Suppressed, result.Delivered)
+	return result, nil
+}
+
+// consumeBatch は受信した通知を1トランザクションで作成し、成功した通知をキューから削除します
+func (s *NotificationBatchService) consumeBatch(ctx context.Context, receiver queue.Receiver, messages []queue.Message, result *ConsumeResult) error {
+	petNameMap := make(map[string]string)
+	displays := newDisplayCache(s.userRepo, s.display)
+	records := make([]model.NotificationRecord, 0, len(messages))
+	handles := make([]string, 0, len(messages))
+
+	for _, message := range messages {
+		record, err := s.toNotificationRecord(ctx, message, petNameMap, displays)
+		if err != nil {
+			// 不正な通知はキューに残し、最大受信回数を超えたらデッドレターキューに移動させる
+			if apperrors.Is(err, apperrors.CodeInvalidInput) {
+				log.Printf("Leaving invalid notification message %s in queue (receive count: %d): %v",
+					message.ID, message.ReceiveCount, err)
+				result.Failed++
+				continue
+			}
+			return err
+		}
+		records = append(records, *record)
+		handles = append(handles, message.ReceiptHandle)
+	}
+
+	if len(records) == 0 {
+		return nil
+	}
+
+	// 受け取りを停止している通知は作成せずにキューから削除する
+	var suppressed SuppressionResult
+	targets, err := s.prepareRecords(ctx, records, &suppressed)
+	if err != nil {
+		return err
+	}
+
+	// 一時的なエラーの場合はトランザクションごとリトライする
+	// 失敗した場合は削除せずに返し、可視性タイムアウト後に再度受信させる
+	err = s.cfg.Retry.Do(ctx, "NotificationRepository.CreateNotifications", func(ctx context.Context) error {
+		return s.notificationRepo.CreateNotifications(ctx, targets)
+	})
+	if err != nil {
+		result.Failed += len(records)
+		return apperrors.FromDB("NotificationBatchService.consumeBatch", fmt.Errorf("failed to create notifications: %w", err))
+	}
+
+	// 削除に失敗した通知は再度受信されて重複して作成される可能性がある
+	err = s.cfg.Retry.Do(ctx, "queue.Delete", func(ctx context.Context) error {
+		return receiver.Delete(ctx, handles)
+	})
+	if err != nil {
+		log.Printf("Failed to delete %d processed notification messages: %v", len(handles), err)
+	}
+
+	result.Processed += len(targets)
+	result.Suppressed += suppressed.Notifications
+
+	// 配信の失敗は配信状況に記録して次のバッチ以降に再試行する
+	delivery, err := s.deliverPending(ctx)
+	if err != nil {
+		return err
+	}
+	result.Delivered += delivery.Sent
+	return nil
+}
+
+// toNotificationRecord は受信したメッセージを通知レコードに変換します
+// ペット名とユーザーの表示設定は同じバッチ内で再利用するためpetNameMapとdisplaysに保持します
+// 接続断などの一時的なエラーで受信を止めないよう、ペット名とユーザーの表示設定の取得はリトライします
+func (s *NotificationBatchService) toNotificationRecord(ctx context.Context, message queue.Message, petNameMap map[string]string, displays *displayCache) (*model.NotificationRecord, error) {
+	notification, err := model.ParseNotificationMessage([]byte(message.Body))
+	if err != nil {
+		return nil, apperrors.InvalidInput("NotificationBatchService.toNotificationRecord", err)
+	}
+
+	if petID, ok := notification.Data.(map[string]interface{})["pet_id"].(string); ok {
+		if _, ok := petNameMap[petID]; !ok {
+			var names map[string]string
+			err := s.cfg.Retry.Do(ctx, "NotificationBatchService.getPetNameMap", func(ctx context.Context) error {
+				var err error
+				names, err = s.getPetNameMap(ctx, []model.Notification{*notification})
+				return err
+			})
+			if err != nil {
+				return nil, err
+			}
+			petNameMap[petID] = names[petID]
+		}
+	}
+
+	var opts model.DisplayOptions
+	err = s.cfg.Retry.Do(ctx, "displayCache.get", func(ctx context.Context) error {
+		var err error
+		opts, err = displays.get(ctx, notification.UserID())
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	record, err := notification.ToNotificationRecord(petNameMap, opts)
+	if err != nil {
+		return nil, apperrors.InvalidInput("NotificationBatchService.toNotificationRecord", err)
+	}
+	return record, nil
+}
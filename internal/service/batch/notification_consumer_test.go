@@ -0,0 +1,181 @@
+package batch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-xray-sdk-go/xray"
+	"github.com/horsewin/echo-playground-batch-task/internal/common/job"
+	"github.com/horsewin/echo-playground-batch-task/internal/common/queue"
+	"github.com/horsewin/echo-playground-batch-task/internal/common/retry"
+	"github.com/horsewin/echo-playground-batch-task/internal/model"
+	"github.com/lib/pq"
+)
+
+// newNotificationMessage はペットIDを指定した通知メッセージを作成します
+func newNotificationMessage(t *testing.T, petID string) string {
+	t.Helper()
+	body, err := json.Marshal(model.NewReservationNotification(model.ReservationEvent{
+		UserID:    "user1",
+		PetID:     petID,
+		DateTime:  time.Now().UTC(),
+		CreatedAt: time.Now().UTC(),
+	}))
+	if err != nil {
+		t.Fatalf("failed to marshal notification: %v", err)
+	}
+	return string(body)
+}
+
+func TestNotificationBatchService_Consume(t *testing.T) {
+	ctx, seg := xray.BeginSegment(context.Background(), "TestNotificationBatchService_Consume")
+	defer seg.Close(nil)
+
+	outboxMessage, err := json.Marshal(model.OutboxMessage{
+		EventID:      1,
+		EventType:    model.ReservationEventConfirmed,
+		Notification: model.NewReservationNotification(model.ReservationEvent{UserID: "user2", PetID: "pet2", DateTime: time.Now()}),
+	})
+	if err != nil {
+		t.Fatalf("failed to marshal outbox message: %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		messages       []string
+		batchSize      int
+		missingPetIDs  map[string]bool
+		createErr      error
+		wantProcessed  int
+		wantFailed     int
+		wantChunks     []int
+		wantDeadLetter int
+		wantRemaining  int
+		wantErr        bool
+	}{
+		{
+			name:          "バッチ単位で通知を作成し、キューから削除する",
+			messages:      []string{newNotificationMessage(t, "pet1"), newNotificationMessage(t, "pet1"), newNotificationMessage(t, "pet2")},
+			batchSize:     2,
+			wantProcessed: 3,
+			wantChunks:    []int{2, 1},
+		},
+		{
+			name:          "アウトボックスから配信されたメッセージを受け付ける",
+			messages:      []string{string(outboxMessage)},
+			wantProcessed: 1,
+			wantChunks:    []int{1},
+		},
+		{
+			name:           "不正な通知はキューに残し、最大受信回数を超えたらデッドレターキューに移動する",
+			messages:       []string{newNotificationMessage(t, "pet1"), `{"type":"reservation","data":"broken"}`, newNotificationMessage(t, "unknown")},
+			missingPetIDs:  map[string]bool{"unknown": true},
+			wantProcessed:  1,
+			wantFailed:     6,
+			wantChunks:     []int{1},
+			wantDeadLetter: 2,
+		},
+		{
+			name:          "データベースのエラーの場合は受信を止め、通知をキューに残す",
+			messages:      []string{newNotificationMessage(t, "pet1")},
+			createErr:     errors.New("connection refused"),
+			wantFailed:    1,
+			wantChunks:    []int{1},
+			wantRemaining: 1,
+			wantErr:       true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dlq := queue.NewMemoryQueue()
+			q := queue.NewMemoryQueueWithOptions(queue.MemoryQueueOptions{MaxReceiveCount: 3, DeadLetterQueue: dlq})
+			if err := q.Send(ctx, tt.messages); err != nil {
+				t.Fatalf("Send() error = %v", err)
+			}
+
+			mockNotificationRepo := &MockNotificationRepository{createNotificationsError: tt.createErr}
+			service := newTestNotificationBatchService(mockNotificationRepo, &MockPetRepository{missingPetIDs: tt.missingPetIDs})
+
+			result, err := service.Consume(ctx, q, ConsumeOptions{BatchSize: tt.batchSize, StopWhenEmpty: true})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Consume() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if result.Processed != tt.wantProcessed || result.Failed != tt.wantFailed {
+				t.Errorf("result = %+v, want processed %d failed %d", result, tt.wantProcessed, tt.wantFailed)
+			}
+			if !slices.Equal(mockNotificationRepo.chunkSizes, tt.wantChunks) {
+				t.Errorf("chunk sizes = %v, want %v", mockNotificationRepo.chunkSizes, tt.wantChunks)
+			}
+			if got := len(dlq.Messages()); got != tt.wantDeadLetter {
+				t.Errorf("dead letter messages = %d, want %d", got, tt.wantDeadLetter)
+			}
+			if got := len(q.Messages()); got != tt.wantRemaining {
+				t.Errorf("remaining messages = %d, want %d", got, tt.wantRemaining)
+			}
+		})
+	}
+}
+
+func TestNotificationBatchService_Consume_StopsOnStopRequest(t *testing.T) {
+	ctx, seg := xray.BeginSegment(context.Background(), "TestNotificationBatchService_Consume_StopsOnStopRequest")
+	defer seg.Close(nil)
+	ctx, stop := job.WithStop(ctx)
+
+	q := queue.NewMemoryQueue()
+	service := newTestNotificationBatchService(&MockNotificationRepository{}, &MockPetRepository{})
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := service.Consume(ctx, q, ConsumeOptions{WaitTime: 10 * time.Millisecond})
+		done <- err
+	}()
+
+	q.Send(ctx, []string{newNotificationMessage(t, "pet1")})
+	time.Sleep(50 * time.Millisecond)
+	stop()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Consume() error = %v, want nil", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Consume() did not stop after stop request")
+	}
+	if len(q.Messages()) != 0 {
+		t.Errorf("remaining messages = %d, want 0", len(q.Messages()))
+	}
+}
+
+func TestNotificationBatchService_Consume_RetriesTransientLookupErrors(t *testing.T) {
+	ctx, seg := xray.BeginSegment(context.Background(), "TestNotificationBatchService_Consume_RetriesTransientLookupErrors")
+	defer seg.Close(nil)
+
+	q := queue.NewMemoryQueue()
+	if err := q.Send(ctx, []string{newNotificationMessage(t, "pet1")}); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	// ユーザーの表示設定の取得が一時的に失敗しても、受信を止めずに通知を作成する
+	userRepo := &MockUserRepository{transientErrors: []error{&pq.Error{Code: "57P01"}}}
+	mockNotificationRepo := &MockNotificationRepository{}
+	service := newTestNotificationBatchService(mockNotificationRepo, &MockPetRepository{})
+	service.userRepo = userRepo
+	service.cfg.Retry = retry.Policy{MaxAttempts: 3}
+
+	result, err := service.Consume(ctx, q, ConsumeOptions{StopWhenEmpty: true})
+	if err != nil {
+		t.Fatalf("Consume() error = %v, want nil", err)
+	}
+	if result.Processed != 1 || userRepo.calls != 2 {
+		t.Errorf("result = %+v, user lookups = %d, want processed 1 after 2 lookups", result, userRepo.calls)
+	}
+	if len(q.Messages()) != 0 {
+		t.Errorf("remaining messages = %d, want 0", len(q.Messages()))
+	}
+}
@@ -2,12 +2,16 @@ package batch
 
 import (
 	"context"
+	"database/sql"
+	"fmt"
+	"slices"
 	"testing"
 	"time"
 
 	"github.com/aws/aws-xray-sdk-go/xray"
 	"github.com/horsewin/echo-playground-batch-task/internal/common/config"
 	"github.com/horsewin/echo-playground-batch-task/internal/model"
+	"github.com/horsewin/echo-playground-batch-task/internal/repository"
 	"github.com/jmoiron/sqlx"
 )
 
@@ -16,15 +20,27 @@ type MockNotificationRepository struct {
 	createNotificationsCalled bool
 	createNotificationsError  error
 	notifications             []model.NotificationRecord
+	// chunkSizes はCreateNotificationsの呼び出しごとの件数です
+	chunkSizes []int
+	// failOnCall が1以上の場合、その回数目の呼び出しでcreateNotificationsErrorを返します
+	failOnCall int
+	// created はCreateで作成した通知レコードです
+	created []model.NotificationRecord
 }
 
 func (m *MockNotificationRepository) CreateNotifications(ctx context.Context, records []model.NotificationRecord) error {
 	m.createNotificationsCalled = true
 	m.notifications = records
+	m.chunkSizes = append(m.chunkSizes, len(records))
+	if m.failOnCall > 0 && len(m.chunkSizes) != m.failOnCall {
+		return nil
+	}
 	return m.createNotificationsError
 }
 
 func (m *MockNotificationRepository) Create(ctx context.Context, tx *sqlx.Tx, record *model.NotificationRecord) error {
+	record.ID = len(m.created) + 1
+	m.created = append(m.created, *record)
 	return nil
 }
 
@@ -36,22 +52,47 @@ func (m *MockNotificationRepository) UpdateIsRead(ctx context.Context, tx *sqlx.
 	return nil
 }
 
+func (m *MockNotificationRepository) UpdateIsReadByFilter(ctx context.Context, filter model.NotificationFilter, isRead bool) (int64, error) {
+	return 0, nil
+}
+
 // MockPetRepository はテスト用のモックリポジトリです
 type MockPetRepository struct {
 	getNameByIDCalled bool
 	getNameByIDError  error
+	// missingPetIDs は存在しないペットIDです
+	missingPetIDs map[string]bool
+	// availabilities はペットIDごとの見学可否です。ない場合は見学できるものとして扱います
+	availabilities map[string]*model.PetAvailability
 }
 
 func (m *MockPetRepository) GetNameByID(ctx context.Context, id string) (string, error) {
 	m.getNameByIDCalled = true
+	if m.missingPetIDs[id] {
+		return "", sql.ErrNoRows
+	}
 	return "TestPet", m.getNameByIDError
 }
 
+// availability はペットの見学可否を返します。ルールの評価でmockRuleRepositoryから参照します
+func (m *MockPetRepository) availability(id string) (*model.PetAvailability, error) {
+	if m.missingPetIDs[id] {
+		return nil, sql.ErrNoRows
+	}
+	if availability, ok := m.availabilities[id]; ok {
+		return availability, nil
+	}
+	return &model.PetAvailability{PetID: id, Status: model.PetStatusAvailable}, nil
+}
+
 // newTestNotificationBatchService はテスト用のNotificationBatchServiceを作成します
 func newTestNotificationBatchService(mockNotificationRepo *MockNotificationRepository, mockPetRepo *MockPetRepository) *NotificationBatchService {
 	return &NotificationBatchService{
 		notificationRepo: mockNotificationRepo,
 		petRepo:          mockPetRepo,
+		preferenceRepo:   &MockNotificationPreferenceRepository{},
+		userRepo:         &MockUserRepository{},
+		now:              time.Now,
 		cfg:              &config.Config{},
 	}
 }
@@ -80,7 +121,6 @@ func TestNotificationBatchService_Run(t *testing.T) {
 				{
 					Type:      model.NotificationTypeReservation,
 					Data:      map[string]interface{}{"user_id": "user1", "pet_id": "pet1", "date_time": now.Format(time.RFC3339)},
-					DateTime:  now,
 					CreatedAt: now,
 				},
 			},
@@ -93,13 +133,11 @@ func TestNotificationBatchService_Run(t *testing.T) {
 				{
 					Type:      model.NotificationTypeReservation,
 					Data:      map[string]interface{}{"user_id": "user1", "pet_id": "pet1", "date_time": now.Format(time.RFC3339)},
-					DateTime:  now,
 					CreatedAt: now,
 				},
 				{
 					Type:      model.NotificationTypeReservation,
 					Data:      map[string]interface{}{"user_id": "user2", "pet_id": "pet2", "date_time": now.Format(time.RFC3339)},
-					DateTime:  now,
 					CreatedAt: now,
 				},
 			},
@@ -139,3 +177,48 @@ func TestNotificationBatchService_Run(t *testing.T) {
 		})
 	}
 }
+
+func TestNotificationBatchService_Run_ResumesFromCheckpoint(t *testing.T) {
+	ctx, seg := xray.BeginSegment(context.Background(), "TestNotificationBatchService_Run_ResumesFromCheckpoint")
+	defer seg.Close(nil)
+
+	now := time.Now().UTC()
+	notifications := make([]model.Notification, 5)
+	for i := range notifications {
+		notifications[i] = model.Notification{
+			Type:      model.NotificationTypeReservation,
+			Data:      map[string]interface{}{"user_id": fmt.Sprintf("user%d", i), "pet_id": "pet1", "date_time": now.Format(time.RFC3339)},
+			CreatedAt: now,
+		}
+	}
+	checkpointRepo := repository.NewFileCheckpointRepository(t.TempDir())
+
+	// 1回目: 2チャンク目の作成に失敗する
+	mockNotificationRepo := &MockNotificationRepository{
+		createNotificationsError: fmt.Errorf("database error"),
+		failOnCall:               2,
+	}
+	service := newTestNotificationBatchService(mockNotificationRepo, &MockPetRepository{})
+	service.checkpointRepo = checkpointRepo
+	service.cfg.Run.ID = "run-1"
+	service.cfg.Notification.ChunkSize = 2
+	service.SetArgs(notifications)
+	if err := service.Run(ctx); err == nil {
+		t.Fatal("first Run() error = nil, want error")
+	}
+
+	// 2回目: 作成済みの1チャンク目を飛ばして再開する
+	mockNotificationRepo = &MockNotificationRepository{}
+	service = newTestNotificationBatchService(mockNotificationRepo, &MockPetRepository{})
+	service.checkpointRepo = checkpointRepo
+	service.cfg.Run.ID = "run-1"
+	service.cfg.Run.Resume = true
+	service.cfg.Notification.ChunkSize = 2
+	service.SetArgs(notifications)
+	if err := service.Run(ctx); err != nil {
+		t.Fatalf("resumed Run() error = %v, want nil", err)
+	}
+	if want := []int{2, 1}; !slices.Equal(mockNotificationRepo.chunkSizes, want) {
+		t.Errorf("chunk sizes = %v, want %v", mockNotificationRepo.chunkSizes, want)
+	}
+}
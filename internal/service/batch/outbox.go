@@ -0,0 +1,196 @@
+package batch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/aws/aws-xray-sdk-go/xray"
+	"github.com/horsewin/echo-playground-batch-task/internal/common/apperrors"
+	"github.com/horsewin/echo-playground-batch-task/internal/common/config"
+	"github.com/horsewin/echo-playground-batch-task/internal/common/queue"
+	"github.com/horsewin/echo-playground-batch-task/internal/common/retry"
+	"github.com/horsewin/echo-playground-batch-task/internal/model"
+	"github.com/horsewin/echo-playground-batch-task/internal/repository"
+)
+
+// errNotPublished はイベントを配信しなかったことを表します
+// ローカル環境でStep Functionsへの通知を省略した場合など、イベントを配信済みにしてはいけない場合に返します
+var errNotPublished = errors.New("events were not published")
+
+// EventPublisher は予約イベントの配信を担当するインターフェースです
+type EventPublisher interface {
+	Publish(ctx context.Context, events []model.OutboxEvent) error
+}
+
+// EventPublisherFunc は関数をEventPublisherとして扱うための型です
+type EventPublisherFunc func(ctx context.Context, events []model.OutboxEvent) error
+
+// Publish は予約イベントを配信します
+func (f EventPublisherFunc) Publish(ctx context.Context, events []model.OutboxEvent) error {
+	return f(ctx, events)
+}
+
+// newEventPublisher は設定に応じた予約イベントの配信先を作成します
+// Step Functionsのタスク出力に配信する場合はnilを返します
+func newEventPublisher(ctx context.Context, cfg *config.Config) (EventPublisher, error) {
+	switch cfg.Outbox.Publisher {
+	case "", "sfn":
+		return nil, nil
+	case "queue":
+		if cfg.Outbox.QueueURL == "" {
+			return nil, fmt.Errorf("OUTBOX_QUEUE_URL is required when OUTBOX_PUBLISHER is queue")
+		}
+		q, err := queue.NewSQSQueue(ctx, cfg.Outbox.QueueURL)
+		if err != nil {
+			return nil, err
+		}
+		return NewQueueEventPublisher(q, cfg.Retry), nil
+	case "file":
+		return NewFileEventPublisher(cfg.Outbox.FilePath), nil
+	}
+	return nil, fmt.Errorf("unknown outbox publisher: %s", cfg.Outbox.Publisher)
+}
+
+// toOutboxMessages は予約イベントを配信するメッセージ(JSON)に変換します
+func toOutboxMessages(events []model.OutboxEvent) ([]string, error) {
+	bodies := make([]string, len(events))
+	for i, event := range events {
+		message, err := model.NewOutboxMessage(event)
+		if err != nil {
+			return nil, err
+		}
+		body, err := json.Marshal(message)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal outbox message: %w", err)
+		}
+		bodies[i] = string(body)
+	}
+	return bodies, nil
+}
+
+// QueueEventPublisher は予約イベントをキューに配信します
+type QueueEventPublisher struct {
+	sender queue.Sender
+	retry  retry.Policy
+}
+
+// NewQueueEventPublisher は新しいQueueEventPublisherを作成します
+func NewQueueEventPublisher(sender queue.Sender, policy retry.Policy) *QueueEventPublisher {
+	return &QueueEventPublisher{sender: sender, retry: policy}
+}
+
+// Publish は予約イベントをキューに送信します
+func (p *QueueEventPublisher) Publish(ctx context.Context, events []model.OutboxEvent) error {
+	bodies, err := toOutboxMessages(events)
+	if err != nil {
+		return apperrors.Internal("QueueEventPublisher.Publish", err)
+	}
+	err = p.retry.Do(ctx, "queue.Send", func(ctx context.Context) error {
+		return p.sender.Send(ctx, bodies)
+	})
+	if err != nil {
+		return apperrors.ExternalService("QueueEventPublisher.Publish", err)
+	}
+	return nil
+}
+
+// FileEventPublisher は予約イベントをJSONLファイルに追記します
+// ローカル環境での実行や、他のシステムにファイルで連携する場合に利用します
+type FileEventPublisher struct {
+	path string
+}
+
+// NewFileEventPublisher は新しいFileEventPublisherを作成します
+func NewFileEventPublisher(path string) *FileEventPublisher {
+	return &FileEventPublisher{path: path}
+}
+
+// Publish は予約イベントを1行1イベントでファイルに追記します
+func (p *FileEventPublisher) Publish(ctx context.Context, events []model.OutboxEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	bodies, err := toOutboxMessages(events)
+	if err != nil {
+		return apperrors.Internal("FileEventPublisher.Publish", err)
+	}
+
+	f, err := os.OpenFile(p.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return apperrors.Internal("FileEventPublisher.Publish", fmt.Errorf("failed to open outbox file: %w", err))
+	}
+	defer f.Close()
+
+	for _, body := range bodies {
+		if _, err := f.WriteString(body + "\n"); err != nil {
+			return apperrors.Internal("FileEventPublisher.Publish", fmt.Errorf("failed to write outbox file: %w", err))
+		}
+	}
+	if err := f.Sync(); err != nil {
+		return apperrors.Internal("FileEventPublisher.Publish", fmt.Errorf("failed to sync outbox file: %w", err))
+	}
+	return nil
+}
+
+// outboxRelay は未配信の予約イベントを配信し、配信済みにします
+// 配信後に配信済みにする前に失敗した場合は次回の実行で再度配信されるため、少なくとも1回の配信を保証します
+type outboxRelay struct {
+	repo      repository.ReservationEventRepository
+	publisher EventPublisher
+	// batchSize は1回に配信するイベントの件数です。0以下の場合は全件を1回で配信します
+	batchSize int
+	retry     retry.Policy
+}
+
+// relay は未配信の予約イベントがなくなるまで配信し、配信した件数を返します
+// イベントがない場合も1回は配信を呼び出します (Step Functionsにはタスクの成功を必ず通知する必要があるため)
+func (r *outboxRelay) relay(ctx context.Context) (int, error) {
+	ctx, seg := xray.BeginSubsegment(ctx, "outboxRelay.relay")
+	defer seg.Close(nil)
+
+	delivered := 0
+	for {
+		events, err := r.repo.GetUndelivered(ctx, r.batchSize)
+		if err != nil {
+			seg.Close(err)
+			return delivered, apperrors.FromDB("outboxRelay.relay", err)
+		}
+
+		if err := r.publisher.Publish(ctx, events); err != nil {
+			if errors.Is(err, errNotPublished) {
+				log.Printf("Left %d reservation events undelivered", len(events))
+				return delivered, nil
+			}
+			seg.Close(err)
+			return delivered, err
+		}
+
+		ids := make([]int64, len(events))
+		for i, event := range events {
+			ids[i] = event.ID
+		}
+		err = r.retry.Do(ctx, "ReservationEventRepository.MarkDelivered", func(ctx context.Context) error {
+			return r.repo.MarkDelivered(ctx, ids)
+		})
+		if err != nil {
+			seg.Close(err)
+			return delivered, apperrors.FromDB("outboxRelay.relay",
+				fmt.Errorf("published %d reservation events but failed to mark them as delivered: %w", len(ids), err))
+		}
+		delivered += len(events)
+
+		if r.batchSize <= 0 || len(events) < r.batchSize {
+			break
+		}
+	}
+
+	if err := seg.AddMetadata("delivered", delivered); err != nil {
+		log.Printf("Failed to add delivered metadata: %v", err)
+	}
+	log.Printf("Delivered %d reservation events", delivered)
+	return delivered, nil
+}
@@ -0,0 +1,172 @@
+package batch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-xray-sdk-go/xray"
+	"github.com/horsewin/echo-playground-batch-task/internal/common/queue"
+	"github.com/horsewin/echo-playground-batch-task/internal/common/retry"
+	"github.com/horsewin/echo-playground-batch-task/internal/model"
+)
+
+// newTestOutbox は指定した件数の未配信のイベントを持つアウトボックスを作成します
+func newTestOutbox(t *testing.T, n int) *MockReservationEventRepository {
+	t.Helper()
+	repo := &MockReservationEventRepository{}
+	for i := 0; i < n; i++ {
+		event, err := model.NewReservationOutboxEvent(int64(i+1), model.ReservationEventConfirmed, model.ReservationEvent{
+			UserID:   "user1",
+			PetID:    "pet1",
+			DateTime: time.Now().UTC(),
+		})
+		if err != nil {
+			t.Fatalf("NewReservationOutboxEvent() error = %v", err)
+		}
+		if err := repo.Create(context.Background(), nil, event); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+	}
+	return repo
+}
+
+func TestOutboxRelay_Relay(t *testing.T) {
+	ctx, seg := xray.BeginSegment(context.Background(), "TestOutboxRelay_Relay")
+	defer seg.Close(nil)
+
+	tests := []struct {
+		name          string
+		events        int
+		batchSize     int
+		publishErr    error
+		markErr       error
+		wantDelivered int
+		wantPublishes int
+		wantErr       bool
+	}{
+		{
+			name:          "バッチサイズごとに配信する",
+			events:        5,
+			batchSize:     2,
+			wantDelivered: 5,
+			wantPublishes: 3,
+		},
+		{
+			name:          "バッチサイズが0の場合は全件を1回で配信する",
+			events:        5,
+			wantDelivered: 5,
+			wantPublishes: 1,
+		},
+		{
+			name:          "イベントがなくても1回は配信を呼び出す",
+			events:        0,
+			wantDelivered: 0,
+			wantPublishes: 1,
+		},
+		{
+			name:          "配信しなかった場合は未配信のまま残す",
+			events:        2,
+			publishErr:    errNotPublished,
+			wantDelivered: 0,
+			wantPublishes: 1,
+		},
+		{
+			name:          "配信に失敗した場合は未配信のまま残す",
+			events:        2,
+			publishErr:    errors.New("queue unavailable"),
+			wantDelivered: 0,
+			wantPublishes: 1,
+			wantErr:       true,
+		},
+		{
+			name:          "配信済みにできなかった場合はエラーを返す",
+			events:        2,
+			markErr:       errors.New("connection refused"),
+			wantDelivered: 0,
+			wantPublishes: 1,
+			wantErr:       true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newTestOutbox(t, tt.events)
+			repo.markDeliveredError = tt.markErr
+
+			publishes := 0
+			relay := &outboxRelay{
+				repo: repo,
+				publisher: EventPublisherFunc(func(ctx context.Context, events []model.OutboxEvent) error {
+					publishes++
+					return tt.publishErr
+				}),
+				batchSize: tt.batchSize,
+			}
+
+			delivered, err := relay.relay(ctx)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("relay() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if delivered != tt.wantDelivered {
+				t.Errorf("delivered = %d, want %d", delivered, tt.wantDelivered)
+			}
+			if publishes != tt.wantPublishes {
+				t.Errorf("publishes = %d, want %d", publishes, tt.wantPublishes)
+			}
+			if want := tt.events - tt.wantDelivered; repo.undelivered() != want {
+				t.Errorf("undelivered = %d, want %d", repo.undelivered(), want)
+			}
+		})
+	}
+}
+
+func TestEventPublishers(t *testing.T) {
+	ctx := context.Background()
+	events, _ := newTestOutbox(t, 3).GetUndelivered(ctx, 0)
+
+	t.Run("キューに1イベント1メッセージで送信する", func(t *testing.T) {
+		q := queue.NewMemoryQueue()
+		if err := NewQueueEventPublisher(q, retry.NoRetry()).Publish(ctx, events); err != nil {
+			t.Fatalf("Publish() error = %v", err)
+		}
+		assertOutboxMessages(t, q.Messages(), events)
+	})
+
+	t.Run("ファイルに1イベント1行で追記する", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "events.jsonl")
+		publisher := NewFileEventPublisher(path)
+		if err := publisher.Publish(ctx, events[:1]); err != nil {
+			t.Fatalf("Publish() error = %v", err)
+		}
+		if err := publisher.Publish(ctx, events[1:]); err != nil {
+			t.Fatalf("Publish() error = %v", err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("ReadFile() error = %v", err)
+		}
+		assertOutboxMessages(t, strings.Split(strings.TrimSpace(string(data)), "\n"), events)
+	})
+}
+
+func assertOutboxMessages(t *testing.T, bodies []string, events []model.OutboxEvent) {
+	t.Helper()
+	if len(bodies) != len(events) {
+		t.Fatalf("messages = %d, want %d", len(bodies), len(events))
+	}
+	for i, body := range bodies {
+		var message model.OutboxMessage
+		if err := json.Unmarshal([]byte(body), &message); err != nil {
+			t.Fatalf("failed to parse message %q: %v", body, err)
+		}
+		if message.EventID != events[i].ID || message.Notification.Type != model.NotificationTypeReservation {
+			t.Errorf("message[%d] = %+v, want event %d", i, message, events[i].ID)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package batch
+
+import (
+	"context"
+
+	"github.com/horsewin/echo-playground-batch-task/internal/common/apperrors"
+	"github.com/horsewin/echo-playground-batch-task/internal/model"
+	"github.com/horsewin/echo-playground-batch-task/internal/repository"
+)
+
+// SuppressionResult はユーザーの受け取り設定により作成しなかった通知の件数です
+type SuppressionResult struct {
+	// Notifications は通知の種類の受け取りを停止しているため、作成しなかった通知レコードの件数です
+	Notifications int `json:"notifications"`
+	// Deliveries はチャネルの受け取りを停止しているため、作成しなかった外部への配信の件数です
+	Deliveries int `json:"deliveries"`
+}
+
+// preferenceCache はユーザーごとの通知の受け取り設定を1回の処理の間保持します
+type preferenceCache struct {
+	repo  repository.NotificationPreferenceRepository
+	prefs map[string]*model.NotificationPreferences
+}
+
+func newPreferenceCache(repo repository.NotificationPreferenceRepository) *preferenceCache {
+	return &preferenceCache{repo: repo, prefs: make(map[string]*model.NotificationPreferences)}
+}
+
+// get は指定されたユーザーの受け取り設定を返します
+func (c *preferenceCache) get(ctx context.Context, userID string) (*model.NotificationPreferences, error) {
+	if p, ok := c.prefs[userID]; ok {
+		return p, nil
+	}
+	p, err := c.repo.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, apperrors.FromDB("preferenceCache.get", err)
+	}
+	c.prefs[userID] = p
+	return p, nil
+}
@@ -0,0 +1,115 @@
+package batch
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-xray-sdk-go/xray"
+	"github.com/horsewin/echo-playground-batch-task/internal/common/notifier"
+	"github.com/horsewin/echo-playground-batch-task/internal/model"
+)
+
+// MockNotificationPreferenceRepository はテスト用のモックリポジトリです
+// 設定がないユーザーはnil(すべて受け取る)を返します
+type MockNotificationPreferenceRepository struct {
+	prefs map[string]*model.NotificationPreferences
+}
+
+func (m *MockNotificationPreferenceRepository) GetByUserID(ctx context.Context, userID string) (*model.NotificationPreferences, error) {
+	return m.prefs[userID], nil
+}
+
+func TestNotificationBatchService_RunSuppressed(t *testing.T) {
+	ctx, seg := xray.BeginSegment(context.Background(), "TestNotificationBatchService_RunSuppressed")
+	defer seg.Close(nil)
+
+	notificationRepo := &MockNotificationRepository{}
+	service := newTestNotificationBatchService(notificationRepo, &MockPetRepository{})
+	service.preferenceRepo = &MockNotificationPreferenceRepository{
+		prefs: map[string]*model.NotificationPreferences{
+			"user2": {Preferences: []model.NotificationPreference{
+				{Type: model.NotificationTypeReservation, Channel: model.NotificationChannelInApp, Enabled: false},
+			}},
+		},
+	}
+	service.cfg.Notification.ChunkSize = 1
+
+	var notifications []model.Notification
+	for _, userID := range []string{"user1", "user2", "user3"} {
+		notifications = append(notifications, model.NewReservationNotification(model.ReservationEvent{
+			UserID:   userID,
+			PetID:    "pet1",
+			DateTime: time.Now(),
+		}))
+	}
+	service.SetArgs(notifications)
+
+	if err := service.Run(ctx); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	// 受け取りを停止しているユーザーの通知は作成しない
+	if !slices.Equal(notificationRepo.chunkSizes, []int{1, 0, 1}) {
+		t.Errorf("chunk sizes = %v, want [1 0 1]", notificationRepo.chunkSizes)
+	}
+	if got := notificationRepo.notifications[0].UserID; got != "user3" {
+		t.Errorf("last created user = %s, want user3", got)
+	}
+}
+
+func TestNotificationBatchService_deliverPendingPreferences(t *testing.T) {
+	ctx, seg := xray.BeginSegment(context.Background(), "TestNotificationBatchService_deliverPendingPreferences")
+	defer seg.Close(nil)
+
+	// 2024-01-01 23:30 JST
+	now := time.Date(2024, 1, 1, 14, 30, 0, 0, time.UTC)
+
+	deliveryRepo := &MockNotificationDeliveryRepository{
+		deliveries: []model.NotificationDelivery{
+			{ID: 1, UserID: "user1", Type: model.NotificationTypeReservation, Channel: model.NotificationChannelEmail, Address: "user1@example.com", Status: model.DeliveryStatusPending},
+			{ID: 2, UserID: "quiet", Type: model.NotificationTypeReservation, Channel: model.NotificationChannelEmail, Address: "quiet@example.com", Status: model.DeliveryStatusPending},
+			{ID: 3, UserID: "optout", Type: model.NotificationTypeReservation, Channel: model.NotificationChannelEmail, Address: "optout@example.com", Status: model.DeliveryStatusPending},
+			{ID: 4, UserID: "invalid", Type: model.NotificationTypeReservation, Channel: model.NotificationChannelEmail, Address: "invalid@example.com", Status: model.DeliveryStatusPending},
+		},
+	}
+	email := &fakeNotifier{channel: model.NotificationChannelEmail}
+
+	service := newTestNotificationBatchService(&MockNotificationRepository{}, &MockPetRepository{})
+	service.deliveryRepo = deliveryRepo
+	service.notifiers = map[model.NotificationChannel]notifier.Notifier{model.NotificationChannelEmail: email}
+	service.preferenceRepo = &MockNotificationPreferenceRepository{
+		prefs: map[string]*model.NotificationPreferences{
+			"quiet": {QuietHours: &model.QuietHours{Start: "22:00", End: "07:00", TimeZone: "Asia/Tokyo"}},
+			// 配信を作成した後に受け取りを停止した
+			"optout": {Preferences: []model.NotificationPreference{
+				{Type: model.NotificationTypeReservation, Channel: model.NotificationChannelEmail, Enabled: false},
+			}},
+			// 不正なおやすみ時間帯は無視して配信する
+			"invalid": {QuietHours: &model.QuietHours{Start: "22:00", End: "07:00", TimeZone: "Invalid/Zone"}},
+		},
+	}
+	service.now = func() time.Time { return now }
+	service.cfg.Delivery.MaxAttempts = 3
+
+	result, err := service.deliverPending(ctx)
+	if err != nil {
+		t.Fatalf("deliverPending() error = %v", err)
+	}
+	if *result != (DeliveryResult{Sent: 2, Suppressed: 1, Deferred: 1}) {
+		t.Errorf("result = %+v, want {Sent:2 Suppressed:1 Deferred:1}", *result)
+	}
+
+	wantStatus := map[int64]model.DeliveryStatus{
+		1: model.DeliveryStatusSent,
+		2: model.DeliveryStatusPending,
+		3: model.DeliveryStatusSuppressed,
+		4: model.DeliveryStatusSent,
+	}
+	for _, d := range deliveryRepo.deliveries {
+		if d.Status != wantStatus[d.ID] {
+			t.Errorf("delivery %d status = %s, want %s", d.ID, d.Status, wantStatus[d.ID])
+		}
+	}
+}
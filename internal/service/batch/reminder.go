@@ -0,0 +1,294 @@
+package batch
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/aws/aws-xray-sdk-go/xray"
+	"github.com/horsewin/echo-playground-batch-task/internal/common/apperrors"
+	"github.com/horsewin/echo-playground-batch-task/internal/common/config"
+	"github.com/horsewin/echo-playground-batch-task/internal/common/database"
+	"github.com/horsewin/echo-playground-batch-task/internal/common/job"
+	"github.com/horsewin/echo-playground-batch-task/internal/model"
+	"github.com/horsewin/echo-playground-batch-task/internal/repository"
+)
+
+// ReminderBatchService は確定済みの予約のリマインド通知を作成するバッチ処理を担当します
+type ReminderBatchService struct {
+	db               *database.DB
+	reservationRepo  repository.ReservationRepository
+	petRepo          repository.PetRepository
+	notificationRepo repository.NotificationRepository
+	reminderRepo     repository.ReservationReminderRepository
+	preferenceRepo   repository.NotificationPreferenceRepository
+	userRepo         repository.UserRepository
+	// display は通知に表示する日時の既定のタイムゾーンと書式です。ユーザーが設定している場合はユーザーの設定で表示します
+	display model.DisplayOptions
+	// now はリマインドの対象を判定する現在時刻です
+	now func() time.Time
+	cfg *config.Config
+}
+
+// NewReminderBatchService は新しいReminderBatchServiceを作成します
+func NewReminderBatchService(cfg *config.Config) (*ReminderBatchService, error) {
+	db, err := database.NewDB(cfg.DB)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create database connection: %w", err)
+	}
+
+	// database.DBをrepository.DBに変換
+	repoDb := &repository.DB{DB: db.DB}
+
+	display, err := newDisplayOptions(cfg)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create notification display options: %w", err)
+	}
+
+	return &ReminderBatchService{
+		db:               db,
+		reservationRepo:  repository.NewReservationRepository(repoDb),
+		petRepo:          repository.NewPetRepository(repoDb),
+		notificationRepo: repository.NewNotificationRepository(repoDb),
+		reminderRepo:     repository.NewReservationReminderRepository(repoDb),
+		preferenceRepo:   repository.NewNotificationPreferenceRepository(repoDb),
+		userRepo:         repository.NewUserRepository(repoDb),
+		display:          display,
+		now:              time.Now,
+		cfg:              cfg,
+	}, nil
+}
+
+// Close は終了処理を行います
+func (s *ReminderBatchService) Close() error {
+	if s.db != nil {
+		return s.db.Close()
+	}
+	return nil
+}
+
+// ReminderResult はリマインドバッチの処理結果です
+type ReminderResult struct {
+	// Found はリマインドの対象期間に予約日時がある確定済みの予約の件数です
+	Found   int `json:"found"`
+	Created int `json:"created"`
+	// Skipped は既にリマインド済みのため作成しなかった件数です
+	Skipped int `json:"skipped"`
+	// Suppressed はユーザーが予約の通知の受け取りを停止しているため作成しなかった件数です
+	Suppressed int `json:"suppressed"`
+	Failed     int `json:"failed"`
+}
+
+// Run はリマインドバッチ処理を実行します
+// 予約日時までの時間が最も短いタイミング(例: 1時間前)のリマインドを1件だけ作成し、
+// 作成したリマインドはreservation_remindersに記録して再実行時に重複して作成しないようにします
+func (s *ReminderBatchService) Run(ctx context.Context) error {
+	// X-Rayセグメントの作成
+	ctx, seg := xray.BeginSubsegment(ctx, "ReminderBatchService.Run")
+	defer seg.Close(nil)
+
+	windows := slices.Clone(s.cfg.Reminder.Windows)
+	slices.Sort(windows)
+	if len(windows) == 0 || windows[0] <= 0 {
+		err := apperrors.InvalidInput("ReminderBatchService.Run", fmt.Errorf("invalid reminder windows: %v", windows))
+		seg.Close(err)
+		return err
+	}
+
+	now := s.now()
+	startTime := time.Now()
+	log.Printf("Starting reminder batch process (windows: %v)", windows)
+
+	// 最も長いタイミングまでに予約日時がある確定済みの予約を取得
+	reservations, err := s.reservationRepo.GetReservationsByStatusBetween(ctx, model.ReservationStatusConfirmed, now, now.Add(windows[len(windows)-1]))
+	if err != nil {
+		seg.Close(err)
+		return apperrors.FromDB("ReminderBatchService.Run", err)
+	}
+	log.Printf("Found %d confirmed reservations to remind", len(reservations))
+
+	sent, err := s.getSentReminders(ctx, reservations)
+	if err != nil {
+		seg.Close(err)
+		return err
+	}
+
+	result := &ReminderResult{Found: len(reservations)}
+	petNameMap := make(map[string]string)
+	displays := newDisplayCache(s.userRepo, s.display)
+	prefs := newPreferenceCache(s.preferenceRepo)
+	for _, reservation := range reservations {
+		// 停止要求を受けた場合は新しいリマインドを作成しない
+		// 作成しなかったリマインドは次回の実行で作成される
+		if job.Stopping(ctx) {
+			log.Printf("Stop requested. Leaving %d reservations unreminded",
+				result.Found-result.Created-result.Skipped-result.Suppressed-result.Failed)
+			break
+		}
+
+		window := reminderWindow(windows, reservation.ReservationDateTime.Sub(now))
+		if sent[reservation.ID][window] {
+			result.Skipped++
+			continue
+		}
+
+		// 予約の通知の受け取りを停止しているユーザーにはリマインドを作成しない
+		// 記録しないため、受け取りを再開すると次回の実行で作成される
+		var pref *model.NotificationPreferences
+		err := s.cfg.Retry.Do(ctx, "preferenceCache.get", func(ctx context.Context) error {
+			var err error
+			pref, err = prefs.get(ctx, reservation.UserID)
+			return err
+		})
+		if err != nil {
+			log.Printf("Failed to get notification preferences for reservation %d: %v", reservation.ID, err)
+			result.Failed++
+			continue
+		}
+		if !pref.AllowsNotification(model.NotificationTypeReservation) {
+			result.Suppressed++
+			continue
+		}
+
+		var created bool
+		err = s.cfg.Retry.Do(ctx, "ReminderBatchService.createReminder", func(ctx context.Context) error {
+			var err error
+			created, err = s.createReminder(ctx, reservation, window, petNameMap, displays)
+			return err
+		})
+		switch {
+		case err != nil:
+			log.Printf("Failed to create reminder for reservation %d: %v", reservation.ID, err)
+			result.Failed++
+		case created:
+			result.Created++
+		default:
+			result.Skipped++
+		}
+	}
+
+	if err := seg.AddMetadata("reminder_result", result); err != nil {
+		log.Printf("Failed to add reminder_result metadata: %v", err)
+	}
+	log.Printf("Reminder batch process completed. Found: %d, Created: %d, Skipped: %d, Suppressed: %d, Failed: %d, Duration: %v",
+		result.Found, result.Created, result.Skipped, result.Suppressed, result.Failed, time.Since(startTime))
+
+	// 作成に失敗したリマインドは記録されないため、次回の実行で再度作成される
+	if result.Failed > 0 {
+		err := apperrors.PartialFailure("ReminderBatchService.Run",
+			fmt.Errorf("failed to create %d of %d reminders", result.Failed, result.Found)).
+			WithDetails(map[string]any{
+				"run_id":     s.cfg.Run.ID,
+				"total":      result.Found,
+				"created":    result.Created,
+				"skipped":    result.Skipped,
+				"suppressed": result.Suppressed,
+				"failed":     result.Failed,
+			})
+		seg.Close(err)
+		return err
+	}
+	return nil
+}
+
+// reminderWindow は予約日時までの時間untilに対応する最も短いタイミングを返します
+// 24時間前と1時間前のリマインドがある場合、予約日時まで30分の予約には1時間前のリマインドだけを作成します
+func reminderWindow(windows []time.Duration, until time.Duration) time.Duration {
+	for _, window := range windows {
+		if until <= window {
+			return window
+		}
+	}
+	return windows[len(windows)-1]
+}
+
+// getSentReminders は予約IDごとに作成済みのリマインドのタイミングを返します
+func (s *ReminderBatchService) getSentReminders(ctx context.Context, reservations []model.Reservation) (map[int64]map[time.Duration]bool, error) {
+	ids := make([]int64, len(reservations))
+	for i, reservation := range reservations {
+		ids[i] = reservation.ID
+	}
+
+	reminders, err := s.reminderRepo.GetByReservationIDs(ctx, ids)
+	if err != nil {
+		return nil, apperrors.FromDB("ReminderBatchService.getSentReminders", err)
+	}
+
+	sent := make(map[int64]map[time.Duration]bool)
+	for _, reminder := range reminders {
+		if sent[reminder.ReservationID] == nil {
+			sent[reminder.ReservationID] = make(map[time.Duration]bool)
+		}
+		sent[reminder.ReservationID][time.Duration(reminder.WindowMinutes)*time.Minute] = true
+	}
+	return sent, nil
+}
+
+// createReminder はリマインド通知とその記録を1トランザクションで作成します
+// 他の実行が先に同じリマインドを作成していた場合はロールバックしてfalseを返します
+func (s *ReminderBatchService) createReminder(ctx context.Context, reservation model.Reservation, window time.Duration, petNameMap map[string]string, displays *displayCache) (bool, error) {
+	petName, ok := petNameMap[reservation.PetID]
+	if !ok {
+		name, err := s.petRepo.GetNameByID(ctx, reservation.PetID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return false, apperrors.InvalidInput("ReminderBatchService.createReminder", err)
+			}
+			return false, apperrors.FromDB("ReminderBatchService.createReminder", err)
+		}
+		petNameMap[reservation.PetID] = name
+		petName = name
+	}
+
+	opts, err := displays.get(ctx, reservation.UserID)
+	if err != nil {
+		return false, err
+	}
+
+	now := s.now()
+	record := model.NewReminderNotificationRecord(model.ReservationEvent{
+		UserID:   reservation.UserID,
+		PetID:    reservation.PetID,
+		DateTime: reservation.ReservationDateTime,
+	}, petName, window, now, opts)
+
+	// トランザクション開始
+	tx, err := s.reservationRepo.BeginTx()
+	if err != nil {
+		return false, apperrors.FromDB("ReminderBatchService.createReminder", err)
+	}
+
+	rollback := func() {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("Failed to rollback transaction for reservation %d: %v",
+				reservation.ID, rollbackErr)
+		}
+	}
+
+	if err := s.notificationRepo.Create(ctx, tx, &record); err != nil {
+		rollback()
+		return false, apperrors.FromDB("ReminderBatchService.createReminder", err)
+	}
+
+	reminder := model.NewReservationReminder(reservation.ID, window, record.ID, now)
+	created, err := s.reminderRepo.Create(ctx, tx, &reminder)
+	if err != nil {
+		rollback()
+		return false, apperrors.FromDB("ReminderBatchService.createReminder", err)
+	}
+	if !created {
+		rollback()
+		return false, nil
+	}
+
+	// トランザクションをコミット
+	if err := tx.Commit(); err != nil {
+		return false, apperrors.FromDB("ReminderBatchService.createReminder", err)
+	}
+	return true, nil
+}
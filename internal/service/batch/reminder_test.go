@@ -0,0 +1,217 @@
+package batch
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-xray-sdk-go/xray"
+	"github.com/horsewin/echo-playground-batch-task/internal/common/apperrors"
+	"github.com/horsewin/echo-playground-batch-task/internal/common/config"
+	"github.com/horsewin/echo-playground-batch-task/internal/model"
+	"github.com/jmoiron/sqlx"
+)
+
+// MockReservationReminderRepository はテスト用のモックリポジトリです
+type MockReservationReminderRepository struct {
+	reminders []model.ReservationReminder
+	// conflicts は他の実行が先に記録したものとして扱う予約IDです
+	conflicts map[int64]bool
+}
+
+func (m *MockReservationReminderRepository) Create(ctx context.Context, tx *sqlx.Tx, reminder *model.ReservationReminder) (bool, error) {
+	if m.conflicts[reminder.ReservationID] {
+		return false, nil
+	}
+	for _, r := range m.reminders {
+		if r.ReservationID == reminder.ReservationID && r.WindowMinutes == reminder.WindowMinutes {
+			return false, nil
+		}
+	}
+	m.reminders = append(m.reminders, *reminder)
+	return true, nil
+}
+
+func (m *MockReservationReminderRepository) GetByReservationIDs(ctx context.Context, reservationIDs []int64) ([]model.ReservationReminder, error) {
+	var reminders []model.ReservationReminder
+	for _, r := range m.reminders {
+		if slices.Contains(reservationIDs, r.ReservationID) {
+			reminders = append(reminders, r)
+		}
+	}
+	return reminders, nil
+}
+
+// newTestReminderBatchService はテスト用のReminderBatchServiceを作成します
+func newTestReminderBatchService(reservationRepo *MockReservationRepository, petRepo *MockPetRepository,
+	notificationRepo *MockNotificationRepository, reminderRepo *MockReservationReminderRepository, now time.Time) *ReminderBatchService {
+	cfg := &config.Config{}
+	cfg.Reminder.Windows = []time.Duration{24 * time.Hour, time.Hour}
+	return &ReminderBatchService{
+		reservationRepo:  reservationRepo,
+		petRepo:          petRepo,
+		notificationRepo: notificationRepo,
+		reminderRepo:     reminderRepo,
+		preferenceRepo:   &MockNotificationPreferenceRepository{},
+		userRepo:         &MockUserRepository{},
+		now:              func() time.Time { return now },
+		cfg:              cfg,
+	}
+}
+
+func TestReminderBatchService_Run(t *testing.T) {
+	ctx, seg := xray.BeginSegment(context.Background(), "TestReminderBatchService_Run")
+	defer seg.Close(nil)
+
+	now := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	reservation := func(id int64, status model.ReservationStatus, until time.Duration) model.Reservation {
+		return model.Reservation{
+			ID:                  id,
+			UserID:              "user1",
+			PetID:               "pet1",
+			ReservationDateTime: now.Add(until),
+			Status:              status,
+		}
+	}
+	reservations := []model.Reservation{
+		reservation(1, "confirmed", 30*time.Minute),
+		reservation(2, "confirmed", 5*time.Hour),
+		reservation(3, "confirmed", 30*time.Hour),
+		reservation(4, "confirmed", 40*time.Minute),
+		reservation(5, "confirmed", 50*time.Minute),
+		reservation(6, "pending", 30*time.Minute),
+		reservation(7, "confirmed", -time.Hour),
+	}
+
+	t.Run("タイミングごとにリマインドを作成し、再実行しても重複しない", func(t *testing.T) {
+		reminderRepo := &MockReservationReminderRepository{
+			reminders: []model.ReservationReminder{
+				// 1時間前のリマインドは作成済み
+				{ReservationID: 4, WindowMinutes: 60},
+				// 24時間前のリマインドだけ作成済み
+				{ReservationID: 5, WindowMinutes: 24 * 60},
+			},
+		}
+		notificationRepo := &MockNotificationRepository{}
+		service := newTestReminderBatchService(&MockReservationRepository{pendingReservations: reservations},
+			&MockPetRepository{}, notificationRepo, reminderRepo, now)
+
+		if err := service.Run(ctx); err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+
+		if len(notificationRepo.created) != 3 {
+			t.Fatalf("created notifications = %d, want 3", len(notificationRepo.created))
+		}
+		want := []model.ReservationReminder{
+			{ReservationID: 4, WindowMinutes: 60},
+			{ReservationID: 5, WindowMinutes: 24 * 60},
+			{ReservationID: 1, WindowMinutes: 60},
+			{ReservationID: 2, WindowMinutes: 24 * 60},
+			{ReservationID: 5, WindowMinutes: 60},
+		}
+		if len(reminderRepo.reminders) != len(want) {
+			t.Fatalf("reminders = %+v, want %+v", reminderRepo.reminders, want)
+		}
+		for i, r := range reminderRepo.reminders {
+			if r.ReservationID != want[i].ReservationID || r.WindowMinutes != want[i].WindowMinutes {
+				t.Errorf("reminders[%d] = %+v, want %+v", i, r, want[i])
+			}
+		}
+		// 通知レコードとリマインドの記録が対応している
+		if got := reminderRepo.reminders[2].NotificationID; got != notificationRepo.created[0].ID {
+			t.Errorf("notification id = %d, want %d", got, notificationRepo.created[0].ID)
+		}
+		if notificationRepo.created[0].Title != "予約のリマインド" {
+			t.Errorf("title = %q", notificationRepo.created[0].Title)
+		}
+
+		// 再実行しても作成しない
+		if err := service.Run(ctx); err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+		if len(notificationRepo.created) != 3 {
+			t.Errorf("created notifications after rerun = %d, want 3", len(notificationRepo.created))
+		}
+	})
+
+	t.Run("他の実行が先に作成したリマインドは作成しない", func(t *testing.T) {
+		reminderRepo := &MockReservationReminderRepository{conflicts: map[int64]bool{1: true}}
+		service := newTestReminderBatchService(&MockReservationRepository{pendingReservations: reservations[:1]},
+			&MockPetRepository{}, &MockNotificationRepository{}, reminderRepo, now)
+
+		if err := service.Run(ctx); err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+		if len(reminderRepo.reminders) != 0 {
+			t.Errorf("reminders = %+v, want none", reminderRepo.reminders)
+		}
+	})
+
+	t.Run("予約の通知の受け取りを停止しているユーザーにはリマインドを作成しない", func(t *testing.T) {
+		other := reservation(8, "confirmed", 10*time.Minute)
+		other.UserID = "user2"
+		reminderRepo := &MockReservationReminderRepository{}
+		notificationRepo := &MockNotificationRepository{}
+		service := newTestReminderBatchService(
+			&MockReservationRepository{pendingReservations: []model.Reservation{reservations[0], other}},
+			&MockPetRepository{}, notificationRepo, reminderRepo, now)
+		service.preferenceRepo = &MockNotificationPreferenceRepository{
+			prefs: map[string]*model.NotificationPreferences{
+				"user1": {Preferences: []model.NotificationPreference{
+					{Type: model.NotificationTypeReservation, Channel: model.NotificationChannelInApp, Enabled: false},
+				}},
+			},
+		}
+
+		if err := service.Run(ctx); err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+		if len(notificationRepo.created) != 1 || notificationRepo.created[0].UserID != "user2" {
+			t.Errorf("created notifications = %+v, want user2 only", notificationRepo.created)
+		}
+		if len(reminderRepo.reminders) != 1 || reminderRepo.reminders[0].ReservationID != 8 {
+			t.Errorf("reminders = %+v, want reservation 8 only", reminderRepo.reminders)
+		}
+	})
+
+	t.Run("作成に失敗したリマインドがある場合はPartialFailure", func(t *testing.T) {
+		missing := reservation(8, "confirmed", 10*time.Minute)
+		missing.PetID = "missing"
+		reminderRepo := &MockReservationReminderRepository{}
+		service := newTestReminderBatchService(
+			&MockReservationRepository{pendingReservations: []model.Reservation{missing, reservations[0]}},
+			&MockPetRepository{missingPetIDs: map[string]bool{"missing": true}},
+			&MockNotificationRepository{}, reminderRepo, now)
+
+		err := service.Run(ctx)
+		if !apperrors.Is(err, apperrors.CodePartialFailure) {
+			t.Fatalf("Run() error = %v, want PartialFailure", err)
+		}
+		if len(reminderRepo.reminders) != 1 || reminderRepo.reminders[0].ReservationID != 1 {
+			t.Errorf("reminders = %+v, want reservation 1 only", reminderRepo.reminders)
+		}
+	})
+}
+
+func TestReminderWindow(t *testing.T) {
+	windows := []time.Duration{time.Hour, 24 * time.Hour}
+	tests := []struct {
+		name  string
+		until time.Duration
+		want  time.Duration
+	}{
+		{name: "1時間以内", until: 30 * time.Minute, want: time.Hour},
+		{name: "ちょうど1時間", until: time.Hour, want: time.Hour},
+		{name: "1時間より後", until: time.Hour + time.Minute, want: 24 * time.Hour},
+		{name: "24時間以内", until: 23 * time.Hour, want: 24 * time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := reminderWindow(windows, tt.until); got != tt.want {
+				t.Errorf("reminderWindow() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package batch
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -10,26 +11,42 @@ import (
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/sfn"
+	"github.com/horsewin/echo-playground-batch-task/internal/common/apperrors"
 	"github.com/horsewin/echo-playground-batch-task/internal/common/utils"
 
 	"github.com/aws/aws-xray-sdk-go/xray"
 	"github.com/horsewin/echo-playground-batch-task/internal/common/config"
 	"github.com/horsewin/echo-playground-batch-task/internal/common/database"
+	"github.com/horsewin/echo-playground-batch-task/internal/common/job"
+	"github.com/horsewin/echo-playground-batch-task/internal/common/redact"
 	"github.com/horsewin/echo-playground-batch-task/internal/model"
 	"github.com/horsewin/echo-playground-batch-task/internal/repository"
+	"github.com/jmoiron/sqlx"
 )
 
+// reservationJobName はチェックポイントに記録する予約バッチのジョブ名です
+const reservationJobName = "reservation"
+
 // ReservationBatchService は予約バッチ処理を担当します
 type ReservationBatchService struct {
-	args            []model.Reservation
+	// filter は処理対象の予約の条件です。ステータスは処理するステータスを1つだけ指定できます
+	filter          model.ReservationFilter
 	db              *database.DB
 	reservationRepo repository.ReservationRepository
-	sfnClient       *sfn.Client
-	cfg             *config.Config
+	// rules は予約の確定前に順に評価するルールです
+	rules *ruleChain
+	// newRuleRepository はルールが予約を処理するトランザクション内で読み取るためのリポジトリを作成します
+	newRuleRepository func(tx *sqlx.Tx) repository.ReservationRuleRepository
+	checkpointRepo    repository.CheckpointRepository
+	eventRepo         repository.ReservationEventRepository
+	// eventPublisher は予約イベントの配信先です。nilの場合はStep Functionsのタスク出力として配信します
+	eventPublisher EventPublisher
+	sfnClient      job.SFNClient
+	cfg            *config.Config
 }
 
 // NewReservationBatchService は新しいReservationBatchServiceを作成します
-func NewReservationBatchService(cfg *config.Config, sfnClient *sfn.Client) (*ReservationBatchService, error) {
+func NewReservationBatchService(cfg *config.Config, sfnClient job.SFNClient) (*ReservationBatchService, error) {
 	db, err := database.NewDB(cfg.DB)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create database connection: %w", err)
@@ -38,11 +55,30 @@ func NewReservationBatchService(cfg *config.Config, sfnClient *sfn.Client) (*Res
 	// database.DBをrepository.DBに変換
 	repoDb := &repository.DB{DB: db.DB}
 
+	eventPublisher, err := newEventPublisher(context.Background(), cfg)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create event publisher: %w", err)
+	}
+
+	rules, err := NewReservationRules(cfg)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create reservation rules: %w", err)
+	}
+
 	return &ReservationBatchService{
 		db:              db,
 		reservationRepo: repository.NewReservationRepository(repoDb),
-		sfnClient:       sfnClient,
-		cfg:             cfg,
+		rules:           newRuleChain(rules),
+		newRuleRepository: func(tx *sqlx.Tx) repository.ReservationRuleRepository {
+			return repository.NewReservationRuleRepository(tx)
+		},
+		checkpointRepo: newCheckpointRepository(cfg, repoDb),
+		eventRepo:      repository.NewReservationEventRepository(repoDb),
+		eventPublisher: eventPublisher,
+		sfnClient:      sfnClient,
+		cfg:            cfg,
 	}, nil
 }
 
@@ -54,28 +90,152 @@ func (s *ReservationBatchService) Close() error {
 	return nil
 }
 
-// SetArgs は予約バッチ処理の引数を設定します
-func (s *ReservationBatchService) SetArgs(args []model.Reservation) {
-	s.args = args
+// SetFilter は処理対象の予約を絞り込む条件を設定します
+// 特定の予約やペットの予約だけを処理し直す場合や、保留中以外のステータスの予約を処理する場合に利用します
+func (s *ReservationBatchService) SetFilter(filter model.ReservationFilter) {
+	s.filter = filter
+}
+
+// processableStatuses は予約バッチが処理できる予約のステータスです
+var processableStatuses = []model.ReservationStatus{
+	model.ReservationStatusPending,
+	model.ReservationStatusConfirmed,
+	model.ReservationStatusFailed,
+}
+
+// sourceStatus は処理する予約のステータスを返します。指定されていない場合は保留中の予約を処理します
+func (s *ReservationBatchService) sourceStatus() model.ReservationStatus {
+	if len(s.filter.Statuses) > 0 {
+		return s.filter.Statuses[0]
+	}
+	return model.ReservationStatusPending
+}
+
+// checkpointScope はチェックポイントに記録する処理対象の条件を返します
+// 最大件数は再開後に処理する件数に適用するため、条件に含めません
+func (s *ReservationBatchService) checkpointScope() string {
+	filter := s.filter
+	filter.Statuses = []model.ReservationStatus{s.sourceStatus()}
+	filter.Limit = 0
+	return filter.String()
 }
 
 // Run は予約バッチ処理を実行します
-func (s *ReservationBatchService) Run(ctx context.Context) error {
+// 再開する場合は、チェックポイントに記録された処理位置より後の予約から処理を続けます
+func (s *ReservationBatchService) Run(ctx context.Context) (runErr error) {
 	// X-Rayセグメントの作成
 	ctx, seg := xray.BeginSubsegment(ctx, "ReservationBatchService.Run")
 	defer seg.Close(nil)
 
 	startTime := time.Now()
 
+	// チェックポイントの記録を開始
+	cp, err := startCheckpoint(ctx, s.checkpointRepo, s.cfg, reservationJobName, s.checkpointScope())
+	if err != nil {
+		seg.Close(err)
+		return err
+	}
+	defer func() {
+		cp.finish(ctx, runErr)
+	}()
+
+	// 再開元の実行の処理位置を読み込む
+	cursor, err := model.ParseReservationCursor(cp.lastKey())
+	if err != nil {
+		seg.Close(err)
+		return apperrors.InvalidInput("ReservationBatchService.Run", err)
+	}
+
+	// 予約日時を過ぎた保留中の予約などを先に期限切れにする
+	// 処理対象を絞り込んだ実行や保留中以外の予約を処理する実行では、対象外の予約を変更しないよう期限切れにしない
+	status := s.sourceStatus()
+	expired := 0
+	switch {
+	case s.filter.Targeted():
+		log.Printf("Processing targeted reservations %s. Skipping expiry of stale reservations", s.filter)
+	case status != model.ReservationStatusPending:
+		log.Printf("Processing %s reservations. Skipping expiry of stale reservations", status)
+	default:
+		if expired, err = s.expireStaleReservations(ctx, startTime); err != nil {
+			seg.Close(err)
+			return err
+		}
+	}
+
+	// 確定済みの予約がなくなったペットのキャンセル待ちの予約を、保留中の予約より先に繰り上げる
+	// 確定済みの予約の再評価では、キャンセルによって空いたペットを処理後に繰り上げる
+	promoted := 0
+	if !s.filter.Targeted() && status != model.ReservationStatusConfirmed {
+		if promoted, err = s.promoteWaitlisted(ctx, startTime); err != nil {
+			seg.Close(err)
+			return err
+		}
+	}
+
 	// バッチ処理を実行
-	events, err := s.processReservationsByStatus(ctx, "pending")
+	result, err := s.processReservationsByStatus(ctx, status, startTime, cursor, cp, cp.processed())
+	if err != nil {
+		seg.Close(err)
+		return apperrors.FromDB("ReservationBatchService.Run",
+			utils.GetStackWithError(fmt.Errorf("failed to process %s reservations: %w", status, err)))
+	}
+
+	// ルールごとの評価の集計をログとトレースに記録
+	s.rules.logMetrics()
+	if err := seg.AddMetadata("rules", s.rules.metrics); err != nil {
+		log.Printf("Failed to add rules metadata: %v", err)
+	}
+
+	// 中断・一部失敗で終了する場合も、処理済みの予約のイベントを配信してから失敗を通知する
+	if result.interrupted || result.failed > 0 {
+		s.relayBeforeFailure(ctx)
+	}
+
+	// 停止要求により途中で処理を止めた場合は、進捗を付加情報として含めて中断を通知する
+	if result.interrupted {
+		err := apperrors.Interrupted("ReservationBatchService.Run",
+			fmt.Errorf("stopped after processing %d of %d reservations", result.processed(), result.total)).
+			WithDetails(result.details())
+		seg.Close(err)
+		return err
+	}
+
+	// 一部の予約の処理に失敗した場合は、進捗を付加情報として含めて失敗を通知する
+	// 処理済みの予約の通知はアウトボックスから配信するため、付加情報には含めない (含めると重複して通知されるため)
+	if result.failed > 0 {
+		err := apperrors.PartialFailure("ReservationBatchService.Run",
+			fmt.Errorf("failed to process %d of %d reservations", result.failed, result.total)).
+			WithDetails(result.details())
+		seg.Close(err)
+		return err
+	}
+
+	if !s.filter.Targeted() && status == model.ReservationStatusConfirmed {
+		if promoted, err = s.promoteWaitlisted(ctx, startTime); err != nil {
+			seg.Close(err)
+			return err
+		}
+	}
+
+	// アウトボックスに記録された未配信のイベントを配信
+	// 以前の実行で配信できなかったイベントもここで配信される
+	delivered, err := s.newOutboxRelay().relay(ctx)
 	if err != nil {
-		return utils.GetStackWithError(fmt.Errorf("failed to process pending reservations: %w", err))
+		seg.Close(err)
+		return utils.GetStackWithError(fmt.Errorf("failed to relay reservation events: %w", err))
 	}
 
-	// イベントを発行
-	if err := s.sendTaskSuccess(ctx, events); err != nil {
-		return utils.GetStackWithError(fmt.Errorf("failed to send task success: %w", err))
+	// キューやファイルに配信した場合は、配信件数をタスク出力としてタスクの成功を通知する
+	if s.eventPublisher != nil {
+		output := map[string]any{
+			"run_id":        s.cfg.Run.ID,
+			"delivered":     delivered,
+			"notifications": []model.Notification{},
+		}
+		if err := s.sendTaskSuccess(ctx, output); err != nil && !errors.Is(err, errNotPublished) {
+			seg.Close(err)
+			return utils.GetStackWithError(fmt.Errorf("failed to send task success: %w", err))
+		}
 	}
 
 	endTime := time.Now()
@@ -86,115 +246,335 @@ func (s *ReservationBatchService) Run(ctx context.Context) error {
 		log.Printf("Failed to add duration metadata: %v", err)
 	}
 
-	log.Printf("Reservation batch process completed successfully. Duration: %v", duration)
+	log.Printf("Reservation batch process completed successfully. Expired: %d, Promoted: %d, Duration: %v", expired, promoted, duration)
 	return nil
 }
 
-// processReservationsByStatus は、指定されたステータスの予約を処理します
-func (s *ReservationBatchService) processReservationsByStatus(ctx context.Context, status string) ([]model.ReservationEvent, error) {
-	// 指定されたステータスの予約を取得
-	reservations, err := s.reservationRepo.GetReservationsByStatus(ctx, status)
+// relayBeforeFailure は中断・一部失敗で終了する前に、アウトボックスの未配信のイベントをキューやファイルに配信します
+// Step Functionsのタスク出力はタスクの失敗と同時に通知できないため、次に成功した実行で配信するまで未配信のままにします
+// 配信できなかったイベントも未配信のまま次の実行で配信されるため、配信のエラーはログに出力するだけにします
+func (s *ReservationBatchService) relayBeforeFailure(ctx context.Context) {
+	if s.eventPublisher == nil {
+		log.Printf("Leaving reservation events undelivered until the next successful run")
+		return
+	}
+	delivered, err := s.newOutboxRelay().relay(ctx)
+	if err != nil {
+		log.Printf("Failed to relay reservation events before reporting failure: %v", err)
+		return
+	}
+	log.Printf("Relayed %d reservation events before reporting failure", delivered)
+}
+
+// newOutboxRelay は予約イベントの配信先に応じたリレーを作成します
+// Step Functionsのタスク出力はタスクごとに1回しか通知できないため、全件を1回で配信します
+func (s *ReservationBatchService) newOutboxRelay() *outboxRelay {
+	if s.eventPublisher == nil {
+		return &outboxRelay{
+			repo:      s.eventRepo,
+			publisher: EventPublisherFunc(s.publishToTaskOutput),
+			retry:     s.cfg.Retry,
+		}
+	}
+	return &outboxRelay{
+		repo:      s.eventRepo,
+		publisher: s.eventPublisher,
+		batchSize: s.cfg.Outbox.BatchSize,
+		retry:     s.cfg.Retry,
+	}
+}
+
+// publishToTaskOutput は予約イベントを通知形式に変換し、Step Functionsのタスク出力として配信します
+func (s *ReservationBatchService) publishToTaskOutput(ctx context.Context, events []model.OutboxEvent) error {
+	notifications := make([]model.Notification, len(events))
+	for i, e := range events {
+		event, err := e.ReservationEvent()
+		if err != nil {
+			return apperrors.Internal("ReservationBatchService.publishToTaskOutput", err)
+		}
+		notifications[i] = model.NewReservationNotification(event)
+	}
+
+	return s.sendTaskSuccess(ctx, map[string]any{
+		"run_id":        s.cfg.Run.ID,
+		"notifications": notifications,
+	})
+}
+
+// processResult は予約処理の結果を表します
+type processResult struct {
+	// resumed は再開元の実行でステータスを変更した予約の件数です
+	resumed int
+	events  []model.ReservationEvent
+	total   int
+	failed  int
+	// skipped は他の処理が先にステータスを変更したため処理しなかった予約の件数です
+	skipped int
+	// unchanged は再評価した結果、ステータスを変更しなかった予約の件数です
+	unchanged int
+	// interrupted は停止要求により未処理の予約を残して終了したことを表します
+	interrupted bool
+}
+
+// processed は処理を試みた予約の件数を返します
+func (r *processResult) processed() int {
+	return len(r.events) + r.failed + r.skipped + r.unchanged
+}
+
+// details はStep Functionsに通知する進捗を返します
+func (r *processResult) details() map[string]any {
+	return map[string]any{
+		"total":     r.total,
+		"processed": len(r.events),
+		"failed":    r.failed,
+		"skipped":   r.skipped,
+		"unchanged": r.unchanged,
+		"remaining": r.total - r.processed(),
+		"resumed":   r.resumed,
+	}
+}
+
+// processReservationsByStatus は、指定されたステータスの予約を処理位置より後から処理します
+// 1件処理するごとに処理位置とイベントをチェックポイントに記録します
+func (s *ReservationBatchService) processReservationsByStatus(ctx context.Context, status model.ReservationStatus, now time.Time, cursor *model.ReservationCursor, cp *checkpointer, resumed int) (*processResult, error) {
+	// 指定されたステータスの予約のうち、処理対象の条件に一致する予約を取得
+	filter := s.filter
+	filter.Statuses = []model.ReservationStatus{status}
+	// 確定済みの予約の再評価では予約日時を過ぎた予約を対象にしない
+	// 見学が終わった予約を後からキャンセルすると履歴が書き換わり、利用者に通知も届いてしまうため
+	if status == model.ReservationStatusConfirmed {
+		filter.SlotAfter = &now
+	}
+	reservations, err := s.reservationRepo.GetReservations(ctx, filter, cursor)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get reservations with status %s: %w", status, err)
 	}
 
 	log.Printf("Found %d reservations with status %s", len(reservations), status)
 
-	// 成功した予約のイベントを収集
-	var events []model.ReservationEvent
+	result := &processResult{resumed: resumed, total: len(reservations)}
 
 	for _, reservation := range reservations {
-		// トランザクション開始
-		tx, err := s.reservationRepo.BeginTx()
-		if err != nil {
-			log.Printf("Failed to begin transaction for reservation %d: %v",
-				reservation.ReservationID, err)
-			continue
+		// 停止要求を受けた場合は新しい予約の処理を始めない
+		// 実行中のトランザクションは完了させてから抜ける
+		if job.Stopping(ctx) {
+			log.Printf("Stop requested. Leaving %d reservations unprocessed", result.total-result.processed())
+			result.interrupted = true
+			break
 		}
 
-		// 既存の予約をチェック
-		exists, err := s.reservationRepo.CheckExistingReservation(ctx, reservation.PetID)
-		if err != nil {
-			if rollbackErr := tx.Rollback(); rollbackErr != nil {
-				log.Printf("Failed to rollback transaction for reservation %d: %v",
-					reservation.ReservationID, rollbackErr)
-			}
-			log.Printf("Failed to check existing reservation for pet %s: %v",
-				reservation.PetID, err)
-			continue
-		}
-
-		if exists {
-			// 既存の予約がある場合は、この予約をキャンセル
-			if err := s.reservationRepo.UpdateStatus(ctx, tx, reservation.ReservationID, "cancelled"); err != nil {
-				if rollbackErr := tx.Rollback(); rollbackErr != nil {
-					log.Printf("Failed to rollback transaction for reservation %d: %v",
-						reservation.ReservationID, rollbackErr)
-				}
-				log.Printf("Failed to update reservation status to cancelled: %v", err)
-				continue
-			}
-		} else {
-			// 既存の予約がない場合は、予約を確定
-			if err := s.reservationRepo.UpdateStatus(ctx, tx, reservation.ReservationID, "confirmed"); err != nil {
-				if rollbackErr := tx.Rollback(); rollbackErr != nil {
-					log.Printf("Failed to rollback transaction for reservation %d: %v",
-						reservation.ReservationID, rollbackErr)
-				}
-				log.Printf("Failed to update reservation status to confirmed: %v", err)
-				continue
-			}
+		// 成功した予約のイベントを収集
+		// コミット時の接続断やシリアライズ失敗などの一時的なエラーはトランザクションごとリトライする
+		var event *model.ReservationEvent
+		err := s.cfg.Retry.Do(ctx, "ReservationBatchService.processReservation", func(ctx context.Context) error {
+			var err error
+			event, err = s.processReservation(ctx, reservation, now)
+			return err
+		})
+		switch {
+		case errors.Is(err, model.ErrStatusConflict):
+			log.Printf("Skipping reservation %d: status was changed by another process", reservation.ID)
+			result.skipped++
+		case err != nil:
+			// リトライしてもコミットできなかった予約は、保留中のまま残さず失敗として原因を記録する
+			s.markFailed(ctx, reservation, err)
+			result.failed++
+		case event == nil:
+			result.unchanged++
+		default:
+			result.events = append(result.events, *event)
 		}
 
-		// トランザクションをコミット
-		if err := tx.Commit(); err != nil {
-			log.Printf("Failed to commit transaction for reservation %d: %v",
-				reservation.ReservationID, err)
-			continue
+		// 処理位置を記録
+		// 失敗した予約は--status failedを指定した実行で再度処理できます
+		cursor := model.ReservationCursor{DateTime: reservation.ReservationDateTime, ID: reservation.ID}
+		cp.advance(ctx, cursor.String(), result.resumed+len(result.events))
+	}
+
+	return result, nil
+}
+
+// processReservation は1件の予約をトランザクション内で確定・キャンセル・キャンセル待ちのいずれかにします
+// 確定済みの予約は、ペットを見学できなくなった場合や他の確定済みの予約と重なる場合のみキャンセルし、それ以外の場合はnilを返します
+// キャンセル待ちの予約は確定できる場合のみ確定し、それ以外の場合はnilを返します
+// 予約日時がnow以前の予約は確定せずに期限切れにします
+func (s *ReservationBatchService) processReservation(ctx context.Context, reservation model.Reservation, now time.Time) (*model.ReservationEvent, error) {
+	// トランザクション開始
+	tx, err := s.reservationRepo.BeginTx()
+	if err != nil {
+		log.Printf("Failed to begin transaction for reservation %d: %v",
+			reservation.ID, err)
+		return nil, apperrors.FromDB("ReservationBatchService.processReservation", err)
+	}
+
+	// ルールを同じトランザクション内で評価し、変更後のステータスを決める
+	status, reason, err := s.decideStatus(ctx, s.newRuleRepository(tx), reservation, now)
+	if err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("Failed to rollback transaction for reservation %d: %v",
+				reservation.ID, rollbackErr)
 		}
+		log.Printf("Failed to decide status of reservation %d: %v", reservation.ID, err)
+		return nil, apperrors.FromDB("ReservationBatchService.processReservation", err)
+	}
 
-		// 成功/キャンセルした予約のイベントを収集
-		events = append(events, model.ReservationEvent{
-			UserID:    reservation.UserID,
-			DateTime:  reservation.ReservationDateTime,
-			PetID:     reservation.PetID,
-			CreatedAt: reservation.CreatedAt,
-		})
+	// 確定済みの予約は見学できなくなったか、他の予約と重ならない限り、キャンセル待ちの予約は確定できるまでそのままにする
+	if status == "" {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("Failed to rollback transaction for reservation %d: %v",
+				reservation.ID, rollbackErr)
+		}
+		return nil, nil
+	}
+
+	change := model.NewReservationStatusChange(reservation.ID, reservation.Status,
+		status, reason, s.cfg.Run.ID, time.Now())
+
+	if err := s.reservationRepo.UpdateStatus(ctx, tx, change); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("Failed to rollback transaction for reservation %d: %v",
+				reservation.ID, rollbackErr)
+		}
+		log.Printf("Failed to update reservation status to %s: %v", change.ToStatus, err)
+		return nil, apperrors.FromDB("ReservationBatchService.processReservation", err)
+	}
+
+	event := &model.ReservationEvent{
+		UserID:    reservation.UserID,
+		DateTime:  reservation.ReservationDateTime,
+		PetID:     reservation.PetID,
+		CreatedAt: reservation.CreatedAt,
 	}
 
-	return events, nil
+	// ステータスの変更と同じトランザクションでイベントをアウトボックスに記録
+	// タスク成功の通知に失敗してもイベントが失われないようにするため
+	eventType := model.ReservationEventConfirmed
+	switch {
+	case change.ToStatus == model.ReservationStatusExpired:
+		eventType = model.ReservationEventExpired
+		event.Status = model.ReservationStatusExpired
+	case change.ToStatus == model.ReservationStatusCancelled:
+		eventType = model.ReservationEventCancelled
+		event.Status = model.ReservationStatusCancelled
+		event.Reason = change.Reason
+	case change.ToStatus == model.ReservationStatusWaitlisted:
+		eventType = model.ReservationEventWaitlisted
+		event.Status = model.ReservationStatusWaitlisted
+	case change.FromStatus == model.ReservationStatusWaitlisted:
+		eventType = model.ReservationEventPromoted
+		event.Promoted = true
+	}
+	outboxEvent, err := model.NewReservationOutboxEvent(reservation.ID, eventType, *event)
+	if err == nil {
+		err = s.eventRepo.Create(ctx, tx, outboxEvent)
+	}
+	if err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("Failed to rollback transaction for reservation %d: %v",
+				reservation.ID, rollbackErr)
+		}
+		log.Printf("Failed to record %s event for reservation %d: %v", eventType, reservation.ID, err)
+		return nil, apperrors.FromDB("ReservationBatchService.processReservation", err)
+	}
+
+	// トランザクションをコミット
+	if err := tx.Commit(); err != nil {
+		log.Printf("Failed to commit transaction for reservation %d: %v",
+			reservation.ID, err)
+		return nil, apperrors.FromDB("ReservationBatchService.processReservation", err)
+	}
+
+	// 成功/キャンセルした予約のイベントを返却
+	return event, nil
+}
+
+// decideStatus は予約の変更後のステータスと理由を返します。ステータスを変更しない場合は空を返します
+// ルールのいずれかが拒否した場合はキャンセル、キャンセル待ちとした場合はキャンセル待ちにし、それ以外の場合は確定します
+// 確定済みの予約は拒否されない限り、キャンセル待ちの予約は確定できるまでステータスを変更しません
+// 予約日時がnow以前の予約はルールを評価せずに期限切れにし、期限切れにできないステータスの場合は変更しません
+// 対象を絞り込んだ実行では期限切れの処理を行わないため、過去の日時の予約が確定されないようにここで確認します
+func (s *ReservationBatchService) decideStatus(ctx context.Context, repo repository.ReservationRuleRepository, reservation model.Reservation, now time.Time) (model.ReservationStatus, string, error) {
+	if !reservation.ReservationDateTime.After(now) {
+		if !reservation.Status.CanTransitionTo(model.ReservationStatusExpired) {
+			return "", "", nil
+		}
+		return model.ReservationStatusExpired, model.StatusReasonSlotPassed, nil
+	}
+
+	decision, err := s.rules.evaluate(ctx, repo, reservation)
+	switch {
+	case err != nil:
+		return "", "", err
+	case decision.Waitlisted && reservation.Status == model.ReservationStatusWaitlisted:
+		return "", "", nil
+	case decision.Waitlisted:
+		return model.ReservationStatusWaitlisted, decision.Reason, nil
+	case !decision.Accepted:
+		return model.ReservationStatusCancelled, decision.Reason, nil
+	case reservation.Status == model.ReservationStatusConfirmed:
+		return "", "", nil
+	case reservation.Status == model.ReservationStatusWaitlisted:
+		return model.ReservationStatusConfirmed, model.StatusReasonPromotedFromWaitlist, nil
+	default:
+		return model.ReservationStatusConfirmed, model.StatusReasonPetAvailable, nil
+	}
+}
+
+// markFailed は処理に失敗した予約のステータスを失敗に変更し、原因をlast_errorに記録します
+// 失敗に変更できないステータスの予約や、記録自体に失敗した場合は、ステータスを変更せずにログへ出力します
+func (s *ReservationBatchService) markFailed(ctx context.Context, reservation model.Reservation, cause error) {
+	if !reservation.Status.CanTransitionTo(model.ReservationStatusFailed) {
+		log.Printf("Failed to process reservation %d. Leaving it %s: %v", reservation.ID, reservation.Status, cause)
+		return
+	}
+
+	change := model.NewReservationStatusChange(reservation.ID, reservation.Status,
+		model.ReservationStatusFailed, model.StatusReasonProcessingFailed, s.cfg.Run.ID, time.Now())
+	change.Error = cause.Error()
+	err := s.cfg.Retry.Do(ctx, "ReservationBatchService.markFailed", func(ctx context.Context) error {
+		tx, err := s.reservationRepo.BeginTx()
+		if err != nil {
+			return err
+		}
+		if err := s.reservationRepo.UpdateStatus(ctx, tx, change); err != nil {
+			if rollbackErr := tx.Rollback(); rollbackErr != nil {
+				log.Printf("Failed to rollback transaction for reservation %d: %v",
+					reservation.ID, rollbackErr)
+			}
+			return err
+		}
+		return tx.Commit()
+	})
+	if err != nil {
+		log.Printf("Failed to mark reservation %d as failed. Leaving it %s: %v", reservation.ID, reservation.Status, err)
+		return
+	}
+	log.Printf("Marked reservation %d as failed: %v", reservation.ID, cause)
 }
 
-// sendTaskSuccess は、Step Functionsのタスク成功を通知し、イベントを返却します
-func (s *ReservationBatchService) sendTaskSuccess(ctx context.Context, events []model.ReservationEvent) error {
+// sendTaskSuccess は、Step Functionsのタスク成功を通知し、タスク出力を返却します
+// ローカル環境などで通知を省略した場合はerrNotPublishedを返します
+func (s *ReservationBatchService) sendTaskSuccess(ctx context.Context, taskOutput map[string]any) error {
 	// ローカルの場合はStep Functionsの処理をスキップ
 	if os.Getenv("ENV") == "LOCAL" || s.sfnClient == nil {
 		log.Printf("Local environment detected. Skipping Step Functions task success notification")
-		return nil
+		return errNotPublished
 	}
 
 	if s.sfnClient == nil {
-		return fmt.Errorf("sfnClient is not initialized")
-	}
-
-	// イベントを通知形式に変換
-	notifications := make([]model.Notification, len(events))
-	for i, event := range events {
-		notifications[i] = model.NewReservationNotification(event)
+		return apperrors.Internal("ReservationBatchService.sendTaskSuccess", fmt.Errorf("sfnClient is not initialized"))
 	}
 
-	// 通知をJSONに変換
-	output, err := json.Marshal(map[string]any{
-		"notifications": notifications,
-	})
+	// タスク出力をJSONに変換
+	output, err := json.Marshal(taskOutput)
 	if err != nil {
-		return fmt.Errorf("failed to marshal notifications: %w", err)
+		return apperrors.Internal("ReservationBatchService.sendTaskSuccess", fmt.Errorf("failed to marshal notifications: %w", err))
 	}
 
 	// タスクトークンを設定から取得
 	taskToken := s.cfg.SFN.TaskToken
 	if taskToken == "" && os.Getenv("ENV") != "LOCAL" {
-		return fmt.Errorf("SFN_TASK_TOKEN is not set in config")
+		return apperrors.InvalidInput("ReservationBatchService.sendTaskSuccess", fmt.Errorf("SFN_TASK_TOKEN is not set in config"))
 	}
 
 	// SendTaskSuccess APIを呼び出す
@@ -203,11 +583,17 @@ func (s *ReservationBatchService) sendTaskSuccess(ctx context.Context, events []
 		Output:    aws.String(string(output)),
 	}
 
-	_, err = s.sfnClient.SendTaskSuccess(ctx, input)
+	err = s.cfg.Retry.Do(ctx, "SendTaskSuccess", func(ctx context.Context) error {
+		_, err := s.sfnClient.SendTaskSuccess(ctx, input)
+		return err
+	})
 	if err != nil {
-		return fmt.Errorf("failed to send task success: %w", err)
+		return apperrors.ExternalService("ReservationBatchService.sendTaskSuccess", fmt.Errorf("failed to send task success: %w", err))
 	}
 
-	log.Printf("Successfully sent task success with notifications: %s", string(output))
+	// タスク出力はマスキングの例外で、後続のステートがuser_idなどの値をそのまま利用するためマスクせずに送信する
+	// 実行履歴に残るため、タスク出力には氏名やメールアドレスを含めない (model.ReservationEventを参照)
+	// ログに出力する内容だけ許可リスト外の個人情報をマスクする
+	log.Printf("Successfully sent task success with notifications: %s", string(redact.JSON(output)))
 	return nil
 }
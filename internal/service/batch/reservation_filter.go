@@ -0,0 +1,111 @@
+package batch
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/horsewin/echo-playground-batch-task/internal/common/apperrors"
+	"github.com/horsewin/echo-playground-batch-task/internal/model"
+)
+
+// ReservationFilterFlags は処理対象の予約を絞り込むコマンドライン引数をfsに登録します
+// 返却された関数は、引数のパース後に指定された条件を返します
+// Step Functionsからは、個別の引数または-filterにJSON形式の条件 (例: {"ids":[1,2]}) を指定します
+func ReservationFilterFlags(fs *flag.FlagSet) func() (model.ReservationFilter, error) {
+	status := fs.String("status", "", "処理する予約のステータス (pending, confirmed, failed。省略時はpending)")
+	ids := fs.String("id", "", "処理する予約のID (カンマ区切り)")
+	pets := fs.String("pet", "", "処理する予約のペットID (カンマ区切り)")
+	users := fs.String("user", "", "処理する予約のユーザーID (カンマ区切り)")
+	from := fs.String("from", "", "処理する予約の予約日時の下限 (この日時を含む。2006-01-02 またはRFC3339)")
+	to := fs.String("to", "", "処理する予約の予約日時の上限 (この日時を含まない。2006-01-02 またはRFC3339)")
+	limit := fs.Int("limit", 0, "処理する予約の最大件数 (0の場合は制限しない)")
+	input := fs.String("filter", "", "処理する予約の条件 (JSON形式。個別の引数とは同時に指定できない)")
+
+	return func() (model.ReservationFilter, error) {
+		filter, err := parseReservationFilter(*input, *status, *ids, *pets, *users, *from, *to, *limit)
+		if err != nil {
+			return model.ReservationFilter{}, apperrors.InvalidInput("ReservationFilterFlags", err)
+		}
+		return filter, nil
+	}
+}
+
+// parseReservationFilter はコマンドライン引数の値から処理対象の予約の条件を作成します
+func parseReservationFilter(input, status, ids, pets, users, from, to string, limit int) (model.ReservationFilter, error) {
+	var filter model.ReservationFilter
+	if input != "" {
+		if status != "" || ids != "" || pets != "" || users != "" || from != "" || to != "" || limit != 0 {
+			return filter, errors.New("-filter cannot be used with other filter flags")
+		}
+		dec := json.NewDecoder(strings.NewReader(input))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&filter); err != nil {
+			return filter, fmt.Errorf("invalid filter: %w", err)
+		}
+	} else {
+		if status != "" {
+			filter.Statuses = []model.ReservationStatus{model.ReservationStatus(status)}
+		}
+		for _, v := range splitValues(ids) {
+			id, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || id <= 0 {
+				return filter, fmt.Errorf("invalid reservation id: %q", v)
+			}
+			filter.IDs = append(filter.IDs, id)
+		}
+		filter.PetIDs = splitValues(pets)
+		filter.UserIDs = splitValues(users)
+		var err error
+		if filter.From, err = parseFilterTime(from); err != nil {
+			return filter, err
+		}
+		if filter.To, err = parseFilterTime(to); err != nil {
+			return filter, err
+		}
+		filter.Limit = limit
+	}
+
+	// 1回の実行では1つのステータスの予約のみを処理する
+	if len(filter.Statuses) > 1 {
+		return filter, errors.New("only one status can be specified in filter")
+	}
+	for _, s := range filter.Statuses {
+		if !slices.Contains(processableStatuses, s) {
+			return filter, fmt.Errorf("reservations with status %q cannot be processed", s)
+		}
+	}
+	if err := filter.Validate(); err != nil {
+		return filter, err
+	}
+	return filter, nil
+}
+
+// splitValues はカンマ区切りの値を分割します。空の値は無視します
+func splitValues(s string) []string {
+	var values []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// parseFilterTime は日付 (2006-01-02、UTCの0時) またはRFC3339形式の日時をパースします。空の場合はnilを返します
+func parseFilterTime(s string) (*time.Time, error) {
+	if s == "" {
+		return nil, nil
+	}
+	for _, layout := range []string{time.DateOnly, time.RFC3339} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return &t, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid date: %q (expected 2006-01-02 or RFC3339)", s)
+}
@@ -0,0 +1,101 @@
+package batch
+
+import (
+	"flag"
+	"io"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/horsewin/echo-playground-batch-task/internal/common/apperrors"
+	"github.com/horsewin/echo-playground-batch-task/internal/model"
+)
+
+func TestReservationFilterFlags(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr bool
+		check   func(t *testing.T, filter model.ReservationFilter)
+	}{
+		{
+			name: "条件を指定しない",
+			check: func(t *testing.T, filter model.ReservationFilter) {
+				if filter.Statuses != nil || filter.IDs != nil || filter.PetIDs != nil || filter.UserIDs != nil || filter.From != nil || filter.Limit != 0 {
+					t.Errorf("filter has conditions")
+				}
+			},
+		},
+		{
+			name: "個別の引数で指定する",
+			args: []string{"-id", "1, 2", "-pet", "pet1", "-user", "user1,user2", "-from", "2024-06-01", "-limit", "10"},
+			check: func(t *testing.T, filter model.ReservationFilter) {
+				if !slices.Equal(filter.IDs, []int64{1, 2}) || !slices.Equal(filter.PetIDs, []string{"pet1"}) || len(filter.UserIDs) != 2 || filter.Limit != 10 {
+					t.Errorf("filter = %s", filter)
+				}
+				if filter.From == nil || !filter.From.Equal(time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)) {
+					t.Errorf("from = %v", filter.From)
+				}
+			},
+		},
+		{
+			name: "JSONで指定する",
+			args: []string{"-filter", `{"ids":[3],"from":"2024-06-01T09:00:00+09:00"}`},
+			check: func(t *testing.T, filter model.ReservationFilter) {
+				if !slices.Equal(filter.IDs, []int64{3}) || filter.From == nil || filter.From.UTC().Hour() != 0 {
+					t.Errorf("filter = %s", filter)
+				}
+			},
+		},
+		{name: "不正なID", args: []string{"-id", "abc"}, wantErr: true},
+		{name: "不正な日付", args: []string{"-to", "2024/06/01"}, wantErr: true},
+		{name: "期間の上限が下限以前", args: []string{"-from", "2024-06-02", "-to", "2024-06-01"}, wantErr: true},
+		{name: "JSONと個別の引数を同時に指定", args: []string{"-filter", `{"ids":[3]}`, "-pet", "pet1"}, wantErr: true},
+		{name: "JSONとステータスを同時に指定", args: []string{"-filter", `{"ids":[3]}`, "-status", "failed"}, wantErr: true},
+		{name: "JSONに不明なキー", args: []string{"-filter", `{"pet":"pet1"}`}, wantErr: true},
+		{
+			name: "ステータスを指定する",
+			args: []string{"-status", "failed", "-pet", "pet1"},
+			check: func(t *testing.T, filter model.ReservationFilter) {
+				if !slices.Equal(filter.Statuses, []model.ReservationStatus{model.ReservationStatusFailed}) {
+					t.Errorf("statuses = %v, want [failed]", filter.Statuses)
+				}
+			},
+		},
+		{
+			name: "JSONでステータスを指定する",
+			args: []string{"-filter", `{"statuses":["confirmed"]}`},
+			check: func(t *testing.T, filter model.ReservationFilter) {
+				if !slices.Equal(filter.Statuses, []model.ReservationStatus{model.ReservationStatusConfirmed}) {
+					t.Errorf("statuses = %v, want [confirmed]", filter.Statuses)
+				}
+			},
+		},
+		{name: "処理できないステータス", args: []string{"-status", "cancelled"}, wantErr: true},
+		{name: "未定義のステータス", args: []string{"-status", "unknown"}, wantErr: true},
+		{name: "JSONに複数のステータス", args: []string{"-filter", `{"statuses":["pending","failed"]}`}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			fs.SetOutput(io.Discard)
+			parse := ReservationFilterFlags(fs)
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+
+			filter, err := parse()
+			if tt.wantErr {
+				if apperrors.CodeOf(err) != apperrors.CodeInvalidInput {
+					t.Errorf("error = %v, want invalid input", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error = %v", err)
+			}
+			tt.check(t, filter)
+		})
+	}
+}
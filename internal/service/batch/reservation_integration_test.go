@@ -121,11 +121,11 @@ func TestReservationBatchService_StatusTransition(t *testing.T) {
 			description:         "既存予約がない場合、pendingからconfirmedに遷移",
 		},
 		{
-			name:                "重複予約をキャンセル",
+			name:                "重複予約をキャンセル待ちにする",
 			initialStatus:       "pending",
 			existingReservation: true,
-			expectedStatus:      "cancelled",
-			description:         "既存予約がある場合、pendingからcancelledに遷移",
+			expectedStatus:      "waitlisted",
+			description:         "既存予約がある場合、pendingからwaitlistedに遷移",
 		},
 	}
 
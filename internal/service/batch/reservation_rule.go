@@ -0,0 +1,271 @@
+package batch
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/aws/aws-xray-sdk-go/xray"
+	"github.com/horsewin/echo-playground-batch-task/internal/common/config"
+	"github.com/horsewin/echo-playground-batch-task/internal/model"
+	"github.com/horsewin/echo-playground-batch-task/internal/repository"
+)
+
+// Decision はルールによる予約の評価の結果です
+type Decision struct {
+	// Accepted は予約を確定できることを表します
+	Accepted bool
+	// Waitlisted は今は確定できないが、キャンセル待ちとして確定を待てることを表します
+	Waitlisted bool
+	// Reason は確定できない場合のキャンセルまたはキャンセル待ちの理由です
+	Reason string
+}
+
+// Accept は予約を確定できることを表す評価の結果を返します
+func Accept() Decision {
+	return Decision{Accepted: true}
+}
+
+// Reject は予約を確定できないことを表す評価の結果を返します
+func Reject(reason string) Decision {
+	return Decision{Reason: reason}
+}
+
+// Waitlist は予約をキャンセル待ちにすることを表す評価の結果を返します
+func Waitlist(reason string) Decision {
+	return Decision{Waitlisted: true, Reason: reason}
+}
+
+// ReservationRule は予約を確定できるかを判定するルールです
+// 新しいルールを追加する場合は、ルールを実装してreservationRuleFactoriesに登録します
+type ReservationRule interface {
+	// Name はルールの名前です。設定・メトリクス・トレースで利用します
+	Name() string
+	// Evaluate は予約を評価します。repoは予約を処理するトランザクション内で読み取ります
+	Evaluate(ctx context.Context, repo repository.ReservationRuleRepository, reservation model.Reservation) (Decision, error)
+}
+
+// ルールの名前
+const (
+	RulePetAvailability = "pet_availability"
+	RuleUserPet         = "user_pet"
+	RuleUserLimit       = "user_limit"
+	RulePetConflict     = "pet_conflict"
+)
+
+// reservationRuleFactories はルールの名前ごとに、設定からルールを作成する関数です
+var reservationRuleFactories = map[string]func(cfg *config.Config) ReservationRule{
+	RulePetAvailability: func(*config.Config) ReservationRule { return petAvailabilityRule{} },
+	RuleUserPet:         func(*config.Config) ReservationRule { return userPetRule{} },
+	RuleUserLimit:       func(cfg *config.Config) ReservationRule { return userLimitRule{maxActive: cfg.Limits.MaxActivePerUser} },
+	RulePetConflict:     func(*config.Config) ReservationRule { return petConflictRule{} },
+}
+
+// NewReservationRules は設定 (RESERVATION_RULES) の順にルールを作成します
+// 未定義または重複したルールの名前が指定された場合はエラーを返します
+func NewReservationRules(cfg *config.Config) ([]ReservationRule, error) {
+	rules := make([]ReservationRule, 0, len(cfg.Rules.Order))
+	for i, name := range cfg.Rules.Order {
+		factory, ok := reservationRuleFactories[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown reservation rule: %q", name)
+		}
+		if slices.Contains(cfg.Rules.Order[:i], name) {
+			return nil, fmt.Errorf("duplicate reservation rule: %q", name)
+		}
+		rules = append(rules, factory(cfg))
+	}
+	return rules, nil
+}
+
+// petAvailabilityRule は譲渡済み・掲載の取り下げ・営業時間外などでペットを見学できない予約を拒否します
+type petAvailabilityRule struct{}
+
+func (petAvailabilityRule) Name() string { return RulePetAvailability }
+
+func (petAvailabilityRule) Evaluate(ctx context.Context, repo repository.ReservationRuleRepository, reservation model.Reservation) (Decision, error) {
+	availability, err := repo.GetPetAvailability(ctx, reservation.PetID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Reject(model.StatusReasonPetNotFound), nil
+	}
+	if err != nil {
+		return Decision{}, err
+	}
+	reason, err := availability.UnavailableReason(reservation.ReservationDateTime)
+	if err != nil || reason == "" {
+		return Accept(), err
+	}
+	return Reject(reason), nil
+}
+
+// userPetRule は同じユーザーが同じペットに確定済みの予約を持っている予約を拒否します
+// 確定済みの予約の再評価では、重複はペットの予約の重なりとして判定します
+type userPetRule struct{}
+
+func (userPetRule) Name() string { return RuleUserPet }
+
+func (userPetRule) Evaluate(ctx context.Context, repo repository.ReservationRuleRepository, reservation model.Reservation) (Decision, error) {
+	if reservation.Status == model.ReservationStatusConfirmed {
+		return Accept(), nil
+	}
+	exists, err := repo.CheckUserReservationForPet(ctx, reservation.UserID, reservation.PetID, reservation.ID)
+	if err != nil {
+		return Decision{}, err
+	}
+	if exists {
+		return Reject(model.StatusReasonUserAlreadyReservedPet), nil
+	}
+	return Accept(), nil
+}
+
+// userLimitRule はユーザーの確定済みの予約がmaxActive件に達している予約を拒否します
+// maxActiveが0以下の場合は制限しません。確定済みの予約は上限の判定に含まれるため再評価しません
+type userLimitRule struct {
+	maxActive int
+}
+
+func (userLimitRule) Name() string { return RuleUserLimit }
+
+func (r userLimitRule) Evaluate(ctx context.Context, repo repository.ReservationRuleRepository, reservation model.Reservation) (Decision, error) {
+	if r.maxActive <= 0 || reservation.Status == model.ReservationStatusConfirmed {
+		return Accept(), nil
+	}
+	count, err := repo.CountActiveReservationsByUser(ctx, reservation.UserID, reservation.ID)
+	if err != nil {
+		return Decision{}, err
+	}
+	if count >= r.maxActive {
+		return Reject(model.StatusReasonUserLimitExceeded), nil
+	}
+	return Accept(), nil
+}
+
+// petConflictRule はペットに他の確定済みの予約がある予約をキャンセル待ちにします
+// 確定済みの予約の再評価で他の確定済みの予約と重なった場合は拒否します
+type petConflictRule struct{}
+
+func (petConflictRule) Name() string { return RulePetConflict }
+
+func (petConflictRule) Evaluate(ctx context.Context, repo repository.ReservationRuleRepository, reservation model.Reservation) (Decision, error) {
+	exists, err := repo.CheckExistingReservation(ctx, reservation.PetID, reservation.ID)
+	if err != nil {
+		return Decision{}, err
+	}
+	switch {
+	case !exists:
+		return Accept(), nil
+	case reservation.Status == model.ReservationStatusConfirmed:
+		return Reject(model.StatusReasonPetAlreadyReserved), nil
+	default:
+		return Waitlist(model.StatusReasonPetAlreadyReserved), nil
+	}
+}
+
+// ruleMetrics はルールごとの評価の集計です
+type ruleMetrics struct {
+	Evaluated  int     `json:"evaluated"`
+	Rejected   int     `json:"rejected"`
+	Waitlisted int     `json:"waitlisted"`
+	Errors     int     `json:"errors"`
+	DurationMs float64 `json:"duration_ms"`
+}
+
+// ruleChain はルールを順に評価し、ルールごとの評価を集計します
+type ruleChain struct {
+	rules   []ReservationRule
+	metrics map[string]*ruleMetrics
+}
+
+// newRuleChain は指定された順にルールを評価するruleChainを作成します
+func newRuleChain(rules []ReservationRule) *ruleChain {
+	metrics := make(map[string]*ruleMetrics, len(rules))
+	for _, rule := range rules {
+		metrics[rule.Name()] = &ruleMetrics{}
+	}
+	return &ruleChain{rules: rules, metrics: metrics}
+}
+
+// evaluate はルールを順に評価し、最初に拒否したルールの結果を返します
+// キャンセル待ちとしたルールがある場合も後のルールを評価し、拒否されなければ最初のキャンセル待ちの結果を返します
+// すべてのルールが許可した場合は確定を返します
+func (c *ruleChain) evaluate(ctx context.Context, repo repository.ReservationRuleRepository, reservation model.Reservation) (Decision, error) {
+	var waitlist *Decision
+	for _, rule := range c.rules {
+		decision, err := c.evaluateRule(ctx, rule, repo, reservation)
+		if err != nil {
+			return Decision{}, fmt.Errorf("failed to evaluate rule %s for reservation %d: %w", rule.Name(), reservation.ID, err)
+		}
+		switch {
+		case decision.Accepted:
+		case decision.Waitlisted:
+			if waitlist == nil {
+				waitlist = &decision
+			}
+		default:
+			return decision, nil
+		}
+	}
+	if waitlist != nil {
+		return *waitlist, nil
+	}
+	return Accept(), nil
+}
+
+// evaluateRule は1つのルールをサブセグメント内で評価し、結果を集計します
+func (c *ruleChain) evaluateRule(ctx context.Context, rule ReservationRule, repo repository.ReservationRuleRepository, reservation model.Reservation) (Decision, error) {
+	ctx, seg := xray.BeginSubsegment(ctx, "ReservationRule."+rule.Name())
+
+	start := time.Now()
+	decision, err := rule.Evaluate(ctx, repo, reservation)
+	c.record(rule.Name(), decision, err, time.Since(start))
+
+	if seg == nil {
+		return decision, err
+	}
+	if err != nil {
+		seg.Close(err)
+		return decision, err
+	}
+	if err := seg.AddAnnotation("accepted", decision.Accepted); err != nil {
+		log.Printf("Failed to add accepted annotation: %v", err)
+	}
+	if decision.Reason != "" {
+		if err := seg.AddAnnotation("reason", decision.Reason); err != nil {
+			log.Printf("Failed to add reason annotation: %v", err)
+		}
+	}
+	seg.Close(nil)
+	return decision, nil
+}
+
+// record はルールの評価の結果を集計します
+func (c *ruleChain) record(name string, decision Decision, err error, elapsed time.Duration) {
+	m, ok := c.metrics[name]
+	if !ok {
+		m = &ruleMetrics{}
+		c.metrics[name] = m
+	}
+	m.Evaluated++
+	m.DurationMs += float64(elapsed) / float64(time.Millisecond)
+	switch {
+	case err != nil:
+		m.Errors++
+	case decision.Waitlisted:
+		m.Waitlisted++
+	case !decision.Accepted:
+		m.Rejected++
+	}
+}
+
+// logMetrics はルールごとの評価の集計をログに出力します
+func (c *ruleChain) logMetrics() {
+	for _, rule := range c.rules {
+		m := c.metrics[rule.Name()]
+		log.Printf("Reservation rule %s: evaluated=%d rejected=%d waitlisted=%d errors=%d duration=%.1fms",
+			rule.Name(), m.Evaluated, m.Rejected, m.Waitlisted, m.Errors, m.DurationMs)
+	}
+}
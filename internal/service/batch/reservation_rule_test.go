@@ -0,0 +1,315 @@
+package batch
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-xray-sdk-go/xray"
+	"github.com/horsewin/echo-playground-batch-task/internal/common/config"
+	"github.com/horsewin/echo-playground-batch-task/internal/model"
+	"github.com/horsewin/echo-playground-batch-task/internal/repository"
+)
+
+// testRule は決まった結果を返すテスト用のルールです
+type testRule struct {
+	name     string
+	decision Decision
+	err      error
+	calls    int
+}
+
+func (r *testRule) Name() string { return r.name }
+
+func (r *testRule) Evaluate(ctx context.Context, repo repository.ReservationRuleRepository, reservation model.Reservation) (Decision, error) {
+	r.calls++
+	return r.decision, r.err
+}
+
+func TestReservationBatchService_decideStatus(t *testing.T) {
+	ctx, seg := xray.BeginSegment(context.Background(), "TestReservationBatchService_decideStatus")
+	defer seg.Close(nil)
+
+	slot := time.Now().Add(24 * time.Hour)
+	pending := model.Reservation{ID: 1, UserID: "user1", PetID: "pet1", ReservationDateTime: slot, Status: model.ReservationStatusPending}
+	confirmed := pending
+	confirmed.Status = model.ReservationStatusConfirmed
+	waitlisted := pending
+	waitlisted.Status = model.ReservationStatusWaitlisted
+	passed := pending
+	passed.ReservationDateTime = time.Now().Add(-time.Hour)
+	passedConfirmed := passed
+	passedConfirmed.Status = model.ReservationStatusConfirmed
+
+	tests := []struct {
+		name        string
+		reservation model.Reservation
+		repo        *MockReservationRepository
+		petRepo     *MockPetRepository
+		maxActive   int
+		// extra は既定のルールの後に追加するルールです
+		extra      ReservationRule
+		wantStatus model.ReservationStatus
+		wantReason string
+	}{
+		{
+			name:        "すべての検証を通過",
+			reservation: pending,
+			repo:        &MockReservationRepository{},
+			maxActive:   3,
+			wantStatus:  model.ReservationStatusConfirmed,
+			wantReason:  model.StatusReasonPetAvailable,
+		},
+		{
+			name:        "同じペットに同じユーザーの確定済みの予約",
+			reservation: pending,
+			repo: &MockReservationRepository{
+				userPetReservations: map[string]bool{"user1/pet1": true},
+				existingPetIDs:      map[string]bool{"pet1": true},
+			},
+			wantStatus: model.ReservationStatusCancelled,
+			wantReason: model.StatusReasonUserAlreadyReservedPet,
+		},
+		{
+			name:        "ユーザーの確定済みの予約が上限",
+			reservation: pending,
+			repo:        &MockReservationRepository{activeCounts: map[string]int{"user1": 3}},
+			maxActive:   3,
+			wantStatus:  model.ReservationStatusCancelled,
+			wantReason:  model.StatusReasonUserLimitExceeded,
+		},
+		{
+			name:        "上限が0の場合は制限しない",
+			reservation: pending,
+			repo:        &MockReservationRepository{activeCounts: map[string]int{"user1": 10}},
+			wantStatus:  model.ReservationStatusConfirmed,
+			wantReason:  model.StatusReasonPetAvailable,
+		},
+		{
+			name:        "見学できないペットの判定を優先",
+			reservation: pending,
+			repo:        &MockReservationRepository{activeCounts: map[string]int{"user1": 3}},
+			petRepo:     &MockPetRepository{missingPetIDs: map[string]bool{"pet1": true}},
+			maxActive:   3,
+			wantStatus:  model.ReservationStatusCancelled,
+			wantReason:  model.StatusReasonPetNotFound,
+		},
+		{
+			name:        "確定済みの予約はユーザーの上限で再評価しない",
+			reservation: confirmed,
+			repo: &MockReservationRepository{
+				userPetReservations: map[string]bool{"user1/pet1": true},
+				activeCounts:        map[string]int{"user1": 5},
+			},
+			maxActive: 3,
+		},
+		{
+			name:        "確定済みの予約があるペットはキャンセル待ち",
+			reservation: pending,
+			repo:        &MockReservationRepository{existingPetIDs: map[string]bool{"pet1": true}},
+			wantStatus:  model.ReservationStatusWaitlisted,
+			wantReason:  model.StatusReasonPetAlreadyReserved,
+		},
+		{
+			name:        "キャンセル待ちより後のルールの拒否を優先",
+			reservation: pending,
+			repo:        &MockReservationRepository{existingPetIDs: map[string]bool{"pet1": true}},
+			extra:       &testRule{name: "custom", decision: Reject("custom_rule")},
+			wantStatus:  model.ReservationStatusCancelled,
+			wantReason:  "custom_rule",
+		},
+		{
+			name:        "キャンセル待ちの予約は確定できるまで変更しない",
+			reservation: waitlisted,
+			repo:        &MockReservationRepository{existingPetIDs: map[string]bool{"pet1": true}},
+		},
+		{
+			name:        "キャンセル待ちの予約を繰り上げて確定",
+			reservation: waitlisted,
+			repo:        &MockReservationRepository{},
+			wantStatus:  model.ReservationStatusConfirmed,
+			wantReason:  model.StatusReasonPromotedFromWaitlist,
+		},
+		{
+			name:        "他の確定済みの予約と重なる確定済みの予約はキャンセル",
+			reservation: confirmed,
+			repo:        &MockReservationRepository{existingPetIDs: map[string]bool{"pet1": true}},
+			wantStatus:  model.ReservationStatusCancelled,
+			wantReason:  model.StatusReasonPetAlreadyReserved,
+		},
+		{
+			name:        "予約日時を過ぎた予約は期限切れ",
+			reservation: passed,
+			repo:        &MockReservationRepository{},
+			wantStatus:  model.ReservationStatusExpired,
+			wantReason:  model.StatusReasonSlotPassed,
+		},
+		{
+			name:        "予約日時を過ぎた確定済みの予約は変更しない",
+			reservation: passedConfirmed,
+			repo:        &MockReservationRepository{existingPetIDs: map[string]bool{"pet1": true}},
+		},
+		{
+			name:        "追加したルールでキャンセル",
+			reservation: pending,
+			repo:        &MockReservationRepository{},
+			extra:       &testRule{name: "custom", decision: Reject("custom_rule")},
+			wantStatus:  model.ReservationStatusCancelled,
+			wantReason:  "custom_rule",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{}
+			cfg.Rules.Order = testRuleOrder
+			cfg.Limits.MaxActivePerUser = tt.maxActive
+			petRepo := tt.petRepo
+			if petRepo == nil {
+				petRepo = &MockPetRepository{}
+			}
+			rules, err := NewReservationRules(cfg)
+			if err != nil {
+				t.Fatalf("NewReservationRules() error = %v", err)
+			}
+			if tt.extra != nil {
+				rules = append(rules, tt.extra)
+			}
+			service := &ReservationBatchService{rules: newRuleChain(rules), cfg: cfg}
+
+			// ルールの評価はトランザクションなしでリポジトリのモックだけで検証できる
+			repo := &mockRuleRepository{MockReservationRepository: tt.repo, pets: petRepo}
+			status, reason, err := service.decideStatus(ctx, repo, tt.reservation, time.Now())
+			if err != nil {
+				t.Fatalf("decideStatus() error = %v", err)
+			}
+			if status != tt.wantStatus || reason != tt.wantReason {
+				t.Errorf("decideStatus() = %q, %q, want %q, %q", status, reason, tt.wantStatus, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestNewReservationRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		order   []string
+		want    []string
+		wantErr bool
+	}{
+		{name: "指定された順に作成", order: []string{RulePetConflict, RulePetAvailability}, want: []string{RulePetConflict, RulePetAvailability}},
+		{name: "ルールを指定しない", order: nil, want: []string{}},
+		{name: "未定義のルール", order: []string{RulePetAvailability, "unknown"}, wantErr: true},
+		{name: "重複したルール", order: []string{RulePetConflict, RulePetConflict}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{}
+			cfg.Rules.Order = tt.order
+			rules, err := NewReservationRules(cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewReservationRules() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			names := make([]string, len(rules))
+			for i, rule := range rules {
+				names[i] = rule.Name()
+			}
+			if !slices.Equal(names, tt.want) {
+				t.Errorf("rules = %v, want %v", names, tt.want)
+			}
+		})
+	}
+}
+
+func TestRuleChain_evaluate(t *testing.T) {
+	ctx, seg := xray.BeginSegment(context.Background(), "TestRuleChain_evaluate")
+	defer seg.Close(nil)
+
+	first := &testRule{name: "first", decision: Accept()}
+	second := &testRule{name: "second", decision: Reject("second_rejected")}
+	third := &testRule{name: "third", decision: Accept()}
+	chain := newRuleChain([]ReservationRule{first, second, third})
+
+	for i := 0; i < 2; i++ {
+		decision, err := chain.evaluate(ctx, nil, model.Reservation{ID: int64(i + 1)})
+		if err != nil || decision != Reject("second_rejected") {
+			t.Fatalf("evaluate() = %+v, %v, want rejected by second", decision, err)
+		}
+	}
+	// 拒否したルールより後のルールは評価しない
+	if third.calls != 0 {
+		t.Errorf("third rule was evaluated %d times, want 0", third.calls)
+	}
+
+	second.err = errors.New("connection reset")
+	if _, err := chain.evaluate(ctx, nil, model.Reservation{ID: 3}); err == nil || !strings.Contains(err.Error(), "second") {
+		t.Errorf("evaluate() error = %v, want error of rule second", err)
+	}
+
+	want := map[string]ruleMetrics{
+		"first":  {Evaluated: 3},
+		"second": {Evaluated: 3, Rejected: 2, Errors: 1},
+		"third":  {},
+	}
+	for name, w := range want {
+		got := *chain.metrics[name]
+		got.DurationMs = 0
+		if got != w {
+			t.Errorf("metrics[%s] = %+v, want %+v", name, got, w)
+		}
+	}
+}
+
+func TestRuleChain_evaluate_Waitlist(t *testing.T) {
+	ctx, seg := xray.BeginSegment(context.Background(), "TestRuleChain_evaluate_Waitlist")
+	defer seg.Close(nil)
+
+	tests := []struct {
+		name  string
+		rules []ReservationRule
+		want  Decision
+	}{
+		{
+			name: "他のルールが許可した場合はキャンセル待ち",
+			rules: []ReservationRule{
+				&testRule{name: "first", decision: Waitlist("first_waitlisted")},
+				&testRule{name: "second", decision: Accept()},
+			},
+			want: Waitlist("first_waitlisted"),
+		},
+		{
+			name: "最初のキャンセル待ちの理由を返す",
+			rules: []ReservationRule{
+				&testRule{name: "first", decision: Waitlist("first_waitlisted")},
+				&testRule{name: "second", decision: Waitlist("second_waitlisted")},
+			},
+			want: Waitlist("first_waitlisted"),
+		},
+		{
+			name: "後のルールの拒否を優先",
+			rules: []ReservationRule{
+				&testRule{name: "first", decision: Waitlist("first_waitlisted")},
+				&testRule{name: "second", decision: Reject("second_rejected")},
+			},
+			want: Reject("second_rejected"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain := newRuleChain(tt.rules)
+			decision, err := chain.evaluate(ctx, nil, model.Reservation{ID: 1})
+			if err != nil || decision != tt.want {
+				t.Errorf("evaluate() = %+v, %v, want %+v", decision, err, tt.want)
+			}
+			if got := chain.metrics["first"].Waitlisted; got != 1 {
+				t.Errorf("metrics[first].Waitlisted = %d, want 1", got)
+			}
+		})
+	}
+}
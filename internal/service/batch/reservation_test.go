@@ -2,22 +2,90 @@ package batch
 
 import (
 	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sfn"
 	"github.com/aws/aws-xray-sdk-go/xray"
+	"github.com/horsewin/echo-playground-batch-task/internal/common/apperrors"
 	"github.com/horsewin/echo-playground-batch-task/internal/common/config"
-	"github.com/horsewin/echo-playground-batch-task/internal/common/models"
+	"github.com/horsewin/echo-playground-batch-task/internal/common/job"
+	"github.com/horsewin/echo-playground-batch-task/internal/common/retry"
 	"github.com/horsewin/echo-playground-batch-task/internal/model"
+	"github.com/horsewin/echo-playground-batch-task/internal/repository"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
+// fakeTxDriver はトランザクションの開始・コミット・ロールバックのみをサポートするテスト用ドライバです
+// モックリポジトリのBeginTxから実際の*sqlx.Txを返すために利用します
+type fakeTxDriver struct{}
+
+func (fakeTxDriver) Open(name string) (driver.Conn, error) { return fakeTxConn{}, nil }
+
+type fakeTxConn struct{}
+
+func (fakeTxConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("fakeTxConn: prepare is not supported")
+}
+func (fakeTxConn) Close() error              { return nil }
+func (fakeTxConn) Begin() (driver.Tx, error) { return fakeTx{}, nil }
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+func init() {
+	sql.Register("batch-fake-tx", fakeTxDriver{})
+}
+
+// newFakeTx はテスト用のトランザクションを作成します
+func newFakeTx() (*sqlx.Tx, error) {
+	db, err := sqlx.Open("batch-fake-tx", "")
+	if err != nil {
+		return nil, err
+	}
+	return db.Beginx()
+}
+
 // MockReservationRepository はテスト用のモックリポジトリです
 type MockReservationRepository struct {
 	createReservationsCalled bool
 	createReservationsError  error
 	reservations             []model.Reservation
+
+	pendingReservations  []model.Reservation
+	getReservationsError error
+	existingPetIDs       map[string]bool
+	updatedStatuses      map[int64]string
+	statusHistory        []model.ReservationStatusChange
+	// conflictIDs は他の処理が先にステータスを変更したものとして扱う予約IDです
+	conflictIDs map[int64]bool
+	// updateStatusErrors は予約IDごとに、UpdateStatusの呼び出し順に返すエラーです
+	updateStatusErrors map[int64][]error
+	// onUpdateStatus はUpdateStatusの呼び出し時に実行されます
+	onUpdateStatus func(reservationID int64)
+	// staleReservations はGetStaleReservationsで返す予約です
+	staleReservations []model.Reservation
+	// staleCalled はGetStaleReservationsが呼び出されたことを表します
+	staleCalled bool
+	// filters はGetReservationsに渡された条件です
+	filters []model.ReservationFilter
+	// userPetReservations は確定済みの予約を持つユーザーIDとペットIDの組です ("user1/pet1")
+	userPetReservations map[string]bool
+	// activeCounts はユーザーIDごとの確定済みの予約の件数です
+	activeCounts map[string]int
+	// checkConfirmed はCheckExistingReservationでpendingReservationsの確定済みの予約を参照することを表します
+	checkConfirmed bool
 }
 
 func (m *MockReservationRepository) CreateReservations(ctx context.Context, reservations []model.Reservation) error {
@@ -27,27 +95,263 @@ func (m *MockReservationRepository) CreateReservations(ctx context.Context, rese
 }
 
 func (m *MockReservationRepository) BeginTx() (*sqlx.Tx, error) {
-	return nil, nil
+	return newFakeTx()
 }
 
-func (m *MockReservationRepository) CheckExistingReservation(ctx context.Context, petID string) (bool, error) {
+// CheckExistingReservation はexistingPetIDsに含まれるペットの場合にtrueを返します
+// checkConfirmedがtrueの場合は、予約日時が現在より後の確定済みの予約があるペットの場合もtrueを返します
+func (m *MockReservationRepository) CheckExistingReservation(ctx context.Context, petID string, excludeID int64) (bool, error) {
+	if m.existingPetIDs[petID] || !m.checkConfirmed {
+		return m.existingPetIDs[petID], nil
+	}
+	now := time.Now()
+	for _, r := range m.pendingReservations {
+		if r.ID != excludeID && r.PetID == petID && m.currentStatus(r) == model.ReservationStatusConfirmed && r.ReservationDateTime.After(now) {
+			return true, nil
+		}
+	}
 	return false, nil
 }
 
-func (m *MockReservationRepository) UpdateStatus(ctx context.Context, tx *sqlx.Tx, reservationID int64, status string) error {
+func (m *MockReservationRepository) CheckUserReservationForPet(ctx context.Context, userID, petID string, excludeID int64) (bool, error) {
+	return m.userPetReservations[userID+"/"+petID], nil
+}
+
+func (m *MockReservationRepository) CountActiveReservationsByUser(ctx context.Context, userID string, excludeID int64) (int, error) {
+	return m.activeCounts[userID], nil
+}
+
+func (m *MockReservationRepository) UpdateStatus(ctx context.Context, tx *sqlx.Tx, change *model.ReservationStatusChange) error {
+	reservationID := change.ReservationID
+	if err := model.ValidateStatusTransition(change.FromStatus, change.ToStatus); err != nil {
+		return err
+	}
+	if m.conflictIDs[reservationID] {
+		return fmt.Errorf("reservation %d: %w", reservationID, model.ErrStatusConflict)
+	}
+	if errs := m.updateStatusErrors[reservationID]; len(errs) > 0 {
+		m.updateStatusErrors[reservationID] = errs[1:]
+		if errs[0] != nil {
+			return errs[0]
+		}
+	}
+	if m.onUpdateStatus != nil {
+		m.onUpdateStatus(reservationID)
+	}
+	if m.updatedStatuses == nil {
+		m.updatedStatuses = make(map[int64]string)
+	}
+	m.updatedStatuses[reservationID] = string(change.ToStatus)
+	m.statusHistory = append(m.statusHistory, *change)
+	return nil
+}
+
+func (m *MockReservationRepository) GetStatusHistory(ctx context.Context, reservationID int64) ([]model.ReservationStatusChange, error) {
+	var history []model.ReservationStatusChange
+	for _, change := range m.statusHistory {
+		if change.ReservationID == reservationID {
+			history = append(history, change)
+		}
+	}
+	return history, nil
+}
+
+func (m *MockReservationRepository) GetReservations(ctx context.Context, filter model.ReservationFilter, cursor *model.ReservationCursor) ([]model.Reservation, error) {
+	m.filters = append(m.filters, filter)
+	if m.getReservationsError != nil {
+		return nil, m.getReservationsError
+	}
+	var reservations []model.Reservation
+	for _, r := range m.pendingReservations {
+		switch {
+		case len(filter.Statuses) > 0 && !slices.Contains(filter.Statuses, r.Status),
+			len(filter.IDs) > 0 && !slices.Contains(filter.IDs, r.ID),
+			len(filter.PetIDs) > 0 && !slices.Contains(filter.PetIDs, r.PetID),
+			len(filter.UserIDs) > 0 && !slices.Contains(filter.UserIDs, r.UserID),
+			filter.From != nil && r.ReservationDateTime.Before(*filter.From),
+			filter.To != nil && !r.ReservationDateTime.Before(*filter.To),
+			filter.SlotAfter != nil && !r.ReservationDateTime.After(*filter.SlotAfter),
+			cursor != nil && !(r.ReservationDateTime.After(cursor.DateTime) ||
+				(r.ReservationDateTime.Equal(cursor.DateTime) && r.ID > cursor.ID)):
+			continue
+		}
+		if filter.Limit > 0 && len(reservations) >= filter.Limit {
+			break
+		}
+		reservations = append(reservations, r)
+	}
+	return reservations, nil
+}
+
+func (m *MockReservationRepository) GetReservationsByStatusBetween(ctx context.Context, status model.ReservationStatus, from, to time.Time) ([]model.Reservation, error) {
+	if m.getReservationsError != nil {
+		return nil, m.getReservationsError
+	}
+	var between []model.Reservation
+	for _, r := range m.pendingReservations {
+		if r.Status == status && r.ReservationDateTime.After(from) && !r.ReservationDateTime.After(to) {
+			between = append(between, r)
+		}
+	}
+	return between, nil
+}
+
+func (m *MockReservationRepository) GetStaleReservations(ctx context.Context, slotBefore time.Time, createdBefore *time.Time) ([]model.Reservation, error) {
+	m.staleCalled = true
+	return m.staleReservations, m.getReservationsError
+}
+
+// currentStatus はUpdateStatusで変更した後の予約のステータスを返します
+func (m *MockReservationRepository) currentStatus(r model.Reservation) model.ReservationStatus {
+	if status, ok := m.updatedStatuses[r.ID]; ok {
+		return model.ReservationStatus(status)
+	}
+	return r.Status
+}
+
+func (m *MockReservationRepository) GetPromotableWaitlisted(ctx context.Context, now time.Time) ([]model.Reservation, error) {
+	if m.getReservationsError != nil {
+		return nil, m.getReservationsError
+	}
+	reserved := make(map[string]bool)
+	for petID, exists := range m.existingPetIDs {
+		reserved[petID] = exists
+	}
+	for _, r := range m.pendingReservations {
+		if m.currentStatus(r) == model.ReservationStatusConfirmed && r.ReservationDateTime.After(now) {
+			reserved[r.PetID] = true
+		}
+	}
+
+	waitlisted := make(map[string]model.Reservation)
+	var petIDs []string
+	for _, r := range m.pendingReservations {
+		if m.currentStatus(r) != model.ReservationStatusWaitlisted || !r.ReservationDateTime.After(now) || reserved[r.PetID] {
+			continue
+		}
+		first, ok := waitlisted[r.PetID]
+		if !ok {
+			petIDs = append(petIDs, r.PetID)
+		}
+		if !ok || r.CreatedAt.Before(first.CreatedAt) || (r.CreatedAt.Equal(first.CreatedAt) && r.ID < first.ID) {
+			r.Status = model.ReservationStatusWaitlisted
+			waitlisted[r.PetID] = r
+		}
+	}
+	slices.Sort(petIDs)
+	reservations := make([]model.Reservation, 0, len(petIDs))
+	for _, petID := range petIDs {
+		reservations = append(reservations, waitlisted[petID])
+	}
+	return reservations, nil
+}
+
+// mockRuleRepository はテスト用のReservationRuleRepositoryです
+// 予約の情報はMockReservationRepository、ペットの見学可否はMockPetRepositoryから返します
+type mockRuleRepository struct {
+	*MockReservationRepository
+	pets *MockPetRepository
+}
+
+func (m *mockRuleRepository) GetPetAvailability(ctx context.Context, petID string) (*model.PetAvailability, error) {
+	return m.pets.availability(petID)
+}
+
+// testRuleOrder はテストで評価するルールの順序です (RESERVATION_RULESの既定値)
+var testRuleOrder = []string{RulePetAvailability, RuleUserPet, RuleUserLimit, RulePetConflict}
+
+// setTestRules は設定の順序で、モックリポジトリを参照するルールをサービスに設定します
+// 順序が設定されていない場合はtestRuleOrderの順に評価します
+func setTestRules(service *ReservationBatchService, repo *MockReservationRepository, pets *MockPetRepository) error {
+	if service.cfg.Rules.Order == nil {
+		service.cfg.Rules.Order = testRuleOrder
+	}
+	rules, err := NewReservationRules(service.cfg)
+	if err != nil {
+		return err
+	}
+	service.rules = newRuleChain(rules)
+	service.newRuleRepository = func(*sqlx.Tx) repository.ReservationRuleRepository {
+		return &mockRuleRepository{MockReservationRepository: repo, pets: pets}
+	}
 	return nil
 }
 
-func (m *MockReservationRepository) GetReservationsByStatus(ctx context.Context, status string) ([]models.Reservation, error) {
-	return nil, nil
+// MockReservationEventRepository はテスト用のアウトボックスです
+type MockReservationEventRepository struct {
+	events []model.OutboxEvent
+	// markDeliveredError はMarkDeliveredで返すエラーです
+	markDeliveredError error
+}
+
+func (m *MockReservationEventRepository) Create(ctx context.Context, tx *sqlx.Tx, event *model.OutboxEvent) error {
+	event.ID = int64(len(m.events) + 1)
+	m.events = append(m.events, *event)
+	return nil
+}
+
+func (m *MockReservationEventRepository) GetUndelivered(ctx context.Context, limit int) ([]model.OutboxEvent, error) {
+	var events []model.OutboxEvent
+	for _, e := range m.events {
+		if e.DeliveredAt == nil && (limit <= 0 || len(events) < limit) {
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
+
+func (m *MockReservationEventRepository) MarkDelivered(ctx context.Context, ids []int64) error {
+	if m.markDeliveredError != nil {
+		return m.markDeliveredError
+	}
+	now := time.Now()
+	for i := range m.events {
+		if slices.Contains(ids, m.events[i].ID) {
+			m.events[i].DeliveredAt = &now
+		}
+	}
+	return nil
+}
+
+// undelivered は未配信のイベントの件数を返します
+func (m *MockReservationEventRepository) undelivered() int {
+	events, _ := m.GetUndelivered(context.Background(), 0)
+	return len(events)
+}
+
+// mockSFNClient はSendTaskSuccessの出力を記録するテスト用のクライアントです
+type mockSFNClient struct {
+	outputs []string
+	// sendTaskSuccessError はSendTaskSuccessで返すエラーです
+	sendTaskSuccessError error
+}
+
+func (m *mockSFNClient) SendTaskSuccess(ctx context.Context, params *sfn.SendTaskSuccessInput, optFns ...func(*sfn.Options)) (*sfn.SendTaskSuccessOutput, error) {
+	if m.sendTaskSuccessError != nil {
+		return nil, m.sendTaskSuccessError
+	}
+	m.outputs = append(m.outputs, aws.ToString(params.Output))
+	return &sfn.SendTaskSuccessOutput{}, nil
+}
+
+func (m *mockSFNClient) SendTaskFailure(ctx context.Context, params *sfn.SendTaskFailureInput, optFns ...func(*sfn.Options)) (*sfn.SendTaskFailureOutput, error) {
+	return &sfn.SendTaskFailureOutput{}, nil
+}
+
+func (m *mockSFNClient) SendTaskHeartbeat(ctx context.Context, params *sfn.SendTaskHeartbeatInput, optFns ...func(*sfn.Options)) (*sfn.SendTaskHeartbeatOutput, error) {
+	return &sfn.SendTaskHeartbeatOutput{}, nil
 }
 
 // newTestReservationBatchService はテスト用のReservationBatchServiceを作成します
 func newTestReservationBatchService(mockReservationRepo *MockReservationRepository) *ReservationBatchService {
-	return &ReservationBatchService{
+	service := &ReservationBatchService{
 		reservationRepo: mockReservationRepo,
+		eventRepo:       &MockReservationEventRepository{},
 		cfg:             &config.Config{},
 	}
+	// testRuleOrderのルールは常に作成できる
+	_ = setTestRules(service, mockReservationRepo, &MockPetRepository{})
+	return service
 }
 
 func TestReservationBatchService_Run(t *testing.T) {
@@ -55,18 +359,21 @@ func TestReservationBatchService_Run(t *testing.T) {
 	ctx, seg := xray.BeginSegment(context.Background(), "TestReservationBatchService_Run")
 	defer seg.Close(nil)
 
-	now := time.Now().UTC()
+	now := time.Now().UTC().Add(time.Hour)
 	tests := []struct {
-		name         string
-		reservations []model.Reservation
-		mockError    error
-		wantErr      bool
+		name           string
+		reservations   []model.Reservation
+		existingPetIDs map[string]bool
+		mockError      error
+		wantErr        bool
+		wantStatuses   map[int64]string
 	}{
 		{
 			name:         "0件の予約を正常に処理",
 			reservations: []model.Reservation{},
 			mockError:    nil,
 			wantErr:      false,
+			wantStatuses: map[int64]string{},
 		},
 		{
 			name: "1件の予約を正常に処理",
@@ -83,8 +390,9 @@ func TestReservationBatchService_Run(t *testing.T) {
 					UpdatedAt:           now,
 				},
 			},
-			mockError: nil,
-			wantErr:   false,
+			mockError:    nil,
+			wantErr:      false,
+			wantStatuses: map[int64]string{1: "confirmed"},
 		},
 		{
 			name: "2件の予約を正常に処理",
@@ -112,8 +420,9 @@ func TestReservationBatchService_Run(t *testing.T) {
 					UpdatedAt:           now,
 				},
 			},
-			mockError: nil,
-			wantErr:   false,
+			mockError:    nil,
+			wantErr:      false,
+			wantStatuses: map[int64]string{1: "confirmed", 2: "confirmed"},
 		},
 		{
 			name: "異なるステータスの予約を処理",
@@ -152,11 +461,12 @@ func TestReservationBatchService_Run(t *testing.T) {
 					UpdatedAt:           now,
 				},
 			},
-			mockError: nil,
-			wantErr:   false,
+			mockError:    nil,
+			wantErr:      false,
+			wantStatuses: map[int64]string{3: "confirmed"},
 		},
 		{
-			name: "リポジトリからのエラーを処理",
+			name: "既存の予約があるペットの予約をキャンセル待ちにする",
 			reservations: []model.Reservation{
 				{
 					ID:                  1,
@@ -170,31 +480,704 @@ func TestReservationBatchService_Run(t *testing.T) {
 					UpdatedAt:           now,
 				},
 			},
-			mockError: fmt.Errorf("database error: connection failed"),
-			wantErr:   true,
+			existingPetIDs: map[string]bool{"pet1": true},
+			mockError:      nil,
+			wantErr:        false,
+			wantStatuses:   map[int64]string{1: "waitlisted"},
+		},
+		{
+			name:         "リポジトリからのエラーを処理",
+			reservations: []model.Reservation{},
+			mockError:    fmt.Errorf("database error: connection failed"),
+			wantErr:      true,
+			wantStatuses: map[int64]string{},
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			mockReservationRepo := &MockReservationRepository{
-				createReservationsError: tt.mockError,
+				pendingReservations:  tt.reservations,
+				getReservationsError: tt.mockError,
+				existingPetIDs:       tt.existingPetIDs,
 			}
 
 			service := newTestReservationBatchService(mockReservationRepo)
-			service.SetArgs(tt.reservations)
 			err := service.Run(ctx)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Run() error = %v, wantErr %v", err, tt.wantErr)
 			}
 
-			if !mockReservationRepo.createReservationsCalled {
-				t.Error("CreateReservations was not called")
+			if len(mockReservationRepo.updatedStatuses) != len(tt.wantStatuses) {
+				t.Errorf("Expected %d status updates, got %d", len(tt.wantStatuses), len(mockReservationRepo.updatedStatuses))
+			}
+			for id, want := range tt.wantStatuses {
+				if got := mockReservationRepo.updatedStatuses[id]; got != want {
+					t.Errorf("reservation %d status = %q, want %q", id, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestReservationBatchService_Run_PartialFailure(t *testing.T) {
+	ctx, seg := xray.BeginSegment(context.Background(), "TestReservationBatchService_Run_PartialFailure")
+	defer seg.Close(nil)
+
+	now := time.Now().UTC().Add(time.Hour)
+	mockReservationRepo := &MockReservationRepository{
+		pendingReservations: []model.Reservation{
+			{ID: 1, UserID: "user1", PetID: "pet1", ReservationDateTime: now, Status: "pending"},
+			{ID: 2, UserID: "user2", PetID: "pet2", ReservationDateTime: now, Status: "pending"},
+		},
+		updateStatusErrors: map[int64][]error{
+			2: {&pq.Error{Code: "40001"}},
+		},
+	}
+
+	service := newTestReservationBatchService(mockReservationRepo)
+	err := service.Run(ctx)
+	if !apperrors.Is(err, apperrors.CodePartialFailure) {
+		t.Fatalf("Run() error = %v, want %s", err, apperrors.CodePartialFailure)
+	}
+
+	var appErr *apperrors.Error
+	if !errors.As(err, &appErr) {
+		t.Fatalf("Run() error is not *apperrors.Error: %T", err)
+	}
+	if appErr.Details["failed"] != 1 {
+		t.Errorf("details.failed = %v, want 1", appErr.Details["failed"])
+	}
+	if appErr.Details["processed"] != 1 {
+		t.Errorf("details.processed = %v, want 1", appErr.Details["processed"])
+	}
+	// 処理済みの予約の通知はアウトボックスから配信するため、重複しないよう付加情報に含めない
+	if _, ok := appErr.Details["notifications"]; ok {
+		t.Errorf("details = %v, want no notifications", appErr.Details)
+	}
+	// Step Functionsに配信する場合は、次に成功した実行で配信するまで未配信のままにする
+	eventRepo := service.eventRepo.(*MockReservationEventRepository)
+	if undelivered, _ := eventRepo.GetUndelivered(ctx, 0); len(undelivered) != 1 {
+		t.Errorf("undelivered events = %d, want 1", len(undelivered))
+	}
+
+	// 処理に失敗した予約は保留中のまま残さず、失敗として原因を記録する
+	if got := mockReservationRepo.updatedStatuses[2]; got != "failed" {
+		t.Errorf("reservation 2 status = %q, want %q", got, "failed")
+	}
+	history, _ := mockReservationRepo.GetStatusHistory(ctx, 2)
+	if len(history) != 1 || history[0].Reason != model.StatusReasonProcessingFailed || history[0].Error == "" {
+		t.Errorf("GetStatusHistory(2) = %+v, want processing_failed with error", history)
+	}
+}
+
+func TestReservationBatchService_Run_RelaysEventsBeforePartialFailure(t *testing.T) {
+	ctx, seg := xray.BeginSegment(context.Background(), "TestReservationBatchService_Run_RelaysEventsBeforePartialFailure")
+	defer seg.Close(nil)
+
+	now := time.Now().UTC().Add(time.Hour)
+	mockReservationRepo := &MockReservationRepository{
+		pendingReservations: []model.Reservation{
+			{ID: 1, UserID: "user1", PetID: "pet1", ReservationDateTime: now, Status: "pending"},
+			{ID: 2, UserID: "user2", PetID: "pet2", ReservationDateTime: now, Status: "pending"},
+		},
+		updateStatusErrors: map[int64][]error{
+			2: {&pq.Error{Code: "40001"}},
+		},
+	}
+
+	service := newTestReservationBatchService(mockReservationRepo)
+	var published []model.OutboxEvent
+	service.eventPublisher = EventPublisherFunc(func(ctx context.Context, events []model.OutboxEvent) error {
+		published = append(published, events...)
+		return nil
+	})
+	if err := service.Run(ctx); !apperrors.Is(err, apperrors.CodePartialFailure) {
+		t.Fatalf("Run() error = %v, want %s", err, apperrors.CodePartialFailure)
+	}
+
+	// キューやファイルに配信する場合は、失敗を通知する前に処理済みの予約のイベントを配信済みにする
+	if len(published) != 1 || published[0].ReservationID != 1 {
+		t.Errorf("published = %+v, want event of reservation 1", published)
+	}
+	eventRepo := service.eventRepo.(*MockReservationEventRepository)
+	if undelivered, _ := eventRepo.GetUndelivered(ctx, 0); len(undelivered) != 0 {
+		t.Errorf("undelivered events = %d, want 0", len(undelivered))
+	}
+}
+
+func TestReservationBatchService_Run_SourceStatus(t *testing.T) {
+	ctx, seg := xray.BeginSegment(context.Background(), "TestReservationBatchService_Run_SourceStatus")
+	defer seg.Close(nil)
+
+	now := time.Now().UTC().Add(time.Hour)
+	reservations := []model.Reservation{
+		{ID: 1, UserID: "user1", PetID: "pet1", ReservationDateTime: now, Status: "pending"},
+		{ID: 2, UserID: "user2", PetID: "pet1", ReservationDateTime: now, Status: "failed", LastError: "commit failed"},
+		{ID: 3, UserID: "user3", PetID: "pet2", ReservationDateTime: now, Status: "failed", LastError: "commit failed"},
+		{ID: 4, UserID: "user4", PetID: "pet2", ReservationDateTime: now, Status: "confirmed"},
+		{ID: 5, UserID: "user5", PetID: "pet3", ReservationDateTime: now, Status: "confirmed"},
+	}
+
+	tests := []struct {
+		name   string
+		status model.ReservationStatus
+		// want は予約IDごとの変更後のステータスです
+		want          map[int64]string
+		wantUnchanged int
+	}{
+		{
+			name:   "失敗した予約を処理し直す",
+			status: model.ReservationStatusFailed,
+			want:   map[int64]string{2: "waitlisted", 3: "confirmed"},
+		},
+		{
+			name:   "確定済みの予約を再評価する",
+			status: model.ReservationStatusConfirmed,
+			// 予約5は他の確定済みの予約と重ならないため変更しない
+			want:          map[int64]string{4: "cancelled"},
+			wantUnchanged: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockReservationRepo := &MockReservationRepository{
+				pendingReservations: reservations,
+				existingPetIDs:      map[string]bool{"pet1": true, "pet2": tt.status == model.ReservationStatusConfirmed},
+				staleReservations: []model.Reservation{
+					{ID: 6, UserID: "user6", PetID: "pet6", ReservationDateTime: now.Add(-2 * time.Hour), Status: "pending"},
+				},
+			}
+			eventRepo := &MockReservationEventRepository{}
+			service := newTestReservationBatchService(mockReservationRepo)
+			service.eventRepo = eventRepo
+			service.SetFilter(model.ReservationFilter{Statuses: []model.ReservationStatus{tt.status}})
+
+			result, err := service.processReservationsByStatus(ctx, service.sourceStatus(), time.Now(), nil, &checkpointer{}, 0)
+			if err != nil {
+				t.Fatalf("processReservationsByStatus() error = %v", err)
+			}
+			if len(mockReservationRepo.updatedStatuses) != len(tt.want) {
+				t.Errorf("updated statuses = %v, want %v", mockReservationRepo.updatedStatuses, tt.want)
+			}
+			for id, want := range tt.want {
+				if got := mockReservationRepo.updatedStatuses[id]; got != want {
+					t.Errorf("reservation %d status = %q, want %q", id, got, want)
+				}
+			}
+			if result.unchanged != tt.wantUnchanged || len(eventRepo.events) != len(tt.want) {
+				t.Errorf("unchanged = %d, events = %d, want %d, %d", result.unchanged, len(eventRepo.events), tt.wantUnchanged, len(tt.want))
 			}
 
-			if len(mockReservationRepo.reservations) != len(tt.reservations) {
-				t.Errorf("Expected %d reservations, got %d", len(tt.reservations), len(mockReservationRepo.reservations))
+			// 保留中以外の予約を処理する実行では期限切れにしない
+			if err := service.Run(ctx); err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			if mockReservationRepo.staleCalled {
+				t.Error("stale reservations should not be expired")
 			}
 		})
 	}
 }
+
+func TestReservationBatchService_Run_ConfirmedSkipsPastSlots(t *testing.T) {
+	ctx, seg := xray.BeginSegment(context.Background(), "TestReservationBatchService_Run_ConfirmedSkipsPastSlots")
+	defer seg.Close(nil)
+
+	now := time.Now().UTC()
+	mockReservationRepo := &MockReservationRepository{
+		pendingReservations: []model.Reservation{
+			// 見学が終わった予約は、同じペットに後の確定済みの予約があっても変更しない
+			{ID: 1, UserID: "user1", PetID: "pet1", ReservationDateTime: now.Add(-24 * time.Hour), Status: "confirmed"},
+			{ID: 2, UserID: "user2", PetID: "pet1", ReservationDateTime: now.Add(24 * time.Hour), Status: "confirmed"},
+		},
+		checkConfirmed: true,
+	}
+	eventRepo := &MockReservationEventRepository{}
+	service := newTestReservationBatchService(mockReservationRepo)
+	service.eventRepo = eventRepo
+	service.SetFilter(model.ReservationFilter{Statuses: []model.ReservationStatus{model.ReservationStatusConfirmed}})
+
+	if err := service.Run(ctx); err != nil {
+		t.Fatalf("Run() error = %v, want nil", err)
+	}
+	if len(mockReservationRepo.updatedStatuses) != 0 || len(eventRepo.events) != 0 {
+		t.Errorf("updated statuses = %v, events = %d, want no changes", mockReservationRepo.updatedStatuses, len(eventRepo.events))
+	}
+	filter := mockReservationRepo.filters[0]
+	if filter.SlotAfter == nil || filter.SlotAfter.Before(now) {
+		t.Errorf("filter = %s, want slot_after not before %s", filter, now.Format(time.RFC3339))
+	}
+}
+
+func TestReservationBatchService_Run_RetriesTransientErrors(t *testing.T) {
+	ctx, seg := xray.BeginSegment(context.Background(), "TestReservationBatchService_Run_RetriesTransientErrors")
+	defer seg.Close(nil)
+
+	now := time.Now().UTC().Add(time.Hour)
+	mockReservationRepo := &MockReservationRepository{
+		pendingReservations: []model.Reservation{
+			{ID: 1, UserID: "user1", PetID: "pet1", ReservationDateTime: now, Status: "pending"},
+		},
+		updateStatusErrors: map[int64][]error{
+			1: {&pq.Error{Code: "40001"}, &pq.Error{Code: "57P01"}},
+		},
+	}
+
+	service := newTestReservationBatchService(mockReservationRepo)
+	service.cfg.Retry = retry.Policy{MaxAttempts: 3}
+	if err := service.Run(ctx); err != nil {
+		t.Fatalf("Run() error = %v, want nil", err)
+	}
+	if got := mockReservationRepo.updatedStatuses[1]; got != "confirmed" {
+		t.Errorf("reservation 1 status = %q, want %q", got, "confirmed")
+	}
+}
+
+func TestReservationBatchService_Run_StatusHistory(t *testing.T) {
+	ctx, seg := xray.BeginSegment(context.Background(), "TestReservationBatchService_Run_StatusHistory")
+	defer seg.Close(nil)
+
+	now := time.Now().UTC().Add(time.Hour)
+	mockReservationRepo := &MockReservationRepository{
+		pendingReservations: []model.Reservation{
+			{ID: 1, UserID: "user1", PetID: "pet1", ReservationDateTime: now, Status: "pending"},
+			{ID: 2, UserID: "user2", PetID: "pet2", ReservationDateTime: now, Status: "pending"},
+			{ID: 3, UserID: "user3", PetID: "pet3", ReservationDateTime: now, Status: "pending"},
+		},
+		existingPetIDs: map[string]bool{"pet2": true},
+		// 予約3は他の実行が先に確定した
+		conflictIDs: map[int64]bool{3: true},
+	}
+
+	service := newTestReservationBatchService(mockReservationRepo)
+	service.cfg.Run.ID = "run-1"
+	if err := service.Run(ctx); err != nil {
+		t.Fatalf("Run() error = %v, want nil", err)
+	}
+
+	want := map[int64]model.ReservationStatusChange{
+		1: {ReservationID: 1, FromStatus: "pending", ToStatus: "confirmed", Reason: model.StatusReasonPetAvailable, RunID: "run-1"},
+		2: {ReservationID: 2, FromStatus: "pending", ToStatus: "waitlisted", Reason: model.StatusReasonPetAlreadyReserved, RunID: "run-1"},
+	}
+	for id, w := range want {
+		history, err := mockReservationRepo.GetStatusHistory(ctx, id)
+		if err != nil || len(history) != 1 {
+			t.Fatalf("GetStatusHistory(%d) = %+v, %v, want 1 change", id, history, err)
+		}
+		got := history[0]
+		got.ChangedAt = time.Time{}
+		if got != w {
+			t.Errorf("GetStatusHistory(%d) = %+v, want %+v", id, got, w)
+		}
+	}
+
+	// ステータスを変更できなかった予約は失敗として扱わない
+	if _, ok := mockReservationRepo.updatedStatuses[3]; ok {
+		t.Errorf("reservation 3 should not be updated")
+	}
+}
+
+func TestReservationBatchService_Run_PetAvailability(t *testing.T) {
+	ctx, seg := xray.BeginSegment(context.Background(), "TestReservationBatchService_Run_PetAvailability")
+	defer seg.Close(nil)
+
+	// 2030-06-03は月曜日
+	slot := time.Date(2030, 6, 3, 10, 0, 0, 0, time.UTC)
+	mockReservationRepo := &MockReservationRepository{
+		pendingReservations: []model.Reservation{
+			{ID: 1, UserID: "user1", PetID: "pet1", ReservationDateTime: slot, Status: "pending"},
+			{ID: 2, UserID: "user2", PetID: "adopted", ReservationDateTime: slot, Status: "pending"},
+			{ID: 3, UserID: "user3", PetID: "missing", ReservationDateTime: slot, Status: "pending"},
+			{ID: 4, UserID: "user4", PetID: "shop", ReservationDateTime: slot, Status: "pending"},
+			{ID: 5, UserID: "user5", PetID: "shop", ReservationDateTime: slot.Add(-8 * time.Hour), Status: "pending"},
+		},
+	}
+	petRepo := &MockPetRepository{
+		missingPetIDs: map[string]bool{"missing": true},
+		availabilities: map[string]*model.PetAvailability{
+			"adopted": {PetID: "adopted", Status: model.PetStatusAvailable, Adopted: true},
+			"shop": {PetID: "shop", Status: model.PetStatusAvailable, ShopID: "shop1", TimeZone: "UTC", OpeningHours: []model.OpeningHours{
+				{Weekday: time.Monday, Opens: "09:00", Closes: "18:00"},
+			}},
+		},
+	}
+
+	service := newTestReservationBatchService(mockReservationRepo)
+	if err := setTestRules(service, mockReservationRepo, petRepo); err != nil {
+		t.Fatal(err)
+	}
+	if err := service.Run(ctx); err != nil {
+		t.Fatalf("Run() error = %v, want nil", err)
+	}
+
+	want := map[int64]string{
+		1: model.StatusReasonPetAvailable,
+		2: model.StatusReasonPetAdopted,
+		3: model.StatusReasonPetNotFound,
+		4: model.StatusReasonPetAvailable,
+		5: model.StatusReasonOutsideOpeningHours,
+	}
+	for id, reason := range want {
+		history, _ := mockReservationRepo.GetStatusHistory(ctx, id)
+		if len(history) != 1 || history[0].Reason != reason {
+			t.Errorf("GetStatusHistory(%d) = %+v, want reason %s", id, history, reason)
+		}
+	}
+
+	// キャンセルした予約のイベントは、通知でキャンセルと理由を伝えるためにステータスと理由を含める
+	found := false
+	for _, e := range service.eventRepo.(*MockReservationEventRepository).events {
+		if e.ReservationID != 2 {
+			continue
+		}
+		found = true
+		event, err := e.ReservationEvent()
+		if err != nil || e.EventType != model.ReservationEventCancelled || event.Status != model.ReservationStatusCancelled || event.Reason != model.StatusReasonPetAdopted {
+			t.Errorf("event of reservation 2 = %s %+v, err = %v, want cancelled by pet_adopted", e.EventType, event, err)
+		}
+	}
+	if !found {
+		t.Error("event of reservation 2 was not recorded")
+	}
+
+	// 掲載を取り下げたペットの確定済みの予約は、再評価でキャンセルする
+	mockReservationRepo.pendingReservations = []model.Reservation{
+		{ID: 6, UserID: "user6", PetID: "pet1", ReservationDateTime: slot, Status: "confirmed"},
+	}
+	petRepo.availabilities["pet1"] = &model.PetAvailability{PetID: "pet1", Status: model.PetStatusWithdrawn}
+	service.SetFilter(model.ReservationFilter{Statuses: []model.ReservationStatus{model.ReservationStatusConfirmed}})
+	if err := service.Run(ctx); err != nil {
+		t.Fatalf("Run() error = %v, want nil", err)
+	}
+	history, _ := mockReservationRepo.GetStatusHistory(ctx, 6)
+	if len(history) != 1 || history[0].ToStatus != model.ReservationStatusCancelled || history[0].Reason != model.StatusReasonPetWithdrawn {
+		t.Errorf("GetStatusHistory(6) = %+v, want cancelled by pet_withdrawn", history)
+	}
+}
+
+func TestReservationBatchService_Run_Targeted(t *testing.T) {
+	ctx, seg := xray.BeginSegment(context.Background(), "TestReservationBatchService_Run_Targeted")
+	defer seg.Close(nil)
+
+	now := time.Now().UTC().Add(time.Hour)
+	mockReservationRepo := &MockReservationRepository{
+		pendingReservations: []model.Reservation{
+			{ID: 1, UserID: "user1", PetID: "pet1", ReservationDateTime: now, Status: "pending"},
+			{ID: 2, UserID: "user2", PetID: "pet2", ReservationDateTime: now, Status: "pending"},
+			{ID: 3, UserID: "user3", PetID: "pet1", ReservationDateTime: now.Add(time.Hour), Status: "pending"},
+			{ID: 4, UserID: "user4", PetID: "pet1", ReservationDateTime: now.Add(2 * time.Hour), Status: "pending"},
+		},
+		staleReservations: []model.Reservation{
+			{ID: 5, UserID: "user5", PetID: "pet5", ReservationDateTime: now.Add(-2 * time.Hour), Status: "pending"},
+		},
+	}
+
+	service := newTestReservationBatchService(mockReservationRepo)
+	service.SetFilter(model.ReservationFilter{PetIDs: []string{"pet1"}, Limit: 2})
+	if err := service.Run(ctx); err != nil {
+		t.Fatalf("Run() error = %v, want nil", err)
+	}
+
+	// 条件に一致する予約のみ、最大件数まで処理する
+	if len(mockReservationRepo.updatedStatuses) != 2 || mockReservationRepo.updatedStatuses[1] == "" || mockReservationRepo.updatedStatuses[3] == "" {
+		t.Errorf("updated statuses = %v, want reservations 1 and 3", mockReservationRepo.updatedStatuses)
+	}
+	filter := mockReservationRepo.filters[0]
+	if !slices.Equal(filter.Statuses, []model.ReservationStatus{model.ReservationStatusPending}) || !slices.Equal(filter.PetIDs, []string{"pet1"}) {
+		t.Errorf("filter = %s, want pending reservations of pet1", filter)
+	}
+	// 対象外の予約は期限切れにしない
+	if mockReservationRepo.staleCalled {
+		t.Error("stale reservations should not be expired in targeted run")
+	}
+}
+
+func TestReservationBatchService_Run_TargetedExpiresPassedSlots(t *testing.T) {
+	ctx, seg := xray.BeginSegment(context.Background(), "TestReservationBatchService_Run_TargetedExpiresPassedSlots")
+	defer seg.Close(nil)
+
+	// 処理が止まったまま予約日時を過ぎた保留中の予約を、IDを指定して処理し直す
+	mockReservationRepo := &MockReservationRepository{
+		pendingReservations: []model.Reservation{
+			{ID: 1, UserID: "user1", PetID: "pet1", ReservationDateTime: time.Now().UTC().Add(-time.Hour), Status: "pending"},
+		},
+	}
+	eventRepo := &MockReservationEventRepository{}
+	service := newTestReservationBatchService(mockReservationRepo)
+	service.eventRepo = eventRepo
+	service.SetFilter(model.ReservationFilter{IDs: []int64{1}})
+	if err := service.Run(ctx); err != nil {
+		t.Fatalf("Run() error = %v, want nil", err)
+	}
+
+	// 対象を絞り込んだ実行でも、予約日時を過ぎた予約は確定せずに期限切れにする
+	history, _ := mockReservationRepo.GetStatusHistory(ctx, 1)
+	if len(history) != 1 || history[0].ToStatus != model.ReservationStatusExpired || history[0].Reason != model.StatusReasonSlotPassed {
+		t.Errorf("GetStatusHistory(1) = %+v, want expired by slot_passed", history)
+	}
+	if len(eventRepo.events) != 1 || eventRepo.events[0].EventType != model.ReservationEventExpired {
+		t.Errorf("events = %+v, want one %s event", eventRepo.events, model.ReservationEventExpired)
+	}
+}
+
+func TestReservationBatchService_Run_StopsClaimingOnStopRequest(t *testing.T) {
+	ctx, seg := xray.BeginSegment(context.Background(), "TestReservationBatchService_Run_StopsClaimingOnStopRequest")
+	defer seg.Close(nil)
+	ctx, stop := job.WithStop(ctx)
+
+	now := time.Now().UTC().Add(time.Hour)
+	mockReservationRepo := &MockReservationRepository{
+		pendingReservations: []model.Reservation{
+			{ID: 1, UserID: "user1", PetID: "pet1", ReservationDateTime: now, Status: "pending"},
+			{ID: 2, UserID: "user2", PetID: "pet2", ReservationDateTime: now, Status: "pending"},
+			{ID: 3, UserID: "user3", PetID: "pet3", ReservationDateTime: now, Status: "pending"},
+		},
+		// 1件目のトランザクション実行中にSIGTERMを受けた状況を再現
+		onUpdateStatus: func(reservationID int64) {
+			if reservationID == 1 {
+				stop()
+			}
+		},
+	}
+
+	service := newTestReservationBatchService(mockReservationRepo)
+	err := service.Run(ctx)
+	if !apperrors.Is(err, apperrors.CodeInterrupted) {
+		t.Fatalf("Run() error = %v, want %s", err, apperrors.CodeInterrupted)
+	}
+
+	// 実行中だった1件目は完了し、2件目以降は処理されないこと
+	if len(mockReservationRepo.updatedStatuses) != 1 || mockReservationRepo.updatedStatuses[1] != "confirmed" {
+		t.Errorf("updatedStatuses = %v, want only reservation 1 confirmed", mockReservationRepo.updatedStatuses)
+	}
+
+	var appErr *apperrors.Error
+	if !errors.As(err, &appErr) {
+		t.Fatalf("Run() error is not *apperrors.Error: %T", err)
+	}
+	if appErr.Details["processed"] != 1 || appErr.Details["remaining"] != 2 {
+		t.Errorf("details = %v, want processed=1 remaining=2", appErr.Details)
+	}
+}
+
+func TestReservationBatchService_Run_ResumesFromCheckpoint(t *testing.T) {
+	ctx, seg := xray.BeginSegment(context.Background(), "TestReservationBatchService_Run_ResumesFromCheckpoint")
+	defer seg.Close(nil)
+
+	now := time.Now().UTC().Add(time.Hour).Truncate(time.Second)
+	mockReservationRepo := &MockReservationRepository{
+		pendingReservations: []model.Reservation{
+			{ID: 1, UserID: "user1", PetID: "pet1", ReservationDateTime: now, Status: "pending"},
+			{ID: 2, UserID: "user2", PetID: "pet2", ReservationDateTime: now, Status: "pending"},
+			{ID: 3, UserID: "user3", PetID: "pet3", ReservationDateTime: now.Add(time.Hour), Status: "pending"},
+		},
+	}
+	checkpointRepo := repository.NewFileCheckpointRepository(t.TempDir())
+	eventRepo := &MockReservationEventRepository{}
+	sfnClient := &mockSFNClient{}
+
+	// 1回目: 1件目の処理中に停止要求を受けて中断する
+	stopCtx, stop := job.WithStop(ctx)
+	mockReservationRepo.onUpdateStatus = func(reservationID int64) {
+		if reservationID == 1 {
+			stop()
+		}
+	}
+	service := newTestReservationBatchService(mockReservationRepo)
+	service.checkpointRepo = checkpointRepo
+	service.eventRepo = eventRepo
+	service.sfnClient = sfnClient
+	service.cfg.Run.ID = "run-1"
+	if err := service.Run(stopCtx); !apperrors.Is(err, apperrors.CodeInterrupted) {
+		t.Fatalf("first Run() error = %v, want %s", err, apperrors.CodeInterrupted)
+	}
+
+	checkpoint, err := checkpointRepo.Get(ctx, "run-1")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if checkpoint.Outcome != model.CheckpointOutcomeInterrupted {
+		t.Errorf("checkpoint outcome = %s, want %s", checkpoint.Outcome, model.CheckpointOutcomeInterrupted)
+	}
+	// チェックポイントには処理位置と件数のみを記録し、イベントはアウトボックスに記録する
+	if checkpoint.Processed != 1 || eventRepo.undelivered() != 1 {
+		t.Errorf("checkpoint processed = %d, undelivered events = %d, want 1 and 1", checkpoint.Processed, eventRepo.undelivered())
+	}
+
+	// 2回目: 同じ実行IDで再開し、残りの予約のみを処理する
+	mockReservationRepo.onUpdateStatus = nil
+	mockReservationRepo.updatedStatuses = nil
+	service = newTestReservationBatchService(mockReservationRepo)
+	service.checkpointRepo = checkpointRepo
+	service.eventRepo = eventRepo
+	service.sfnClient = sfnClient
+	service.cfg.Run.ID = "run-1"
+	service.cfg.Run.Resume = true
+	service.cfg.SFN.TaskToken = "test-task-token"
+	if err := service.Run(ctx); err != nil {
+		t.Fatalf("resumed Run() error = %v, want nil", err)
+	}
+
+	if _, ok := mockReservationRepo.updatedStatuses[1]; ok || len(mockReservationRepo.updatedStatuses) != 2 {
+		t.Errorf("updatedStatuses = %v, want only reservations 2 and 3", mockReservationRepo.updatedStatuses)
+	}
+
+	// 再開後の出力には中断前に処理した予約の通知も含まれること
+	if len(sfnClient.outputs) != 1 {
+		t.Fatalf("SendTaskSuccess called %d times, want 1", len(sfnClient.outputs))
+	}
+	var output struct {
+		RunID         string               `json:"run_id"`
+		Notifications []model.Notification `json:"notifications"`
+	}
+	if err := json.Unmarshal([]byte(sfnClient.outputs[0]), &output); err != nil {
+		t.Fatalf("failed to parse output: %v", err)
+	}
+	if output.RunID != "run-1" || len(output.Notifications) != 3 {
+		t.Errorf("output run_id = %q, notifications = %d, want run-1 and 3", output.RunID, len(output.Notifications))
+	}
+
+	// 完了した実行は再開できないこと
+	service = newTestReservationBatchService(mockReservationRepo)
+	service.checkpointRepo = checkpointRepo
+	service.cfg.Run.ID = "run-1"
+	service.cfg.Run.Resume = true
+	if err := service.Run(ctx); !apperrors.Is(err, apperrors.CodeInvalidInput) {
+		t.Errorf("Run() of completed run error = %v, want %s", err, apperrors.CodeInvalidInput)
+	}
+}
+
+func TestReservationBatchService_Run_RejectsResumeWithDifferentConditions(t *testing.T) {
+	ctx, seg := xray.BeginSegment(context.Background(), "TestReservationBatchService_Run_RejectsResumeWithDifferentConditions")
+	defer seg.Close(nil)
+
+	now := time.Now().UTC().Add(time.Hour)
+	checkpointRepo := repository.NewFileCheckpointRepository(t.TempDir())
+	mockReservationRepo := &MockReservationRepository{
+		pendingReservations: []model.Reservation{
+			{ID: 1, UserID: "user1", PetID: "pet1", ReservationDateTime: now, Status: "pending"},
+		},
+	}
+
+	// ペットを指定して中断した実行
+	interrupted := newTestReservationBatchService(mockReservationRepo)
+	interrupted.SetFilter(model.ReservationFilter{PetIDs: []string{"pet1"}, Limit: 10})
+	if err := checkpointRepo.Save(ctx, &model.Checkpoint{
+		RunID:   "run-1",
+		JobName: reservationJobName,
+		LastKey: model.ReservationCursor{DateTime: now, ID: 1}.String(),
+		Scope:   interrupted.checkpointScope(),
+		Outcome: model.CheckpointOutcomeInterrupted,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		filter  model.ReservationFilter
+		wantErr bool
+	}{
+		{name: "ステータスが異なる", filter: model.ReservationFilter{Statuses: []model.ReservationStatus{model.ReservationStatusFailed}, PetIDs: []string{"pet1"}}, wantErr: true},
+		{name: "絞り込みの条件が異なる", filter: model.ReservationFilter{PetIDs: []string{"pet2"}}, wantErr: true},
+		{name: "絞り込みの条件がない", filter: model.ReservationFilter{}, wantErr: true},
+		{name: "最大件数のみ異なる", filter: model.ReservationFilter{Statuses: []model.ReservationStatus{model.ReservationStatusPending}, PetIDs: []string{"pet1"}, Limit: 5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := newTestReservationBatchService(mockReservationRepo)
+			service.checkpointRepo = checkpointRepo
+			service.cfg.Run.ID = "run-1"
+			service.cfg.Run.Resume = true
+			service.SetFilter(tt.filter)
+			err := service.Run(ctx)
+			if tt.wantErr {
+				if !apperrors.Is(err, apperrors.CodeInvalidInput) {
+					t.Errorf("Run() error = %v, want %s", err, apperrors.CodeInvalidInput)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("Run() error = %v, want nil", err)
+			}
+		})
+	}
+}
+
+func TestReservationBatchService_Run_RedeliversEventsAfterTaskSuccessFailure(t *testing.T) {
+	ctx, seg := xray.BeginSegment(context.Background(), "TestReservationBatchService_Run_RedeliversEventsAfterTaskSuccessFailure")
+	defer seg.Close(nil)
+
+	now := time.Now().UTC().Add(time.Hour)
+	mockReservationRepo := &MockReservationRepository{
+		pendingReservations: []model.Reservation{
+			{ID: 1, UserID: "user1", PetID: "pet1", ReservationDateTime: now, Status: "pending"},
+		},
+		existingPetIDs: map[string]bool{},
+	}
+	eventRepo := &MockReservationEventRepository{}
+	sfnClient := &mockSFNClient{sendTaskSuccessError: errors.New("service unavailable")}
+
+	// 1回目: ステータスは更新されたがタスク成功の通知に失敗する
+	service := newTestReservationBatchService(mockReservationRepo)
+	service.eventRepo = eventRepo
+	service.sfnClient = sfnClient
+	service.cfg.SFN.TaskToken = "test-task-token"
+	if err := service.Run(ctx); !apperrors.Is(err, apperrors.CodeExternalServiceError) {
+		t.Fatalf("first Run() error = %v, want %s", err, apperrors.CodeExternalServiceError)
+	}
+	if eventRepo.undelivered() != 1 {
+		t.Fatalf("undelivered events = %d, want 1", eventRepo.undelivered())
+	}
+
+	// 2回目: 処理対象の予約がなくても、アウトボックスに残ったイベントを配信する
+	mockReservationRepo.pendingReservations = nil
+	sfnClient.sendTaskSuccessError = nil
+	service = newTestReservationBatchService(mockReservationRepo)
+	service.eventRepo = eventRepo
+	service.sfnClient = sfnClient
+	service.cfg.SFN.TaskToken = "test-task-token"
+	if err := service.Run(ctx); err != nil {
+		t.Fatalf("second Run() error = %v, want nil", err)
+	}
+	if eventRepo.undelivered() != 0 {
+		t.Errorf("undelivered events = %d, want 0", eventRepo.undelivered())
+	}
+	var output struct {
+		Notifications []model.Notification `json:"notifications"`
+	}
+	if err := json.Unmarshal([]byte(sfnClient.outputs[0]), &output); err != nil {
+		t.Fatalf("failed to parse output: %v", err)
+	}
+	if len(output.Notifications) != 1 {
+		t.Errorf("notifications = %d, want 1", len(output.Notifications))
+	}
+}
+
+func TestReservationBatchService_sendTaskSuccess_SendsUnredactedOutput(t *testing.T) {
+	ctx, seg := xray.BeginSegment(context.Background(), "TestReservationBatchService_sendTaskSuccess_SendsUnredactedOutput")
+	defer seg.Close(nil)
+	t.Setenv("ENV", "")
+
+	// マスクの対象になるフィールド名や64文字以上の値を含んでも、後続のステートにはそのまま渡す
+	taskOutput := map[string]any{
+		"run_id":    "run-1",
+		"delivered": int64(1234567890123456789),
+		"notifications": []map[string]any{
+			{"data": map[string]any{"pet_id": strings.Repeat("a", 64), "user_name": "山田太郎"}},
+		},
+	}
+	sfnClient := &mockSFNClient{}
+	service := newTestReservationBatchService(&MockReservationRepository{})
+	service.sfnClient = sfnClient
+	service.cfg.SFN.TaskToken = "test-task-token"
+	if err := service.sendTaskSuccess(ctx, taskOutput); err != nil {
+		t.Fatalf("sendTaskSuccess() error = %v", err)
+	}
+
+	want, _ := json.Marshal(taskOutput)
+	if len(sfnClient.outputs) != 1 || sfnClient.outputs[0] != string(want) {
+		t.Errorf("outputs = %v, want %s", sfnClient.outputs, want)
+	}
+}
@@ -0,0 +1,201 @@
+package batch
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aws/aws-xray-sdk-go/xray"
+	"github.com/horsewin/echo-playground-batch-task/internal/common/apperrors"
+	"github.com/horsewin/echo-playground-batch-task/internal/common/config"
+	"github.com/horsewin/echo-playground-batch-task/internal/common/database"
+	"github.com/horsewin/echo-playground-batch-task/internal/common/job"
+	"github.com/horsewin/echo-playground-batch-task/internal/model"
+	"github.com/horsewin/echo-playground-batch-task/internal/repository"
+)
+
+// RetentionBatchService は保持期間を過ぎた通知をアーカイブ・削除するバッチ処理を担当します
+type RetentionBatchService struct {
+	db            *database.DB
+	retentionRepo repository.NotificationRetentionRepository
+	// now は保持期間を判定する現在時刻です
+	now func() time.Time
+	cfg *config.Config
+}
+
+// NewRetentionBatchService は新しいRetentionBatchServiceを作成します
+func NewRetentionBatchService(cfg *config.Config) (*RetentionBatchService, error) {
+	db, err := database.NewDB(cfg.DB)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create database connection: %w", err)
+	}
+
+	// database.DBをrepository.DBに変換
+	repoDb := &repository.DB{DB: db.DB}
+
+	return &RetentionBatchService{
+		db:            db,
+		retentionRepo: repository.NewNotificationRetentionRepository(repoDb),
+		now:           time.Now,
+		cfg:           cfg,
+	}, nil
+}
+
+// Close は終了処理を行います
+func (s *RetentionBatchService) Close() error {
+	if s.db != nil {
+		return s.db.Close()
+	}
+	return nil
+}
+
+// RetentionResult は通知の保持期間バッチの処理結果です
+type RetentionResult struct {
+	// ReadDeleted は削除した既読の通知の件数です
+	ReadDeleted int64 `json:"read_deleted"`
+	// UnreadDeleted は削除した未読の通知の件数です
+	UnreadDeleted int64 `json:"unread_deleted"`
+	// Archived は削除する前にアーカイブした通知の件数です
+	Archived int `json:"archived"`
+	// ArchiveFile はアーカイブしたファイルです。アーカイブしなかった場合は空です
+	ArchiveFile string `json:"archive_file,omitempty"`
+}
+
+// Run は通知の保持期間バッチ処理を実行します
+// 既読・未読それぞれの保持期間を過ぎた通知を、長時間ロックしないようBatchSize件ずつ削除します
+// ArchiveDirが設定されている場合は、削除する前にgzip圧縮したJSONLファイルにアーカイブします
+func (s *RetentionBatchService) Run(ctx context.Context) error {
+	// X-Rayセグメントの作成
+	ctx, seg := xray.BeginSubsegment(ctx, "RetentionBatchService.Run")
+	defer seg.Close(nil)
+
+	if s.cfg.Retention.BatchSize <= 0 {
+		err := apperrors.InvalidInput("RetentionBatchService.Run",
+			fmt.Errorf("invalid retention batch size: %d", s.cfg.Retention.BatchSize))
+		seg.Close(err)
+		return err
+	}
+
+	now := s.now()
+	startTime := time.Now()
+	log.Printf("Starting notification retention batch process (read: %v, unread: %v)",
+		s.cfg.Retention.ReadMaxAge, s.cfg.Retention.UnreadMaxAge)
+
+	result := &RetentionResult{}
+	var archive *notificationArchive
+	if s.cfg.Retention.ArchiveDir != "" {
+		archive = newNotificationArchive(s.cfg.Retention.ArchiveDir, s.cfg.Run.ID)
+		result.ArchiveFile = archive.path
+	}
+
+	var err error
+	if maxAge := s.cfg.Retention.ReadMaxAge; maxAge > 0 {
+		result.ReadDeleted, err = s.purge(ctx, true, now.Add(-maxAge), archive, result)
+	}
+	if maxAge := s.cfg.Retention.UnreadMaxAge; err == nil && maxAge > 0 {
+		result.UnreadDeleted, err = s.purge(ctx, false, now.Add(-maxAge), archive, result)
+	}
+
+	if metaErr := seg.AddMetadata("retention_result", result); metaErr != nil {
+		log.Printf("Failed to add retention_result metadata: %v", metaErr)
+	}
+	log.Printf("Notification retention batch process completed. Read deleted: %d, Unread deleted: %d, Archived: %d, Duration: %v",
+		result.ReadDeleted, result.UnreadDeleted, result.Archived, time.Since(startTime))
+
+	if err != nil {
+		seg.Close(err)
+		return err
+	}
+	return nil
+}
+
+// purge は既読状態がisReadで作成日時がbeforeより前の通知を、なくなるまでBatchSize件ずつ削除し、削除した件数を返します
+// アーカイブに失敗した通知は削除しません
+func (s *RetentionBatchService) purge(ctx context.Context, isRead bool, before time.Time, archive *notificationArchive, result *RetentionResult) (int64, error) {
+	var deleted int64
+	for {
+		// 停止要求を受けた場合は新しいバッチの削除を始めない
+		// 削除しなかった通知は次回の実行で削除される
+		if job.Stopping(ctx) {
+			log.Printf("Stop requested. Leaving remaining notifications (is_read: %t) undeleted", isRead)
+			return deleted, nil
+		}
+
+		records, err := s.retentionRepo.GetExpired(ctx, isRead, before, s.cfg.Retention.BatchSize)
+		if err != nil {
+			return deleted, apperrors.FromDB("RetentionBatchService.purge", err)
+		}
+		if len(records) == 0 {
+			return deleted, nil
+		}
+
+		if archive != nil {
+			if err := archive.write(records); err != nil {
+				return deleted, apperrors.Internal("RetentionBatchService.purge", err)
+			}
+			result.Archived += len(records)
+		}
+
+		ids := make([]int, len(records))
+		for i, record := range records {
+			ids[i] = record.ID
+		}
+		var n int64
+		err = s.cfg.Retry.Do(ctx, "RetentionBatchService.delete", func(ctx context.Context) error {
+			var err error
+			n, err = s.retentionRepo.Delete(ctx, ids)
+			return err
+		})
+		if err != nil {
+			return deleted, apperrors.FromDB("RetentionBatchService.purge", err)
+		}
+		deleted += n
+
+		if len(records) < s.cfg.Retention.BatchSize {
+			return deleted, nil
+		}
+	}
+}
+
+// notificationArchive は削除する通知をgzip圧縮したJSONLファイルに追記します
+// バッチごとにgzipのメンバーを閉じて書き込むため、途中で失敗してもそれまでのバッチは読み込めます
+type notificationArchive struct {
+	path string
+}
+
+// newNotificationArchive は実行IDごとのアーカイブファイルを作成します
+func newNotificationArchive(dir, runID string) *notificationArchive {
+	return &notificationArchive{path: filepath.Join(dir, fmt.Sprintf("notifications-%s.jsonl.gz", runID))}
+}
+
+// write は通知を1行1件でアーカイブファイルに追記し、ディスクに書き込みます
+func (a *notificationArchive) write(records []model.NotificationRecord) error {
+	if err := os.MkdirAll(filepath.Dir(a.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create archive directory: %w", err)
+	}
+	f, err := os.OpenFile(a.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open archive file: %w", err)
+	}
+	defer f.Close()
+
+	gz := gzip.NewWriter(f)
+	enc := json.NewEncoder(gz)
+	for _, record := range records {
+		if err := enc.Encode(record); err != nil {
+			return fmt.Errorf("failed to write archive file: %w", err)
+		}
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to write archive file: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("failed to sync archive file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package batch
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-xray-sdk-go/xray"
+	"github.com/horsewin/echo-playground-batch-task/internal/common/config"
+	"github.com/horsewin/echo-playground-batch-task/internal/model"
+)
+
+// MockNotificationRetentionRepository はテスト用のモックリポジトリです
+type MockNotificationRetentionRepository struct {
+	notifications []model.NotificationRecord
+	// deleteCalls はDeleteに渡された通知IDです
+	deleteCalls [][]int
+}
+
+func (m *MockNotificationRetentionRepository) GetExpired(ctx context.Context, isRead bool, before time.Time, limit int) ([]model.NotificationRecord, error) {
+	var records []model.NotificationRecord
+	for _, n := range m.notifications {
+		if n.IsRead == isRead && n.CreatedAt.Before(before) && len(records) < limit {
+			records = append(records, n)
+		}
+	}
+	return records, nil
+}
+
+func (m *MockNotificationRetentionRepository) Delete(ctx context.Context, ids []int) (int64, error) {
+	m.deleteCalls = append(m.deleteCalls, ids)
+	before := len(m.notifications)
+	m.notifications = slices.DeleteFunc(m.notifications, func(n model.NotificationRecord) bool {
+		return slices.Contains(ids, n.ID)
+	})
+	return int64(before - len(m.notifications)), nil
+}
+
+// newTestRetentionBatchService はテスト用のRetentionBatchServiceを作成します
+func newTestRetentionBatchService(repo *MockNotificationRetentionRepository, now time.Time, archiveDir string) *RetentionBatchService {
+	cfg := &config.Config{}
+	cfg.Run.ID = "run-1"
+	cfg.Retention.ReadMaxAge = 30 * 24 * time.Hour
+	cfg.Retention.UnreadMaxAge = 90 * 24 * time.Hour
+	cfg.Retention.BatchSize = 2
+	cfg.Retention.ArchiveDir = archiveDir
+	return &RetentionBatchService{
+		retentionRepo: repo,
+		now:           func() time.Time { return now },
+		cfg:           cfg,
+	}
+}
+
+// readArchive はアーカイブファイルの通知を読み込みます
+func readArchive(t *testing.T, path string) []model.NotificationRecord {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open archive: %v", err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("failed to read archive: %v", err)
+	}
+	var records []model.NotificationRecord
+	scanner := bufio.NewScanner(gz)
+	for scanner.Scan() {
+		var record model.NotificationRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("failed to parse archive line: %v", err)
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("failed to read archive: %v", err)
+	}
+	return records
+}
+
+func TestRetentionBatchService_Run(t *testing.T) {
+	ctx, seg := xray.BeginSegment(context.Background(), "TestRetentionBatchService_Run")
+	defer seg.Close(nil)
+
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	daysAgo := func(days int) time.Time { return now.Add(-time.Duration(days) * 24 * time.Hour) }
+	notifications := func() []model.NotificationRecord {
+		return []model.NotificationRecord{
+			{ID: 1, UserID: "user1", IsRead: true, CreatedAt: daysAgo(40)},
+			{ID: 2, UserID: "user1", IsRead: true, CreatedAt: daysAgo(35)},
+			{ID: 3, UserID: "user1", IsRead: true, CreatedAt: daysAgo(31)},
+			{ID: 4, UserID: "user1", IsRead: true, CreatedAt: daysAgo(10)},
+			{ID: 5, UserID: "user2", IsRead: false, CreatedAt: daysAgo(100)},
+			{ID: 6, UserID: "user2", IsRead: false, CreatedAt: daysAgo(40)},
+		}
+	}
+
+	t.Run("保持期間を過ぎた通知をバッチごとに削除する", func(t *testing.T) {
+		repo := &MockNotificationRetentionRepository{notifications: notifications()}
+		service := newTestRetentionBatchService(repo, now, "")
+
+		if err := service.Run(ctx); err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+
+		var remaining []int
+		for _, n := range repo.notifications {
+			remaining = append(remaining, n.ID)
+		}
+		if !slices.Equal(remaining, []int{4, 6}) {
+			t.Errorf("remaining = %v, want [4 6]", remaining)
+		}
+		// 既読3件は2件ずつ、未読1件は1回で削除する
+		if len(repo.deleteCalls) != 3 {
+			t.Errorf("delete calls = %v, want 3 batches", repo.deleteCalls)
+		}
+	})
+
+	t.Run("削除する前にアーカイブする", func(t *testing.T) {
+		dir := t.TempDir()
+		repo := &MockNotificationRetentionRepository{notifications: notifications()}
+		service := newTestRetentionBatchService(repo, now, filepath.Join(dir, "archive"))
+
+		if err := service.Run(ctx); err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+
+		archived := readArchive(t, filepath.Join(dir, "archive", "notifications-run-1.jsonl.gz"))
+		var ids []int
+		for _, n := range archived {
+			ids = append(ids, n.ID)
+		}
+		if !slices.Equal(ids, []int{1, 2, 3, 5}) {
+			t.Errorf("archived = %v, want [1 2 3 5]", ids)
+		}
+	})
+
+	t.Run("アーカイブに失敗した場合は削除しない", func(t *testing.T) {
+		// アーカイブ先のディレクトリと同じパスにファイルを作成して書き込めなくする
+		dir := t.TempDir()
+		archiveDir := filepath.Join(dir, "archive")
+		if err := os.WriteFile(archiveDir, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		repo := &MockNotificationRetentionRepository{notifications: notifications()}
+		service := newTestRetentionBatchService(repo, now, archiveDir)
+
+		if err := service.Run(ctx); err == nil {
+			t.Fatal("Run() error = nil, want error")
+		}
+		if len(repo.deleteCalls) != 0 || len(repo.notifications) != 6 {
+			t.Errorf("notifications were deleted: %v", repo.deleteCalls)
+		}
+	})
+
+	t.Run("保持期間が0の場合は削除しない", func(t *testing.T) {
+		repo := &MockNotificationRetentionRepository{notifications: notifications()}
+		service := newTestRetentionBatchService(repo, now, "")
+		service.cfg.Retention.UnreadMaxAge = 0
+
+		if err := service.Run(ctx); err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+		for _, n := range repo.notifications {
+			if n.IsRead && n.ID != 4 {
+				t.Errorf("read notification %d was not deleted", n.ID)
+			}
+		}
+		if !slices.ContainsFunc(repo.notifications, func(n model.NotificationRecord) bool { return n.ID == 5 }) {
+			t.Error("unread notification 5 should not be deleted")
+		}
+	})
+}
+
+func TestNotificationArchive_AppendsMembers(t *testing.T) {
+	archive := newNotificationArchive(t.TempDir(), "run-1")
+	for _, id := range []int{1, 2} {
+		if err := archive.write([]model.NotificationRecord{{ID: id}}); err != nil {
+			t.Fatalf("write() error = %v", err)
+		}
+	}
+	// バッチごとのgzipのメンバーを続けて読み込める
+	if got := readArchive(t, archive.path); len(got) != 2 {
+		t.Errorf("archived records = %d, want 2", len(got))
+	}
+}
@@ -0,0 +1,73 @@
+package batch
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/aws/aws-xray-sdk-go/xray"
+	"github.com/horsewin/echo-playground-batch-task/internal/common/apperrors"
+	"github.com/horsewin/echo-playground-batch-task/internal/common/job"
+	"github.com/horsewin/echo-playground-batch-task/internal/model"
+)
+
+// promoteWaitlisted は確定済みの予約がなくなったペットのキャンセル待ちの予約を、作成日時の順に繰り上げて確定します
+// 繰り上げる予約もルールで評価し、ユーザーの上限などで確定できない場合はキャンセル待ちのままにするか、キャンセルします
+// ペットごとに1件ずつ繰り上げるため、確定した予約がなくなるまで候補の取得を繰り返します
+func (s *ReservationBatchService) promoteWaitlisted(ctx context.Context, now time.Time) (int, error) {
+	ctx, seg := xray.BeginSubsegment(ctx, "ReservationBatchService.promoteWaitlisted")
+	defer seg.Close(nil)
+
+	promoted := 0
+	for {
+		candidates, err := s.reservationRepo.GetPromotableWaitlisted(ctx, now)
+		if err != nil {
+			seg.Close(err)
+			return promoted, apperrors.FromDB("ReservationBatchService.promoteWaitlisted", err)
+		}
+
+		changed := false
+		for _, reservation := range candidates {
+			// 繰り上げなかった予約はキャンセル待ちのまま次の実行で再び候補になるため、停止要求を受けたら中断する
+			if job.Stopping(ctx) {
+				log.Printf("Stop requested. Leaving waitlisted reservations for the next run")
+				return promoted, nil
+			}
+
+			var event *model.ReservationEvent
+			err := s.cfg.Retry.Do(ctx, "ReservationBatchService.promoteReservation", func(ctx context.Context) error {
+				var err error
+				event, err = s.processReservation(ctx, reservation, now)
+				return err
+			})
+			switch {
+			// 他の処理が先にステータスを変更した予約は繰り上げない
+			case errors.Is(err, model.ErrStatusConflict):
+				log.Printf("Skipping promotion of reservation %d: status was changed by another process", reservation.ID)
+			// 繰り上げに失敗した予約はキャンセル待ちのまま、次の実行で再び繰り上げる
+			case err != nil:
+				log.Printf("Failed to promote waitlisted reservation %d: %v", reservation.ID, err)
+			case event == nil:
+				// ルールによりキャンセル待ちのままとした
+			default:
+				changed = true
+				if event.Promoted {
+					promoted++
+				}
+			}
+		}
+		// ステータスを変更した予約がなければ候補は変わらないため終了する
+		if !changed {
+			break
+		}
+	}
+
+	if promoted > 0 {
+		log.Printf("Promoted %d waitlisted reservations", promoted)
+	}
+	if err := seg.AddMetadata("promoted", promoted); err != nil {
+		log.Printf("Failed to add promoted metadata: %v", err)
+	}
+	return promoted, nil
+}
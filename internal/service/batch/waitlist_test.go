@@ -0,0 +1,129 @@
+package batch
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-xray-sdk-go/xray"
+	"github.com/horsewin/echo-playground-batch-task/internal/model"
+	"github.com/horsewin/echo-playground-batch-task/internal/repository"
+)
+
+// rejectReservationsRule は指定された予約IDの予約だけを拒否するテスト用のルールです
+type rejectReservationsRule map[int64]bool
+
+func (rejectReservationsRule) Name() string { return "reject_reservations" }
+
+func (r rejectReservationsRule) Evaluate(ctx context.Context, repo repository.ReservationRuleRepository, reservation model.Reservation) (Decision, error) {
+	if r[reservation.ID] {
+		return Reject("test_rejected"), nil
+	}
+	return Accept(), nil
+}
+
+func TestReservationBatchService_Run_Waitlist(t *testing.T) {
+	ctx, seg := xray.BeginSegment(context.Background(), "TestReservationBatchService_Run_Waitlist")
+	defer seg.Close(nil)
+	t.Setenv("ENV", "LOCAL")
+
+	now := time.Now().UTC()
+	slot := now.Add(24 * time.Hour)
+
+	tests := []struct {
+		name         string
+		status       model.ReservationStatus
+		reservations []model.Reservation
+		existing     map[string]bool
+		// want は予約IDごとの変更後のステータスです
+		want         map[int64]string
+		wantPromoted []int64
+	}{
+		{
+			name: "空いたペットの最も早いキャンセル待ちを保留中の予約より先に繰り上げる",
+			reservations: []model.Reservation{
+				{ID: 1, UserID: "user1", PetID: "pet1", ReservationDateTime: slot, CreatedAt: now.Add(-2 * time.Hour), Status: "waitlisted"},
+				{ID: 2, UserID: "user2", PetID: "pet1", ReservationDateTime: slot, CreatedAt: now.Add(-3 * time.Hour), Status: "waitlisted"},
+				{ID: 3, UserID: "user3", PetID: "pet2", ReservationDateTime: slot, CreatedAt: now.Add(-time.Hour), Status: "waitlisted"},
+				{ID: 4, UserID: "user4", PetID: "pet3", ReservationDateTime: slot, Status: "pending"},
+			},
+			// pet2はまだ確定済みの予約がある
+			existing:     map[string]bool{"pet2": true},
+			want:         map[int64]string{2: "confirmed", 4: "confirmed"},
+			wantPromoted: []int64{2},
+		},
+		{
+			name: "予約日時を過ぎたキャンセル待ちは繰り上げない",
+			reservations: []model.Reservation{
+				{ID: 1, UserID: "user1", PetID: "pet1", ReservationDateTime: now.Add(-time.Hour), Status: "waitlisted"},
+			},
+			want: map[int64]string{},
+		},
+		{
+			name:   "確定済みの予約の再評価でキャンセルした後に繰り上げる",
+			status: model.ReservationStatusConfirmed,
+			reservations: []model.Reservation{
+				{ID: 10, UserID: "user1", PetID: "pet1", ReservationDateTime: slot, Status: "confirmed"},
+				{ID: 2, UserID: "user2", PetID: "pet1", ReservationDateTime: slot, Status: "waitlisted"},
+			},
+			want:         map[int64]string{10: "cancelled", 2: "confirmed"},
+			wantPromoted: []int64{2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := &MockReservationRepository{
+				pendingReservations: tt.reservations,
+				existingPetIDs:      tt.existing,
+			}
+			service := newTestReservationBatchService(mock)
+			eventRepo := service.eventRepo.(*MockReservationEventRepository)
+			if tt.status != "" {
+				service.SetFilter(model.ReservationFilter{Statuses: []model.ReservationStatus{tt.status}})
+			}
+			// 確定済みの予約10だけを再評価でキャンセルする
+			service.rules = newRuleChain(append(service.rules.rules, rejectReservationsRule{10: true}))
+
+			if err := service.Run(ctx); err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+
+			if len(mock.updatedStatuses) != len(tt.want) {
+				t.Errorf("updated statuses = %v, want %v", mock.updatedStatuses, tt.want)
+			}
+			for id, want := range tt.want {
+				if got := mock.updatedStatuses[id]; got != want {
+					t.Errorf("reservation %d status = %q, want %q", id, got, want)
+				}
+			}
+
+			var promoted []int64
+			for _, e := range eventRepo.events {
+				if e.EventType != model.ReservationEventPromoted {
+					continue
+				}
+				event, err := e.ReservationEvent()
+				if err != nil || !event.Promoted {
+					t.Errorf("promoted event payload = %+v, err = %v", event, err)
+				}
+				promoted = append(promoted, e.ReservationID)
+			}
+			if len(promoted) != len(tt.wantPromoted) {
+				t.Fatalf("promoted = %v, want %v", promoted, tt.wantPromoted)
+			}
+			for i, id := range tt.wantPromoted {
+				if promoted[i] != id {
+					t.Errorf("promoted = %v, want %v", promoted, tt.wantPromoted)
+				}
+			}
+			for id := range tt.want {
+				history, _ := mock.GetStatusHistory(ctx, id)
+				if len(history) == 1 && history[0].FromStatus == model.ReservationStatusWaitlisted &&
+					history[0].Reason != model.StatusReasonPromotedFromWaitlist {
+					t.Errorf("reservation %d reason = %q, want %q", id, history[0].Reason, model.StatusReasonPromotedFromWaitlist)
+				}
+			}
+		})
+	}
+}